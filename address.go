@@ -2,8 +2,13 @@ package email
 
 import (
 	"errors"
+	"net/mail"
+	"strings"
 )
 
+// addressParser parses the addresses, decoding the encoded-words.
+var addressParser = &mail.AddressParser{WordDecoder: wordDecoder}
+
 // Address represents a human-friendly email address: a name plus the actual address.
 type Address struct {
 	Name string
@@ -18,10 +23,41 @@ func NewAddress(name, addr string) (*Address, error) {
 	return &Address{name, addr}, nil
 }
 
+// ParseAddress parses a single RFC 5322 address, such as "Ann Smith <ann@example.com>" - with the
+// display name possibly quoted, as in "\"Smith, Ann\" <ann@example.com>", given as a comment, as in
+// "ann@example.com (Ann Smith)", or made of RFC 2047 encoded-words.
+func ParseAddress(s string) (*Address, error) {
+	a, err := addressParser.Parse(s)
+	if err != nil {
+		return nil, errors.New("email: invalid address: " + s + ": " + err.Error())
+	}
+	return &Address{a.Name, a.Address}, nil
+}
+
+// ParseAddressList parses a comma-separated list of RFC 5322 addresses, such as
+// "Ann <ann@example.com>, bob@example.com", as with ParseAddress; the members of groups, such as
+// "Team: ann@example.com, bob@example.com;", are included in the list. An empty or blank `s` is
+// an empty list.
+func ParseAddressList(s string) ([]*Address, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	list, err := addressParser.ParseList(s)
+	if err != nil {
+		return nil, errors.New("email: invalid address list: " + s + ": " + err.Error())
+	}
+	addrs := make([]*Address, len(list))
+	for i, a := range list {
+		addrs[i] = &Address{a.Name, a.Address}
+	}
+	return addrs, nil
+}
+
 // SeemsValidAddr does a very loose check on addr, to weed out obviously invalid addresses.
 // This function only checks that addr contains one and only one '@', followed by a domain name
-// that has a TLD part.
+// that has a TLD part. Internationalized domain names are checked in their ASCII form.
 func SeemsValidAddr(addr string) bool {
+	addr = (&Address{Addr: addr}).ASCII()
 	var seenAt, seenDom, seenDot, seenTld bool
 
 	for _, char := range addr {
@@ -50,6 +86,16 @@ func SeemsValidAddr(addr string) bool {
 	return seenTld
 }
 
+// String returns the RFC 5322 form of the receiver, such as "Ann Smith <ann@example.com>", as net/mail
+// does: the name is quoted if needed, or encoded as RFC 2047 encoded-words if it is not ASCII. It is
+// suitable for logging and display, and for parsing back with ParseAddress.
+func (a *Address) String() string {
+	if a == nil {
+		return ""
+	}
+	return (&mail.Address{Name: a.Name, Address: a.Addr}).String()
+}
+
 // Clone creates a new Address with the same contents as the receiver.
 func (a *Address) Clone() *Address {
 	if a == nil {
@@ -58,18 +104,21 @@ func (a *Address) Clone() *Address {
 	return &Address{a.Name, a.Addr}
 }
 
-// Domain extracts the domain portion of the email address in the receiver.
+// Domain extracts the domain portion of the email address in the receiver, in ASCII form - see
+// ASCII.
 func (a *Address) Domain() string {
-	for i := len(a.Addr) - 1; i > -1; i-- {
-		if a.Addr[i] == '@' {
-			return a.Addr[i+1:]
+	addr := a.ASCII()
+	for i := len(addr) - 1; i > -1; i-- {
+		if addr[i] == '@' {
+			return addr[i+1:]
 		}
 	}
 	return ""
 }
 
 func (a *Address) encode(offset int) (dst []byte, pos int) {
-	la := len(a.Addr)
+	addr := a.ASCII()
+	la := len(addr)
 	if ln := len(a.Name); ln > 0 {
 		nq, safe := 0, true
 		for i := 0; i < ln && safe; i++ {
@@ -120,7 +169,7 @@ func (a *Address) encode(offset int) (dst []byte, pos int) {
 		}
 	}
 	dst = append(dst, '<')
-	dst = append(dst, []byte(a.Addr)...)
+	dst = append(dst, addr...)
 	dst = append(dst, '>')
 	offset += la + 2
 	return dst, offset
@@ -0,0 +1,73 @@
+package email
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_ParseAddressList(t *testing.T) {
+	cases := []struct {
+		src string
+		exp []Address
+		err bool
+	}{
+		{"", nil, false},
+		{"ann@example.com", []Address{{"", "ann@example.com"}}, false},
+		{"Ann Smith <ann@example.com>, <bob@example.com>",
+			[]Address{{"Ann Smith", "ann@example.com"}, {"", "bob@example.com"}}, false},
+		{`"Smith, Ann" <ann@example.com>`, []Address{{"Smith, Ann", "ann@example.com"}}, false},
+		{"ann@example.com (Ann Smith)", []Address{{"Ann Smith", "ann@example.com"}}, false},
+		{"=?utf-8?q?Ren=C3=A9?= <rene@example.com>", []Address{{"René", "rene@example.com"}}, false},
+		{"Team: ann@example.com, bob@example.com;", []Address{{"", "ann@example.com"}, {"", "bob@example.com"}}, false},
+		{"Ann <ann@example.com", nil, true},
+		{"ann, bob", nil, true},
+	}
+	for i, c := range cases {
+		act, err := ParseAddressList(c.src)
+		if (err != nil) != c.err || len(act) != len(c.exp) {
+			t.Errorf("ParseAddressList [%d]: got %v, %v want %v", i, act, err, c.exp)
+			continue
+		}
+		for j, a := range act {
+			if *a != c.exp[j] {
+				t.Errorf("ParseAddressList [%d]: got %v want %v", i, *a, c.exp[j])
+			}
+		}
+	}
+
+	if a, err := ParseAddress(`"Ann \"A\" Smith" <ann@example.com>`); err != nil || *a != (Address{`Ann "A" Smith`, "ann@example.com"}) {
+		t.Errorf("ParseAddress: got %v, %v", a, err)
+	}
+	if _, err := ParseAddress("ann@example.com, bob@example.com"); err == nil {
+		t.Errorf("ParseAddress: got no error for a list")
+	}
+}
+
+func Test_AddressString(t *testing.T) {
+	cases := []struct {
+		addr *Address
+		exp  string
+	}{
+		{&Address{"", "ann@example.com"}, "<ann@example.com>"},
+		{&Address{"Ann Smith", "ann@example.com"}, "\"Ann Smith\" <ann@example.com>"},
+		{&Address{"Smith, Ann", "ann@example.com"}, "\"Smith, Ann\" <ann@example.com>"},
+		{&Address{`Ann "The Boss"`, "ann@example.com"}, "\"Ann \\\"The Boss\\\"\" <ann@example.com>"},
+		{&Address{"Zoë", "zoe@example.com"}, "=?utf-8?q?Zo=C3=AB?= <zoe@example.com>"},
+		{nil, ""},
+	}
+	for i, c := range cases {
+		act := c.addr.String()
+		if act != c.exp {
+			t.Errorf("(*Address).String [%d]: got %q want %q", i, act, c.exp)
+		}
+		if c.addr == nil {
+			continue
+		}
+		if back, err := ParseAddress(act); err != nil || *back != *c.addr {
+			t.Errorf("(*Address).String [%d]: got %v, %v parsing back %q", i, back, err, act)
+		}
+	}
+	if act := fmt.Sprint(&Address{"Ann", "ann@example.com"}); act != "\"Ann\" <ann@example.com>" {
+		t.Errorf("(*Address).String: got %q from fmt", act)
+	}
+}
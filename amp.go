@@ -0,0 +1,88 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	htpl "html/template"
+)
+
+// Amp sets the AMP for Email version of the message body - an interactive HTML version rendered by
+// the supporting mail clients, such as Gmail - to the provided content: a string, a []byte or an
+// html/template Template. The content must be a valid AMP document, e.g. starting with
+// `<!doctype html><html ⚡4email>`.
+//
+// The AMP part is placed between the plain-text and the HTML versions in the multipart/alternative
+// body, as required by the clients, and the message must have an HTML version as a fallback.
+func (m *Message) Amp(amp interface{}) *Message {
+	m.Lock()
+	defer m.Unlock()
+	p := part{
+		ctype: "text/x-amp-html; charset=utf-8",
+		cte:   QuotedPrintable,
+	}
+	switch amp := amp.(type) {
+	case string:
+		p.bytes = []byte(amp)
+	case []byte:
+		p.bytes = amp
+	case *htpl.Template:
+		p.htmlTpl, p.source = amp, htmlSource(amp)
+		if m.strict {
+			p.htmlTpl = m.strictHTML(amp, -1)
+		}
+	default:
+		m.fail(ArgumentError, "amp", ErrInvalidArgument)
+		return m
+	}
+	if p.htmlTpl == nil && !isAmpDocument(p.bytes) {
+		m.fail(ContentError, "amp", errors.New("invalid AMP document: missing the ⚡4email or amp4email attribute"))
+		return m
+	}
+	m.setAmp(p)
+	return m
+}
+
+// AmpTemplate sets the AMP for Email version of the message body to the provided template.
+func (m *Message) AmpTemplate(tpl string) *Message {
+	t, err := htpl.New("").Parse(tpl)
+	if err != nil {
+		m.Lock()
+		m.fail(TemplateError, "amp", &ErrTemplateParse{"amp", tpl, err})
+		m.Unlock()
+		return m
+	}
+	m.Lock()
+	defer m.Unlock()
+	if m.strict {
+		t.Option(m.missingKey())
+	}
+	m.setAmp(part{
+		ctype:   "text/x-amp-html; charset=utf-8",
+		cte:     QuotedPrintable,
+		htmlTpl: t,
+		source:  tplSource{src: tpl},
+	})
+	return m
+}
+
+// setAmp sets the AMP part of the receiver to `p`. The caller must hold the lock on the receiver.
+func (m *Message) setAmp(p part) {
+	if m.amp == nil {
+		m.amp = &part{}
+		m.parts = append(m.parts, m.amp)
+	}
+	*(m.amp) = p
+}
+
+// isAmpDocument reports whether `doc` seems to be an AMP for Email document.
+func isAmpDocument(doc []byte) bool {
+	i := bytes.Index(bytes.ToLower(doc), []byte("<html"))
+	if i < 0 {
+		return false
+	}
+	tag := doc[i:]
+	if j := bytes.IndexByte(tag, '>'); j >= 0 {
+		tag = tag[:j]
+	}
+	return bytes.Contains(tag, []byte("⚡4email")) || bytes.Contains(bytes.ToLower(tag), []byte("amp4email"))
+}
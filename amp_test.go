@@ -0,0 +1,48 @@
+package email
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_Amp(t *testing.T) {
+	msg := QuickMessage("Poll").From(&Address{"", "test@example.com"}).
+		Html("<p>Vote</p>").AmpTemplate(`<!doctype html><html ⚡4email><body>Vote, {{.}}</body></html>`).Text("Vote")
+	e, err := ReadEntity(bytes.NewReader(msg.Compose("Ann")), nil)
+	if err != nil {
+		t.Fatalf("(*Message).Amp: got error %v", err)
+	}
+	var types []string
+	for _, p := range e.Parts {
+		types = append(types, p.MediaType)
+	}
+	if act, exp := strings.Join(types, " "), "text/plain text/x-amp-html text/html"; act != exp {
+		t.Errorf("(*Message).Amp: got parts %q want %q", act, exp)
+	}
+	if amp := e.Parts[1]; !bytes.Contains(amp.Body, []byte("Vote, Ann")) {
+		t.Errorf("(*Message).Amp: got body %q", amp.Body)
+	}
+
+	cases := []struct {
+		doc string
+		exp bool
+	}{
+		{`<!doctype html><html ⚡4email data-css-strict>`, true},
+		{`<!DOCTYPE html><HTML AMP4EMAIL>`, true},
+		{`<html><body>amp4email</body></html>`, false},
+		{`<p>no document</p>`, false},
+	}
+	for i, c := range cases {
+		if act := isAmpDocument([]byte(c.doc)); act != c.exp {
+			t.Errorf("isAmpDocument [%d]: got %v want %v", i, act, c.exp)
+		}
+	}
+	if msg = NewMessage(nil).Amp("<p>not amp</p>"); len(msg.Errors()) == 0 {
+		t.Error("(*Message).Amp: expected an error for an invalid document")
+	}
+	msg = QuickMessage("Poll", "text").From(&Address{"", "test@example.com"}).Amp(`<html amp4email></html>`)
+	if msg.Compose(nil); len(msg.Errors()) == 0 {
+		t.Error("(*Message).Amp: expected an error without an HTML fallback")
+	}
+}
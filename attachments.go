@@ -0,0 +1,71 @@
+package email
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// Attachment gives access to an attachment of a message, as returned by Attachments - e.g. for
+// processing the invoices or the CSV files attached to the messages read with Parse.
+type Attachment struct {
+	// Name is the file name of the attachment, decoded.
+	Name string
+	// ContentType is the content type of the attachment, e.g. "text/csv; charset=utf-8".
+	ContentType string
+	// Size is the size of the content, in bytes.
+	Size int64
+
+	data []byte
+	fsys fs.FS
+	root string
+	file string
+}
+
+// MediaType returns the lowercase media type of the attachment, without the parameters of its
+// content type - e.g. "text/csv".
+func (a *Attachment) MediaType() string {
+	if mt, _, err := mime.ParseMediaType(a.ContentType); err == nil {
+		return mt
+	}
+	return strings.ToLower(strings.TrimSpace(strings.SplitN(a.ContentType, ";", 2)[0]))
+}
+
+// Open returns a reader of the decoded content of the attachment, which must be closed after use.
+// For an attachment that was not read from its file yet, the file is opened.
+func (a *Attachment) Open() (io.ReadCloser, error) {
+	if a.file == "" {
+		return ioutil.NopCloser(bytes.NewReader(a.data)), nil
+	}
+	f, err := openFile(a.fsys, a.root, a.file)
+	if err != nil {
+		return nil, &ErrFileRead{a.file, err}
+	}
+	return f, nil
+}
+
+// Attachments returns the attachments of the receiver, in the order they were added - or found by
+// Parse. The embedded items and the items related to the body parts are not included.
+func (m *Message) Attachments() []*Attachment {
+	m.RLock()
+	defer m.RUnlock()
+	list := make([]*Attachment, len(m.attachments))
+	for i, a := range m.attachments {
+		att := &Attachment{Name: a.name, ContentType: a.ctype, Size: m.fileSize(a.data, a.fileName), data: a.data}
+		if a.data == nil && a.fileName != "" {
+			att.fsys, att.root, att.file = m.fsys, m.root, a.fileName
+			if att.Name == "" {
+				att.Name = m.sanitizeFilename(filepath.Base(a.fileName))
+			}
+			if att.ContentType == "" {
+				att.ContentType = m.detectType(a.fileName, fileHead(m.fsys, m.root, a.fileName))
+			}
+		}
+		list[i] = att
+	}
+	return list
+}
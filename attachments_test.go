@@ -0,0 +1,45 @@
+package email
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_Attachments(t *testing.T) {
+	fsys := fstest.MapFS{"report.csv": {Data: []byte("a,b\r\n1,2\r\n")}}
+	raw := QuickMessage("Invoice", "See attached").From(&Address{"", "test@example.com"}).
+		AttachObject("facture n°1.pdf", "application/pdf", []byte("%PDF-1.4")).Compose(nil)
+	msg, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("(*Message).Attachments: unexpected error: %v", err)
+	}
+	msg.FS(fsys).Attach("report.csv")
+	cases := []struct {
+		name, ctype, mediaType, content string
+	}{
+		{"facture n°1.pdf", "application/pdf", "application/pdf", "%PDF-1.4"},
+		{"report.csv", "text/csv; charset=utf-8", "text/csv", "a,b\r\n1,2\r\n"},
+	}
+	list := msg.Attachments()
+	if len(list) != len(cases) {
+		t.Fatalf("(*Message).Attachments: got %d attachments want %d", len(list), len(cases))
+	}
+	for i, c := range cases {
+		a := list[i]
+		if a.Name != c.name || a.ContentType != c.ctype || a.MediaType() != c.mediaType || a.Size != int64(len(c.content)) {
+			t.Errorf("(*Message).Attachments [%d]: got %+v", i, a)
+		}
+		r, err := a.Open()
+		if err != nil {
+			t.Errorf("(*Message).Attachments [%d]: unexpected error: %v", i, err)
+			continue
+		}
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil || string(data) != c.content {
+			t.Errorf("(*Message).Attachments [%d]: got content %q, %v want %q", i, data, err, c.content)
+		}
+	}
+}
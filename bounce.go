@@ -0,0 +1,212 @@
+package email
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+// Bounce holds the information extracted from a delivery status notification (a "bounce").
+type Bounce struct {
+	// Standard is true for RFC 3464 notifications, and false for the ones recognized heuristically.
+	Standard bool
+	// ReportingMTA is the host that generated the notification, if known.
+	ReportingMTA string
+	// OriginalMessageID is the Message-ID of the message that bounced, if known.
+	OriginalMessageID string
+	// Recipients holds an entry for each recipient reported in the notification.
+	Recipients []BounceRecipient
+}
+
+// BounceRecipient holds the delivery status of one of the recipients reported in a Bounce.
+type BounceRecipient struct {
+	// Addr is the recipient address.
+	Addr string
+	// Action is one of "failed", "delayed", "delivered", "relayed" or "expanded".
+	Action string
+	// Status is the RFC 3463 status code, e.g. "5.1.1".
+	Status string
+	// Diagnostic is the explanation provided by the remote server, if any.
+	Diagnostic string
+	// RemoteMTA is the host that reported the problem, if known.
+	RemoteMTA string
+}
+
+// Permanent reports whether the status indicates a permanent failure, which usually warrants
+// adding the address to a suppression list.
+func (r BounceRecipient) Permanent() bool {
+	return strings.HasPrefix(r.Status, "5")
+}
+
+// ErrNotBounce is returned by ParseBounce for messages that are not delivery status notifications.
+var ErrNotBounce = errors.New("email: not a delivery status notification")
+
+// ParseBounce parses a raw bounce message, extracting the failed recipients, their status codes
+// and the diagnostic texts. Besides the standard RFC 3464 notifications, it recognizes the common
+// non-standard formats, in which the information is only available in the human-readable text.
+//
+// The message is read with DefaultLimits; ErrNotBounce is returned if no bounce information
+// could be found.
+func ParseBounce(r io.Reader) (*Bounce, error) {
+	e, err := ReadEntity(r, nil)
+	if err != nil {
+		return nil, err
+	}
+	if b := standardBounce(e); b != nil {
+		return b, nil
+	}
+	if b := heuristicBounce(e); b != nil {
+		return b, nil
+	}
+	return nil, ErrNotBounce
+}
+
+// findEntity returns the first entity in the tree of `e` (in depth-first order) that `match`
+// accepts, without descending into embedded messages.
+func findEntity(e *Entity, match func(*Entity) bool) *Entity {
+	if match(e) {
+		return e
+	}
+	if e.MediaType == "message/rfc822" {
+		return nil
+	}
+	for _, p := range e.Parts {
+		if found := findEntity(p, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// typeValue strips the type prefix from a DSN field value, as in "rfc822; user@example.com".
+func typeValue(value string) string {
+	if i := strings.IndexByte(value, ';'); i >= 0 {
+		value = value[i+1:]
+	}
+	return strings.Trim(strings.TrimSpace(value), "<>")
+}
+
+func standardBounce(e *Entity) *Bounce {
+	ds := findEntity(e, func(e *Entity) bool {
+		return e.MediaType == "message/delivery-status" || e.MediaType == "message/global-delivery-status"
+	})
+	if ds == nil {
+		return nil
+	}
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(bytes.TrimLeft(ds.Body, "\r\n"))))
+	perMsg, err := tp.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil
+	}
+	b := &Bounce{Standard: true, ReportingMTA: typeValue(perMsg.Get("Reporting-MTA"))}
+	for err == nil {
+		var h textproto.MIMEHeader
+		h, err = tp.ReadMIMEHeader()
+		if len(h) == 0 {
+			continue
+		}
+		addr := typeValue(h.Get("Final-Recipient"))
+		if addr == "" {
+			addr = typeValue(h.Get("Original-Recipient"))
+		}
+		b.Recipients = append(b.Recipients, BounceRecipient{
+			Addr:       addr,
+			Action:     strings.ToLower(strings.TrimSpace(h.Get("Action"))),
+			Status:     strings.Fields(h.Get("Status") + " ")[0],
+			Diagnostic: typeValue(h.Get("Diagnostic-Code")),
+			RemoteMTA:  typeValue(h.Get("Remote-MTA")),
+		})
+	}
+	if len(b.Recipients) == 0 {
+		return nil
+	}
+	b.OriginalMessageID = originalMessageID(e)
+	return b
+}
+
+// originalMessageID extracts the Message-ID of the original message from a bounce.
+func originalMessageID(e *Entity) string {
+	orig := findEntity(e, func(e *Entity) bool {
+		return e.MediaType == "message/rfc822" || e.MediaType == "text/rfc822-headers"
+	})
+	if orig == nil {
+		return ""
+	}
+	var h textproto.MIMEHeader
+	if len(orig.Parts) > 0 {
+		h = orig.Parts[0].Header
+	} else {
+		h, _ = textproto.NewReader(bufio.NewReader(bytes.NewReader(orig.Body))).ReadMIMEHeader()
+	}
+	if ids := parseMsgIDs(h.Get("Message-Id")); len(ids) > 0 {
+		return "<" + ids[0] + ">"
+	}
+	return ""
+}
+
+var (
+	reBounceSender   = regexp.MustCompile(`(?i)mailer-daemon|postmaster|mail delivery`)
+	reBounceSubject  = regexp.MustCompile(`(?i)undeliver|delivery (status|fail|problem)|failure notice|returned mail|could not be delivered|delivery has failed`)
+	reBounceAddr     = regexp.MustCompile(`[A-Za-z0-9.!#$%&'*+/=?^_{|}~-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)+`)
+	reBounceStatus   = regexp.MustCompile(`\b([245]\.\d{1,3}\.\d{1,3})\b`)
+	reBounceCode     = regexp.MustCompile(`\b([45]\d\d)[ -]`)
+	reBounceOriginal = regexp.MustCompile(`(?i)^-+ *(original message|this is a copy|below this line)|^(return-path|received):`)
+	reBounceHeader   = regexp.MustCompile(`(?i)^(from|reply-to|sender|message-id|return-path):`)
+)
+
+// heuristicBounce recognizes the non-standard bounce formats, by looking for the recipient
+// addresses and the SMTP status codes in the text of a message sent by a mailer daemon.
+func heuristicBounce(e *Entity) *Bounce {
+	if !reBounceSender.MatchString(e.Header.Get("From")) && !reBounceSubject.MatchString(e.Header.Get("Subject")) {
+		return nil
+	}
+	text := findEntity(e, func(e *Entity) bool { return e.MediaType == "text/plain" && len(e.Body) > 0 })
+	if text == nil {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.Replace(string(text.Body), "\r\n", "\n", -1), "\n") {
+		if reBounceOriginal.MatchString(line) {
+			break
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	b := &Bounce{OriginalMessageID: originalMessageID(e)}
+	seen := map[string]bool{}
+	for i, line := range lines {
+		if reBounceHeader.MatchString(line) {
+			continue
+		}
+		for _, addr := range reBounceAddr.FindAllString(line, -1) {
+			addr = strings.TrimRight(addr, ".")
+			if seen[strings.ToLower(addr)] || reBounceSender.MatchString(addr) {
+				continue
+			}
+			seen[strings.ToLower(addr)] = true
+			rcpt := BounceRecipient{Addr: addr, Action: "failed"}
+			// look for the status in the same line or the next few ones
+			for j := i; j < len(lines) && j < i+4 && rcpt.Status == ""; j++ {
+				if m := reBounceStatus.FindStringSubmatch(lines[j]); m != nil {
+					rcpt.Status, rcpt.Diagnostic = m[1], lines[j]
+				} else if m := reBounceCode.FindStringSubmatch(lines[j]); m != nil {
+					rcpt.Status, rcpt.Diagnostic = m[1][:1]+".0.0", lines[j]
+				}
+			}
+			if rcpt.Status == "" {
+				rcpt.Status = "5.0.0"
+			}
+			if rcpt.Status[0] == '4' {
+				rcpt.Action = "delayed"
+			}
+			b.Recipients = append(b.Recipients, rcpt)
+		}
+	}
+	if len(b.Recipients) == 0 {
+		return nil
+	}
+	return b
+}
@@ -0,0 +1,66 @@
+package email
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_ParseBounce(t *testing.T) {
+	cases := []struct {
+		src string
+		exp *Bounce
+	}{
+		{
+			"From: Mail Delivery System <MAILER-DAEMON@mx.example.net>\r\n" +
+				"Subject: Undelivered Mail Returned to Sender\r\n" +
+				"Content-Type: multipart/report; report-type=delivery-status; boundary=\"B\"\r\n\r\n" +
+				"--B\r\nContent-Type: text/plain\r\n\r\nSorry, your message could not be delivered.\r\n" +
+				"--B\r\nContent-Type: message/delivery-status\r\n\r\n" +
+				"Reporting-MTA: dns; mx.example.net\r\nArrival-Date: Fri, 30 Aug 2013 09:10:11 +0000\r\n\r\n" +
+				"Final-Recipient: rfc822; nobody@example.org\r\nOriginal-Recipient: rfc822;nobody@example.org\r\n" +
+				"Action: failed\r\nStatus: 5.1.1\r\nRemote-MTA: dns; mail.example.org\r\n" +
+				"Diagnostic-Code: smtp; 550 5.1.1 <nobody@example.org>: Recipient address rejected\r\n\r\n" +
+				"Final-Recipient: rfc822; busy@example.org\r\nAction: delayed\r\nStatus: 4.2.2 (mailbox full)\r\n\r\n" +
+				"--B\r\nContent-Type: message/rfc822\r\n\r\n" +
+				"Message-ID: <orig@example.com>\r\nFrom: app@example.com\r\nSubject: Hi\r\n\r\nHi!\r\n" +
+				"--B--\r\n",
+			&Bounce{
+				Standard:          true,
+				ReportingMTA:      "mx.example.net",
+				OriginalMessageID: "<orig@example.com>",
+				Recipients: []BounceRecipient{
+					{"nobody@example.org", "failed", "5.1.1", "550 5.1.1 <nobody@example.org>: Recipient address rejected", "mail.example.org"},
+					{"busy@example.org", "delayed", "4.2.2", "", ""},
+				},
+			},
+		},
+		{
+			"From: MAILER-DAEMON@mail.example.net\r\n" +
+				"Subject: failure notice\r\n\r\n" +
+				"Hi. This is the qmail-send program at mail.example.net.\r\n" +
+				"I'm afraid I wasn't able to deliver your message to the following addresses.\r\n\r\n" +
+				"<gone@example.org>:\r\n" +
+				"192.0.2.1 does not like recipient.\r\n" +
+				"Remote host said: 550 No such user here\r\n\r\n" +
+				"--- Below this line is a copy of the message.\r\n\r\n" +
+				"Return-Path: <app@example.com>\r\nMessage-ID: <orig2@example.com>\r\nTo: gone@example.org\r\n",
+			&Bounce{
+				Recipients: []BounceRecipient{
+					{"gone@example.org", "failed", "5.0.0", "Remote host said: 550 No such user here", ""},
+				},
+			},
+		},
+	}
+	for i, c := range cases {
+		act, err := ParseBounce(strings.NewReader(c.src))
+		if err != nil {
+			t.Errorf("ParseBounce [%d]: unexpected error: %v", i, err)
+		} else if !reflect.DeepEqual(act, c.exp) {
+			t.Errorf("ParseBounce [%d]: got\n%+v\nwant\n%+v", i, act, c.exp)
+		}
+	}
+	if _, err := ParseBounce(strings.NewReader("From: a@example.com\r\nSubject: Hi\r\n\r\nHello b@example.com\r\n")); err != ErrNotBounce {
+		t.Errorf("ParseBounce: got error %v, want ErrNotBounce", err)
+	}
+}
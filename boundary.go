@@ -0,0 +1,136 @@
+package email
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// BoundaryKind identifies the multipart entity a MIME boundary is generated for.
+type BoundaryKind string
+
+const (
+	// MixedBoundary is the kind of the boundary of the multipart/mixed entity holding the body and
+	// the attachments of a message.
+	MixedBoundary BoundaryKind = "mixed"
+	// AlternativeBoundary is the kind of the boundary of the multipart/alternative entity holding
+	// the alternative versions of the body.
+	AlternativeBoundary BoundaryKind = "alternative"
+	// RelatedBoundary is the kind of the boundaries of the multipart/related entities holding an
+	// alternative part along with its related items.
+	RelatedBoundary BoundaryKind = "related"
+)
+
+// BoundaryGenerator generates the MIME boundaries of the composed messages. Implementations must be
+// safe for concurrent use.
+type BoundaryGenerator interface {
+	// Boundary returns the boundary for the multipart entity of the `kind`, in the message with
+	// the unique identifier `uid`; for the related kind, `part` is the index of the alternative
+	// part the entity holds, and it is 0 otherwise. The boundaries of a message must be distinct,
+	// and made of 1 to 70 ASCII letters, digits and the characters "'()+_,-./:=?".
+	Boundary(kind BoundaryKind, part int, uid string) string
+}
+
+// BoundaryFunc adapts a function to the BoundaryGenerator interface.
+type BoundaryFunc func(kind BoundaryKind, part int, uid string) string
+
+// Boundary returns f(kind, part, uid).
+func (f BoundaryFunc) Boundary(kind BoundaryKind, part int, uid string) string {
+	return f(kind, part, uid)
+}
+
+// DefaultBoundaries generates the boundaries from the unique identifier of the message - e.g.
+// "B_a_" followed by the identifier for the alternative entity. It is used by the messages having
+// no BoundaryGenerator set, and not sent by a Sender having one.
+var DefaultBoundaries BoundaryGenerator = BoundaryFunc(func(kind BoundaryKind, part int, uid string) string {
+	switch kind {
+	case MixedBoundary:
+		return "B_m_" + uid
+	case AlternativeBoundary:
+		return "B_a_" + uid
+	}
+	return "B_r_" + strconv.Itoa(part) + uid
+})
+
+// FixedBoundaries returns a BoundaryGenerator that does not depend on the unique identifier of the
+// message, generating the `prefix` followed by the kind and, for the related kind, the part index
+// - e.g. "golden_alternative" or "golden_related_1" - so that golden-file tests can compare whole
+// messages byte for byte, whatever their Message-ID.
+func FixedBoundaries(prefix string) BoundaryGenerator {
+	return BoundaryFunc(func(kind BoundaryKind, part int, uid string) string {
+		if kind == RelatedBoundary {
+			return prefix + "_" + string(kind) + "_" + strconv.Itoa(part)
+		}
+		return prefix + "_" + string(kind)
+	})
+}
+
+// Boundaries sets the generator of the MIME boundaries used when composing the message, overriding
+// the one of the Sender, if any; a nil `g` restores the default.
+func (m *Message) Boundaries(g BoundaryGenerator) *Message {
+	m.Lock()
+	m.boundaries = g
+	m.Unlock()
+	return m
+}
+
+// Boundaries sets the generator of the MIME boundaries used when composing the messages sent by the
+// receiver, unless they have their own.
+func (s *Sender) Boundaries(g BoundaryGenerator) *Sender {
+	s.mu.Lock()
+	s.boundaries = g
+	s.mu.Unlock()
+	return s
+}
+
+// WithBoundaries sets the generator of the MIME boundaries of the message, like
+// (*Message).Boundaries.
+func WithBoundaries(g BoundaryGenerator) ComposeOption {
+	return func(m *Message) {
+		m.boundaries = g
+	}
+}
+
+// boundary returns the boundary of the `kind` and `part` for composing the receiver, with the unique
+// identifier `uid`, sent by the `sender`, if not nil. An invalid boundary is recorded as an error.
+// The caller must hold the lock on the receiver.
+func (m *Message) boundary(sender *Sender, kind BoundaryKind, part int, uid string) string {
+	g := m.boundaries
+	if g == nil && sender != nil {
+		sender.mu.RLock()
+		g = sender.boundaries
+		sender.mu.RUnlock()
+	}
+	if g == nil {
+		g = DefaultBoundaries
+	}
+	b := g.Boundary(kind, part, uid)
+	if !validBoundary(b) {
+		m.fail(ContentError, "boundary", errors.New("invalid MIME boundary: "+b))
+	}
+	return b
+}
+
+// validBoundary checks that `b` is a valid MIME boundary, as defined by RFC 2046 - excluding the
+// space. See boundaryParam for the boundaries that need quoting.
+func validBoundary(b string) bool {
+	if b == "" || len(b) > 70 {
+		return false
+	}
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("'()+_,-./:=?", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// boundaryParam returns the value of the boundary parameter of a Content-Type header for the valid
+// boundary `b`, quoted if it contains characters that are tspecials under RFC 2045.
+func boundaryParam(b string) string {
+	if strings.ContainsAny(b, "()/,:=?") {
+		return `"` + b + `"`
+	}
+	return b
+}
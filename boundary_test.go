@@ -0,0 +1,50 @@
+package email
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func Test_Boundaries(t *testing.T) {
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	build := func() *Message {
+		return QuickMessage("test").From(&Address{"", "test@example.com"}).Date(at).
+			Text("text").Html(`<img src="cid:logo">`, RelatedObject("logo", "image/png", []byte("png"))).
+			AttachObject("a.txt", "text/plain", []byte("a")).Boundaries(FixedBoundaries("golden"))
+	}
+	a, b := build().IDs(FixedID("one")).Compose(nil), build().IDs(FixedID("two")).Compose(nil)
+	for _, exp := range []string{"boundary=golden_mixed\r\n", "boundary=golden_alternative\r\n", "boundary=golden_related_1\r\n"} {
+		if !bytes.Contains(a, []byte(exp)) {
+			t.Errorf("FixedBoundaries: got\n%s\nwant %q", a, exp)
+		}
+	}
+	if bytes.Contains(a, []byte("B_")) {
+		t.Errorf("FixedBoundaries: got default boundaries in\n%s", a)
+	}
+	e, err := ReadEntity(bytes.NewReader(b), nil)
+	if err != nil || len(e.Parts) != 2 || len(e.Parts[0].Parts) != 2 || len(e.Parts[0].Parts[1].Parts) != 2 {
+		t.Errorf("FixedBoundaries: got error %v, or an unexpected structure", err)
+	}
+
+	s := &Sender{}
+	s.Boundaries(BoundaryFunc(func(kind BoundaryKind, part int, uid string) string { return "s_" + string(kind) + uid }))
+	msg := QuickMessage("test").Sender(s).From(&Address{"", "test@example.com"}).IDs(FixedID("id")).Html("<p>hi</p>")
+	if body := msg.Compose(nil); !bytes.Contains(body, []byte("boundary=s_alternativeid\r\n")) {
+		t.Errorf("(*Sender).Boundaries: got\n%s", body)
+	}
+	for _, prefix := range []string{"a=b", "x:y", "(c)/d,e?"} {
+		body := build().Boundaries(FixedBoundaries(prefix)).Compose(nil)
+		if exp := `boundary="` + prefix + `_mixed"` + "\r\n"; !bytes.Contains(body, []byte(exp)) {
+			t.Errorf("FixedBoundaries(%q): got\n%s\nwant %q", prefix, body, exp)
+		}
+		e, err := ReadEntity(bytes.NewReader(body), nil)
+		if err != nil || len(e.Parts) != 2 || len(e.Parts[0].Parts) != 2 || len(e.Parts[0].Parts[1].Parts) != 2 {
+			t.Errorf("FixedBoundaries(%q): got error %v, or an unexpected structure", prefix, err)
+		}
+	}
+	msg.Boundaries(FixedBoundaries("not valid"))
+	if msg.Compose(nil); len(msg.Errors()) == 0 {
+		t.Error("(*Message).Boundaries: expected an error for an invalid boundary")
+	}
+}
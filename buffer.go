@@ -1,7 +1,35 @@
 package email
 
+import "sync"
+
 type buffer []byte
 
+// maxPooledBuffer is the capacity above which buffers are not returned to the pool, so that a few
+// exceptionally large messages do not keep holding a lot of memory.
+const maxPooledBuffer = 1 << 20
+
+// bufferPool holds the buffers reused across compositions, to reduce the allocations made by
+// services composing many messages.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return newBuffer(4096)
+	},
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *buffer {
+	b := bufferPool.Get().(*buffer)
+	*b = (*b)[:0]
+	return b
+}
+
+// putBuffer returns the buffer `b` to the pool; it must not be used afterwards.
+func putBuffer(b *buffer) {
+	if cap(*b) <= maxPooledBuffer {
+		bufferPool.Put(b)
+	}
+}
+
 func newBuffer(size int) *buffer {
 	b := buffer(make([]byte, 0, size))
 	return &b
@@ -25,3 +53,27 @@ func (b *buffer) Write(data ...interface{}) {
 func (b *buffer) Bytes() []byte {
 	return *b
 }
+
+func (b *buffer) Reset() {
+	*b = (*b)[:0]
+}
+
+// writeQP appends `data`, encoded as quoted-printable.
+func (b *buffer) writeQP(data []byte) {
+	*b = appendQuotedPrintable(*b, data)
+}
+
+// writeBase64 appends `data`, encoded as base64.
+func (b *buffer) writeBase64(data []byte) {
+	*b = appendBase64(*b, data)
+}
+
+// bufferWriter adapts a buffer to io.Writer.
+type bufferWriter struct {
+	b *buffer
+}
+
+func (bw bufferWriter) Write(p []byte) (int, error) {
+	*bw.b = append(*bw.b, p...)
+	return len(p), nil
+}
@@ -0,0 +1,40 @@
+package email
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_appendEncoded(t *testing.T) {
+	src := bytes.Repeat([]byte("Crème brûlée "), 50)
+	for _, prefix := range [][]byte{nil, []byte("prefix"), append(make([]byte, 0, 4096), "prefix"...)} {
+		exp := string(prefix) + string(Base64Encode(src))
+		if act := appendBase64(append([]byte(nil), prefix...), src); string(act) != exp {
+			t.Errorf("appendBase64: got %q want %q", act, exp)
+		}
+		exp = string(prefix) + string(QuotedPrintableEncode(src))
+		if act := appendQuotedPrintable(append([]byte(nil), prefix...), src); string(act) != exp {
+			t.Errorf("appendQuotedPrintable: got %q want %q", act, exp)
+		}
+	}
+}
+
+func Test_ComposePooledBuffers(t *testing.T) {
+	msg := QuickMessage("test", "first").From(&Address{"", "test@example.com"})
+	first := msg.Compose(nil)
+	exp := string(first)
+	msg.Text("second")
+	second := msg.Compose(nil)
+	if string(first) != exp || bytes.Equal(first, second) {
+		t.Errorf("(*Message).Compose: the composed messages share memory")
+	}
+}
+
+func Benchmark_Compose(b *testing.B) {
+	msg := QuickMessage("test", "Hello, {{.}}!").From(&Address{"", "test@example.com"}).
+		To(&Address{"", "to@example.com"}).Attach("test-file.txt")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		msg.Compose("world")
+	}
+}
@@ -0,0 +1,190 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BulkLimits sets the maximum number of messages sent concurrently by the bulk sending methods of
+// the receiver (SendEach, SendEachFrom and campaigns), and the maximum rate, in messages per
+// second, at which they are sent. The `concurrency` defaults to 1; a `rate` of 0 means no limit.
+func (s *Sender) BulkLimits(concurrency int, rate float64) *Sender {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	s.mu.Lock()
+	s.bulkConcurrency = concurrency
+	s.bulkRate = rate
+	s.mu.Unlock()
+	return s
+}
+
+// BulkError reports the failures of a bulk send: a *RowError for each invalid entry in the
+// recipient source and a *RecipientError for each recipient the message could not be sent to.
+type BulkError struct {
+	Errors []error
+}
+
+func (e *BulkError) Error() string {
+	if len(e.Errors) == 1 {
+		return "bulk send: " + e.Errors[0].Error()
+	}
+	return "bulk send: " + strconv.Itoa(len(e.Errors)) + " errors, first: " + e.Errors[0].Error()
+}
+
+// SendEach sends an individual copy of `msg` to each of the recipients, composed with the data of
+// the recipient and addressed only to them, so that each recipient only sees themselves in the
+// To: header. The messages are sent within the limits set with BulkLimits, and SendEach returns
+// when all the deliveries are complete. The message is prepared first - see Prepare - and an error
+// reading its files is returned without sending it to anyone.
+//
+// If any recipient could not be sent the message, the returned error is a *BulkError.
+func (s *Sender) SendEach(msg *Message, recipients []Recipient) error {
+	return s.SendEachFrom(msg, &sliceSource{recipients: recipients})
+}
+
+// SendEachFrom is like SendEach, but streams the recipients from `src`.
+//
+// If the source fails with an error other than a *RowError, sending stops and that error is
+// returned; otherwise, if any entry was invalid or any recipient could not be sent the message,
+// the returned error is a *BulkError.
+func (s *Sender) SendEachFrom(msg *Message, src RecipientSource) error {
+	if msg == nil {
+		return errors.New("Sender.SendEach: no message to send")
+	}
+	if err := prepareBase(msg); err != nil {
+		return errors.New("Sender.SendEach: " + err.Error())
+	}
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	err := s.forEach(src, func(rcpt *Recipient, err error) error {
+		if err == nil {
+			if err = s.sendTo(msg, rcpt); err != nil {
+				err = &RecipientError{rcpt.Addr.Addr, err}
+			}
+		}
+		if err != nil {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return &BulkError{errs}
+	}
+	return nil
+}
+
+// prepareBase prepares the base message `msg` of a bulk send, returning the first error reading its
+// files. The copies sent to the recipients share its attachments, so they must not prepare them
+// concurrently.
+func prepareBase(msg *Message) error {
+	msg.Lock()
+	defer msg.Unlock()
+	return msg.prepareContext(context.Background(), false)
+}
+
+// forEach reads the recipients from `src` and calls `fn` for each of them, within the bulk limits
+// of the receiver. The invalid entries are passed to `fn` as a *RowError, instead of a recipient.
+// Processing stops at the first error returned by `fn` or any error from `src` other than a
+// *RowError, and that error is returned once the pending calls to `fn` complete.
+func (s *Sender) forEach(src RecipientSource, fn func(rcpt *Recipient, err error) error) error {
+	s.mu.RLock()
+	concurrency, rate := s.bulkConcurrency, s.bulkRate
+	s.mu.RUnlock()
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		fatal    error
+		interval time.Duration
+		next     = time.Now()
+		slots    = make(chan struct{}, concurrency)
+	)
+	if rate > 0 {
+		interval = time.Duration(float64(time.Second) / rate)
+	}
+	failed := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		return fatal
+	}
+	for failed() == nil {
+		rcpt, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if _, ok := err.(*RowError); !ok {
+				mu.Lock()
+				fatal = err
+				mu.Unlock()
+				break
+			}
+			if err = fn(nil, err); err != nil {
+				mu.Lock()
+				fatal = err
+				mu.Unlock()
+			}
+			continue
+		}
+		if interval > 0 {
+			if wait := time.Until(next); wait > 0 {
+				time.Sleep(wait)
+			}
+			next = next.Add(interval)
+			if now := time.Now(); next.Before(now) {
+				next = now
+			}
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(rcpt *Recipient) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			if err := fn(rcpt, nil); err != nil {
+				mu.Lock()
+				if fatal == nil {
+					fatal = err
+				}
+				mu.Unlock()
+			}
+		}(rcpt)
+	}
+	wg.Wait()
+	return fatal
+}
+
+type sliceSource struct {
+	recipients []Recipient
+	row        int
+}
+
+func (s *sliceSource) Next() (*Recipient, error) {
+	if s.row == len(s.recipients) {
+		return nil, io.EOF
+	}
+	rcpt := &s.recipients[s.row]
+	s.row++
+	if rcpt.Addr == nil {
+		return nil, &RowError{s.row, errors.New("missing address")}
+	}
+	if !SeemsValidAddr(rcpt.Addr.Addr) {
+		return nil, &RowError{s.row, errors.New("invalid address: " + rcpt.Addr.Addr)}
+	}
+	return rcpt, nil
+}
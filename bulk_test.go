@@ -0,0 +1,126 @@
+package email
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_SendEach(t *testing.T) {
+	defer func(f func(string, smtp.Auth, string, []string, []byte) error) { sendMail = f }(sendMail)
+	var (
+		mu         sync.Mutex
+		sent       []string
+		active     int
+		maxActive  int
+		errRefused = errors.New("550 refused")
+	)
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		active--
+		if to[0] == "refused@example.com" {
+			return errRefused
+		}
+		body := string(msg)
+		// each recipient must see only themselves, with their own data
+		if !strings.Contains(body, "To: <"+to[0]+">") || !strings.Contains(body, "Hi "+to[0]) {
+			return errors.New("unexpected message:\n" + body)
+		}
+		sent = append(sent, to...)
+		return nil
+	}
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	s.BulkLimits(2, 0)
+	msg := NewMessage(nil).Subject("Hi").TextTemplate("Hi {{.}}").To(&Address{"", "base@example.com"})
+	var rcpts []Recipient
+	for _, addr := range []string{"a@example.com", "b@example.com", "refused@example.com", "c@example.com", "d@example.com"} {
+		rcpts = append(rcpts, Recipient{&Address{"", addr}, addr})
+	}
+	rcpts = append(rcpts, Recipient{Addr: nil})
+
+	err := s.SendEach(msg, rcpts)
+	be, ok := err.(*BulkError)
+	if !ok || len(be.Errors) != 2 {
+		t.Fatalf("(*Sender).SendEach: got error %v, want a *BulkError with 2 errors", err)
+	}
+	if re, ok := be.Errors[0].(*RecipientError); ok {
+		if re.Addr != "refused@example.com" || re.Err != errRefused {
+			t.Errorf("(*Sender).SendEach: got %v", re)
+		}
+	}
+	sort.Strings(sent)
+	if strings.Join(sent, ",") != "a@example.com,b@example.com,c@example.com,d@example.com" {
+		t.Errorf("(*Sender).SendEach: sent to %v", sent)
+	}
+	if maxActive != 2 {
+		t.Errorf("(*Sender).SendEach: got %d concurrent deliveries, want 2", maxActive)
+	}
+
+	sent = nil
+	s.BulkLimits(4, 50)
+	start := time.Now()
+	if err := s.SendEach(msg, rcpts[:2]); err != nil {
+		t.Errorf("(*Sender).SendEach: unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("(*Sender).SendEach: sending 2 messages at 50/s took only %v", elapsed)
+	}
+}
+
+func Test_SendEachAttachedFile(t *testing.T) {
+	defer func(f func(string, smtp.Auth, string, []string, []byte) error) { sendMail = f }(sendMail)
+	dir, err := ioutil.TempDir("", "email-bulk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "report.txt")
+	if err = ioutil.WriteFile(file, []byte("report"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu   sync.Mutex
+		sent int
+	)
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		if !strings.Contains(string(msg), base64.StdEncoding.EncodeToString([]byte("report"))) {
+			return errors.New("missing attachment:\n" + string(msg))
+		}
+		mu.Lock()
+		sent++
+		mu.Unlock()
+		return nil
+	}
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	s.BulkLimits(4, 0)
+	var rcpts []Recipient
+	for _, addr := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"} {
+		rcpts = append(rcpts, Recipient{&Address{"", addr}, addr})
+	}
+	msg := NewMessage(nil).Subject("Hi").TextTemplate("Hi {{.}}").Attach(file)
+	if err := s.SendEach(msg, rcpts); err != nil || sent != len(rcpts) {
+		t.Errorf("(*Sender).SendEach: got %v, sent %d want %d", err, sent, len(rcpts))
+	}
+
+	sent = 0
+	msg = NewMessage(nil).Subject("Hi").TextTemplate("Hi {{.}}").Attach(filepath.Join(dir, "missing.txt"))
+	if err := s.SendEach(msg, rcpts); err == nil || sent != 0 {
+		t.Errorf("(*Sender).SendEach: got %v, sent %d, want an error reading the file and no deliveries", err, sent)
+	}
+}
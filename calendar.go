@@ -0,0 +1,69 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// calendarMethods are the iTIP methods, as defined by RFC 5546.
+var calendarMethods = map[string]bool{
+	"PUBLISH": true, "REQUEST": true, "REPLY": true, "ADD": true,
+	"CANCEL": true, "REFRESH": true, "COUNTER": true, "DECLINECOUNTER": true,
+}
+
+// Calendar sets an iCalendar alternative part of the message body - e.g. a meeting invitation, with
+// the "REQUEST" `method` - so that mail clients such as Outlook and Gmail render it with the
+// accept and decline buttons. The `ics` data must be a complete VCALENDAR object, with a METHOD
+// property matching the `method`. Last call overrides any previous calls.
+//
+// The calendar part is placed last in the multipart/alternative body, after the text and HTML
+// versions, which should describe the event for the clients not supporting calendar invitations.
+func (m *Message) Calendar(ics []byte, method string) *Message {
+	m.Lock()
+	defer m.Unlock()
+	method = strings.ToUpper(method)
+	if !calendarMethods[method] {
+		m.fail(ArgumentError, "calendar", errors.New("invalid calendar method: "+method))
+		return m
+	}
+	if !bytes.Contains(ics, []byte("BEGIN:VCALENDAR")) {
+		m.fail(ContentError, "calendar", errors.New("invalid calendar data: missing VCALENDAR object"))
+		return m
+	}
+	if m.calendar == nil {
+		m.calendar = &part{}
+		m.parts = append(m.parts, m.calendar)
+	}
+	*(m.calendar) = part{
+		ctype: "text/calendar; charset=utf-8; method=" + method,
+		cte:   QuotedPrintable,
+		bytes: ics,
+	}
+	return m
+}
+
+// alternatives returns the parts of the receiver in the order they are to be written in the
+// multipart/alternative body: by increasing faithfulness to the original content, as required by
+// RFC 2046 - that is, the plain text first, then any other parts, the AMP, the HTML, and the
+// calendar last.
+// Otherwise, the parts keep the order they were added in.
+func (m *Message) alternatives() []*part {
+	rank := func(p *part) int {
+		switch p {
+		case m.text:
+			return 0
+		case m.amp:
+			return 2
+		case m.html:
+			return 3
+		case m.calendar:
+			return 4
+		}
+		return 1
+	}
+	parts := append([]*part(nil), m.parts...)
+	sort.SliceStable(parts, func(i, j int) bool { return rank(parts[i]) < rank(parts[j]) })
+	return parts
+}
@@ -0,0 +1,34 @@
+package email
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_Calendar(t *testing.T) {
+	ics := []byte("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nMETHOD:REQUEST\r\nBEGIN:VEVENT\r\nSUMMARY:Review\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n")
+	msg := QuickMessage("Review").From(&Address{"", "test@example.com"}).
+		Calendar(ics, "request").Text("Review meeting").Html("<p>Review meeting</p>")
+	e, err := ReadEntity(bytes.NewReader(msg.Compose(nil)), nil)
+	if err != nil {
+		t.Fatalf("(*Message).Calendar: got error %v", err)
+	}
+	var types []string
+	for _, p := range e.Parts {
+		types = append(types, p.MediaType)
+	}
+	if act, exp := strings.Join(types, " "), "text/plain text/html text/calendar"; act != exp {
+		t.Errorf("(*Message).Calendar: got parts %q want %q", act, exp)
+	}
+	if cal := e.Parts[2]; cal.Params["method"] != "REQUEST" || cal.Params["charset"] != "utf-8" || !bytes.Equal(bytes.TrimSpace(cal.Body), bytes.TrimSpace(ics)) {
+		t.Errorf("(*Message).Calendar: got params %v, body %q", cal.Params, cal.Body)
+	}
+
+	if msg = NewMessage(nil).Calendar(ics, "INVITE"); len(msg.Errors()) == 0 {
+		t.Error("(*Message).Calendar: expected an error for an invalid method")
+	}
+	if msg = NewMessage(nil).Calendar([]byte("BEGIN:VEVENT"), "REQUEST"); len(msg.Errors()) == 0 {
+		t.Error("(*Message).Calendar: expected an error for invalid data")
+	}
+}
@@ -0,0 +1,277 @@
+package email
+
+import (
+	"bufio"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// DeliveryStatus represents the recorded outcome of sending a message to a recipient.
+type DeliveryStatus byte
+
+const (
+	// Pending indicates that no delivery to the recipient was recorded.
+	Pending DeliveryStatus = iota
+	// Delivered indicates that the message was accepted by the SMTP server for the recipient.
+	Delivered
+	// Failed indicates that the last attempt to deliver the message to the recipient failed.
+	Failed
+)
+
+// ProgressStore persists the per-recipient progress of campaigns, so that an interrupted
+// campaign can be resumed without sending the message again to the recipients that already got it.
+// Implementations must be safe for concurrent use.
+type ProgressStore interface {
+	// Status returns the status recorded for the recipient `addr` in the campaign `id`,
+	// or Pending if there is none.
+	Status(id, addr string) (DeliveryStatus, error)
+	// SetStatus durably records the status of the recipient `addr` in the campaign `id`.
+	SetStatus(id, addr string, status DeliveryStatus) error
+}
+
+// Campaign represents a message to be sent individually to each recipient from a source,
+// with the progress tracked in a ProgressStore.
+type Campaign struct {
+	// ID uniquely identifies the campaign in the ProgressStore.
+	ID string
+	// Sender is used to send the messages; defaults to the default Sender.
+	Sender *Sender
+	// Message is composed once per recipient, with the recipient as the only To: address
+	// and the recipient data.
+	Message *Message
+	// Recipients opens the source of recipients. It is called on every run of the campaign, and
+	// must provide the same recipients each time.
+	Recipients func() (RecipientSource, error)
+	// Progress records which recipients were already sent the message.
+	Progress ProgressStore
+}
+
+// CampaignResult summarizes a run of a Campaign.
+type CampaignResult struct {
+	// Sent is the number of recipients that the message was delivered to during the run.
+	Sent int
+	// Skipped is the number of recipients that the message was delivered to by earlier runs.
+	Skipped int
+	// Failed is the number of recipients that the message could not be delivered to.
+	Failed int
+	// Invalid is the number of invalid entries in the recipient source.
+	Invalid int
+	// Errors holds a *RowError for each invalid entry and a *RecipientError for each failure.
+	Errors []error
+}
+
+// RecipientError reports a failure to send a message to a specific recipient.
+type RecipientError struct {
+	Addr string
+	Err  error
+}
+
+func (e *RecipientError) Error() string {
+	return e.Addr + ": " + e.Err.Error()
+}
+
+var (
+	campaigns      = map[string]*Campaign{}
+	campaignsMutex sync.RWMutex
+)
+
+// RegisterCampaign makes a campaign available to ResumeCampaign. Applications should register all
+// their campaigns on start-up, so that the interrupted ones can be resumed after a restart.
+func RegisterCampaign(c *Campaign) error {
+	if c == nil || c.ID == "" {
+		return errors.New("RegisterCampaign: missing campaign id")
+	}
+	campaignsMutex.Lock()
+	defer campaignsMutex.Unlock()
+	if _, dup := campaigns[c.ID]; dup {
+		return errors.New("RegisterCampaign: duplicate campaign id: " + c.ID)
+	}
+	campaigns[c.ID] = c
+	return nil
+}
+
+// ResumeCampaign runs the registered campaign with the given id, skipping the recipients already
+// recorded as Delivered in its ProgressStore - e.g. by a run interrupted by a crash.
+// Recipients recorded as Failed are retried.
+func ResumeCampaign(id string) (*CampaignResult, error) {
+	campaignsMutex.RLock()
+	c := campaigns[id]
+	campaignsMutex.RUnlock()
+	if c == nil {
+		return nil, errors.New("ResumeCampaign: unknown campaign id: " + id)
+	}
+	return c.Run()
+}
+
+// Run sends the campaign message to each recipient not yet recorded as Delivered, within the bulk
+// limits of the Sender, recording the outcome of each delivery as soon as it is known.
+//
+// Run stops with an error if the files of the message cannot be read - before sending it to anyone
+// - or if the recipient source or the progress store fail; the errors concerning individual
+// recipients are collected in the result instead.
+func (c *Campaign) Run() (*CampaignResult, error) {
+	if c.Message == nil || c.Recipients == nil || c.Progress == nil {
+		return nil, errors.New("Campaign.Run: incomplete campaign: " + c.ID)
+	}
+	s := c.Sender
+	if s == nil {
+		defaultSenderMutex.RLock()
+		s = defaultSender
+		defaultSenderMutex.RUnlock()
+		if s == nil {
+			return nil, errors.New("Campaign.Run: no default sender")
+		}
+	}
+	if err := prepareBase(c.Message); err != nil {
+		return nil, errors.New("Campaign.Run: cannot prepare message: " + err.Error())
+	}
+	src, err := c.Recipients()
+	if err != nil {
+		return nil, errors.New("Campaign.Run: cannot open recipients: " + err.Error())
+	}
+	var (
+		res = &CampaignResult{}
+		mu  sync.Mutex
+	)
+	err = s.forEach(src, func(rcpt *Recipient, err error) error {
+		if err != nil {
+			mu.Lock()
+			res.Invalid++
+			res.Errors = append(res.Errors, err)
+			mu.Unlock()
+			return nil
+		}
+		status, err := c.Progress.Status(c.ID, rcpt.Addr.Addr)
+		if err != nil {
+			return err
+		}
+		if status == Delivered {
+			mu.Lock()
+			res.Skipped++
+			mu.Unlock()
+			return nil
+		}
+		err = s.sendTo(c.Message, rcpt)
+		mu.Lock()
+		if err != nil {
+			res.Failed++
+			res.Errors = append(res.Errors, &RecipientError{rcpt.Addr.Addr, err})
+			status = Failed
+		} else {
+			res.Sent++
+			status = Delivered
+		}
+		mu.Unlock()
+		return c.Progress.SetStatus(c.ID, rcpt.Addr.Addr, status)
+	})
+	return res, err
+}
+
+// sendTo sends a copy of `msg` to the recipient only, composed with the recipient data, and waits
+// for the outcome of the delivery.
+func (s *Sender) sendTo(msg *Message, rcpt *Recipient) error {
+	m := NewMessage(msg).To(rcpt.Addr).Cc().Bcc()
+	err := s.chain(s.sendSync)(m, rcpt.Data)
+	if errs := m.Errors(); len(errs) > 0 {
+		return errors.New("failed to compose message: " + errs[0].Error())
+	}
+	return err
+}
+
+// FileProgressStore is a ProgressStore keeping an append-only file for each campaign in a
+// directory. Every status change is synced to disk before SetStatus returns.
+type FileProgressStore struct {
+	dir   string
+	mu    sync.Mutex
+	files map[string]*progressFile
+}
+
+type progressFile struct {
+	f      *os.File
+	status map[string]DeliveryStatus
+}
+
+// NewFileProgressStore creates a FileProgressStore keeping its files in `dir`, which is created
+// if it does not exist.
+func NewFileProgressStore(dir string) (*FileProgressStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.New("NewFileProgressStore: " + err.Error())
+	}
+	return &FileProgressStore{dir: dir, files: map[string]*progressFile{}}, nil
+}
+
+// open loads the progress file of the campaign `id`. The caller must hold the lock on the receiver.
+func (ps *FileProgressStore) open(id string) (*progressFile, error) {
+	if pf := ps.files[id]; pf != nil {
+		return pf, nil
+	}
+	f, err := os.OpenFile(filepath.Join(ps.dir, url.PathEscape(id)+".progress"), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	pf := &progressFile{f: f, status: map[string]DeliveryStatus{}}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		// each line is "<status> <address>"; a truncated last line, left by a crash, is ignored
+		line := sc.Text()
+		if len(line) < 3 || line[1] != ' ' {
+			continue
+		}
+		if status, err := strconv.Atoi(line[:1]); err == nil {
+			pf.status[line[2:]] = DeliveryStatus(status)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	ps.files[id] = pf
+	return pf, nil
+}
+
+// Status implements ProgressStore.
+func (ps *FileProgressStore) Status(id, addr string) (DeliveryStatus, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	pf, err := ps.open(id)
+	if err != nil {
+		return Pending, err
+	}
+	return pf.status[addr], nil
+}
+
+// SetStatus implements ProgressStore.
+func (ps *FileProgressStore) SetStatus(id, addr string, status DeliveryStatus) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	pf, err := ps.open(id)
+	if err != nil {
+		return err
+	}
+	if _, err := pf.f.WriteString(strconv.Itoa(int(status)) + " " + addr + "\n"); err != nil {
+		return err
+	}
+	if err := pf.f.Sync(); err != nil {
+		return err
+	}
+	pf.status[addr] = status
+	return nil
+}
+
+// Close closes all the files opened by the receiver.
+func (ps *FileProgressStore) Close() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	var err error
+	for id, pf := range ps.files {
+		if e := pf.f.Close(); e != nil && err == nil {
+			err = e
+		}
+		delete(ps.files, id)
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package email
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/smtp"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_ResumeCampaign(t *testing.T) {
+	defer func(f func(string, smtp.Auth, string, []string, []byte) error) { sendMail = f }(sendMail)
+	dir, _ := ioutil.TempDir("", "email-test")
+	defer os.RemoveAll(dir)
+	store, err := NewFileProgressStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileProgressStore: unexpected error: %v", err)
+	}
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	csvData := "email,name\na@example.com,Ann\nb@example.com,Bob\nbad,Cid\nc@example.com,Cyd\n"
+	c := &Campaign{
+		ID:      "test/campaign",
+		Sender:  s,
+		Message: NewMessage(nil).Subject("Hi").TextTemplate("Hi {{.name}}"),
+		Recipients: func() (RecipientSource, error) {
+			return NewCSVSource(strings.NewReader(csvData), FieldMap{Name: "name"})
+		},
+		Progress: store,
+	}
+	if err := RegisterCampaign(c); err != nil {
+		t.Fatalf("RegisterCampaign: unexpected error: %v", err)
+	}
+	defer func() {
+		campaignsMutex.Lock()
+		delete(campaigns, c.ID)
+		campaignsMutex.Unlock()
+	}()
+	if err := RegisterCampaign(c); err == nil {
+		t.Error("RegisterCampaign: expected an error for a duplicate id")
+	}
+
+	var sent []string
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		if to[0] == "b@example.com" {
+			return errors.New("450 try later")
+		}
+		sent = append(sent, to...)
+		return nil
+	}
+	res, err := ResumeCampaign("test/campaign")
+	if err != nil {
+		t.Fatalf("ResumeCampaign: unexpected error: %v", err)
+	}
+	if res.Sent != 2 || res.Failed != 1 || res.Invalid != 1 || res.Skipped != 0 || len(res.Errors) != 2 {
+		t.Errorf("ResumeCampaign: got %+v", res)
+	}
+
+	// simulate a restart, with a fresh store reading the same files
+	store.Close()
+	c.Progress, _ = NewFileProgressStore(dir)
+	sent = nil
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sent = append(sent, to...)
+		return nil
+	}
+	res, err = ResumeCampaign("test/campaign")
+	if err != nil {
+		t.Fatalf("ResumeCampaign: unexpected error: %v", err)
+	}
+	if res.Sent != 1 || res.Skipped != 2 || res.Failed != 0 || len(sent) != 1 || sent[0] != "b@example.com" {
+		t.Errorf("ResumeCampaign: got %+v, sent to %v", res, sent)
+	}
+
+	if _, err := ResumeCampaign("unknown"); err == nil {
+		t.Error("ResumeCampaign: expected an error for an unknown campaign")
+	}
+}
@@ -0,0 +1,361 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// CharsetReader, if not nil, is used when parsing messages to transcode to UTF-8 the text in the
+// charsets that are not supported natively - e.g. Shift_JIS, by wrapping a decoder from the
+// golang.org/x/text/encoding packages. It has the same signature as the CharsetReader field of
+// mime.WordDecoder, so the same function can be used for both.
+//
+// The charsets supported natively are UTF-8, US-ASCII, ISO-8859-1, ISO-8859-2, ISO-8859-15,
+// windows-1251, windows-1252 and KOI8-R.
+var CharsetReader func(charset string, input io.Reader) (io.Reader, error)
+
+// CharsetEncoder, if not nil, is used when composing messages to transcode from UTF-8 the text in
+// the charsets that are not supported natively - e.g. Shift_JIS, by wrapping the Bytes method of an
+// encoder from the golang.org/x/text/encoding packages. It should fail if the text cannot be
+// represented in the charset.
+var CharsetEncoder func(charset string, data []byte) ([]byte, error)
+
+// charsetTables maps the normalized names of the natively supported single-byte charsets to the
+// runes of their upper halves. As is common practice, ISO-8859-1 is read as windows-1252.
+var charsetTables = map[string]*[128]rune{
+	"iso-8859-1":   &windows1252,
+	"iso8859-1":    &windows1252,
+	"iso_8859-1":   &windows1252,
+	"latin1":       &windows1252,
+	"l1":           &windows1252,
+	"cp819":        &windows1252,
+	"windows-1252": &windows1252,
+	"cp1252":       &windows1252,
+	"x-cp1252":     &windows1252,
+	"iso-8859-2":   &iso88592,
+	"iso8859-2":    &iso88592,
+	"iso_8859-2":   &iso88592,
+	"latin2":       &iso88592,
+	"l2":           &iso88592,
+	"iso-8859-15":  &iso885915,
+	"iso8859-15":   &iso885915,
+	"iso_8859-15":  &iso885915,
+	"latin9":       &iso885915,
+	"windows-1251": &windows1251,
+	"cp1251":       &windows1251,
+	"x-cp1251":     &windows1251,
+	"koi8-r":       &koi8r,
+	"koi8r":        &koi8r,
+}
+
+// toUTF8 transcodes `data` from `charset` to UTF-8, returning the result along with the name of
+// the charset actually used. When the charset is missing or not supported, it is guessed with
+// detectCharset. Invalid UTF-8 sequences are replaced with U+FFFD, so the result is always valid.
+func toUTF8(charset string, data []byte) ([]byte, string) {
+	charset = strings.ToLower(strings.Trim(charset, " \t\""))
+	switch charset {
+	case "utf-8", "utf8":
+		return validUTF8(data), "utf-8"
+	case "", "us-ascii", "ascii", "unknown-8bit", "x-unknown":
+		charset = detectCharset(data)
+		if charset == "utf-8" {
+			return data, charset
+		}
+	}
+	if table, ok := charsetTables[charset]; ok {
+		return decodeSingleByte(data, table), charset
+	}
+	if CharsetReader != nil {
+		if r, err := CharsetReader(charset, bytes.NewReader(data)); err == nil {
+			if out, err := ioutil.ReadAll(r); err == nil {
+				return validUTF8(out), charset
+			}
+		}
+	}
+	return toUTF8(detectCharset(data), data)
+}
+
+// charsetReader adapts the natively supported charsets to the CharsetReader signature, falling
+// back to CharsetReader for the others.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	if table, ok := charsetTables[strings.ToLower(charset)]; ok {
+		data, err := ioutil.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(decodeSingleByte(data, table)), nil
+	}
+	if CharsetReader != nil {
+		return CharsetReader(charset, input)
+	}
+	return nil, errors.New("email: unsupported charset: " + charset)
+}
+
+// fromUTF8 transcodes the UTF-8 `data` to `charset`, which is either supported natively or by the
+// CharsetEncoder, failing if any character cannot be represented in the charset.
+func fromUTF8(charset string, data []byte) ([]byte, error) {
+	cs := strings.ToLower(charset)
+	switch cs {
+	case "utf-8", "utf8":
+		return data, nil
+	case "us-ascii", "ascii":
+		for _, b := range data {
+			if b >= 0x80 {
+				return nil, errors.New("email: cannot encode text in charset " + charset)
+			}
+		}
+		return data, nil
+	}
+	table, ok := charsetTables[cs]
+	if !ok {
+		if CharsetEncoder != nil {
+			return CharsetEncoder(charset, data)
+		}
+		return nil, errors.New("email: unsupported charset: " + charset)
+	}
+	// unlike for reading, ISO-8859-1 is not written as windows-1252
+	latin1 := table == &windows1252 && !strings.Contains(cs, "1252")
+	out := make([]byte, 0, len(data))
+	for _, r := range string(data) {
+		if r < 0x80 {
+			out = append(out, byte(r))
+			continue
+		}
+		b, ok := encodeRune(r, table, latin1)
+		if !ok {
+			return nil, errors.New("email: cannot encode " + strconv.QuoteRune(r) + " in charset " + charset)
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// encodeRune returns the byte representing `r` in the single-byte charset with the `table`, if any.
+// For `latin1`, only the runes in the range of ISO-8859-1 are accepted.
+func encodeRune(r rune, table *[128]rune, latin1 bool) (byte, bool) {
+	if latin1 {
+		return byte(r), 0xA0 <= r && r <= 0xFF
+	}
+	for i, t := range table {
+		if t == r {
+			return byte(0x80 + i), true
+		}
+	}
+	return 0, false
+}
+
+// nativeCharset reports whether `charset` is supported natively and uses a single byte per
+// character, so it can be used for q-encoding header fields.
+func nativeCharset(charset string) bool {
+	cs := strings.ToLower(charset)
+	_, ok := charsetTables[cs]
+	return ok || cs == "us-ascii" || cs == "ascii"
+}
+
+// withCharset replaces the charset parameter of the content type `ctype` with `charset`.
+func withCharset(ctype, charset string) string {
+	return strings.Replace(ctype, "charset=utf-8", "charset="+charset, 1)
+}
+
+// Charset sets the charset of the text and HTML bodies of the message, which are transcoded from
+// UTF-8 when composing the message, e.g. "ISO-8859-1" for recipients on legacy systems that do not
+// render UTF-8 properly. The subject is encoded in the charset as well, if it is a single-byte
+// charset supported natively and can represent the subject; other header fields are encoded in
+// UTF-8. The charsets that are not supported natively require a CharsetEncoder.
+//
+// An empty `charset` restores UTF-8.
+func (m *Message) Charset(charset string) *Message {
+	m.Lock()
+	defer m.Unlock()
+	if strings.ContainsAny(charset, " \t\r\n\";?") {
+		m.fail(ArgumentError, "charset", errors.New("invalid charset: "+charset))
+		return m
+	}
+	m.charset = charset
+	return m
+}
+
+// PartCharset sets the charset of the last part added to the message - which must be a text part,
+// such as those added with Text or Html - overriding the charset of the message.
+func (m *Message) PartCharset(charset string) *Message {
+	m.Lock()
+	defer m.Unlock()
+	if strings.ContainsAny(charset, " \t\r\n\";?") {
+		m.fail(ArgumentError, "charset", errors.New("invalid charset: "+charset))
+		return m
+	}
+	if len(m.parts) == 0 || !strings.Contains(m.parts[len(m.parts)-1].ctype, "charset=utf-8") {
+		m.fail(ArgumentError, "charset", errors.New("no text part to set the charset of"))
+		return m
+	}
+	m.parts[len(m.parts)-1].charset = charset
+	return m
+}
+
+// partCharset returns the charset the part `p` is to be transcoded to, if any.
+func (m *Message) partCharset(p *part) string {
+	if p.charset != "" {
+		return p.charset
+	}
+	if strings.HasPrefix(p.ctype, "text/plain;") || strings.HasPrefix(p.ctype, "text/html;") {
+		return m.charset
+	}
+	return ""
+}
+
+// validUTF8 replaces the invalid UTF-8 sequences in `data` with U+FFFD.
+func validUTF8(data []byte) []byte {
+	if utf8.Valid(data) {
+		return data
+	}
+	return bytes.ToValidUTF8(data, []byte("\uFFFD"))
+}
+
+// decodeSingleByte transcodes `data` to UTF-8 using the `table` for the bytes above 127.
+func decodeSingleByte(data []byte, table *[128]rune) []byte {
+	out := make([]byte, 0, len(data)+len(data)/4)
+	for _, b := range data {
+		if b < 0x80 {
+			out = append(out, b)
+		} else {
+			out = append(out, string(table[b-0x80])...)
+		}
+	}
+	return out
+}
+
+// detectCharset guesses the charset of unlabeled text: "utf-8" if the text is valid UTF-8,
+// "windows-1251" if the 8-bit characters make up a large share of the letters and mostly
+// fall in the range of the Cyrillic letters, and "windows-1252" otherwise.
+func detectCharset(data []byte) string {
+	if utf8.Valid(data) {
+		return "utf-8"
+	}
+	var ascii, high, cyrillic int
+	for _, b := range data {
+		switch {
+		case 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z':
+			ascii++
+		case b >= 0xC0:
+			high++
+			cyrillic++
+		case b >= 0x80:
+			high++
+		}
+	}
+	if high > (ascii+high)/3 && cyrillic*10 >= high*9 {
+		return "windows-1251"
+	}
+	return "windows-1252"
+}
+
+var windows1252 = [128]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+	0x00A0, 0x00A1, 0x00A2, 0x00A3, 0x00A4, 0x00A5, 0x00A6, 0x00A7,
+	0x00A8, 0x00A9, 0x00AA, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x00AF,
+	0x00B0, 0x00B1, 0x00B2, 0x00B3, 0x00B4, 0x00B5, 0x00B6, 0x00B7,
+	0x00B8, 0x00B9, 0x00BA, 0x00BB, 0x00BC, 0x00BD, 0x00BE, 0x00BF,
+	0x00C0, 0x00C1, 0x00C2, 0x00C3, 0x00C4, 0x00C5, 0x00C6, 0x00C7,
+	0x00C8, 0x00C9, 0x00CA, 0x00CB, 0x00CC, 0x00CD, 0x00CE, 0x00CF,
+	0x00D0, 0x00D1, 0x00D2, 0x00D3, 0x00D4, 0x00D5, 0x00D6, 0x00D7,
+	0x00D8, 0x00D9, 0x00DA, 0x00DB, 0x00DC, 0x00DD, 0x00DE, 0x00DF,
+	0x00E0, 0x00E1, 0x00E2, 0x00E3, 0x00E4, 0x00E5, 0x00E6, 0x00E7,
+	0x00E8, 0x00E9, 0x00EA, 0x00EB, 0x00EC, 0x00ED, 0x00EE, 0x00EF,
+	0x00F0, 0x00F1, 0x00F2, 0x00F3, 0x00F4, 0x00F5, 0x00F6, 0x00F7,
+	0x00F8, 0x00F9, 0x00FA, 0x00FB, 0x00FC, 0x00FD, 0x00FE, 0x00FF,
+}
+
+var windows1251 = [128]rune{
+	0x0402, 0x0403, 0x201A, 0x0453, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x20AC, 0x2030, 0x0409, 0x2039, 0x040A, 0x040C, 0x040B, 0x040F,
+	0x0452, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x0098, 0x2122, 0x0459, 0x203A, 0x045A, 0x045C, 0x045B, 0x045F,
+	0x00A0, 0x040E, 0x045E, 0x0408, 0x00A4, 0x0490, 0x00A6, 0x00A7,
+	0x0401, 0x00A9, 0x0404, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x0407,
+	0x00B0, 0x00B1, 0x0406, 0x0456, 0x0491, 0x00B5, 0x00B6, 0x00B7,
+	0x0451, 0x2116, 0x0454, 0x00BB, 0x0458, 0x0405, 0x0455, 0x0457,
+	0x0410, 0x0411, 0x0412, 0x0413, 0x0414, 0x0415, 0x0416, 0x0417,
+	0x0418, 0x0419, 0x041A, 0x041B, 0x041C, 0x041D, 0x041E, 0x041F,
+	0x0420, 0x0421, 0x0422, 0x0423, 0x0424, 0x0425, 0x0426, 0x0427,
+	0x0428, 0x0429, 0x042A, 0x042B, 0x042C, 0x042D, 0x042E, 0x042F,
+	0x0430, 0x0431, 0x0432, 0x0433, 0x0434, 0x0435, 0x0436, 0x0437,
+	0x0438, 0x0439, 0x043A, 0x043B, 0x043C, 0x043D, 0x043E, 0x043F,
+	0x0440, 0x0441, 0x0442, 0x0443, 0x0444, 0x0445, 0x0446, 0x0447,
+	0x0448, 0x0449, 0x044A, 0x044B, 0x044C, 0x044D, 0x044E, 0x044F,
+}
+
+var koi8r = [128]rune{
+	0x2500, 0x2502, 0x250C, 0x2510, 0x2514, 0x2518, 0x251C, 0x2524,
+	0x252C, 0x2534, 0x253C, 0x2580, 0x2584, 0x2588, 0x258C, 0x2590,
+	0x2591, 0x2592, 0x2593, 0x2320, 0x25A0, 0x2219, 0x221A, 0x2248,
+	0x2264, 0x2265, 0x00A0, 0x2321, 0x00B0, 0x00B2, 0x00B7, 0x00F7,
+	0x2550, 0x2551, 0x2552, 0x0451, 0x2553, 0x2554, 0x2555, 0x2556,
+	0x2557, 0x2558, 0x2559, 0x255A, 0x255B, 0x255C, 0x255D, 0x255E,
+	0x255F, 0x2560, 0x2561, 0x0401, 0x2562, 0x2563, 0x2564, 0x2565,
+	0x2566, 0x2567, 0x2568, 0x2569, 0x256A, 0x256B, 0x256C, 0x00A9,
+	0x044E, 0x0430, 0x0431, 0x0446, 0x0434, 0x0435, 0x0444, 0x0433,
+	0x0445, 0x0438, 0x0439, 0x043A, 0x043B, 0x043C, 0x043D, 0x043E,
+	0x043F, 0x044F, 0x0440, 0x0441, 0x0442, 0x0443, 0x0436, 0x0432,
+	0x044C, 0x044B, 0x0437, 0x0448, 0x044D, 0x0449, 0x0447, 0x044A,
+	0x042E, 0x0410, 0x0411, 0x0426, 0x0414, 0x0415, 0x0424, 0x0413,
+	0x0425, 0x0418, 0x0419, 0x041A, 0x041B, 0x041C, 0x041D, 0x041E,
+	0x041F, 0x042F, 0x0420, 0x0421, 0x0422, 0x0423, 0x0416, 0x0412,
+	0x042C, 0x042B, 0x0417, 0x0428, 0x042D, 0x0429, 0x0427, 0x042A,
+}
+
+var iso885915 = [128]rune{
+	0x0080, 0x0081, 0x0082, 0x0083, 0x0084, 0x0085, 0x0086, 0x0087,
+	0x0088, 0x0089, 0x008A, 0x008B, 0x008C, 0x008D, 0x008E, 0x008F,
+	0x0090, 0x0091, 0x0092, 0x0093, 0x0094, 0x0095, 0x0096, 0x0097,
+	0x0098, 0x0099, 0x009A, 0x009B, 0x009C, 0x009D, 0x009E, 0x009F,
+	0x00A0, 0x00A1, 0x00A2, 0x00A3, 0x20AC, 0x00A5, 0x0160, 0x00A7,
+	0x0161, 0x00A9, 0x00AA, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x00AF,
+	0x00B0, 0x00B1, 0x00B2, 0x00B3, 0x017D, 0x00B5, 0x00B6, 0x00B7,
+	0x017E, 0x00B9, 0x00BA, 0x00BB, 0x0152, 0x0153, 0x0178, 0x00BF,
+	0x00C0, 0x00C1, 0x00C2, 0x00C3, 0x00C4, 0x00C5, 0x00C6, 0x00C7,
+	0x00C8, 0x00C9, 0x00CA, 0x00CB, 0x00CC, 0x00CD, 0x00CE, 0x00CF,
+	0x00D0, 0x00D1, 0x00D2, 0x00D3, 0x00D4, 0x00D5, 0x00D6, 0x00D7,
+	0x00D8, 0x00D9, 0x00DA, 0x00DB, 0x00DC, 0x00DD, 0x00DE, 0x00DF,
+	0x00E0, 0x00E1, 0x00E2, 0x00E3, 0x00E4, 0x00E5, 0x00E6, 0x00E7,
+	0x00E8, 0x00E9, 0x00EA, 0x00EB, 0x00EC, 0x00ED, 0x00EE, 0x00EF,
+	0x00F0, 0x00F1, 0x00F2, 0x00F3, 0x00F4, 0x00F5, 0x00F6, 0x00F7,
+	0x00F8, 0x00F9, 0x00FA, 0x00FB, 0x00FC, 0x00FD, 0x00FE, 0x00FF,
+}
+
+var iso88592 = [128]rune{
+	0x0080, 0x0081, 0x0082, 0x0083, 0x0084, 0x0085, 0x0086, 0x0087,
+	0x0088, 0x0089, 0x008A, 0x008B, 0x008C, 0x008D, 0x008E, 0x008F,
+	0x0090, 0x0091, 0x0092, 0x0093, 0x0094, 0x0095, 0x0096, 0x0097,
+	0x0098, 0x0099, 0x009A, 0x009B, 0x009C, 0x009D, 0x009E, 0x009F,
+	0x00A0, 0x0104, 0x02D8, 0x0141, 0x00A4, 0x013D, 0x015A, 0x00A7,
+	0x00A8, 0x0160, 0x015E, 0x0164, 0x0179, 0x00AD, 0x017D, 0x017B,
+	0x00B0, 0x0105, 0x02DB, 0x0142, 0x00B4, 0x013E, 0x015B, 0x02C7,
+	0x00B8, 0x0161, 0x015F, 0x0165, 0x017A, 0x02DD, 0x017E, 0x017C,
+	0x0154, 0x00C1, 0x00C2, 0x0102, 0x00C4, 0x0139, 0x0106, 0x00C7,
+	0x010C, 0x00C9, 0x0118, 0x00CB, 0x011A, 0x00CD, 0x00CE, 0x010E,
+	0x0110, 0x0143, 0x0147, 0x00D3, 0x00D4, 0x0150, 0x00D6, 0x00D7,
+	0x0158, 0x016E, 0x00DA, 0x0170, 0x00DC, 0x00DD, 0x0162, 0x00DF,
+	0x0155, 0x00E1, 0x00E2, 0x0103, 0x00E4, 0x013A, 0x0107, 0x00E7,
+	0x010D, 0x00E9, 0x0119, 0x00EB, 0x011B, 0x00ED, 0x00EE, 0x010F,
+	0x0111, 0x0144, 0x0148, 0x00F3, 0x00F4, 0x0151, 0x00F6, 0x00F7,
+	0x0159, 0x016F, 0x00FA, 0x0171, 0x00FC, 0x00FD, 0x0163, 0x02D9,
+}
+
+// encodeSubject encodes the `subject` for the Subject header, in the charset of the receiver if
+// possible, or else in UTF-8.
+func (m *Message) encodeSubject(subject []byte) []byte {
+	if m.charset != "" && nativeCharset(m.charset) {
+		if enc, err := fromUTF8(m.charset, subject); err == nil {
+			return qEncodeIfNeeded(enc, m.charset, 9)
+		}
+	}
+	return QEncodeIfNeeded(subject, 9)
+}
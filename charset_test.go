@@ -0,0 +1,108 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_toUTF8(t *testing.T) {
+	cases := []struct {
+		charset string
+		data    string
+		exp     string
+		used    string
+	}{
+		{"utf-8", "Grüße", "Grüße", "utf-8"},
+		{"UTF-8", "bad \xff byte", "bad � byte", "utf-8"},
+		{"ISO-8859-1", "Gr\xfc\xdfe", "Grüße", "iso-8859-1"},
+		{"windows-1252", "\x93quoted\x94 \x80", "“quoted” €", "windows-1252"},
+		{"iso-8859-15", "\xa4", "€", "iso-8859-15"},
+		{"\"windows-1251\"", "\xcf\xf0\xe8\xe2\xe5\xf2", "Привет", "windows-1251"},
+		{"koi8-r", "\xf0\xd2\xc9\xd7\xc5\xd4", "Привет", "koi8-r"},
+		{"", "plain", "plain", "utf-8"},
+		{"", "Gr\xfc\xdfe aus K\xf6ln", "Grüße aus Köln", "windows-1252"},
+		{"us-ascii", "\xcf\xf0\xe8\xe2\xe5\xf2, \xec\xe8\xf0", "Привет, мир", "windows-1251"},
+		{"x-unsupported", "caf\xe9", "café", "windows-1252"},
+	}
+	for i, c := range cases {
+		act, used := toUTF8(c.charset, []byte(c.data))
+		if string(act) != c.exp || used != c.used {
+			t.Errorf("toUTF8 [%d]: got %q (%s), want %q (%s)", i, act, used, c.exp, c.used)
+		}
+	}
+}
+
+func Test_CharsetReader(t *testing.T) {
+	defer func(cr func(string, io.Reader) (io.Reader, error)) { CharsetReader = cr }(CharsetReader)
+	CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		// a stand-in for a real Shift_JIS decoder
+		return strings.NewReader("日本"), nil
+	}
+	src := "Subject: =?shift_jis?B?k/qWew==?=\r\nContent-Type: text/plain; charset=Shift_JIS\r\n\r\n\x93\xfa\x96\x7b"
+	e, err := ReadEntity(strings.NewReader(src), nil)
+	if err != nil {
+		t.Fatalf("ReadEntity: unexpected error: %v", err)
+	}
+	if string(e.Body) != "日本" || e.Charset != "shift_jis" {
+		t.Errorf("ReadEntity: got body %q (%s)", e.Body, e.Charset)
+	}
+	if subj, err := wordDecoder.DecodeHeader(e.Header.Get("Subject")); err != nil || subj != "日本" {
+		t.Errorf("wordDecoder: got %q, %v", subj, err)
+	}
+	e, err = ReadEntity(strings.NewReader("Content-Type: application/octet-stream\r\n\r\n\x93\xfa"), nil)
+	if err != nil || !bytes.Equal(e.Body, []byte("\x93\xfa")) || e.Charset != "" {
+		t.Errorf("ReadEntity: got body %q (%s), error %v", e.Body, e.Charset, err)
+	}
+}
+
+func Test_fromUTF8(t *testing.T) {
+	cases := []struct {
+		charset string
+		src     string
+		exp     string
+		err     bool
+	}{
+		{"utf-8", "café", "café", false},
+		{"US-ASCII", "cafe", "cafe", false},
+		{"us-ascii", "café", "", true},
+		{"ISO-8859-1", "café", "caf\xe9", false},
+		{"ISO-8859-1", "5 €", "", true},
+		{"windows-1252", "5 €", "5 \x80", false},
+		{"ISO-8859-15", "5 €", "5 \xa4", false},
+		{"KOI8-R", "Да", "\xe4\xc1", false},
+		{"x-unknown", "abc", "", true},
+	}
+	for i, c := range cases {
+		act, err := fromUTF8(c.charset, []byte(c.src))
+		if (err != nil) != c.err || string(act) != c.exp {
+			t.Errorf("fromUTF8 [%d]: got %q, %v want %q", i, act, err, c.exp)
+		}
+	}
+}
+
+func Test_MessageCharset(t *testing.T) {
+	msg := NewMessage(nil).From(&Address{"", "test@example.com"}).To(&Address{"", "to@example.com"}).
+		Subject("Café").Text("Crème brûlée").Html("<p>Crème</p>").PartCharset("utf-8").Charset("ISO-8859-1")
+	act := string(msg.Compose(nil))
+	if errs := msg.Errors(); len(errs) > 0 {
+		t.Fatalf("(*Message).Charset: unexpected errors: %v", errs)
+	}
+	for _, exp := range []string{"Subject: =?ISO-8859-1?q?Caf=E9?=", "Content-Type: text/plain; charset=ISO-8859-1",
+		"Cr=E8me br=FBl=E9e", "Content-Type: text/html; charset=utf-8", "Cr=C3=A8me"} {
+		if !strings.Contains(act, exp) {
+			t.Errorf("(*Message).Charset: got %q, missing %q", act, exp)
+		}
+	}
+
+	msg = NewMessage(nil).From(&Address{"", "test@example.com"}).To(&Address{"", "to@example.com"}).
+		Subject("Price").Text("5 €").Charset("ISO-8859-1")
+	msg.Compose(nil)
+	errs := msg.Errors()
+	var me *MessageError
+	if len(errs) != 1 || !errors.As(errs[0], &me) || me.Field != "charset" || me.PartIndex != 0 {
+		t.Errorf("(*Message).Charset: got errors %v", errs)
+	}
+}
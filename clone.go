@@ -0,0 +1,61 @@
+package email
+
+// Clone returns a copy of the receiver, which can be changed without affecting it, and vice versa:
+//
+//   - the addresses, recipient lists, headers, parts and their content, and the recorded errors
+//     are copied;
+//   - the attachments are copied, so that preparing the copy - e.g. reading the attached files -
+//     does not change the receiver; their data, and that of the related and embedded items, is
+//     shared, since the message never updates it in place;
+//   - the parsed templates, the Sender, the base message set with Inherit, and the policies and
+//     hooks are shared, since they are not changed once set - setting them replaces them.
+//
+// Use DeepClone for copying the data of the attachments and related items as well, if it may be
+// changed by the caller after the fact.
+func (m *Message) Clone() *Message {
+	return m.clone(false)
+}
+
+// DeepClone returns a copy of the receiver, like Clone, that holds its own copies of the data of the
+// attachments, and of the related and embedded items.
+func (m *Message) DeepClone() *Message {
+	return m.clone(true)
+}
+
+// clone returns a copy of the receiver, copying the data of the attachments and related items if
+// `deep`.
+func (m *Message) clone(deep bool) *Message {
+	c := NewMessage(m)
+	m.RLock()
+	defer m.RUnlock()
+	c.receiptTo, c.senderAddr = m.receiptTo.Clone(), m.senderAddr.Clone()
+	c.errors = append([]error(nil), m.errors...)
+	for i, a := range m.attachments {
+		att := *a
+		if a.disposition != nil {
+			d := *a.disposition
+			att.disposition = &d
+		}
+		if deep {
+			att.data = append([]byte(nil), a.data...)
+			att.encoded = &encodedCache{}
+		}
+		c.attachments[i] = &att
+	}
+	if deep {
+		for _, p := range c.parts {
+			copyRelatedData(p.related)
+		}
+		copyRelatedData(c.embeds)
+	}
+	return c
+}
+
+// copyRelatedData replaces the data of the related items in `rel` with copies.
+func copyRelatedData(rel []Related) {
+	for i := range rel {
+		if rel[i].data != nil {
+			rel[i].data = append([]byte(nil), rel[i].data...)
+		}
+	}
+}
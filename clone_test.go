@@ -0,0 +1,37 @@
+package email
+
+import "testing"
+
+func Test_Clone(t *testing.T) {
+	data := []byte("original")
+	img := []byte("image")
+	msg := QuickMessage("subject", "text").From(&Address{"", "ann@example.com"}).
+		To(&Address{"", "bob@example.com"}).
+		SenderHeader(&Address{"", "sender@example.com"}).
+		Html("<img src=\"cid:logo\">", RelatedObject("logo", "image/png", img)).
+		AttachObject("a.txt", "text/plain", data)
+	msg.Lock()
+	msg.fail(ArgumentError, "test", ErrInvalidArgument)
+	msg.Unlock()
+
+	c := msg.Clone()
+	c.To(&Address{"", "carl@example.com"})
+	c.senderAddr.Addr = "other@example.com"
+	c.attachments[0].name = "b.txt"
+	if msg.to[0].Addr != "bob@example.com" || msg.senderAddr.Addr != "sender@example.com" || msg.attachments[0].name != "a.txt" {
+		t.Errorf("(*Message).Clone: the copy affects the original")
+	}
+	if len(c.errors) != 1 || &c.attachments[0].data[0] != &data[0] || &c.html.related[0].data[0] != &img[0] {
+		t.Errorf("(*Message).Clone: got %v errors, unexpected copied data", c.errors)
+	}
+
+	d := msg.DeepClone()
+	copy(data, "modified")
+	copy(img, "IMAGE")
+	if act := string(d.attachments[0].data); act != "original" {
+		t.Errorf("(*Message).DeepClone: got attachment %q want %q", act, "original")
+	}
+	if act := string(d.html.related[0].data); act != "image" {
+		t.Errorf("(*Message).DeepClone: got related %q want %q", act, "image")
+	}
+}
@@ -0,0 +1,87 @@
+package email
+
+import "errors"
+
+// Content holds the rendered content of a message, as passed through the compose middleware.
+type Content struct {
+	// Subject is the subject of the message, with the template executed.
+	Subject string
+	// Text is the plain-text body, or nil if the message has none - in which case the text is
+	// derived from the HTML body after all the middleware has run.
+	Text []byte
+	// Html is the HTML body, or nil if the message has none.
+	Html []byte
+	// Data is the data the message is composed with.
+	Data interface{}
+}
+
+// ComposeFunc is the signature of the functions processing the rendered content of a message, as
+// wrapped by ComposeMiddleware.
+type ComposeFunc func(c *Content) error
+
+// ComposeMiddleware wraps a ComposeFunc with a transformation of the rendered content of messages -
+// e.g. CSS inlining, link rewriting for tracking, footer injection or sanitization. It may alter
+// the content before and/or after calling `next`; an error returned by it makes the composition
+// of the message fail.
+type ComposeMiddleware func(next ComposeFunc) ComposeFunc
+
+// UseCompose installs middleware to be invoked on the rendered content of the receiver every time
+// it is composed. It runs after the compose middleware of the Sender, and the middleware installed
+// first is outermost.
+func (m *Message) UseCompose(mw ...ComposeMiddleware) *Message {
+	m.Lock()
+	defer m.Unlock()
+	mws := make([]ComposeMiddleware, 0, len(m.composeMws)+len(mw))
+	m.composeMws = append(append(mws, m.composeMws...), mw...)
+	return m
+}
+
+// UseCompose installs middleware to be invoked on the rendered content of every message composed
+// for sending by the receiver, before the compose middleware of the message itself. The middleware
+// installed first is outermost.
+func (s *Sender) UseCompose(mw ...ComposeMiddleware) *Sender {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mws := make([]ComposeMiddleware, 0, len(s.composeMws)+len(mw))
+	s.composeMws = append(append(mws, s.composeMws...), mw...)
+	return s
+}
+
+// applyCompose runs the compose middleware of the receiver and of its sender, if any, over the
+// rendered content. It returns the resulting subject, along with the bodies to be used instead of
+// the bytes of the text and HTML parts; the parts themselves are left unchanged. The receiver must
+// be locked by the caller.
+func (m *Message) applyCompose(data interface{}, sender *Sender) (subject []byte, bodies map[*part][]byte) {
+	var mws []ComposeMiddleware
+	if sender != nil {
+		sender.mu.RLock()
+		mws = append(mws, sender.composeMws...)
+		sender.mu.RUnlock()
+	}
+	mws = append(mws, m.composeMws...)
+	if len(mws) == 0 {
+		return m.subject, nil
+	}
+	c := &Content{Subject: string(m.subject), Data: data}
+	if m.text != nil {
+		c.Text = append([]byte{}, m.text.bytes...)
+	}
+	if m.html != nil {
+		c.Html = append([]byte{}, m.html.bytes...)
+	}
+	fn := func(*Content) error { return nil }
+	for i := len(mws) - 1; i >= 0; i-- {
+		fn = mws[i](fn)
+	}
+	if err := fn(c); err != nil {
+		m.fail(MiddlewareError, "", errors.New("compose middleware failed: "+err.Error()))
+	}
+	bodies = map[*part][]byte{}
+	if m.text != nil {
+		bodies[m.text] = c.Text
+	}
+	if m.html != nil {
+		bodies[m.html] = c.Html
+	}
+	return []byte(c.Subject), bodies
+}
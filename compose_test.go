@@ -0,0 +1,66 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_UseCompose(t *testing.T) {
+	var order []string
+	tag := func(name string) ComposeMiddleware {
+		return func(next ComposeFunc) ComposeFunc {
+			return func(c *Content) error {
+				order = append(order, name)
+				c.Html = append(c.Html, "<p>"+name+"</p>"...)
+				return next(c)
+			}
+		}
+	}
+	upper := func(next ComposeFunc) ComposeFunc {
+		return func(c *Content) error {
+			if err := next(c); err != nil {
+				return err
+			}
+			c.Subject = strings.ToUpper(c.Subject)
+			return nil
+		}
+	}
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	s.UseCompose(tag("sender"))
+	msg := NewMessage(nil).Subject("hello").HtmlTemplate("<p>Hi {{.}}</p>").Sender(s).UseCompose(tag("message"), upper)
+
+	for i := 0; i < 2; i++ {
+		order = nil
+		body := msg.Compose("Bob")
+		if msg.HasErrors() {
+			t.Fatalf("(*Message).Compose [%d]: unexpected errors: %v", i, msg.Errors())
+		}
+		e, err := ReadEntity(bytes.NewReader(body), nil)
+		if err != nil {
+			t.Fatalf("ReadEntity [%d]: unexpected error: %v", i, err)
+		}
+		if subj := e.Header.Get("Subject"); subj != "HELLO" {
+			t.Errorf("(*Message).Compose [%d]: got subject %q, want %q", i, subj, "HELLO")
+		}
+		text, html := string(e.Parts[0].Body), string(e.Parts[1].Body)
+		if exp := "<p>Hi Bob</p><p>sender</p><p>message</p>\r\n"; html != exp {
+			t.Errorf("(*Message).Compose [%d]: got html %q, want %q", i, html, exp)
+		}
+		if !strings.Contains(text, "message") {
+			t.Errorf("(*Message).Compose [%d]: got text %q, want it derived from the altered html", i, text)
+		}
+		if strings.Join(order, ",") != "sender,message" {
+			t.Errorf("(*Message).Compose [%d]: got order %v", i, order)
+		}
+	}
+
+	fail := func(next ComposeFunc) ComposeFunc {
+		return func(c *Content) error { return errors.New("boom") }
+	}
+	msg = QuickMessage("hello", "text").From(&Address{"", "test@example.com"}).UseCompose(fail)
+	if body := msg.Compose(nil); len(body) != 0 || !msg.HasErrors() {
+		t.Errorf("(*Message).Compose: expected a failure from the middleware, got %q", body)
+	}
+}
@@ -0,0 +1,156 @@
+package email
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// ComposedMessage is the immutable result of composing a message with ComposeMessage: its content,
+// along with its SMTP envelope and identity, so that it can be queued, stored or sent as a
+// self-contained artifact, without composing the message again - possibly with different data.
+type ComposedMessage struct {
+	raw  []byte
+	from string
+	to   []string
+	id   string
+	date time.Time
+	fp   Fingerprint
+	msg  *Message
+}
+
+// ComposeMessage composes the receiver with the `data`, like Compose, returning the result as a
+// ComposedMessage. If the message cannot be composed, it returns the first error recorded; all of
+// them are available with Errors, as for Compose.
+func (m *Message) ComposeMessage(data interface{}) (*ComposedMessage, error) {
+	m.Lock()
+	defer m.Unlock()
+	raw := m.compose(data, nil)
+	if len(m.errors) > 0 {
+		return nil, m.errors[0]
+	}
+	return &ComposedMessage{
+		raw:  raw,
+		from: m.fromAddr(),
+		to:   m.recipientAddrs(),
+		id:   m.id,
+		date: m.composedAt,
+		fp:   m.fingerprint,
+		msg:  m,
+	}, nil
+}
+
+// Bytes returns a copy of the content of the message, as sent to the SMTP server.
+func (c *ComposedMessage) Bytes() []byte {
+	return append([]byte(nil), c.raw...)
+}
+
+// WriteTo writes the content of the message to `w`, implementing io.WriterTo.
+func (c *ComposedMessage) WriteTo(w io.Writer) (int64, error) {
+	return bytes.NewReader(c.raw).WriteTo(w)
+}
+
+// Size returns the size of the content of the message, in bytes.
+func (c *ComposedMessage) Size() int {
+	return len(c.raw)
+}
+
+// From returns the envelope sender address of the message - see (*Message).FromAddr.
+func (c *ComposedMessage) From() string {
+	return c.from
+}
+
+// Recipients returns the envelope recipient addresses of the message - see
+// (*Message).RecipientAddrs.
+func (c *ComposedMessage) Recipients() []string {
+	return append([]string(nil), c.to...)
+}
+
+// MessageID returns the Message-ID header value of the message, including the angle brackets.
+func (c *ComposedMessage) MessageID() string {
+	return c.id
+}
+
+// Date returns the time in the Date header of the message.
+func (c *ComposedMessage) Date() time.Time {
+	return c.date
+}
+
+// Fingerprint returns the similarity hash of the subject and body of the message - see
+// (*Message).Fingerprint.
+func (c *ComposedMessage) Fingerprint() Fingerprint {
+	return c.fp
+}
+
+// ContentHash returns a stable hash of the logical content of the message, as a hex-encoded
+// SHA-256 sum: it ignores the Date header and the unique identifier of the message - found in its
+// Message-ID, MIME boundaries and Content-IDs - so that two compositions of a message with the
+// same data, to the same recipients, have the same hash - e.g. for detecting duplicate sends.
+// Unlike the Fingerprint, any change to the content, headers or recipients changes the hash.
+//
+// The hash is only stable for the messages using the default boundaries - see Boundaries - and
+// it changes if the message is encrypted.
+func (c *ComposedMessage) ContentHash() string {
+	raw := c.raw
+	end := bytes.Index(raw, []byte("\r\n\r\n"))
+	if end < 0 {
+		end = len(raw)
+	}
+	h := sha256.New()
+	if i := bytes.Index(raw[:end], []byte("\r\nDate: ")); i >= 0 {
+		n := bytes.Index(raw[i+2:], []byte("\r\n"))
+		h.Write(contentWithoutID(raw[:i], c.id))
+		raw = raw[i+2+n:]
+	}
+	h.Write(contentWithoutID(raw, c.id))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// contentWithoutID returns the content `b` of the message with the Message-ID `id` with its unique
+// identifier replaced by a placeholder of the same length.
+func contentWithoutID(b []byte, id string) []byte {
+	uid := strings.TrimPrefix(id, "<")
+	if at := strings.LastIndexByte(uid, '@'); at > 0 {
+		uid = uid[:at]
+	}
+	if uid == "" {
+		return b
+	}
+	return bytes.Replace(b, []byte(uid), bytes.Repeat([]byte{'-'}, len(uid)), -1)
+}
+
+// ContentHash composes a copy of the receiver with the `data`, and returns the ContentHash of the
+// result, leaving the receiver unchanged; it returns the first error recorded composing the copy,
+// if any.
+func (m *Message) ContentHash(data interface{}) (string, error) {
+	c, err := NewMessage(m).ComposeMessage(data)
+	if err != nil {
+		return "", err
+	}
+	return c.ContentHash(), nil
+}
+
+// SendComposed queues the composed message `c` for delivery by the workers of the receiver, like
+// Send, without composing it again. In sandbox mode, it is only delivered to the sandbox address,
+// although its headers list the original recipients - compose the message with Send for rewriting
+// them.
+func (s *Sender) SendComposed(c *ComposedMessage) error {
+	if c == nil {
+		return errors.New("Sender.SendComposed: no message to send")
+	}
+	s.mu.RLock()
+	sandbox := s.sandbox
+	s.mu.RUnlock()
+	to := c.to
+	if sandbox != nil {
+		to = []string{sandbox.ASCII()}
+	}
+	if err := s.checkSize(len(c.raw)); err != nil {
+		return err
+	}
+	return s.enqueue(delivery{c.msg, c.id, c.fp, c.from, to, c.raw, now()})
+}
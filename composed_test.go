@@ -0,0 +1,88 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"net/smtp"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ComposeMessage(t *testing.T) {
+	defer func(f func(string, smtp.Auth, string, []string, []byte) error) { sendMail = f }(sendMail)
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	msg := QuickMessage("Hello, {{.}}", "Hi!").From(&Address{"", "ann@example.com"}).
+		To(&Address{"", "bob@example.com"}).Bcc(&Address{"", "carl@example.com"}).
+		Options(Deterministic(date, "seed"))
+	c, err := msg.ComposeMessage(nil)
+	if err != nil {
+		t.Fatalf("(*Message).ComposeMessage: got %v", err)
+	}
+	raw := msg.Compose(nil)
+	if !bytes.Equal(c.Bytes(), raw) || c.Size() != len(raw) || c.From() != "ann@example.com" ||
+		!reflect.DeepEqual(c.Recipients(), []string{"bob@example.com", "carl@example.com"}) ||
+		c.MessageID() != msg.MessageID() || !c.Date().Equal(date) {
+		t.Errorf("(*Message).ComposeMessage: got %+v", c)
+	}
+	c.Bytes()[0] = 'X'
+	c.Recipients()[0] = "x@example.com"
+	var buf bytes.Buffer
+	if n, err := c.WriteTo(&buf); err != nil || n != int64(len(raw)) || !bytes.Equal(buf.Bytes(), raw) {
+		t.Errorf("(*ComposedMessage).WriteTo: got %d, %v", n, err)
+	}
+
+	var sent [][]string
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		if !bytes.Equal(msg, raw) {
+			t.Errorf("(*Sender).SendComposed: got %q", msg)
+		}
+		sent = append(sent, to)
+		return nil
+	}
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	s.Workers(1, 2)
+	if err = s.SendComposed(c); err != nil {
+		t.Fatalf("(*Sender).SendComposed: got %v", err)
+	}
+	s.Sandbox(&Address{"", "sandbox@example.com"}, false)
+	if err = s.SendComposed(c); err != nil {
+		t.Fatalf("(*Sender).SendComposed: got %v", err)
+	}
+	s.Flush(context.Background())
+	if exp := [][]string{{"bob@example.com", "carl@example.com"}, {"sandbox@example.com"}}; !reflect.DeepEqual(sent, exp) {
+		t.Errorf("(*Sender).SendComposed: got recipients %v want %v", sent, exp)
+	}
+
+	if c, err = QuickMessage("no from", "body").ComposeMessage(nil); c != nil || err == nil ||
+		!strings.Contains(err.Error(), ErrNoFrom.Error()) {
+		t.Errorf("(*Message).ComposeMessage: got %v, %v want %v", c, err, ErrNoFrom)
+	}
+}
+
+func Test_ContentHash(t *testing.T) {
+	newMsg := func() *Message {
+		return QuickMessage("Hello, {{.}}", "Hi!", "<p>Hi! <img src=\"cid:logo\"></p>").
+			From(&Address{"", "ann@example.com"}).To(&Address{"", "bob@example.com"}).
+			Embed("logo", "image/png", []byte("png")).AttachObject("a.txt", "text/plain", []byte("attached"))
+	}
+	msg := newMsg()
+	h1, err := msg.ContentHash(nil)
+	if err != nil || len(h1) != 64 || msg.MessageID() != "" {
+		t.Fatalf("(*Message).ContentHash: got %q, %v, %q", h1, err, msg.MessageID())
+	}
+	c, _ := newMsg().Options(WithClock(func() time.Time { return time.Now().Add(time.Hour) })).ComposeMessage(nil)
+	if h2 := c.ContentHash(); h2 != h1 || c.Fingerprint() == 0 {
+		t.Errorf("(*ComposedMessage).ContentHash: got %q want %q", h2, h1)
+	}
+	for i, m := range []*Message{
+		newMsg().To(&Address{"", "carl@example.com"}),
+		newMsg().Text("Hi again!"),
+		newMsg().AttachObject("b.txt", "text/plain", []byte("more")),
+	} {
+		if h, _ := m.ContentHash(nil); h == h1 {
+			t.Errorf("(*Message).ContentHash [%d]: got the same hash for a different message", i)
+		}
+	}
+}
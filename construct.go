@@ -0,0 +1,94 @@
+package email
+
+// MessageOption is an option of NewMessageWith, setting up the message and returning the error
+// recorded doing so, if any.
+type MessageOption func(m *Message) error
+
+// NewMessageWith creates a new Message, applying the `opts` in order, and returns the first error
+// recorded by any of them - e.g. a template that cannot be parsed, or an invalid address - instead
+// of recording it for Errors, for those who prefer errors upfront to the fluent style.
+func NewMessageWith(opts ...MessageOption) (*Message, error) {
+	m := NewMessage(nil)
+	for _, opt := range opts {
+		if err := opt(m); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// With returns an option calling the setter `set` on the message, failing with the first error it
+// records - e.g. With(func(m *Message) *Message { return m.Attach("invoice.pdf") }), for the
+// setters that have no dedicated option.
+func With(set func(m *Message) *Message) MessageOption {
+	return func(m *Message) error {
+		m.RLock()
+		n := len(m.errors)
+		m.RUnlock()
+		set(m)
+		m.RLock()
+		defer m.RUnlock()
+		if len(m.errors) > n {
+			return m.errors[n]
+		}
+		return nil
+	}
+}
+
+// WithSubject sets the subject of the message, like (*Message).Subject.
+func WithSubject(subject string) MessageOption {
+	return With(func(m *Message) *Message { return m.Subject(subject) })
+}
+
+// WithSubjectTemplate sets the template of the subject of the message, like
+// (*Message).SubjectTemplate.
+func WithSubjectTemplate(tpl string) MessageOption {
+	return With(func(m *Message) *Message { return m.SubjectTemplate(tpl) })
+}
+
+// WithFrom sets the From address of the message, like (*Message).From.
+func WithFrom(addr *Address) MessageOption {
+	return With(func(m *Message) *Message { return m.From(addr) })
+}
+
+// WithReplyTo sets the Reply-To address of the message, like (*Message).ReplyTo.
+func WithReplyTo(addr *Address) MessageOption {
+	return With(func(m *Message) *Message { return m.ReplyTo(addr) })
+}
+
+// WithTo sets the To addresses of the message, like (*Message).To.
+func WithTo(addr ...*Address) MessageOption {
+	return With(func(m *Message) *Message { return m.To(addr...) })
+}
+
+// WithCc sets the Cc addresses of the message, like (*Message).Cc.
+func WithCc(addr ...*Address) MessageOption {
+	return With(func(m *Message) *Message { return m.Cc(addr...) })
+}
+
+// WithBcc sets the Bcc addresses of the message, like (*Message).Bcc.
+func WithBcc(addr ...*Address) MessageOption {
+	return With(func(m *Message) *Message { return m.Bcc(addr...) })
+}
+
+// WithTextTemplate sets the template of the plain-text body of the message, like
+// (*Message).TextTemplate.
+func WithTextTemplate(tpl string) MessageOption {
+	return With(func(m *Message) *Message { return m.TextTemplate(tpl) })
+}
+
+// WithHtmlTemplate sets the template of the HTML body of the message, like
+// (*Message).HtmlTemplate.
+func WithHtmlTemplate(tpl string, related ...Related) MessageOption {
+	return With(func(m *Message) *Message { return m.HtmlTemplate(tpl, related...) })
+}
+
+// WithHeader adds a header to the message, like (*Message).Header.
+func WithHeader(name, value string) MessageOption {
+	return With(func(m *Message) *Message { return m.Header(name, value) })
+}
+
+// WithOptions applies the compose options `opts` to the message, like (*Message).Options.
+func WithOptions(opts ...ComposeOption) MessageOption {
+	return With(func(m *Message) *Message { return m.Options(opts...) })
+}
@@ -0,0 +1,43 @@
+package email
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_NewMessageWith(t *testing.T) {
+	msg, err := NewMessageWith(
+		WithSubjectTemplate("Hello, {{.}}"),
+		WithFrom(&Address{"Ann", "ann@example.com"}),
+		WithTo(&Address{"", "bob@example.com"}),
+		WithTextTemplate("Hi {{.}}!"),
+		WithHeader("X-Campaign", "spring"),
+		WithOptions(Deterministic(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), "seed")),
+	)
+	if err != nil {
+		t.Fatalf("NewMessageWith: got %v", err)
+	}
+	act := string(msg.Compose("Bob"))
+	for _, exp := range []string{"Subject: Hello, Bob\r\n", "X-Campaign: spring\r\n", "Hi Bob!"} {
+		if !strings.Contains(act, exp) {
+			t.Errorf("NewMessageWith: got %q, missing %q", act, exp)
+		}
+	}
+
+	cases := []struct {
+		opts  []MessageOption
+		field string
+	}{
+		{[]MessageOption{WithSubject("ok"), WithTextTemplate("{{.Name")}, "text"},
+		{[]MessageOption{WithFrom(&Address{"", "ann@example.com"}), WithTo(&Address{"", "bob@localhost"})}, "to"},
+		{[]MessageOption{WithHeader("Bad Name", "x")}, "Bad Name"},
+		{[]MessageOption{With(func(m *Message) *Message { return m.Priority(Priority(9)) })}, "priority"},
+	}
+	for i, c := range cases {
+		msg, err := NewMessageWith(c.opts...)
+		if e, ok := err.(*MessageError); msg != nil || !ok || e.Field != c.field {
+			t.Errorf("NewMessageWith [%d]: got %v, %#v want %s error", i, msg, err, c.field)
+		}
+	}
+}
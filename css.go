@@ -0,0 +1,314 @@
+package email
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	reStyleElement = regexp.MustCompile(`(?is)<style\b[^>]*>(.*?)</style\s*>`)
+	reCSSComment   = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	reCSSSelector  = regexp.MustCompile(`^([a-zA-Z][\w-]*)?((?:[.#][\w-]+)*)$`)
+)
+
+// cssRule is a style sheet rule with a simple selector, which can be inlined.
+type cssRule struct {
+	tag          string
+	ids, classes []string
+	decls        []cssDecl
+	specificity  int
+}
+
+// cssDecl is a CSS declaration.
+type cssDecl struct {
+	property, value string
+	important       bool
+}
+
+// InlineCSS enables or disables moving the rules of the style sheets of the HTML part into style
+// attributes of the elements they apply to, when composing the message. See InlineCSS.
+func (m *Message) InlineCSS(enable bool) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.inlineCSS = enable
+	return m
+}
+
+// InlineCSS moves the rules of the <style> elements of the HTML content `src` into style attributes
+// of the elements they apply to, since many mail clients ignore or mangle style sheets.
+//
+// Only the rules with selectors made of an element name, class names and ids - e.g. "p",
+// "td.header" or "#footer" - are inlined, following the precedence of the selectors; the existing
+// style attributes take precedence over the inlined rules, unless these are !important. The rules
+// that cannot be inlined, such as those with pseudo-classes or within at-rules, are kept in their
+// <style> element, which is removed when nothing is left.
+func InlineCSS(src []byte) []byte {
+	var rules []cssRule
+	src = reStyleElement.ReplaceAllFunc(src, func(elem []byte) []byte {
+		sub := reStyleElement.FindSubmatchIndex(elem)
+		var rest string
+		rules, rest = parseCSS(string(elem[sub[2]:sub[3]]), rules)
+		if strings.TrimSpace(rest) == "" {
+			return nil
+		}
+		return append(append(append([]byte{}, elem[:sub[2]]...), rest...), elem[sub[3]:]...)
+	})
+	if len(rules) == 0 {
+		return src
+	}
+	var dst bytes.Buffer
+	dst.Grow(len(src))
+	for i := 0; i < len(src); {
+		lt := bytes.IndexByte(src[i:], '<')
+		if lt < 0 {
+			dst.Write(src[i:])
+			break
+		}
+		dst.Write(src[i : i+lt])
+		i += lt
+		if bytes.HasPrefix(src[i:], []byte("<!--")) {
+			end := bytes.Index(src[i+4:], []byte("-->"))
+			if end < 0 {
+				dst.Write(src[i:])
+				break
+			}
+			dst.Write(src[i : i+4+end+3])
+			i += 4 + end + 3
+			continue
+		}
+		t, n := parseTag(src[i:])
+		if n == 0 {
+			dst.WriteByte('<')
+			i++
+			continue
+		}
+		style, ok := inlineStyle(t, rules)
+		if !ok {
+			dst.Write(src[i : i+n])
+		} else {
+			dst.WriteString("<" + t.name)
+			for _, a := range t.attrs {
+				if a.name != "style" {
+					dst.WriteString(" " + a.name + `="` + html.EscapeString(a.value) + `"`)
+				}
+			}
+			dst.WriteString(` style="` + html.EscapeString(style) + `"`)
+			if t.selfClosing {
+				dst.WriteString(" /")
+			}
+			dst.WriteByte('>')
+		}
+		i += n
+		if rawTextElements[t.name] && !t.closing && !t.selfClosing {
+			end := rawTextEnd(src[i:], t.name)
+			dst.Write(src[i : i+end])
+			i += end
+		}
+	}
+	return dst.Bytes()
+}
+
+// parseCSS appends the rules of the style sheet `css` that can be inlined to `rules`, returning
+// them along with the rest of the style sheet.
+func parseCSS(css string, rules []cssRule) ([]cssRule, string) {
+	var rest strings.Builder
+	css = reCSSComment.ReplaceAllString(css, "")
+	for css = strings.TrimSpace(css); css != ""; css = strings.TrimSpace(css) {
+		if css[0] == '@' {
+			// keep at-rules as they are
+			end := cssBlockEnd(css)
+			rest.WriteString(css[:end] + "\n")
+			css = css[end:]
+			continue
+		}
+		open := strings.IndexByte(css, '{')
+		if open < 0 {
+			rest.WriteString(css)
+			break
+		}
+		end := strings.IndexByte(css[open:], '}')
+		if end < 0 {
+			rest.WriteString(css)
+			break
+		}
+		selectors, body := css[:open], css[open+1:open+end]
+		css = css[open+end+1:]
+		decls := parseCSSDecls(body)
+		var kept []string
+		for _, sel := range strings.Split(selectors, ",") {
+			sel = strings.TrimSpace(sel)
+			sub := reCSSSelector.FindStringSubmatch(sel)
+			if sub == nil || sel == "" {
+				kept = append(kept, sel)
+				continue
+			}
+			r := cssRule{tag: strings.ToLower(sub[1]), decls: decls}
+			for _, s := range splitSelector(sub[2]) {
+				if s[0] == '#' {
+					r.ids = append(r.ids, s[1:])
+				} else {
+					r.classes = append(r.classes, s[1:])
+				}
+			}
+			r.specificity = len(r.ids)<<16 + len(r.classes)<<8
+			if r.tag != "" {
+				r.specificity++
+			}
+			rules = append(rules, r)
+		}
+		if len(kept) > 0 {
+			rest.WriteString(strings.Join(kept, ", ") + " {" + body + "}\n")
+		}
+	}
+	return rules, rest.String()
+}
+
+// cssBlockEnd returns the length of the at-rule at the beginning of `css`, including its block,
+// if any.
+func cssBlockEnd(css string) int {
+	depth := 0
+	for i := 0; i < len(css); i++ {
+		switch css[i] {
+		case ';':
+			if depth == 0 {
+				return i + 1
+			}
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth <= 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(css)
+}
+
+// splitSelector splits a sequence of class and id selectors, such as ".a#b.c".
+func splitSelector(s string) (parts []string) {
+	for len(s) > 0 {
+		end := strings.IndexAny(s[1:], ".#")
+		if end < 0 {
+			return append(parts, s)
+		}
+		parts, s = append(parts, s[:end+1]), s[end+1:]
+	}
+	return parts
+}
+
+// parseCSSDecls parses the declaration block `body`.
+func parseCSSDecls(body string) (decls []cssDecl) {
+	for _, d := range strings.Split(body, ";") {
+		colon := strings.IndexByte(d, ':')
+		if colon < 0 {
+			continue
+		}
+		decl := cssDecl{
+			property: strings.ToLower(strings.TrimSpace(d[:colon])),
+			value:    strings.TrimSpace(d[colon+1:]),
+		}
+		if i := strings.LastIndex(strings.ToLower(decl.value), "!important"); i >= 0 {
+			decl.value, decl.important = strings.TrimSpace(decl.value[:i]), true
+		}
+		if decl.property != "" && decl.value != "" {
+			decls = append(decls, decl)
+		}
+	}
+	return decls
+}
+
+// matches reports whether the rule applies to the tag `t`.
+func (r *cssRule) matches(t htmlTag) bool {
+	if r.tag != "" && r.tag != t.name {
+		return false
+	}
+	var id string
+	var classes []string
+	for _, a := range t.attrs {
+		switch a.name {
+		case "id":
+			id = a.value
+		case "class":
+			classes = strings.Fields(a.value)
+		}
+	}
+	for _, s := range r.ids {
+		if s != id {
+			return false
+		}
+	}
+	for _, s := range r.classes {
+		found := false
+		for _, c := range classes {
+			if c == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// inlineStyle returns the style attribute of the tag `t` resulting from applying the `rules`, and
+// whether any of them applies.
+func inlineStyle(t htmlTag, rules []cssRule) (string, bool) {
+	if t.closing || t.name == "style" || t.name == "head" || t.name == "html" {
+		return "", false
+	}
+	var matched []*cssRule
+	for i := range rules {
+		if rules[i].matches(t) {
+			matched = append(matched, &rules[i])
+		}
+	}
+	if len(matched) == 0 {
+		return "", false
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].specificity < matched[j].specificity
+	})
+	var (
+		decls []cssDecl
+		index = map[string]int{}
+	)
+	apply := func(d cssDecl) {
+		i, ok := index[d.property]
+		switch {
+		case !ok:
+			index[d.property] = len(decls)
+			decls = append(decls, d)
+		case d.important || !decls[i].important:
+			decls[i] = d
+		}
+	}
+	for _, r := range matched {
+		for _, d := range r.decls {
+			apply(d)
+		}
+	}
+	for _, a := range t.attrs {
+		if a.name == "style" {
+			for _, d := range parseCSSDecls(a.value) {
+				apply(d)
+			}
+		}
+	}
+	var buf strings.Builder
+	for i, d := range decls {
+		if i > 0 {
+			buf.WriteString("; ")
+		}
+		buf.WriteString(d.property + ": " + d.value)
+		if d.important {
+			buf.WriteString(" !important")
+		}
+	}
+	return buf.String(), true
+}
@@ -0,0 +1,46 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_InlineCSS(t *testing.T) {
+	cases := []struct {
+		src string
+		exp string
+	}{
+		{`<p>no style</p>`, `<p>no style</p>`},
+		{`<style>p { color: red }</style><p>x</p><div>y</div>`, `<p style="color: red">x</p><div>y</div>`},
+		{`<style>.a { color: red; margin: 0 } p.a { color: blue } #b { color: green }</style>` +
+			`<p class="x a" id="b">1</p><p class="a">2</p><span class=a>3</span>`,
+			`<p class="x a" id="b" style="color: green; margin: 0">1</p><p class="a" style="color: blue; margin: 0">2</p>` +
+				`<span class="a" style="color: red; margin: 0">3</span>`},
+		{`<style>td { color: red !important; padding: 2px }</style><td style="color: blue; padding: 0">x</td>`,
+			`<td style="color: red !important; padding: 0">x</td>`},
+		{`<style>/* c */ a:hover { color: red } a, h1 { font-weight: bold } @media (max-width: 600px) { p { margin: 0 } }</style>` +
+			`<h1>t</h1><br/>`,
+			"<style>a:hover { color: red }\n@media (max-width: 600px) { p { margin: 0 } }\n</style>" +
+				`<h1 style="font-weight: bold">t</h1><br/>`},
+		{`<style>img { border: 0 }</style><!-- <img> --><img src="a.png" />`,
+			`<!-- <img> --><img src="a.png" style="border: 0" />`},
+	}
+	for i, c := range cases {
+		act := string(InlineCSS([]byte(c.src)))
+		if act != c.exp {
+			t.Errorf("InlineCSS [%d]: got %q want %q", i, act, c.exp)
+		}
+	}
+}
+
+func Test_MessageInlineCSS(t *testing.T) {
+	msg := NewMessage(nil).From(&Address{"", "test@example.com"}).To(&Address{"", "to@example.com"}).
+		Subject("test").Html(`<style>p { color: red }</style><p>x</p>`).InlineCSS(true)
+	act := string(msg.Compose(nil))
+	if errs := msg.Errors(); len(errs) > 0 {
+		t.Fatalf("(*Message).InlineCSS: unexpected errors: %v", errs)
+	}
+	if strings.Contains(act, "<style>") || !strings.Contains(act, `<p style=3D"color: red">`) {
+		t.Errorf("(*Message).InlineCSS: got %q", act)
+	}
+}
@@ -0,0 +1,151 @@
+package email
+
+import (
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// DangerousAction is the action taken on the attachments deemed dangerous by an AttachmentPolicy.
+type DangerousAction byte
+
+const (
+	// AllowDangerous leaves the dangerous attachments alone.
+	AllowDangerous DangerousAction = iota
+	// WarnDangerous reports the dangerous attachments to the Warn function of the policy, each time
+	// the message is composed, and leaves them alone.
+	WarnDangerous
+	// RenameDangerous appends the Suffix of the policy to the names of the dangerous attachments,
+	// and changes their content type to "application/octet-stream", so that they are neither
+	// rejected nor opened by the receiving side.
+	RenameDangerous
+	// BlockDangerous rejects the dangerous attachments: adding one records an error, and so does
+	// composing a message with one - e.g. a file attached before the policy was set.
+	BlockDangerous
+)
+
+var (
+	// DangerousExtensions lists the extensions of the attachments commonly rejected by receivers:
+	// executables, scripts, shortcuts, disk images, and macro-enabled Office documents.
+	DangerousExtensions = []string{
+		".ade", ".adp", ".app", ".bat", ".chm", ".cmd", ".com", ".cpl", ".dll", ".exe", ".hta",
+		".img", ".ins", ".iso", ".isp", ".jar", ".js", ".jse", ".lib", ".lnk", ".mde", ".msc",
+		".msi", ".msp", ".mst", ".pif", ".ps1", ".reg", ".scr", ".sct", ".shb", ".sys", ".vb",
+		".vbe", ".vbs", ".vxd", ".wsc", ".wsf", ".wsh",
+		".docm", ".dotm", ".xlsm", ".xltm", ".xlam", ".pptm", ".potm", ".ppam", ".ppsm", ".sldm",
+	}
+	// DangerousContentTypes lists the content types of the attachments commonly rejected by
+	// receivers.
+	DangerousContentTypes = []string{
+		"application/hta",
+		"application/java-archive",
+		"application/javascript",
+		"application/x-bat",
+		"application/x-msdos-program",
+		"application/x-msdownload",
+		"application/x-ms-installer",
+		"application/x-sh",
+		"text/javascript",
+		"application/vnd.ms-excel.sheet.macroenabled.12",
+		"application/vnd.ms-powerpoint.presentation.macroenabled.12",
+		"application/vnd.ms-word.document.macroenabled.12",
+	}
+)
+
+// AttachmentPolicy defines which attachments are dangerous - i.e. commonly rejected by the receiving
+// mail servers, often without notice - and what to do with them.
+//
+// An attachment is dangerous if the extension of its name, or its content type, is listed.
+type AttachmentPolicy struct {
+	// Action is the action taken on the dangerous attachments.
+	Action DangerousAction
+	// Extensions lists the dangerous extensions, with their leading dot; nil means
+	// DangerousExtensions.
+	Extensions []string
+	// ContentTypes lists the dangerous media types; nil means DangerousContentTypes.
+	ContentTypes []string
+	// Suffix is appended to the names of the dangerous attachments with RenameDangerous; it
+	// defaults to "_".
+	Suffix string
+	// Warn is called with the name and content type of each dangerous attachment with
+	// WarnDangerous.
+	Warn func(name, ctype string)
+}
+
+// Dangerous reports whether an attachment with the `name` and content type `ctype` is dangerous.
+func (p AttachmentPolicy) Dangerous(name, ctype string) bool {
+	exts, ctypes := p.Extensions, p.ContentTypes
+	if exts == nil {
+		exts = DangerousExtensions
+	}
+	if ctypes == nil {
+		ctypes = DangerousContentTypes
+	}
+	if ext := filepath.Ext(name); ext != "" {
+		for _, e := range exts {
+			if strings.EqualFold(e, ext) {
+				return true
+			}
+		}
+	}
+	if mt, _, err := mime.ParseMediaType(ctype); err == nil {
+		for _, t := range ctypes {
+			if strings.EqualFold(t, mt) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AttachmentPolicy sets the policy applied to the dangerous attachments of the message: the
+// attachments added from then on are checked right away, as far as their name and content type are
+// known, and all of them are checked again when composing the message.
+func (m *Message) AttachmentPolicy(p AttachmentPolicy) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.attachmentPolicy = &p
+	return m
+}
+
+// screenAttachment applies the attachment policy of the receiver to the attachment `a`, reporting
+// whether it may be kept; the rejected attachments are recorded as errors. The dangerous attachments
+// are only reported to the Warn function of the policy if `warn`. The caller must hold the lock on
+// the receiver.
+func (m *Message) screenAttachment(a *attachment, warn bool) bool {
+	p := m.attachmentPolicy
+	if p == nil || p.Action == AllowDangerous {
+		return true
+	}
+	name := a.name
+	if name == "" {
+		name = filepath.Base(a.fileName)
+	}
+	if !p.Dangerous(name, a.ctype) {
+		return true
+	}
+	switch p.Action {
+	case WarnDangerous:
+		if warn && p.Warn != nil {
+			p.Warn(name, a.ctype)
+		}
+	case RenameDangerous:
+		suffix := p.Suffix
+		if suffix == "" {
+			suffix = "_"
+		}
+		a.name, a.ctype = m.sanitizeFilename(name)+suffix, "application/octet-stream"
+	case BlockDangerous:
+		m.failAttachment(ContentError, name, &ErrDangerousAttachment{name, a.ctype})
+		return false
+	}
+	return true
+}
+
+// screenAttachments applies the attachment policy of the receiver to all its attachments. The
+// caller must hold the lock on the receiver.
+func (m *Message) screenAttachments() {
+	for _, a := range m.attachments {
+		m.screenAttachment(a, true)
+	}
+}
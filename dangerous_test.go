@@ -0,0 +1,74 @@
+package email
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func Test_AttachmentPolicy(t *testing.T) {
+	var warned []string
+	warn := func(name, ctype string) {
+		warned = append(warned, name)
+	}
+	newMsg := func(p AttachmentPolicy) *Message {
+		return QuickMessage("test", "body").From(&Address{"", "ann@example.com"}).
+			AttachmentPolicy(p).
+			AttachObject("setup.EXE", "", []byte("MZ")).
+			AttachObject("report.pdf", "application/pdf", []byte("%PDF-1.4")).
+			AttachObject("budget", "application/vnd.ms-excel.sheet.macroEnabled.12", []byte("xlsm")).
+			AttachObject("notes.txt", "", []byte("notes"))
+	}
+	cases := []struct {
+		policy AttachmentPolicy
+		names  []string
+		errs   int
+		warned []string
+	}{
+		{AttachmentPolicy{}, []string{"setup.EXE", "report.pdf", "budget", "notes.txt"}, 0, nil},
+		{AttachmentPolicy{Action: WarnDangerous, Warn: warn},
+			[]string{"setup.EXE", "report.pdf", "budget", "notes.txt"}, 0, []string{"setup.EXE", "budget"}},
+		{AttachmentPolicy{Action: RenameDangerous},
+			[]string{"setup.EXE_", "report.pdf", "budget_", "notes.txt"}, 0, nil},
+		{AttachmentPolicy{Action: RenameDangerous, Suffix: ".blocked", Extensions: []string{".txt"}, ContentTypes: []string{}},
+			[]string{"setup.EXE", "report.pdf", "budget", "notes.txt.blocked"}, 0, nil},
+		{AttachmentPolicy{Action: BlockDangerous}, []string{"report.pdf", "notes.txt"}, 2, nil},
+	}
+	for i, c := range cases {
+		warned = nil
+		msg := newMsg(c.policy)
+		errs := msg.Errors()
+		if len(errs) != c.errs {
+			t.Errorf("(*Message).AttachmentPolicy [%d]: got %d errors want %d", i, len(errs), c.errs)
+		}
+		for _, err := range errs {
+			var de *ErrDangerousAttachment
+			if !errors.As(err, &de) {
+				t.Errorf("(*Message).AttachmentPolicy [%d]: got %v want *ErrDangerousAttachment", i, err)
+			}
+		}
+		if len(msg.Compose(nil)) == 0 {
+			t.Errorf("(*Message).AttachmentPolicy [%d]: got %v", i, msg.Errors())
+		}
+		var names []string
+		for _, a := range msg.Attachments() {
+			names = append(names, a.Name)
+		}
+		if !reflect.DeepEqual(names, c.names) {
+			t.Errorf("(*Message).AttachmentPolicy [%d]: got %v want %v", i, names, c.names)
+		}
+		if !reflect.DeepEqual(warned, c.warned) {
+			t.Errorf("(*Message).AttachmentPolicy [%d]: warned about %v want %v", i, warned, c.warned)
+		}
+	}
+
+	msg := QuickMessage("test", "body").From(&Address{"", "ann@example.com"}).
+		AttachObject("run.bat", "", []byte("echo")).
+		AttachmentPolicy(AttachmentPolicy{Action: BlockDangerous})
+	if len(msg.Compose(nil)) != 0 {
+		t.Errorf("(*Message).AttachmentPolicy: composed a message with a blocked attachment")
+	}
+	if errs := msg.Errors(); len(errs) != 1 || errs[0].(*MessageError).AttachmentName != "run.bat" {
+		t.Errorf("(*Message).AttachmentPolicy: got %v want 1 error for run.bat", errs)
+	}
+}
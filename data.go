@@ -0,0 +1,70 @@
+package email
+
+import "reflect"
+
+// DefaultData sets default values for the template data of the message - e.g. branding values,
+// such as the company name or the support URL - so that the call sites composing it do not need to
+// repeat them. The defaults are merged under the data provided when composing the message, whose
+// values take precedence, if that data is nil or a map with string keys; other kinds of data, such
+// as structs, are used as they are.
+//
+// The map is copied, so later changes to `data` do not affect the message; a nil `data` removes
+// the defaults.
+func (m *Message) DefaultData(data map[string]interface{}) *Message {
+	m.Lock()
+	defer m.Unlock()
+	if data == nil {
+		m.defaultData = nil
+		return m
+	}
+	m.defaultData = make(map[string]interface{}, len(data))
+	for k, v := range data {
+		m.defaultData[k] = v
+	}
+	return m
+}
+
+// mergeData returns the `data` merged over the default data of the receiver, if any and if
+// possible. The caller must hold the lock on the receiver.
+func (m *Message) mergeData(data interface{}) interface{} {
+	if len(m.defaultData) == 0 {
+		return data
+	}
+	if data == nil {
+		return m.defaultData
+	}
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return data
+	}
+	merged := make(map[string]interface{}, len(m.defaultData)+v.Len())
+	for k, val := range m.defaultData {
+		merged[k] = val
+	}
+	for iter := v.MapRange(); iter.Next(); {
+		merged[iter.Key().String()] = iter.Value().Interface()
+	}
+	return merged
+}
+
+// dataField returns the value of the `field` of the template `data` - a map with string keys, or
+// a struct, or a pointer to either - or the zero Value if there is no such field.
+func dataField(data interface{}, field string) reflect.Value {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}
+		}
+		return v.MapIndex(reflect.ValueOf(field).Convert(v.Type().Key()))
+	case reflect.Struct:
+		return v.FieldByName(field)
+	}
+	return reflect.Value{}
+}
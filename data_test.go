@@ -0,0 +1,34 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_DefaultData(t *testing.T) {
+	defaults := map[string]interface{}{"company": "Acme", "support": "https://example.com/help"}
+	base := NewMessage(nil).From(&Address{"", "test@example.com"}).To(&Address{"", "to@example.com"}).
+		SubjectTemplate("{{.company}} news").TextTemplate("Hi {{.name}}, see {{.support}}").DefaultData(defaults)
+	defaults["company"] = "Changed"
+	cases := []struct {
+		data interface{}
+		exp  []string
+	}{
+		{nil, []string{"Subject: Acme news", "Hi <no value>, see https://example.com/help"}},
+		{map[string]interface{}{"name": "Ann"}, []string{"Subject: Acme news", "Hi Ann, see https://example.com/help"}},
+		{map[string]string{"name": "Bob", "company": "Other"}, []string{"Subject: Other news", "Hi Bob"}},
+	}
+	for i, c := range cases {
+		msg := NewMessage(base)
+		act := string(msg.Compose(c.data))
+		for _, exp := range c.exp {
+			if !strings.Contains(act, exp) {
+				t.Errorf("(*Message).DefaultData [%d]: got %q, missing %q", i, act, exp)
+			}
+		}
+	}
+	data := struct{ Name string }{"Cid"}
+	if act := base.mergeData(data); act != data {
+		t.Errorf("(*Message).mergeData: got %v want %v", act, data)
+	}
+}
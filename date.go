@@ -0,0 +1,178 @@
+package email
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatDate formats `t` for use in a Date header, as specified by RFC 5322 section 3.3.
+func FormatDate(t time.Time) string {
+	return t.Format(time.RFC1123Z)
+}
+
+var (
+	months = map[string]time.Month{
+		"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+		"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+		"sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+	}
+	// zone offsets in minutes; see RFC 5322 section 4.3, plus a few common extras
+	zones = map[string]int{
+		"ut": 0, "utc": 0, "gmt": 0, "z": 0,
+		"edt": -4 * 60, "est": -5 * 60, "cdt": -5 * 60, "cst": -6 * 60,
+		"mdt": -6 * 60, "mst": -7 * 60, "pdt": -7 * 60, "pst": -8 * 60,
+		"bst": 60, "cet": 60, "cest": 2 * 60, "eet": 2 * 60, "eest": 3 * 60,
+	}
+)
+
+// ParseDate parses the value of a Date header (or any other RFC 5322 date-time, like the ones in
+// Received headers), tolerating the common deviations found in real-world messages: missing or
+// full day names, full month names, missing seconds, two-digit years, comments, named and
+// military time zones, as well as the asctime and RFC 3339 formats.
+//
+// When the time zone is missing or unknown, UTC is assumed.
+func ParseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	var (
+		day, year, hour, min, sec = -1, -1, -1, 0, 0
+		month                     time.Month
+		offset                    int
+		hasZone                   bool
+		yearDigits                int
+	)
+	invalid := func() (time.Time, error) {
+		return time.Time{}, errors.New("invalid date: " + value)
+	}
+	for _, tok := range strings.Fields(strings.NewReplacer(",", " ").Replace(stripComments(value))) {
+		switch c := tok[0]; {
+		case strings.IndexByte(tok, ':') > 0 && c >= '0' && c <= '9' && hour < 0:
+			parts := strings.Split(tok, ":")
+			if len(parts) > 3 {
+				return invalid()
+			}
+			vals := [3]int{}
+			for i, p := range parts {
+				v, err := strconv.Atoi(p)
+				if err != nil {
+					return invalid()
+				}
+				vals[i] = v
+			}
+			hour, min, sec = vals[0], vals[1], vals[2]
+		case (c == '+' || c == '-') && len(tok) > 1 && !hasZone:
+			digits := strings.Replace(tok[1:], ":", "", 1)
+			v, err := strconv.Atoi(digits)
+			if err != nil || len(digits) != 4 && len(digits) != 2 {
+				return invalid()
+			}
+			if len(digits) == 2 {
+				v *= 100
+			}
+			offset = v/100*60 + v%100
+			if c == '-' {
+				offset = -offset
+			}
+			hasZone = true
+		case c >= '0' && c <= '9':
+			v, err := strconv.Atoi(tok)
+			if err != nil {
+				return invalid()
+			}
+			if day < 0 && len(tok) <= 2 {
+				day = v
+			} else if year < 0 {
+				year, yearDigits = v, len(tok)
+			} else {
+				return invalid()
+			}
+		default:
+			lower := strings.ToLower(strings.TrimSuffix(tok, "."))
+			if m, ok := months[lower]; ok && month == 0 {
+				month = m
+			} else if m, ok := months[prefix3(lower)]; ok && month == 0 && len(lower) > 3 && isMonthName(lower) {
+				month = m
+			} else if isDayName(lower) {
+				// ignore the day of the week
+			} else if hour >= 0 && !hasZone {
+				// named zone; unknown names, including military ones, mean UTC (RFC 5322 section 4.3)
+				offset = zones[lower]
+				hasZone = true
+			} else {
+				return invalid()
+			}
+		}
+	}
+	if day < 1 || day > 31 || month == 0 || year < 0 || hour > 23 || min > 59 || sec > 60 {
+		return invalid()
+	}
+	switch {
+	case yearDigits <= 2 && year < 50:
+		year += 2000
+	case yearDigits <= 3 && year < 1000:
+		year += 1900
+	}
+	if hour < 0 {
+		hour = 0
+	}
+	t := time.Date(year, month, day, hour, min, sec, 0, time.FixedZone("", offset*60))
+	if t.Day() != day {
+		return invalid()
+	}
+	return t, nil
+}
+
+// stripComments removes the (possibly nested) parenthesized comments from a header value.
+func stripComments(value string) string {
+	if strings.IndexByte(value, '(') < 0 {
+		return value
+	}
+	var (
+		buf   strings.Builder
+		depth int
+	)
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '\\' && depth > 0:
+			i++
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+			if depth == 0 {
+				buf.WriteByte(' ')
+			}
+		case depth == 0:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+func prefix3(s string) string {
+	if len(s) > 3 {
+		return s[:3]
+	}
+	return s
+}
+
+func isMonthName(s string) bool {
+	switch s {
+	case "january", "february", "march", "april", "june", "july", "august",
+		"september", "sept", "october", "november", "december":
+		return true
+	}
+	return false
+}
+
+func isDayName(s string) bool {
+	switch prefix3(s) {
+	case "mon", "tue", "wed", "thu", "fri", "sat", "sun":
+		return len(s) == 3 || strings.HasSuffix(s, "day") || s == "tues" || s == "thur" || s == "thurs"
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package email
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ParseDate(t *testing.T) {
+	exp := time.Date(2013, 8, 30, 9, 10, 11, 0, time.UTC)
+	cases := []struct {
+		src string
+		exp time.Time
+	}{
+		{"Fri, 30 Aug 2013 09:10:11 +0000", exp},
+		{"30 Aug 2013 09:10:11 +0000", exp},
+		{"Fri,  30  Aug  2013  09:10:11  +0000 (UTC)", exp},
+		{"Friday, 30 August 2013 09:10:11 GMT", exp},
+		{"Fri, 30 Aug 13 09:10:11 UT", exp},
+		{"Fri, 30 Aug 113 09:10:11 Z", exp},
+		{"Fri, 30 Aug 2013 11:10:11 +02:00", exp},
+		{"Fri, 30 Aug 2013 05:10:11 EDT", exp},
+		{"Fri, 30 Aug 2013 09:10:11 A", exp},
+		{"Fri, 30 Aug 2013 09:10:11", exp},
+		{"Fri, 30 Aug 2013 09:10 +0000", exp.Add(-11 * time.Second)},
+		{"Fri Aug 30 09:10:11 2013", exp},
+		{"2013-08-30T09:10:11Z", exp},
+		{"(sent) Fri, 30 Aug 2013 09:10:11 -0100 (comment (nested))", exp.Add(time.Hour)},
+	}
+	for i, c := range cases {
+		act, err := ParseDate(c.src)
+		if err != nil {
+			t.Errorf("ParseDate [%d]: unexpected error: %v", i, err)
+		} else if !act.Equal(c.exp) {
+			t.Errorf("ParseDate [%d]: got %v, want %v", i, act, c.exp)
+		}
+	}
+	for _, src := range []string{"", "yesterday", "31 Feb 2013 09:10:11 +0000", "30 Foo 2013 09:10:11", "30 Aug 2013 25:10:11"} {
+		if _, err := ParseDate(src); err == nil {
+			t.Errorf("ParseDate(%q): expected an error", src)
+		}
+	}
+	if act := FormatDate(exp); act != "Fri, 30 Aug 2013 09:10:11 +0000" {
+		t.Errorf("FormatDate: got %q", act)
+	}
+}
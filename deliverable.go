@@ -0,0 +1,113 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeliverableCacheTTL is the time the results of CheckDeliverable are cached for, by domain.
+var DeliverableCacheTTL = 10 * time.Minute
+
+// MXResolver looks up the DNS records needed by CheckDeliverable; it is implemented by
+// *net.Resolver.
+type MXResolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// ErrUndeliverable is returned by CheckDeliverable when the domain of an address does not accept
+// mail.
+type ErrUndeliverable struct {
+	// Domain is the domain of the address, in ASCII form.
+	Domain string
+	// Reason describes why the domain does not accept mail.
+	Reason string
+}
+
+func (e *ErrUndeliverable) Error() string {
+	return "email: domain " + e.Domain + " does not accept mail: " + e.Reason
+}
+
+// deliverableResult is a cached result of CheckDeliverable.
+type deliverableResult struct {
+	err     error
+	expires time.Time
+}
+
+var (
+	deliverableMu    sync.Mutex
+	deliverableCache = map[string]deliverableResult{}
+)
+
+// CheckDeliverable verifies that the domain of the receiver accepts mail: that it has MX records -
+// other than a "null MX", as defined by RFC 7505 - or else an A or AAAA record, using the
+// `resolver`, or net.DefaultResolver if nil. It is meant for rejecting obviously undeliverable
+// addresses - e.g. in signup flows - before queuing mail; it does not tell whether the mailbox
+// exists.
+//
+// It returns an *ErrUndeliverable if the domain does not accept mail, or the error of the lookup,
+// if it fails - e.g. on timeout, or when the `ctx` is done. The definitive results are cached for
+// DeliverableCacheTTL, regardless of the resolver. CheckDeliverable is safe for concurrent use, so
+// the checks can be run in the background.
+func (a *Address) CheckDeliverable(ctx context.Context, resolver MXResolver) error {
+	domain := strings.ToLower(a.Domain())
+	if domain == "" {
+		return &ErrInvalidAddr{Addr: a.Addr}
+	}
+	if domain[0] == '[' { // address literal
+		return nil
+	}
+	now := time.Now()
+	deliverableMu.Lock()
+	res, ok := deliverableCache[domain]
+	deliverableMu.Unlock()
+	if ok && now.Before(res.expires) {
+		return res.err
+	}
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	err := lookupDeliverable(ctx, resolver, domain)
+	var ue *ErrUndeliverable
+	if err == nil || errors.As(err, &ue) {
+		deliverableMu.Lock()
+		for d, r := range deliverableCache {
+			if !now.Before(r.expires) {
+				delete(deliverableCache, d)
+			}
+		}
+		deliverableCache[domain] = deliverableResult{err, now.Add(DeliverableCacheTTL)}
+		deliverableMu.Unlock()
+	}
+	return err
+}
+
+// lookupDeliverable looks up the records of the `domain` needed by CheckDeliverable.
+func lookupDeliverable(ctx context.Context, resolver MXResolver, domain string) error {
+	mxs, err := resolver.LookupMX(ctx, domain)
+	switch {
+	case err == nil && len(mxs) == 1 && strings.TrimSuffix(mxs[0].Host, ".") == "":
+		return &ErrUndeliverable{domain, "null MX record"}
+	case err == nil && len(mxs) > 0:
+		return nil
+	case err != nil && !isNotFound(err):
+		return err
+	}
+	if _, err = resolver.LookupHost(ctx, domain); err != nil {
+		if isNotFound(err) {
+			return &ErrUndeliverable{domain, "no MX or address records"}
+		}
+		return err
+	}
+	return nil
+}
+
+// isNotFound reports whether the DNS lookup error `err` means that the records do not exist.
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
@@ -0,0 +1,75 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+type testResolver struct {
+	mx      map[string][]*net.MX
+	hosts   map[string][]string
+	lookups int
+}
+
+func (r *testResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	r.lookups++
+	if name == "timeout.example" {
+		return nil, &net.DNSError{Err: "i/o timeout", Name: name, IsTimeout: true}
+	}
+	if mx, ok := r.mx[name]; ok {
+		return mx, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *testResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := r.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func Test_CheckDeliverable(t *testing.T) {
+	r := &testResolver{
+		mx: map[string][]*net.MX{
+			"mx.deliverable.example":     {{Host: "mail.deliverable.example.", Pref: 10}},
+			"nullmx.deliverable.example": {{Host: ".", Pref: 0}},
+			"xn--bcher-kva.example":      {{Host: "mail.xn--bcher-kva.example.", Pref: 10}},
+		},
+		hosts: map[string][]string{
+			"a.deliverable.example": {"192.0.2.1"},
+		},
+	}
+	cases := []struct {
+		addr        string
+		undelivered bool
+		err         bool
+	}{
+		{"ann@mx.deliverable.example", false, false},
+		{"ann@MX.Deliverable.example", false, false},
+		{"ann@a.deliverable.example", false, false},
+		{"ann@bücher.example", false, false},
+		{"ann@[192.0.2.1]", false, false},
+		{"ann@nullmx.deliverable.example", true, true},
+		{"ann@none.deliverable.example", true, true},
+		{"ann@timeout.example", false, true},
+		{"ann", false, true},
+	}
+	for i, c := range cases {
+		err := (&Address{"", c.addr}).CheckDeliverable(context.Background(), r)
+		var ue *ErrUndeliverable
+		if (err != nil) != c.err || errors.As(err, &ue) != c.undelivered {
+			t.Errorf("(*Address).CheckDeliverable [%d]: got %v", i, err)
+		}
+	}
+
+	n := r.lookups
+	for _, addr := range []string{"bob@mx.deliverable.example", "bob@none.deliverable.example", "bob@timeout.example"} {
+		(&Address{"", addr}).CheckDeliverable(context.Background(), r)
+	}
+	if r.lookups != n+1 {
+		t.Errorf("(*Address).CheckDeliverable: got %d lookups want 1", r.lookups-n)
+	}
+}
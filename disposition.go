@@ -0,0 +1,74 @@
+package email
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Disposition sets the parameters of the Content-Disposition header of an attachment, as defined by
+// RFC 2183 - which some archiving systems rely on.
+type Disposition struct {
+	// Inline asks for the attachment to be displayed automatically, within the message, rather than
+	// only on request.
+	Inline bool
+	// Created, Modified and Read are the dates of the attached content, if not zero.
+	Created, Modified, Read time.Time
+	// FileDates sets the modification date to the one of the attached file, unless set explicitly.
+	FileDates bool
+	// Size includes the size of the attached content, in bytes.
+	Size bool
+}
+
+// Disposition sets the Content-Disposition parameters of the most recently added attachment of the
+// message, e.g.
+//
+//	msg.AttachFile("report.pdf", "application/pdf", "out/report.pdf").Disposition(Disposition{Size: true})
+func (m *Message) Disposition(d Disposition) *Message {
+	m.Lock()
+	defer m.Unlock()
+	if len(m.attachments) == 0 {
+		m.fail(ArgumentError, "disposition", errors.New("no attachment to set the disposition of"))
+		return m
+	}
+	a := *m.attachments[len(m.attachments)-1]
+	a.disposition = &d
+	m.attachments[len(m.attachments)-1] = &a
+	return m
+}
+
+// dispositionHeader returns the Content-Disposition header for the attachment `a`.
+func dispositionHeader(a *attachment) []byte {
+	d := a.disposition
+	if d == nil {
+		d = &Disposition{}
+	}
+	dst := []byte("Content-Disposition: ")
+	if d.Inline {
+		dst = append(dst, "inline"...)
+	} else {
+		dst = append(dst, "attachment"...)
+	}
+	dst = append(dst, filenameParams(a.name)...)
+	modified := d.Modified
+	if modified.IsZero() && d.FileDates {
+		modified = a.modTime
+	}
+	for _, p := range []struct {
+		name string
+		t    time.Time
+	}{{"creation-date", d.Created}, {"modification-date", modified}, {"read-date", d.Read}} {
+		if !p.t.IsZero() {
+			dst = append(append(append(dst, ";\r\n\t"...), p.name...), `="`...)
+			dst = append(append(dst, FormatDate(p.t)...), '"')
+		}
+	}
+	if d.Size {
+		size := int64(len(a.data))
+		if a.size > 0 && a.data == nil {
+			size = a.size
+		}
+		dst = append(append(dst, ";\r\n\tsize="...), strconv.FormatInt(size, 10)...)
+	}
+	return append(dst, '\r', '\n')
+}
@@ -0,0 +1,43 @@
+package email
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_dispositionHeader(t *testing.T) {
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	cases := []struct {
+		a   *attachment
+		exp string
+	}{
+		{&attachment{name: "a.txt", data: []byte("abc")},
+			"Content-Disposition: attachment;\r\n\tfilename=\"a.txt\"\r\n"},
+		{&attachment{name: "a.png", data: []byte("abc"), disposition: &Disposition{Inline: true, Size: true}},
+			"Content-Disposition: inline;\r\n\tfilename=\"a.png\";\r\n\tsize=3\r\n"},
+		{&attachment{name: "a.txt", size: 10, modTime: at, disposition: &Disposition{Created: at, FileDates: true, Size: true}},
+			"Content-Disposition: attachment;\r\n\tfilename=\"a.txt\";\r\n\tcreation-date=\"Thu, 02 Jan 2020 03:04:05 +0000\";\r\n" +
+				"\tmodification-date=\"Thu, 02 Jan 2020 03:04:05 +0000\";\r\n\tsize=10\r\n"},
+		{&attachment{name: "a.txt", disposition: &Disposition{Read: at}},
+			"Content-Disposition: attachment;\r\n\tfilename=\"a.txt\";\r\n\tread-date=\"Thu, 02 Jan 2020 03:04:05 +0000\"\r\n"},
+	}
+	for i, c := range cases {
+		if act := string(dispositionHeader(c.a)); act != c.exp {
+			t.Errorf("dispositionHeader [%d]: got\n%q\nwant\n%q", i, act, c.exp)
+		}
+	}
+}
+
+func Test_Disposition(t *testing.T) {
+	base := NewMessage(nil).AttachObject("a.txt", "text/plain", []byte("a"))
+	msg := NewMessage(base).Disposition(Disposition{Inline: true})
+	if msg.attachments[0].disposition == nil || !msg.attachments[0].disposition.Inline {
+		t.Error("(*Message).Disposition: got no disposition")
+	}
+	if base.attachments[0].disposition != nil {
+		t.Error("(*Message).Disposition: base message changed")
+	}
+	if msg = NewMessage(nil).Disposition(Disposition{}); len(msg.Errors()) == 0 {
+		t.Error("(*Message).Disposition: expected an error without attachments")
+	}
+}
@@ -0,0 +1,28 @@
+package email
+
+// DryRunMode represents the extent to which a Sender goes through the motions of sending a
+// message, without actually sending it.
+type DryRunMode byte
+
+const (
+	// NoDryRun indicates that messages are actually sent.
+	NoDryRun DryRunMode = iota
+	// DryRunCompose indicates that messages are fully composed and validated, but the SMTP
+	// server is not contacted at all.
+	DryRunCompose
+	// DryRunNegotiate indicates that, after composition, the SMTP server is contacted and the
+	// envelope is negotiated (MAIL and RCPT commands), but RSET is issued instead of DATA.
+	DryRunNegotiate
+)
+
+// DryRun sets the dry-run mode of the receiver, useful for pre-flight checks in staging
+// environments with production configurations.
+//
+// In a dry-run mode, the metrics collector is not notified of sent messages, but failures are
+// reported as usual; the log records have an extra "dry_run" field set to true.
+func (s *Sender) DryRun(mode DryRunMode) *Sender {
+	s.mu.Lock()
+	s.dryRun = mode
+	s.mu.Unlock()
+	return s
+}
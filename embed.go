@@ -0,0 +1,153 @@
+package email
+
+import (
+	"errors"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Embed adds an inline item - typically an image - to be displayed within the HTML body of the
+// message, rather than listed as an attachment. The `src` is either the content of the item, as a
+// []byte, or the filesystem path of a file, as a string; for a file, an empty `ctype` is derived
+// from its extension.
+//
+// The `name` is used as the Content-ID of the item, so the HTML body can reference it as a "cid:"
+// URL - e.g. Embed("logo.png", "image/png", data) and <img src="cid:logo.png">. Unless the name
+// already has a domain part, the domain of the message is appended to it in the Content-ID, as
+// required by RFC 2392, and the "cid:" URLs in the HTML body are updated accordingly. Embedding
+// requires an HTML body, set with Html, HtmlTemplate or Templates.
+func (m *Message) Embed(name, ctype string, src interface{}) *Message {
+	m.Lock()
+	defer m.Unlock()
+	if name == "" || strings.ContainsAny(name, "<>\"\\ \t\r\n") {
+		m.failAttachment(ArgumentError, name, errors.New("invalid embedded item name: "+name))
+		return m
+	}
+	r := Related{id: name, ctype: ctype, inline: true}
+	switch src := src.(type) {
+	case []byte:
+		r.data = src
+	case string:
+		r.fileName = src
+		if r.ctype == "" {
+			r.ctype = mime.TypeByExtension(filepath.Ext(src))
+		}
+		m.prepared = false
+	default:
+		m.failAttachment(ArgumentError, name, ErrInvalidArgument)
+		return m
+	}
+	if r.ctype == "" {
+		r.ctype = "application/octet-stream"
+	}
+	embeds := make([]Related, 0, len(m.embeds)+1)
+	m.embeds = append(append(embeds, m.embeds...), r)
+	return m
+}
+
+// partRelated returns the related items of the part `p` of the receiver, including the embedded
+// items and the remote images being embedded for the HTML part.
+func (m *Message) partRelated(p *part) []Related {
+	if p != m.html || len(m.embeds)+len(m.remote) == 0 {
+		return p.related
+	}
+	related := make([]Related, 0, len(p.related)+len(m.embeds)+len(m.remote))
+	return append(append(append(related, p.related...), m.embeds...), m.remote...)
+}
+
+// relatedContentIDs returns the Content-IDs of the `related` items of the part numbered `pn`, in the
+// message with the unique id `uid` and the `domain`. The embedded items keep their names as
+// Content-IDs, with the `domain` appended to those without one, while the other items get ids
+// unique to the message.
+func relatedContentIDs(related []Related, pn, uid, domain string) []string {
+	cids := make([]string, len(related))
+	for i, r := range related {
+		switch {
+		case r.inline && strings.Contains(r.id, "@"):
+			cids[i] = r.id
+		case r.inline:
+			cids[i] = r.id + "@" + domain
+		default:
+			cids[i] = "r" + pn + "." + strconv.Itoa(i) + "." + uid + "@" + domain
+		}
+	}
+	return cids
+}
+
+// substituteContentIDs replaces the references to the `related` items in the `body` with "cid:" URLs
+// for the `cids`: both the quoted or parenthesized "cid:" URLs using the ids of the items, and the
+// values of the URL attributes - like src or href - or of the CSS url() functions consisting of
+// just an id - e.g. src="cid:logo", src="logo" and url(logo) all refer to the item with the id
+// "logo", while alt="logo" is left alone.
+// The embedded items are only referenced by "cid:" URLs, which are left alone if they already use
+// their Content-IDs.
+func substituteContentIDs(body []byte, related []Related, cids []string) []byte {
+	var oldnew []string
+	for i, r := range related {
+		if r.id == "" || r.id == cids[i] {
+			continue
+		}
+		for _, q := range []string{`"`, `'`} {
+			oldnew = append(oldnew, q+"cid:"+r.id+q, q+"cid:"+cids[i]+q)
+		}
+		oldnew = append(oldnew, "(cid:"+r.id+")", "(cid:"+cids[i]+")")
+	}
+	if len(oldnew) == 0 {
+		return body
+	}
+	s := strings.NewReplacer(oldnew...).Replace(string(body))
+	for i, r := range related {
+		if !r.inline && r.id != "" {
+			s = substituteBareID(s, r.id, "cid:"+cids[i])
+		}
+	}
+	return []byte(s)
+}
+
+// substituteBareID replaces with `url` the quoted values of the URL attributes, and the arguments of
+// the CSS url() functions, that consist of just the `id` in `s`.
+func substituteBareID(s, id, url string) string {
+	var b strings.Builder
+	for _, delims := range []string{`""`, `''`, "()"} {
+		b.Reset()
+		old, last := delims[:1]+id+delims[1:], 0
+		for i := strings.Index(s, old); i >= 0; i = strings.Index(s[last:], old) {
+			i += last
+			prefix := s[:i]
+			if delims == "()" && hasSuffixFold(prefix, "url") || delims != "()" && isURLValue(prefix) {
+				b.WriteString(s[last:i])
+				b.WriteString(delims[:1] + url + delims[1:])
+			} else {
+				b.WriteString(s[last : i+len(old)])
+			}
+			last = i + len(old)
+		}
+		if last > 0 {
+			b.WriteString(s[last:])
+			s = b.String()
+		}
+	}
+	return s
+}
+
+// isURLValue reports whether the text preceding a quoted string ends with the name of a URL
+// attribute and an equals sign, or with the opening of a CSS url() function.
+func isURLValue(prefix string) bool {
+	prefix = strings.TrimRight(prefix, " \t\r\n")
+	if hasSuffixFold(prefix, "url(") {
+		return true
+	}
+	if !strings.HasSuffix(prefix, "=") {
+		return false
+	}
+	prefix = strings.TrimRight(prefix[:len(prefix)-1], " \t\r\n")
+	i := strings.LastIndexAny(prefix, " \t\r\n\"'/<")
+	return urlAttributes[strings.ToLower(prefix[i+1:])]
+}
+
+// hasSuffixFold reports whether `s` ends with `suffix`, ignoring case.
+func hasSuffixFold(s, suffix string) bool {
+	return len(s) >= len(suffix) && strings.EqualFold(s[len(s)-len(suffix):], suffix)
+}
@@ -0,0 +1,109 @@
+package email
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Embed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "email-embed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "logo.png")
+	if err = ioutil.WriteFile(file, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	msg := QuickMessage("test").From(&Address{"", "test@example.com"}).
+		Html(`<img src="cid:logo.png"><img src="cid:dot"><img src='cid:chart@img.example.com'>`).
+		Embed("logo.png", "", file).Embed("dot", "image/gif", []byte("gif")).
+		Embed("chart@img.example.com", "image/svg+xml", []byte("svg"))
+	e, err := ReadEntity(bytes.NewReader(msg.Compose(nil)), nil)
+	if err != nil {
+		t.Fatalf("(*Message).Embed: got error %v", err)
+	}
+	var (
+		items   []*Entity
+		html    string
+		collect func(e *Entity)
+	)
+	collect = func(e *Entity) {
+		if e.Header.Get("Content-ID") != "" {
+			items = append(items, e)
+		}
+		if e.MediaType == "text/html" {
+			html = string(e.Body)
+		}
+		for _, p := range e.Parts {
+			collect(p)
+		}
+	}
+	collect(e)
+	exp := []struct{ id, ctype, body string }{
+		{"<logo.png@example.com>", "image/png", "png"},
+		{"<dot@example.com>", "image/gif", "gif"},
+		{"<chart@img.example.com>", "image/svg+xml", "svg"},
+	}
+	if len(items) != len(exp) {
+		t.Fatalf("(*Message).Embed: got %d inline items, want %d", len(items), len(exp))
+	}
+	for i, item := range items {
+		if item.Header.Get("Content-ID") != exp[i].id || item.MediaType != exp[i].ctype ||
+			string(item.Body) != exp[i].body || !strings.HasPrefix(item.Header.Get("Content-Disposition"), "inline;") {
+			t.Errorf("(*Message).Embed [%d]: got %v %q", i, item.Header, item.Body)
+		}
+	}
+	if exp := `<img src="cid:logo.png@example.com"><img src="cid:dot@example.com"><img src='cid:chart@img.example.com'>`; strings.TrimSpace(html) != exp {
+		t.Errorf("(*Message).Embed: got HTML %q want %q", html, exp)
+	}
+
+	if msg = NewMessage(nil).Embed("bad name", "image/png", []byte("x")); len(msg.Errors()) == 0 {
+		t.Error("(*Message).Embed: expected an error for an invalid name")
+	}
+	msg = QuickMessage("test", "text").From(&Address{"", "test@example.com"}).Embed("dot", "image/gif", []byte("gif"))
+	if msg.Compose(nil); len(msg.Errors()) == 0 {
+		t.Error("(*Message).Embed: expected an error without an HTML body")
+	}
+}
+
+func Test_substituteContentIDs(t *testing.T) {
+	related := []Related{{id: "logo"}, {id: "bg"}, {id: "embed", inline: true}}
+	cids := relatedContentIDs(related, "1", "uid", "example.com")
+	if exp := []string{"r1.0.uid@example.com", "r1.1.uid@example.com", "embed@example.com"}; strings.Join(cids, " ") != strings.Join(exp, " ") {
+		t.Errorf("relatedContentIDs: got %v want %v", cids, exp)
+	}
+	cases := []struct {
+		body, exp string
+	}{
+		{`<img src="cid:logo">`, `<img src="cid:r1.0.uid@example.com">`},
+		{`<img src='logo' alt="logo">`, `<img src='cid:r1.0.uid@example.com' alt="logo">`},
+		{`<img class="logo" title='logo' SRC = "logo">`, `<img class="logo" title='logo' SRC = "cid:r1.0.uid@example.com">`},
+		{`<a href="logo">logo</a><img data-src="logo">`, `<a href="cid:r1.0.uid@example.com">logo</a><img data-src="logo">`},
+		{`<td style="background:url(cid:bg)">`, `<td style="background:url(cid:r1.1.uid@example.com)">`},
+		{`<td background="bg" style="background:url(bg)" class="bg">`,
+			`<td background="cid:r1.1.uid@example.com" style="background:url(cid:r1.1.uid@example.com)" class="bg">`},
+		{`<div style="background: URL('bg')">(bg)</div>`, `<div style="background: URL('cid:r1.1.uid@example.com')">(bg)</div>`},
+		{`<img src="cid:logo2"> logo <img src="cid:embed" alt="embed">`,
+			`<img src="cid:logo2"> logo <img src="cid:embed@example.com" alt="embed">`},
+		{`<img src="embed"><img src="cid:embed@example.com">`, `<img src="embed"><img src="cid:embed@example.com">`},
+	}
+	for i, c := range cases {
+		if act := string(substituteContentIDs([]byte(c.body), related, cids)); act != c.exp {
+			t.Errorf("substituteContentIDs [%d]: got %q want %q", i, act, c.exp)
+		}
+	}
+
+	msg := QuickMessage("test").From(&Address{"", "test@example.com"}).IDs(FixedID("uid")).
+		Html(`<img src="cid:logo">`, RelatedObject("logo", "image/png", []byte("png")))
+	body := msg.Compose(nil)
+	for _, exp := range []string{"Content-ID: <r0.0.uid@example.com>\r\n", "cid:r0.0.uid@example.com"} {
+		if !bytes.Contains(body, []byte(exp)) {
+			t.Errorf("(*Message).Compose: got\n%s\nwant %q", body, exp)
+		}
+	}
+}
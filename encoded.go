@@ -0,0 +1,44 @@
+package email
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+// encodedCacheSeed is the seed of the hashes identifying the content of the cached attachments.
+var encodedCacheSeed = maphash.MakeSeed()
+
+// encodedCache holds the base64 encoding of the data of an attachment, and the record of its last
+// successful scan - see ScanAttachments. Since the attachments are shared by the copies of a message
+// made with NewMessage or Clone, the data is encoded and scanned only once for all of them - e.g.
+// for bulk sends - as long as its content does not change.
+type encodedCache struct {
+	sync.Mutex
+	sum     uint64
+	size    int
+	data    []byte
+	scanned scanRecord
+}
+
+// base64 returns the base64 encoding of the data of the attachment, from its cache if possible.
+func (a *attachment) base64() []byte {
+	c := a.encoded
+	if c == nil {
+		return Base64Encode(a.data)
+	}
+	sum := a.sum()
+	c.Lock()
+	defer c.Unlock()
+	if c.data == nil || c.sum != sum || c.size != len(a.data) {
+		c.data, c.sum, c.size = Base64Encode(a.data), sum, len(a.data)
+	}
+	return c.data
+}
+
+// sum returns the hash identifying the content of the data of the attachment.
+func (a *attachment) sum() uint64 {
+	var h maphash.Hash
+	h.SetSeed(encodedCacheSeed)
+	h.Write(a.data)
+	return h.Sum64()
+}
@@ -0,0 +1,28 @@
+package email
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_EncodedCache(t *testing.T) {
+	base := QuickMessage("test", "body").From(&Address{"", "test@example.com"}).
+		AttachObject("a.bin", "application/octet-stream", []byte("first content"))
+	first := NewMessage(base).Compose(nil)
+	a := base.attachments[0]
+	cached := a.encoded.data
+	if !bytes.Equal(cached, Base64Encode([]byte("first content"))) {
+		t.Fatalf("(*attachment).base64: got cache %q", cached)
+	}
+	if second := NewMessage(base).Compose(nil); !bytes.Contains(second, cached) || &a.encoded.data[0] != &cached[0] {
+		t.Errorf("(*attachment).base64: the encoding was not reused")
+	}
+	a.data = []byte("other content")
+	third := NewMessage(base).Compose(nil)
+	if exp := Base64Encode([]byte("other content")); !bytes.Contains(third, exp) || bytes.Contains(third, cached) {
+		t.Errorf("(*attachment).base64: got stale encoding in %q", third)
+	}
+	if !bytes.Contains(first, cached) {
+		t.Errorf("(*Message).Compose: got %q", first)
+	}
+}
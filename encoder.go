@@ -0,0 +1,191 @@
+package email
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+)
+
+// errEncoderClosed is returned when writing to a closed encoder.
+var errEncoderClosed = errors.New("email: write to closed encoder")
+
+// Base64Encoder is an io.WriteCloser that base64-encodes the data written to it, in lines of 76
+// characters separated by CRLF, writing the result to an underlying writer as it goes - so the
+// output is identical to that of Base64Encode, without holding the whole data in memory.
+//
+// Close must be called to flush the last, partial group of bytes; it does not close the underlying
+// writer.
+type Base64Encoder struct {
+	w      io.Writer
+	buf    [3]byte
+	nbuf   int
+	line   int
+	out    []byte
+	err    error
+	closed bool
+}
+
+// NewBase64Encoder creates a Base64Encoder writing to `w`.
+func NewBase64Encoder(w io.Writer) *Base64Encoder {
+	return &Base64Encoder{w: w}
+}
+
+// Write encodes `p`, writing the complete groups of bytes to the underlying writer.
+func (e *Base64Encoder) Write(p []byte) (n int, err error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	if e.closed {
+		return 0, errEncoderClosed
+	}
+	n = len(p)
+	if e.nbuf > 0 {
+		for len(p) > 0 && e.nbuf < 3 {
+			e.buf[e.nbuf] = p[0]
+			e.nbuf++
+			p = p[1:]
+		}
+		if e.nbuf < 3 {
+			return n, nil
+		}
+		e.encode(e.buf[:])
+		e.nbuf = 0
+	}
+	full := len(p) / 3 * 3
+	e.encode(p[:full])
+	e.nbuf = copy(e.buf[:], p[full:])
+	return n, e.flush()
+}
+
+// Close encodes the remaining bytes, with padding, and writes them to the underlying writer.
+func (e *Base64Encoder) Close() error {
+	if e.closed || e.err != nil {
+		return e.err
+	}
+	e.closed = true
+	if e.nbuf > 0 {
+		e.encode(e.buf[:e.nbuf])
+		e.nbuf = 0
+	}
+	return e.flush()
+}
+
+// encode appends the encoding of `src` to the output, breaking the lines as needed.
+func (e *Base64Encoder) encode(src []byte) {
+	var enc [4 * 19]byte // 57 bytes of input encode to a full line
+	for len(src) > 0 {
+		chunk := src
+		if len(chunk) > 57 {
+			chunk = chunk[:57]
+		}
+		src = src[len(chunk):]
+		el := base64.StdEncoding.EncodedLen(len(chunk))
+		base64.StdEncoding.Encode(enc[:], chunk)
+		for _, c := range enc[:el] {
+			if e.line == 76 {
+				e.out = append(e.out, '\r', '\n')
+				e.line = 0
+			}
+			e.out = append(e.out, c)
+			e.line++
+		}
+	}
+}
+
+// flush writes the output to the underlying writer.
+func (e *Base64Encoder) flush() error {
+	if len(e.out) > 0 {
+		_, e.err = e.w.Write(e.out)
+		e.out = e.out[:0]
+	}
+	return e.err
+}
+
+// QPEncoder is an io.WriteCloser that encodes the data written to it as quoted-printable, in lines
+// of at most 76 characters, writing the result to an underlying writer as it goes - so the output
+// is identical to that of QuotedPrintableEncode, including keeping UTF-8 multi-byte characters on
+// the same line, without holding the whole data in memory.
+//
+// Close must be called to flush the last character; it does not close the underlying writer.
+type QPEncoder struct {
+	w      io.Writer
+	pos    int
+	char   []byte // the encoding of the pending multi-byte character, if any
+	eis    bool   // does the encoded text end in a whitespace?
+	out    []byte
+	err    error
+	closed bool
+}
+
+// NewQPEncoder creates a QPEncoder writing to `w`.
+func NewQPEncoder(w io.Writer) *QPEncoder {
+	return &QPEncoder{w: w, char: make([]byte, 0, 12)}
+}
+
+// Write encodes `p`, writing the complete characters to the underlying writer.
+func (e *QPEncoder) Write(p []byte) (n int, err error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	if e.closed {
+		return 0, errEncoderClosed
+	}
+	for _, c := range p {
+		if len(e.char) > 0 {
+			if c&0xC0 == 0x80 {
+				e.char = append(e.char, '=', hextable[c>>4], hextable[c&0x0f])
+				continue
+			}
+			e.emit(e.char, false)
+			e.char = e.char[:0]
+		}
+		switch {
+		case c == '\t', c == ' ':
+			e.emit([]byte{c}, true)
+		case '!' <= c && c <= '~' && c != '=':
+			e.emit([]byte{c}, false)
+		case c&0xC0 == 0xC0:
+			// start of utf-8 rune; subsequent bytes always have the top two bits set to 10.
+			e.char = append(e.char, '=', hextable[c>>4], hextable[c&0x0f])
+		default:
+			e.emit([]byte{'=', hextable[c>>4], hextable[c&0x0f]}, false)
+		}
+	}
+	if len(e.out) > 0 {
+		_, e.err = e.w.Write(e.out)
+		e.out = e.out[:0]
+	}
+	if e.err != nil {
+		return 0, e.err
+	}
+	return len(p), nil
+}
+
+// Close encodes the pending character, if any, and terminates the output with a soft line break
+// if it ends in whitespace.
+func (e *QPEncoder) Close() error {
+	if e.closed || e.err != nil {
+		return e.err
+	}
+	e.closed = true
+	if len(e.char) > 0 {
+		e.emit(e.char, false)
+	}
+	if e.eis {
+		e.out = append(e.out, '=')
+	}
+	if len(e.out) > 0 {
+		_, e.err = e.w.Write(e.out)
+	}
+	return e.err
+}
+
+// emit appends the encoded character `enc` to the output, breaking the line as needed.
+func (e *QPEncoder) emit(enc []byte, eis bool) {
+	if e.pos += len(enc); e.pos > 75 { // max 76; need room for '='
+		e.out = append(e.out, '=', '\r', '\n')
+		e.pos = len(enc)
+	}
+	e.out = append(e.out, enc...)
+	e.eis = eis
+}
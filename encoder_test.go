@@ -0,0 +1,58 @@
+package email
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func Test_Encoders(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	binary := make([]byte, 5000)
+	rnd.Read(binary)
+	srcs := [][]byte{
+		{},
+		[]byte("a"),
+		[]byte("Hello, world "),
+		[]byte(strings.Repeat("Crème brûlée, s'il vous plaît. ", 40)),
+		[]byte(strings.Repeat("日本語のテキスト", 30) + "\t"),
+		binary,
+	}
+	for i, src := range srcs {
+		for _, size := range []int{1, 2, 7, 57, 1000, len(src) + 1} {
+			var b64, qp bytes.Buffer
+			be, qe := NewBase64Encoder(&b64), NewQPEncoder(&qp)
+			for rest := src; len(rest) > 0; {
+				n := size
+				if n > len(rest) {
+					n = len(rest)
+				}
+				if _, err := be.Write(rest[:n]); err != nil {
+					t.Fatalf("(*Base64Encoder).Write [%d]: got error %v", i, err)
+				}
+				if _, err := qe.Write(rest[:n]); err != nil {
+					t.Fatalf("(*QPEncoder).Write [%d]: got error %v", i, err)
+				}
+				rest = rest[n:]
+			}
+			if err := be.Close(); err != nil {
+				t.Fatalf("(*Base64Encoder).Close [%d]: got error %v", i, err)
+			}
+			if err := qe.Close(); err != nil {
+				t.Fatalf("(*QPEncoder).Close [%d]: got error %v", i, err)
+			}
+			if exp := Base64Encode(src); !bytes.Equal(b64.Bytes(), exp) {
+				t.Errorf("Base64Encoder [%d/%d]: got\n%s\nwant\n%s", i, size, b64.Bytes(), exp)
+			}
+			if exp := QuotedPrintableEncode(src); !bytes.Equal(qp.Bytes(), exp) {
+				t.Errorf("QPEncoder [%d/%d]: got\n%s\nwant\n%s", i, size, qp.Bytes(), exp)
+			}
+		}
+	}
+	e := NewBase64Encoder(&bytes.Buffer{})
+	e.Close()
+	if _, err := e.Write([]byte("x")); err == nil {
+		t.Errorf("(*Base64Encoder).Write: got no error after Close")
+	}
+}
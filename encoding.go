@@ -1,5 +1,7 @@
 package email
 
+import "errors"
+
 const (
 	hextable    = "0123456789ABCDEF"
 	base64table = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
@@ -10,12 +12,17 @@ const (
 // UTF multi-byte characters be kept on the same line of encoded text, this function
 // does so.
 func QuotedPrintableEncode(src []byte) []byte {
-	srcLen := len(src)
-	if srcLen == 0 {
+	if len(src) == 0 {
 		return []byte{}
 	}
 	// guestimate max size of dst, trying to avoid reallocation on append
-	dst := make([]byte, 0, 2*srcLen)
+	return appendQuotedPrintable(make([]byte, 0, 2*len(src)), src)
+}
+
+// appendQuotedPrintable appends the quoted-printable encoding of src to dst, like
+// QuotedPrintableEncode, and returns the extended buffer.
+func appendQuotedPrintable(dst, src []byte) []byte {
+	srcLen := len(src)
 	pos := 0
 
 	var (
@@ -67,6 +74,12 @@ func QuotedPrintableEncode(src []byte) []byte {
 // for the length of the current header line already used up, e.g. by the header
 // name, colon and space.
 func QEncode(src []byte, offset int) (dst []byte, pos int) {
+	return qEncode(src, "utf-8", offset)
+}
+
+// qEncode q-encodes the src data, in the `charset`, like QEncode. Only for UTF-8 are the multi-byte
+// characters kept within the same encoded-word.
+func qEncode(src []byte, charset string, offset int) (dst []byte, pos int) {
 	srcLen := len(src)
 	if srcLen == 0 {
 		return []byte{}, offset
@@ -81,10 +94,12 @@ func QEncode(src []byte, offset int) (dst []byte, pos int) {
 		// but if the first line is empty, we need to pretend it has one char.
 		offset = 1
 	}
-	// count in the 10 chars of "=?utf-8?q?", but do not add them yet! There is
+	// count in the chars of "=?utf-8?q?", but do not add them yet! There is
 	// a chance that we cannot fit even one encoded character on the first line,
 	// but we won't know its length until we encoded it.
-	pos = 10 + offset
+	prefix := "=?" + charset + "?q?"
+	utf := charset == "utf-8"
+	pos = len(prefix) + offset
 
 	var (
 		c  byte
@@ -98,7 +113,7 @@ func QEncode(src []byte, offset int) (dst []byte, pos int) {
 			enc = append(enc, '_')
 		case '!' <= c && c <= '~' && c != '=' && c != '?' && c != '_':
 			enc = append(enc, c)
-		case c&0xC0 == 0xC0:
+		case utf && c&0xC0 == 0xC0:
 			// start of utf-8 rune; subsequent bytes always have the top two bits set to 10.
 			enc = append(make([]byte, 0, 12), '=', hextable[c>>4], hextable[c&0x0f])
 			for i++; i < srcLen; i++ {
@@ -116,17 +131,17 @@ func QEncode(src []byte, offset int) (dst []byte, pos int) {
 		le = len(enc)
 		if pos += le; pos > 74 { // max 76; need room for '?='
 			if len(dst) > 0 {
-				dst = append(dst, []byte("?=\r\n =?utf-8?q?")...)
+				dst = append(append(dst, "?=\r\n "...), prefix...)
 			} else {
 				// the first encoded char doesn't fit on the first line, so
 				// start a new line and the encoded-word
-				dst = append(dst, []byte("\r\n =?utf-8?q?")...)
+				dst = append(append(dst, "\r\n "...), prefix...)
 			}
-			pos = le + 11
+			pos = le + len(prefix) + 1
 		} else {
 			if len(dst) == 0 {
 				// the first encoded char fits on the first line, so start the encoded-word
-				dst = append(dst, []byte("=?utf-8?q?")...)
+				dst = append(dst, prefix...)
 			}
 		}
 		dst = append(dst, enc...)
@@ -138,6 +153,11 @@ func QEncode(src []byte, offset int) (dst []byte, pos int) {
 
 // QEncodeIfNeeded q-encodes the src data only if it contains 'unsafe' characters.
 func QEncodeIfNeeded(src []byte, offset int) (dst []byte) {
+	return qEncodeIfNeeded(src, "utf-8", offset)
+}
+
+// qEncodeIfNeeded q-encodes the src data, in the `charset`, only if it contains 'unsafe' characters.
+func qEncodeIfNeeded(src []byte, charset string, offset int) (dst []byte) {
 	safe := true
 	for i, sl := 0, len(src); i < sl && safe; i++ {
 		safe = ' ' <= src[i] && src[i] <= '~'
@@ -145,7 +165,7 @@ func QEncodeIfNeeded(src []byte, offset int) (dst []byte) {
 	if safe {
 		return src
 	}
-	dst, _ = QEncode(src, offset)
+	dst, _ = qEncode(src, charset, offset)
 	return dst
 }
 
@@ -159,10 +179,25 @@ func Base64Encode(src []byte) []byte {
 	if len(src) == 0 {
 		return []byte{}
 	}
+	return appendBase64(nil, src)
+}
+
+// appendBase64 appends the base64 encoding of src to dst, like Base64Encode, and returns the
+// extended buffer. At most one allocation is made, if dst lacks the capacity.
+func appendBase64(buf, src []byte) []byte {
+	if len(src) == 0 {
+		return buf
+	}
 	dstLen := ((len(src) + 2) / 3 * 4) // base64 encoded length
 	dstLen += (dstLen - 1) / 76 * 2    // add 2 bytes for each full 76-char line
-	dst := make([]byte, dstLen)
-	// fmt.Println(len(src), dstLen)
+	start := len(buf)
+	if cap(buf)-start < dstLen {
+		grown := make([]byte, start, start+dstLen)
+		copy(grown, buf)
+		buf = grown
+	}
+	buf = buf[:start+dstLen]
+	dst := buf[start:]
 
 	var (
 		p [4]int
@@ -202,13 +237,13 @@ func Base64Encode(src []byte) []byte {
 			dst[p[3]], dst[p[2]] = '=', '='
 			dst[p[1]] = base64table[(src[0]<<4)&0x3F]
 			dst[p[0]] = base64table[src[0]>>2]
-			return dst
+			return buf
 		case 2:
 			dst[p[3]] = '='
 			dst[p[2]] = base64table[(src[1]<<2)&0x3F]
 			dst[p[1]] = base64table[(src[1]>>4)|(src[0]<<4)&0x3F]
 			dst[p[0]] = base64table[src[0]>>2]
-			return dst
+			return buf
 		default:
 			dst[p[3]] = base64table[src[2]&0x3F]
 			dst[p[2]] = base64table[(src[2]>>6)|(src[1]<<2)&0x3F]
@@ -218,5 +253,71 @@ func Base64Encode(src []byte) []byte {
 		}
 	}
 
-	return dst
+	return buf
+}
+
+// autoCTE determines the content transfer encoding for the `data`: SevenBit if it is ASCII text in
+// lines of at most 78 characters, which need no encoding, QuotedPrintable if at most a sixth of
+// its bytes need encoding - quoted-printable being the more compact and readable encoding then -
+// and Base64 otherwise, or if the data includes NUL bytes.
+func autoCTE(data []byte) CTE {
+	var (
+		unsafe, line int
+		long, bare   bool
+	)
+	for i, c := range data {
+		switch {
+		case c == 0:
+			return Base64
+		case c == '\n':
+			bare = bare || i == 0 || data[i-1] != '\r'
+			line = 0
+			continue
+		case c == '\r':
+			bare = bare || i+1 == len(data) || data[i+1] != '\n'
+			continue
+		case c >= 0x80 || c < ' ' && c != '\t' || c == 0x7f:
+			unsafe++
+		}
+		if line++; line > 78 {
+			long = true
+		}
+	}
+	switch {
+	case unsafe == 0 && !long && !bare:
+		return SevenBit
+	case unsafe*6 <= len(data):
+		return QuotedPrintable
+	}
+	return Base64
+}
+
+// checkIdentityCTE checks that the `data` is suitable for the identity encoding `cte` - SevenBit or
+// EightBit - as specified by RFC 2045: lines of at most 998 bytes separated by CRLF, without NUL
+// bytes or bare CR or LF characters, and with only ASCII characters for SevenBit.
+func checkIdentityCTE(data []byte, cte CTE) error {
+	line := 0
+	for i, c := range data {
+		switch {
+		case c == '\r':
+			if i+1 == len(data) || data[i+1] != '\n' {
+				return errors.New("invalid " + cte.String() + " content: bare CR")
+			}
+			continue
+		case c == '\n':
+			if i == 0 || data[i-1] != '\r' {
+				return errors.New("invalid " + cte.String() + " content: bare LF")
+			}
+			line = 0
+			continue
+		case c == 0:
+			return errors.New("invalid " + cte.String() + " content: NUL byte")
+		case c >= 0x80 && cte == SevenBit:
+			return errors.New("invalid 7bit content: non-ASCII byte")
+		}
+		if line++; line > 998 {
+			return errors.New("invalid " + cte.String() + " content: line longer than 998 bytes")
+		}
+	}
+	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/rand"
 	"encoding/base64"
+	"strings"
 	"testing"
 )
 
@@ -192,3 +193,45 @@ func Benchmark_Base64Encode_40k(b *testing.B) {
 func Benchmark_Base64Encode_stdlib_40k(b *testing.B) {
 	benchmarkBase64EncodeStdlib(40960, b)
 }
+
+func Test_checkIdentityCTE(t *testing.T) {
+	cases := []struct {
+		data string
+		cte  CTE
+		ok   bool
+	}{
+		{"Hello,\r\nworld\r\n", SevenBit, true},
+		{"Héllo\r\n", SevenBit, false},
+		{"Héllo\r\n", EightBit, true},
+		{"Hello\nworld", EightBit, false},
+		{"Hello\rworld", EightBit, false},
+		{"Hello\x00", EightBit, false},
+		{strings.Repeat("x", 998) + "\r\n" + strings.Repeat("x", 998), SevenBit, true},
+		{strings.Repeat("x", 999), SevenBit, false},
+	}
+	for i, c := range cases {
+		if err := checkIdentityCTE([]byte(c.data), c.cte); (err == nil) != c.ok {
+			t.Errorf("checkIdentityCTE [%d]: got %v want ok %v", i, err, c.ok)
+		}
+	}
+}
+
+func Test_autoCTE(t *testing.T) {
+	cases := []struct {
+		data string
+		exp  CTE
+	}{
+		{"Hello,\r\nworld\r\n", SevenBit},
+		{"", SevenBit},
+		{"Hello,\nworld", QuotedPrintable},
+		{strings.Repeat("x", 79), QuotedPrintable},
+		{"Le café est servi dans le salon, avec des gâteaux.", QuotedPrintable},
+		{"Привет, мир", Base64},
+		{"\x89PNG\r\n\x1a\n\x00\x00", Base64},
+	}
+	for i, c := range cases {
+		if act := autoCTE([]byte(c.data)); act != c.exp {
+			t.Errorf("autoCTE [%d]: got %v want %v", i, act, c.exp)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package email
+
+import "errors"
+
+var (
+	// ErrNoFrom is recorded when composing a message that has no From address, and whose Sender,
+	// if any, has no address either.
+	ErrNoFrom = errors.New("no From address")
+	// ErrNoParts is recorded when composing a message that has no body parts.
+	ErrNoParts = errors.New("message has no parts")
+	// ErrInvalidArgument is recorded when a method of Message is called with an argument of an
+	// unsupported type.
+	ErrInvalidArgument = errors.New("invalid argument type")
+)
+
+// ErrTemplateParse is recorded when a template of a message cannot be parsed.
+type ErrTemplateParse struct {
+	// Part is the template that failed: "subject", "text", "html" or "amp" - or "templates" for
+	// the source given to Templates.
+	Part string
+	// Source is the source of the template.
+	Source string
+	// Err is the error returned by the template package.
+	Err error
+}
+
+func (e *ErrTemplateParse) Error() string {
+	if e.Part == "templates" {
+		return "invalid templates:\n" + e.Source + "\nerror: " + e.Err.Error()
+	}
+	return "invalid " + e.Part + " template:\n" + e.Source + "\nerror: " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ErrTemplateParse) Unwrap() error {
+	return e.Err
+}
+
+// ErrInvalidAddr is recorded when an address given to a message is rejected as invalid, at the
+// validation level of the message - see (*Message).Validation. It is also returned by ValidateAddr.
+type ErrInvalidAddr struct {
+	// Addr is the rejected address.
+	Addr string
+	// Reason describes what is wrong with the address; it is empty with LooseValidation.
+	Reason string
+}
+
+func (e *ErrInvalidAddr) Error() string {
+	if e.Reason == "" {
+		return "email: invalid address " + e.Addr
+	}
+	return "email: invalid address " + e.Addr + ": " + e.Reason
+}
+
+// ErrTemplateExec is recorded when a template of a message fails to execute with the given data.
+type ErrTemplateExec struct {
+	// Part is the template that failed: "subject", or the part number and template flavor, e.g.
+	// "part[1] html" - or, for the templates of a locale rendered by TestRender, the locale and
+	// the template, e.g. "fr subject".
+	Part string
+	// Err is the error returned by the template package.
+	Err error
+}
+
+func (e *ErrTemplateExec) Error() string {
+	return "failed Execute on " + e.Part + " template: " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ErrTemplateExec) Unwrap() error {
+	return e.Err
+}
+
+// ErrFileRead is recorded when a file referenced by a message cannot be read.
+type ErrFileRead struct {
+	// Path is the path of the file, as given to the message.
+	Path string
+	// Err is the underlying error - e.g. a *fs.PathError, or ErrEscapesRoot.
+	Err error
+}
+
+func (e *ErrFileRead) Error() string {
+	return "cannot read file: " + e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ErrFileRead) Unwrap() error {
+	return e.Err
+}
+
+// ErrFetch is recorded when a resource referenced by a message with a URL cannot be fetched.
+type ErrFetch struct {
+	// URL is the URL of the resource, as given to the message.
+	URL string
+	// Err is the underlying error - e.g. a *url.Error, or ErrFetchTooLarge.
+	Err error
+}
+
+func (e *ErrFetch) Error() string {
+	return "cannot fetch " + e.URL + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ErrFetch) Unwrap() error {
+	return e.Err
+}
+
+// ErrDangerousAttachment is recorded for the attachments rejected by the AttachmentPolicy of a
+// message.
+type ErrDangerousAttachment struct {
+	// Name is the name of the attachment.
+	Name string
+	// ContentType is the content type of the attachment, if known.
+	ContentType string
+}
+
+func (e *ErrDangerousAttachment) Error() string {
+	return "dangerous attachment: " + e.Name
+}
+
+// ErrAttachmentRejected is recorded for the attachments vetoed by the ScanFunc of a message.
+type ErrAttachmentRejected struct {
+	// Name is the name of the attachment.
+	Name string
+	// Err is the error returned by the ScanFunc.
+	Err error
+}
+
+func (e *ErrAttachmentRejected) Error() string {
+	return "attachment rejected: " + e.Name + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ErrAttachmentRejected) Unwrap() error {
+	return e.Err
+}
+
+// ErrorKind classifies the errors recorded by a Message.
+type ErrorKind byte
+
+const (
+	// ArgumentError is the kind of the errors caused by invalid arguments given to the setters.
+	ArgumentError ErrorKind = iota + 1
+	// AddressError is the kind of the errors caused by missing or invalid addresses.
+	AddressError
+	// HeaderError is the kind of the errors caused by invalid headers.
+	HeaderError
+	// TemplateError is the kind of the errors parsing or executing templates.
+	TemplateError
+	// FileError is the kind of the errors reading the files of related items and attachments, or
+	// fetching them from URLs.
+	FileError
+	// ContentError is the kind of the errors caused by missing or invalid content, or by an
+	// invalid structure of the message.
+	ContentError
+	// MiddlewareError is the kind of the errors returned by compose middleware.
+	MiddlewareError
+	// EncryptionError is the kind of the errors encrypting the message.
+	EncryptionError
+	// WriteError is the kind of the errors writing the composed message.
+	WriteError
+)
+
+var errorKindNames = [...]string{"", "argument", "address", "header", "template", "file", "content",
+	"middleware", "encryption", "write"}
+
+func (k ErrorKind) String() string {
+	if int(k) < len(errorKindNames) {
+		return errorKindNames[k]
+	}
+	return "unknown"
+}
+
+// MessageError is the type of the errors recorded by a Message, as returned by Errors, providing the
+// context needed to point out exactly what is wrong - e.g. which template or attachment is broken.
+// Its message is the one of the underlying error, which is available to errors.Is and errors.As.
+type MessageError struct {
+	// Kind classifies the error.
+	Kind ErrorKind
+	// Field is the field of the message the error is about, if any - e.g. "subject", "text",
+	// "html", "from", or the name of a header.
+	Field string
+	// PartIndex is the index of the alternative part the error is about - in the order the parts
+	// were added - or -1.
+	PartIndex int
+	// AttachmentName is the name of the attachment or embedded item the error is about, if any.
+	AttachmentName string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *MessageError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *MessageError) Unwrap() error {
+	return e.Err
+}
+
+// fail records the error `err` of the `kind`, about the `field` of the receiver. The caller must
+// hold the lock on the receiver.
+func (m *Message) fail(kind ErrorKind, field string, err error) {
+	m.errors = append(m.errors, &MessageError{Kind: kind, Field: field, PartIndex: -1, Err: err})
+}
+
+// failPart records the error `err` of the `kind`, about the part with the `index`.
+func (m *Message) failPart(kind ErrorKind, index int, field string, err error) {
+	m.errors = append(m.errors, &MessageError{Kind: kind, Field: field, PartIndex: index, Err: err})
+}
+
+// failAttachment records the error `err` of the `kind`, about the attachment with the `name`.
+func (m *Message) failAttachment(kind ErrorKind, name string, err error) {
+	m.errors = append(m.errors, &MessageError{Kind: kind, PartIndex: -1, AttachmentName: name, Err: err})
+}
@@ -0,0 +1,68 @@
+package email
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func Test_Errors(t *testing.T) {
+	var (
+		parseErr *ErrTemplateParse
+		execErr  *ErrTemplateExec
+		fileErr  *ErrFileRead
+	)
+	cases := []struct {
+		msg   *Message
+		check func(err error) bool
+	}{
+		{QuickMessage("test", "body"), func(err error) bool { return errors.Is(err, ErrNoFrom) }},
+		{NewMessage(nil).From(&Address{"", "test@example.com"}), func(err error) bool { return errors.Is(err, ErrNoParts) }},
+		{NewMessage(nil).Subject(42), func(err error) bool { return errors.Is(err, ErrInvalidArgument) }},
+		{NewMessage(nil).TextTemplate("{{.x"), func(err error) bool {
+			return errors.As(err, &parseErr) && parseErr.Part == "text" && parseErr.Source == "{{.x"
+		}},
+		{QuickMessage("test").From(&Address{"", "test@example.com"}).TextTemplate("{{.x.y}}"), func(err error) bool {
+			return errors.As(err, &execErr) && execErr.Part == "part[0]"
+		}},
+		{QuickMessage("test", "body").From(&Address{"", "test@example.com"}).Attach("no-such-file.txt"), func(err error) bool {
+			return errors.As(err, &fileErr) && fileErr.Path == "no-such-file.txt" && errors.Is(err, os.ErrNotExist)
+		}},
+	}
+	for i, c := range cases {
+		c.msg.Compose(map[string]int{"x": 1})
+		errs := c.msg.Errors()
+		if len(errs) == 0 || !c.check(errs[0]) {
+			t.Errorf("(*Message).Errors [%d]: got %v", i, errs)
+		}
+	}
+}
+
+func Test_MessageError(t *testing.T) {
+	from := &Address{"", "test@example.com"}
+	cases := []struct {
+		msg *Message
+		exp MessageError
+	}{
+		{QuickMessage("test", "body"), MessageError{Kind: AddressError, Field: "from", PartIndex: -1}},
+		{NewMessage(nil).Subject(42), MessageError{Kind: ArgumentError, Field: "subject", PartIndex: -1}},
+		{NewMessage(nil).HtmlTemplate("{{.x"), MessageError{Kind: TemplateError, Field: "html", PartIndex: -1}},
+		{QuickMessage("test").From(from).TextTemplate("{{.x.y}}"), MessageError{Kind: TemplateError, Field: "text", PartIndex: 0}},
+		{QuickMessage("test", "body").From(from).Attach("no-such-file.txt"),
+			MessageError{Kind: FileError, PartIndex: -1, AttachmentName: "no-such-file.txt"}},
+		{QuickMessage("test", "body").From(from).Header("Bad Name", "x"), MessageError{Kind: HeaderError, Field: "Bad Name", PartIndex: -1}},
+	}
+	for i, c := range cases {
+		c.msg.Compose(map[string]int{"x": 1})
+		errs := c.msg.Errors()
+		var act *MessageError
+		if len(errs) == 0 || !errors.As(errs[0], &act) {
+			t.Errorf("(*Message).Errors [%d]: got %v, want a *MessageError", i, errs)
+			continue
+		}
+		if act.Kind != c.exp.Kind || act.Field != c.exp.Field || act.PartIndex != c.exp.PartIndex ||
+			act.AttachmentName != c.exp.AttachmentName || act.Err == nil {
+			t.Errorf("(*Message).Errors [%d]: got %+v, want %+v", i, *act, c.exp)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package email
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DeliveryEvent describes the outcome of the delivery of a message, as passed to an EventSink.
+type DeliveryEvent struct {
+	MessageID  string    `json:"message_id"`
+	From       string    `json:"from"`
+	Recipients []string  `json:"recipients"`
+	Status     string    `json:"status"` // "sent" or "failed"
+	Error      string    `json:"error,omitempty"`
+	SMTPCode   int       `json:"smtp_code,omitempty"`
+	QueuedAt   time.Time `json:"queued_at"`
+	DoneAt     time.Time `json:"done_at"`
+}
+
+// EventSink receives an event for every message delivered by a Sender, or failed to be delivered
+// - e.g. for feeding downstream analytics. Implementations must be safe for concurrent use.
+type EventSink interface {
+	OnDelivery(ev *DeliveryEvent) error
+}
+
+// EventSinkFunc adapts a function to the EventSink interface.
+type EventSinkFunc func(ev *DeliveryEvent) error
+
+// OnDelivery calls f(ev).
+func (f EventSinkFunc) OnDelivery(ev *DeliveryEvent) error {
+	return f(ev)
+}
+
+// Events sets the sink to be notified about the outcome of the deliveries by the receiver. The
+// sink is invoked by the delivering goroutine, after the SMTP transaction. No events are emitted
+// in a dry-run mode. A nil `sink` disables the events.
+func (s *Sender) Events(sink EventSink) *Sender {
+	s.mu.Lock()
+	s.events = sink
+	s.mu.Unlock()
+	return s
+}
+
+// emit notifies the `sink` about the outcome of the delivery `d`, logging a failure to do so.
+func (s *Sender) emit(sink EventSink, l Logger, d delivery, err error) {
+	ev := &DeliveryEvent{
+		MessageID:  d.id,
+		From:       d.from,
+		Recipients: d.to,
+		Status:     "sent",
+		QueuedAt:   d.queued,
+		DoneAt:     now(),
+	}
+	if err != nil {
+		ev.Status, ev.Error, ev.SMTPCode = "failed", err.Error(), smtpCode(err)
+	}
+	if err = sink.OnDelivery(ev); err != nil && l != nil {
+		l.Error("email: event sink failed", "message_id", ev.MessageID, "error", err)
+	}
+}
+
+// WebhookSink is an EventSink posting each event as a JSON object to a URL.
+type WebhookSink struct {
+	// URL is the address the events are posted to.
+	URL string
+	// Client is the HTTP client used for posting; if nil, a client with a 10-second timeout is used.
+	Client *http.Client
+	// Header holds extra header fields for the requests - e.g. for authorization.
+	Header http.Header
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewWebhookSink creates a WebhookSink posting the events to `url`.
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{URL: url}
+}
+
+// OnDelivery posts `ev` to the URL of the receiver. Any response status other than 2xx is
+// reported as an error.
+func (w *WebhookSink) OnDelivery(ev *DeliveryEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for name, values := range w.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := w.Client
+	if client == nil {
+		client = webhookClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.New("webhook: unexpected response status " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
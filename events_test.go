@@ -0,0 +1,76 @@
+package email
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"net/textproto"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_SenderEvents(t *testing.T) {
+	defer func(f func(string, smtp.Auth, string, []string, []byte) error) { sendMail = f }(sendMail)
+	defer func(f func() time.Time) { now = f }(now)
+	t0 := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return t0 }
+
+	received := make(chan map[string]interface{}, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev map[string]interface{}
+		data, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer token" ||
+			json.Unmarshal(data, &ev) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- ev
+	}))
+	defer srv.Close()
+	sink := NewWebhookSink(srv.URL)
+	sink.Header = http.Header{"Authorization": {"Bearer token"}}
+
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	s.Events(sink)
+	msg := QuickMessage("test", "body").To(&Address{"", "a@example.com"})
+
+	forceSendMail(nil)
+	if err := s.sendSync(msg, nil); err != nil {
+		t.Fatalf("(*Sender).sendSync: unexpected error: %v", err)
+	}
+	exp := map[string]interface{}{
+		"message_id": msg.MessageID(),
+		"from":       "test@example.com",
+		"recipients": []interface{}{"a@example.com"},
+		"status":     "sent",
+		"queued_at":  "2020-05-01T12:00:00Z",
+		"done_at":    "2020-05-01T12:00:00Z",
+	}
+	if ev := <-received; !reflect.DeepEqual(ev, exp) {
+		t.Errorf("WebhookSink: got %v, want %v", ev, exp)
+	}
+
+	forceSendMail(&textproto.Error{Code: 550, Msg: "mailbox unavailable"})
+	s.sendSync(msg, nil)
+	if ev := <-received; ev["status"] != "failed" || ev["smtp_code"] != 550.0 || ev["error"] != `550 "mailbox unavailable"` {
+		t.Errorf("WebhookSink: got %v, want a failed event", ev)
+	}
+
+	sink.Header = nil
+	if err := sink.OnDelivery(&DeliveryEvent{}); err == nil {
+		t.Error("(*WebhookSink).OnDelivery: expected an error for a bad request response")
+	}
+
+	var events []*DeliveryEvent
+	s.Events(EventSinkFunc(func(ev *DeliveryEvent) error {
+		events = append(events, ev)
+		return nil
+	})).DryRun(DryRunCompose)
+	s.sendSync(msg, nil)
+	if len(events) != 0 {
+		t.Errorf("(*Sender).Events: got %d events in a dry-run mode", len(events))
+	}
+}
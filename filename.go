@@ -0,0 +1,172 @@
+package email
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FilenamePolicy defines how attachment file names are sanitized, to prevent header breakage and
+// path traversal on the receiving side.
+//
+// Sanitize first normalizes the Unicode form of the name with Normalize, if set, then replaces path
+// separators (including their common look-alikes) and characters reserved on popular file systems
+// with the Replacement, removes control and invisible formatting characters (e.g. bidirectional
+// overrides used to disguise extensions), collapses any whitespace into single spaces, trims leading
+// and trailing dots and spaces, and finally shortens the name to MaxLength bytes, preserving its
+// extension when possible.
+type FilenamePolicy struct {
+	// MaxLength is the maximum length of a sanitized name, in bytes; 0 means no limit.
+	MaxLength int
+	// Replacement is substituted for path separators and reserved characters.
+	Replacement string
+	// Fallback is used when nothing is left of a name after sanitization.
+	Fallback string
+	// Normalize converts a name to a Unicode normalization form - e.g. norm.NFC.String from the
+	// golang.org/x/text/unicode/norm package - so that the composed and decomposed forms of the
+	// same name sanitize alike. If nil, names are not normalized.
+	Normalize func(name string) string
+}
+
+// DefaultFilenamePolicy is used for messages that do not have their own FilenamePolicy.
+var DefaultFilenamePolicy = FilenamePolicy{
+	MaxLength:   255,
+	Replacement: "_",
+	Fallback:    "attachment",
+}
+
+// Sanitize returns a version of `name` that is safe to use as an attachment file name.
+func (p FilenamePolicy) Sanitize(name string) string {
+	var (
+		buf   strings.Builder
+		space bool
+	)
+	name = strings.ToValidUTF8(name, p.Replacement)
+	if p.Normalize != nil {
+		name = p.Normalize(name)
+	}
+	for _, r := range name {
+		switch {
+		case unicode.IsSpace(r):
+			space = buf.Len() > 0
+			continue
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r):
+			continue
+		}
+		if space {
+			buf.WriteByte(' ')
+			space = false
+		}
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|',
+			'⁄', '∕', '⧵', '⧸', '／', '＼':
+			buf.WriteString(p.Replacement)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	name = strings.Trim(buf.String(), ". ")
+	if p.MaxLength > 0 && len(name) > p.MaxLength {
+		ext := ""
+		if i := strings.LastIndexByte(name, '.'); i > 0 && len(name)-i < p.MaxLength/2 {
+			ext = name[i:]
+		}
+		name = truncateUTF8(name[:len(name)-len(ext)], p.MaxLength-len(ext))
+		name = strings.TrimRight(name, ". ") + ext
+	}
+	if name == "" {
+		return p.Fallback
+	}
+	return name
+}
+
+// truncateUTF8 shortens `s` to at most `n` bytes, without splitting a multi-byte character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// FilenamePolicy sets the policy used to sanitize the names of the attachments subsequently added
+// to the message. If not set, DefaultFilenamePolicy is used.
+func (m *Message) FilenamePolicy(p FilenamePolicy) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.filenamePolicy = &p
+	return m
+}
+
+// sanitizeFilename applies the filename policy of the receiver to `name`.
+// The caller must hold the lock on the receiver.
+func (m *Message) sanitizeFilename(name string) string {
+	if m.filenamePolicy != nil {
+		return m.filenamePolicy.Sanitize(name)
+	}
+	return DefaultFilenamePolicy.Sanitize(name)
+}
+
+// filenameParams returns the filename parameter of a Content-Disposition header for `name`, each
+// on its own line. An ASCII name is given as a quoted string; otherwise, the name is given both
+// encoded as specified by RFC 2231 - in the "filename*" parameter, split into sections if long -
+// and, for the clients that do not support that, RFC 2047 encoded in the "filename" parameter.
+func filenameParams(name string) []byte {
+	dst := []byte(";\r\n\tfilename=\"")
+	ascii := true
+	for i := 0; i < len(name) && ascii; i++ {
+		ascii = ' ' <= name[i] && name[i] <= '~'
+	}
+	if ascii {
+		for i := 0; i < len(name); i++ {
+			if name[i] == '"' || name[i] == '\\' {
+				dst = append(dst, '\\')
+			}
+			dst = append(dst, name[i])
+		}
+		return append(dst, '"')
+	}
+	dst = append(append(dst, mime.BEncoding.Encode("utf-8", name)...), '"')
+
+	const sectionLen = 60
+	var sections []string
+	section := "utf-8''"
+	for i := 0; i < len(name); {
+		_, n := utf8.DecodeRuneInString(name[i:])
+		var enc string
+		for _, c := range []byte(name[i : i+n]) {
+			if isAttrChar(c) {
+				enc += string(c)
+			} else {
+				enc += "%" + string(hextable[c>>4]) + string(hextable[c&0x0f])
+			}
+		}
+		if len(section)+len(enc) > sectionLen && section != "" {
+			sections = append(sections, section)
+			section = ""
+		}
+		section += enc
+		i += n
+	}
+	sections = append(sections, section)
+	if len(sections) == 1 {
+		return append(append(dst, ";\r\n\tfilename*="...), sections[0]...)
+	}
+	for i, s := range sections {
+		dst = append(append(append(append(dst, ";\r\n\tfilename*"...), strconv.Itoa(i)...), "*="...), s...)
+	}
+	return dst
+}
+
+// isAttrChar reports whether `c` may appear unencoded in an RFC 2231 extended parameter value.
+func isAttrChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
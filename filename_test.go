@@ -0,0 +1,60 @@
+package email
+
+import (
+	"mime"
+	"strings"
+	"testing"
+)
+
+func Test_FilenamePolicySanitize(t *testing.T) {
+	cases := []struct {
+		policy   FilenamePolicy
+		src, exp string
+	}{
+		{DefaultFilenamePolicy, "report.pdf", "report.pdf"},
+		{DefaultFilenamePolicy, "../../etc/passwd", "_.._etc_passwd"},
+		{DefaultFilenamePolicy, `C:\Users\me\file.txt`, "C__Users_me_file.txt"},
+		{DefaultFilenamePolicy, "evil\r\nX-Header: 1.txt", "evil X-Header_ 1.txt"},
+		{DefaultFilenamePolicy, "invoice\u202Efdp.exe", "invoicefdp.exe"},
+		{DefaultFilenamePolicy, "a\t\t b／c.txt", "a b_c.txt"},
+		{DefaultFilenamePolicy, " ..hidden. ", "hidden"},
+		{DefaultFilenamePolicy, "...", "attachment"},
+		{DefaultFilenamePolicy, "bad\xffutf8", "bad_utf8"},
+		{FilenamePolicy{MaxLength: 10}, "a-very-long-name.txt", "a-very.txt"},
+		{FilenamePolicy{MaxLength: 10}, "отчёт-за-год.pdf", "отч.pdf"},
+		{FilenamePolicy{MaxLength: 5}, "noextension", "noext"},
+		{FilenamePolicy{Normalize: composeAcute}, "Cafe\u0301.txt", "Caf\u00e9.txt"},
+	}
+	for i, c := range cases {
+		if act := c.policy.Sanitize(c.src); act != c.exp {
+			t.Errorf("FilenamePolicy.Sanitize [%d]: got %q, want %q", i, act, c.exp)
+		}
+	}
+}
+
+// composeAcute stands in for norm.NFC.String in the tests, composing the letter e followed by a
+// combining acute accent.
+func composeAcute(name string) string {
+	return strings.ReplaceAll(name, "e\u0301", "\u00e9")
+}
+
+func Test_filenameParams(t *testing.T) {
+	cases := []struct {
+		name, exp string
+	}{
+		{"report.pdf", ";\r\n\tfilename=\"report.pdf\""},
+		{`my "final" report\v2.pdf`, ";\r\n\tfilename=\"my \\\"final\\\" report\\\\v2.pdf\""},
+		{"отчёт.pdf", ";\r\n\tfilename=\"=?utf-8?b?0L7RgtGH0ZHRgi5wZGY=?=\";\r\n\tfilename*=utf-8''%D0%BE%D1%82%D1%87%D1%91%D1%82.pdf"},
+	}
+	for i, c := range cases {
+		if act := string(filenameParams(c.name)); act != c.exp {
+			t.Errorf("filenameParams [%d]: got\n%q\nwant\n%q", i, act, c.exp)
+		}
+	}
+	for i, name := range []string{"请求.xlsx", "my report (final).pdf", strings.Repeat("отчёт ", 20) + ".pdf"} {
+		hdr := "attachment" + strings.NewReplacer("\r\n\t", " ").Replace(string(filenameParams(name)))
+		if _, params, err := mime.ParseMediaType(hdr); err != nil || params["filename"] != name {
+			t.Errorf("filenameParams [%d]: got %q, error %v, from %q", i, params["filename"], err, hdr)
+		}
+	}
+}
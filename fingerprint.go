@@ -0,0 +1,181 @@
+package email
+
+import (
+	"errors"
+	"hash/fnv"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Fingerprint is a similarity hash of the content of a message. It is computed over the
+// overlapping word sequences (shingles) of the normalized text, so it is insensitive to case,
+// punctuation, white space and markup, and similar contents have fingerprints that differ in few
+// bits - see Distance.
+type Fingerprint uint64
+
+// shingleSize is the number of words in each of the shingles a Fingerprint is computed over.
+const shingleSize = 3
+
+// NewFingerprint computes the Fingerprint of the `text`.
+func NewFingerprint(text string) Fingerprint {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	n := len(words) - shingleSize + 1
+	if n < 1 {
+		n = 1
+	}
+	var votes [64]int
+	h := fnv.New64a()
+	for i := 0; i < n; i++ {
+		h.Reset()
+		for j := i; j < i+shingleSize && j < len(words); j++ {
+			h.Write([]byte(words[j]))
+			h.Write([]byte{0})
+		}
+		sum := h.Sum64()
+		for b := 0; b < 64; b++ {
+			if sum&(1<<uint(b)) != 0 {
+				votes[b]++
+			} else {
+				votes[b]--
+			}
+		}
+	}
+	var fp Fingerprint
+	for b, v := range votes {
+		if v > 0 {
+			fp |= 1 << uint(b)
+		}
+	}
+	return fp
+}
+
+// Distance returns the number of bits that differ between the receiver and `other`: 0 for
+// identical contents, and small values (e.g. up to 3) for near-identical ones.
+func (f Fingerprint) Distance(other Fingerprint) int {
+	return bits.OnesCount64(uint64(f ^ other))
+}
+
+func (f Fingerprint) String() string {
+	s := strconv.FormatUint(uint64(f), 16)
+	return strings.Repeat("0", 16-len(s)) + s
+}
+
+// Fingerprint returns the Fingerprint of the subject and body generated by the most recent
+// successful call to Compose - or 0 if the message was never composed.
+func (m *Message) Fingerprint() Fingerprint {
+	m.RLock()
+	defer m.RUnlock()
+	return m.fingerprint
+}
+
+// FingerprintStore records the fingerprints of the messages sent to each recipient, for the
+// detection of duplicates. Implementations must be safe for concurrent use.
+type FingerprintStore interface {
+	// LastSent returns the last time a message with the fingerprint `fp` was sent to `addr`, or
+	// the zero time if none was.
+	LastSent(addr string, fp Fingerprint) (time.Time, error)
+	// Record records that a message with the fingerprint `fp` was sent to `addr` at time `t`.
+	Record(addr string, fp Fingerprint, t time.Time) error
+}
+
+// DuplicateCheck makes the receiver check every message it sends against the `store`, and report
+// the recipients who were already sent a message with the same fingerprint within the `window`.
+// The duplicates are logged, if a logger is set, and passed to `onDuplicate`, if not nil; if that
+// returns an error, the message is not sent, and Send returns that error. A nil `store` disables
+// the check.
+func (s *Sender) DuplicateCheck(store FingerprintStore, window time.Duration, onDuplicate func(msg *Message, addr string, last time.Time) error) *Sender {
+	s.mu.Lock()
+	s.dupStore, s.dupWindow, s.onDuplicate = store, window, onDuplicate
+	s.mu.Unlock()
+	return s
+}
+
+// checkDuplicates checks the recipients of the composed `msg` against the duplicate store of the
+// receiver, if any, and records the message as sent to them.
+func (s *Sender) checkDuplicates(msg *Message) error {
+	s.mu.RLock()
+	store, window, onDuplicate, l := s.dupStore, s.dupWindow, s.onDuplicate, s.logger
+	s.mu.RUnlock()
+	if store == nil {
+		return nil
+	}
+	fp, t := msg.Fingerprint(), now()
+	rcpts := msg.RecipientAddrs()
+	for _, addr := range rcpts {
+		last, err := store.LastSent(addr, fp)
+		if err != nil {
+			return errors.New("Sender.Send: duplicate check failed: " + err.Error())
+		}
+		if last.IsZero() || t.Sub(last) > window {
+			continue
+		}
+		if l != nil {
+			l.Info("email: duplicate message", "message_id", msg.MessageID(), "recipient", addr, "last_sent", last)
+		}
+		if onDuplicate != nil {
+			if err = onDuplicate(msg, addr, last); err != nil {
+				return err
+			}
+		}
+	}
+	for _, addr := range rcpts {
+		if err := store.Record(addr, fp, t); err != nil {
+			return errors.New("Sender.Send: duplicate check failed: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// MemoryFingerprintStore is a FingerprintStore keeping the fingerprints in memory.
+type MemoryFingerprintStore struct {
+	mu   sync.Mutex
+	sent map[string]map[Fingerprint]time.Time
+}
+
+// NewMemoryFingerprintStore creates an empty MemoryFingerprintStore.
+func NewMemoryFingerprintStore() *MemoryFingerprintStore {
+	return &MemoryFingerprintStore{sent: map[string]map[Fingerprint]time.Time{}}
+}
+
+// LastSent implements FingerprintStore.
+func (s *MemoryFingerprintStore) LastSent(addr string, fp Fingerprint) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sent[strings.ToLower(addr)][fp], nil
+}
+
+// Record implements FingerprintStore.
+func (s *MemoryFingerprintStore) Record(addr string, fp Fingerprint, t time.Time) error {
+	addr = strings.ToLower(addr)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sent[addr] == nil {
+		s.sent[addr] = map[Fingerprint]time.Time{}
+	}
+	if t.After(s.sent[addr][fp]) {
+		s.sent[addr][fp] = t
+	}
+	return nil
+}
+
+// Prune removes the records older than `before`, to bound the memory used by the receiver.
+func (s *MemoryFingerprintStore) Prune(before time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for addr, fps := range s.sent {
+		for fp, t := range fps {
+			if t.Before(before) {
+				delete(fps, fp)
+			}
+		}
+		if len(fps) == 0 {
+			delete(s.sent, addr)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package email
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_NewFingerprint(t *testing.T) {
+	base := "Dear customer, your order #1234 has shipped and will arrive on Monday. Thank you for shopping with us!"
+	if a, b := NewFingerprint(base), NewFingerprint("  DEAR customer -- your order 1234 has shipped,\nand will arrive on monday.\tThank you for shopping with us"); a != b {
+		t.Errorf("NewFingerprint: got %s and %s for the same normalized text", a, b)
+	}
+	near := NewFingerprint("Dear customer, your order #1234 has shipped and will arrive on Tuesday. Thank you for shopping with us!")
+	other := NewFingerprint("The quarterly report is attached; please review the figures before the meeting next week.")
+	if d, o := NewFingerprint(base).Distance(near), NewFingerprint(base).Distance(other); d >= o {
+		t.Errorf("Fingerprint.Distance: got %d for a near-identical text and %d for a different one", d, o)
+	}
+	if s := Fingerprint(0xab).String(); s != "00000000000000ab" {
+		t.Errorf("Fingerprint.String: got %q", s)
+	}
+
+	msg := QuickMessage("Hi").Html("<p>Hello <b>there</b></p>").From(&Address{"", "test@example.com"})
+	msg.Compose(nil)
+	if fp := msg.Fingerprint(); fp != NewFingerprint("Hi\nHello there") {
+		t.Errorf("(*Message).Fingerprint: got %s, want %s", fp, NewFingerprint("Hi\nHello there"))
+	}
+}
+
+func Test_SenderDuplicateCheck(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	t0 := time.Unix(1000, 0)
+	now = func() time.Time { return t0 }
+
+	store := NewMemoryFingerprintStore()
+	errDup := errors.New("duplicate")
+	var dups []string
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	s.DryRun(DryRunCompose).DuplicateCheck(store, time.Hour, func(msg *Message, addr string, last time.Time) error {
+		dups = append(dups, addr)
+		return errDup
+	})
+	msg := QuickMessage("Hi {{.}}", "Hello").SubjectTemplate("Hi {{.}}").To(&Address{"", "a@example.com"})
+
+	if err := s.Send(msg, "Ann"); err != nil {
+		t.Fatalf("(*Sender).Send: unexpected error: %v", err)
+	}
+	if err := s.Send(msg, "Bob"); err != nil {
+		t.Errorf("(*Sender).Send: unexpected error for a different content: %v", err)
+	}
+	now = func() time.Time { return t0.Add(30 * time.Minute) }
+	if err := s.Send(msg, "Ann"); err != errDup || len(dups) != 1 || dups[0] != "a@example.com" {
+		t.Errorf("(*Sender).Send: got error %v and duplicates %v, want a duplicate", err, dups)
+	}
+	now = func() time.Time { return t0.Add(2 * time.Hour) }
+	if err := s.Send(msg, "Ann"); err != nil {
+		t.Errorf("(*Sender).Send: unexpected error after the window: %v", err)
+	}
+
+	store.Prune(t0.Add(time.Hour))
+	if last, _ := store.LastSent("A@example.com", msg.Fingerprint()); !last.Equal(t0.Add(2 * time.Hour)) {
+		t.Errorf("(*MemoryFingerprintStore).LastSent: got %v", last)
+	}
+	if n := len(store.sent["a@example.com"]); n != 1 {
+		t.Errorf("(*MemoryFingerprintStore).Prune: got %d records, want 1", n)
+	}
+}
@@ -0,0 +1,139 @@
+package email
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// FS sets the file system the files attached to the message, or referenced by its related items,
+// are read from - e.g. an embed.FS, so that a binary can ship its email assets embedded, without
+// depending on the OS file system. The names of the files are then slash-separated paths, as
+// accepted by fs.ValidPath, and AttachmentRoot does not apply. A nil `fsys` restores the OS file
+// system.
+func (m *Message) FS(fsys fs.FS) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.fsys = fsys
+	m.prepared = false
+	return m
+}
+
+// TemplatesFS sets the templates of the message from the source read from the file with the
+// `name` in `fsys`, as with Templates. See ReloadTemplates for picking up changes to the file.
+func (m *Message) TemplatesFS(fsys fs.FS, name string, related ...Related) *Message {
+	f := &templatesFile{fsys: fsys, name: name, related: related}
+	src, err := f.read()
+	m.Lock()
+	defer m.Unlock()
+	if err != nil {
+		m.fail(FileError, "templates", &ErrFileRead{name, err})
+		return m
+	}
+	m.templates(src, related)
+	m.tplFile = f
+	return m
+}
+
+// ReloadTemplates enables or disables the development mode, in which the file the templates of
+// the message were read from with TemplatesFS is checked each time the message is composed, and
+// read and parsed again if its modification time or size changed - so that the templates can be
+// edited without restarting the application. If the changed file cannot be read or parsed, the
+// error is recorded, and the file is read again when composing the message next time.
+//
+// It should not be enabled in production, as it adds a file system access to each composition.
+func (m *Message) ReloadTemplates(enable bool) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.reload = enable
+	return m
+}
+
+// templatesFile is a file the templates of a message are read from.
+type templatesFile struct {
+	fsys    fs.FS
+	name    string
+	related []Related
+	modTime time.Time
+	size    int64
+}
+
+// read reads the source of the templates from the file, recording its modification time and size.
+func (f *templatesFile) read() (string, error) {
+	r, err := f.fsys.Open(f.name)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	fi, err := r.Stat()
+	if err != nil {
+		return "", err
+	}
+	src, err := ioutil.ReadAll(r)
+	f.modTime, f.size = fi.ModTime(), fi.Size()
+	return string(src), err
+}
+
+// reloadTemplates reads and parses the templates of the receiver again, if their file changed.
+// The caller must hold the lock on the receiver.
+func (m *Message) reloadTemplates() {
+	f := m.tplFile
+	if f == nil {
+		return
+	}
+	fi, err := fs.Stat(f.fsys, f.name)
+	if err == nil && fi.ModTime().Equal(f.modTime) && fi.Size() == f.size {
+		return
+	}
+	nf := &templatesFile{fsys: f.fsys, name: f.name, related: f.related}
+	src, err := nf.read()
+	if err != nil {
+		m.fail(FileError, "templates", &ErrFileRead{f.name, err})
+		return
+	}
+	if m.templates(src, f.related) {
+		m.tplFile = nf
+	}
+}
+
+// openFile opens the file with the `name` in `fsys`, if not nil, or else in the OS file system,
+// confined to the `root` directory.
+func openFile(fsys fs.FS, root, name string) (fs.File, error) {
+	if fsys != nil {
+		return fsys.Open(name)
+	}
+	path, err := confine(root, name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// statFile returns the information about the file with the `name`, like openFile.
+func statFile(fsys fs.FS, root, name string) (fs.FileInfo, error) {
+	if fsys != nil {
+		return fs.Stat(fsys, name)
+	}
+	path, err := confine(root, name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(path)
+}
+
+// readFile reads the file with the `name`, like openFile, along with its modification time and
+// size.
+func readFile(fsys fs.FS, root, name string) fileResult {
+	f, err := openFile(fsys, root, name)
+	if err != nil {
+		return fileResult{err: err}
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return fileResult{err: err}
+	}
+	data, err := ioutil.ReadAll(f)
+	return fileResult{data: data, modTime: fi.ModTime(), size: fi.Size(), err: err}
+}
@@ -0,0 +1,79 @@
+package email
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func Test_FS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"mail/welcome.tpl": {Data: []byte(`{{define "subject"}}Welcome, {{.}}{{end}}` +
+			`{{define "html"}}<p>Hello, {{.}}</p><img src="cid:logo">{{end}}`)},
+		"assets/logo.png":  {Data: []byte("PNG"), ModTime: time.Unix(1e9, 0)},
+		"assets/terms.txt": {Data: []byte("Terms of service")},
+	}
+	msg := NewMessage(nil).From(&Address{"", "test@example.com"}).To(&Address{"", "to@example.com"}).FS(fsys).
+		TemplatesFS(fsys, "mail/welcome.tpl", RelatedFile("logo", "image/png", "assets/logo.png")).
+		Attach("assets/terms.txt")
+	act := string(msg.Compose("Ann"))
+	if errs := msg.Errors(); len(errs) > 0 {
+		t.Fatalf("(*Message).FS: unexpected errors: %v", errs)
+	}
+	for _, exp := range []string{"Subject: Welcome, Ann", "Hello, Ann", string(Base64Encode([]byte("PNG"))),
+		`filename="terms.txt"`, string(Base64Encode([]byte("Terms of service")))} {
+		if !strings.Contains(act, exp) {
+			t.Errorf("(*Message).FS: got %q, missing %q", act, exp)
+		}
+	}
+
+	msg = QuickMessage("test", "body").From(&Address{"", "test@example.com"}).FS(fsys).Attach("../etc/passwd").
+		TemplatesFS(fsys, "missing.tpl")
+	msg.Compose(nil)
+	errs := msg.Errors()
+	var fileErr *ErrFileRead
+	if len(errs) != 2 || !errors.As(errs[0], &fileErr) || fileErr.Path != "missing.tpl" ||
+		!errors.Is(errs[1], fs.ErrNotExist) && !errors.Is(errs[1], fs.ErrInvalid) {
+		t.Errorf("(*Message).FS: got errors %v", errs)
+	}
+}
+
+func Test_ReloadTemplates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"welcome.tpl": {Data: []byte(`{{define "subject"}}Welcome{{end}}{{define "text"}}Hello, {{.}}{{end}}`),
+			ModTime: time.Unix(1e9, 0)},
+	}
+	newMsg := func() *Message {
+		return NewMessage(nil).From(&Address{"", "test@example.com"}).To(&Address{"", "to@example.com"}).
+			TemplatesFS(fsys, "welcome.tpl")
+	}
+	msg, fixed := newMsg().ReloadTemplates(true), newMsg()
+	cases := []struct {
+		src  string
+		exp  string
+		errs int
+	}{
+		{"", "Hello, Ann", 0},
+		{`{{define "subject"}}Welcome{{end}}{{define "text"}}Hi, {{.}}{{end}}`, "Hi, Ann", 0},
+		{`{{define "text"}}Hi, {{.}`, "", 1},
+		{`{{define "subject"}}Welcome{{end}}{{define "text"}}Hey, {{.}}{{end}}`, "Hey, Ann", 0},
+	}
+	for i, c := range cases {
+		if c.src != "" {
+			fsys["welcome.tpl"] = &fstest.MapFile{Data: []byte(c.src), ModTime: time.Unix(1e9+int64(i), 0)}
+		}
+		act := string(msg.Compose("Ann"))
+		if errs := msg.Errors(); len(errs) != c.errs {
+			t.Errorf("(*Message).ReloadTemplates [%d]: got errors %v want %d", i, errs, c.errs)
+		}
+		if !strings.Contains(act, c.exp) {
+			t.Errorf("(*Message).ReloadTemplates [%d]: got %q want %q", i, act, c.exp)
+		}
+	}
+	if act := string(fixed.Compose("Ann")); !strings.Contains(act, "Hello, Ann") {
+		t.Errorf("(*Message).ReloadTemplates: got %q for a message not reloading", act)
+	}
+}
@@ -0,0 +1,249 @@
+package email
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// header represents an additional message header.
+type header struct {
+	name, value string
+}
+
+// Header adds a header with the given name and value to the message, e.g. for custom "X-" headers.
+// Non-ASCII values are q-encoded as needed.
+//
+// The headers generated by Compose (e.g. "From", "Subject" or "Content-Type") are not meant to be
+// set this way, and doing so will likely produce an invalid message.
+func (m *Message) Header(name, value string) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.addHeader(name, value)
+	return m
+}
+
+// addHeader validates and adds a header to the receiver. The caller must hold the lock on the
+// receiver.
+func (m *Message) addHeader(name, value string) {
+	if !validHeaderName(name) {
+		m.fail(HeaderError, name, errors.New("invalid header name: "+name))
+		return
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\r' || value[i] == '\n' {
+			m.fail(HeaderError, name, errors.New("invalid header value: "+name))
+			return
+		}
+	}
+	m.headers = append(m.headers, header{name, value})
+}
+
+// validHeaderName checks that `name` is a non-empty sequence of printable ASCII characters other
+// than colon, as required by RFC 5322.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c < '!' || c > '~' || c == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeHeader returns the header line for `name` and `value`, q-encoding the value if needed, and
+// otherwise folding it at white space to keep the lines within 78 characters when possible.
+func encodeHeader(name, value string) []byte {
+	offset := len(name) + 2
+	enc := QEncodeIfNeeded([]byte(value), offset)
+	dst := make([]byte, 0, offset+len(enc)+8)
+	dst = append(dst, name...)
+	dst = append(dst, ':', ' ')
+	if len(enc) != len(value) || offset+len(value) <= 78 {
+		return append(append(dst, enc...), '\r', '\n')
+	}
+	// fold long ASCII values before the white space closest to the line limit
+	for lineLen := offset; len(value) > 0; {
+		cut := -1
+		for i := 1; i < len(value); i++ {
+			if value[i] == ' ' || value[i] == '\t' {
+				if lineLen+i > 78 && cut > 0 {
+					break
+				}
+				cut = i
+			}
+		}
+		if lineLen+len(value) <= 78 || cut < 0 {
+			dst = append(dst, value...)
+			break
+		}
+		dst = append(dst, value[:cut]...)
+		dst = append(dst, '\r', '\n')
+		value = value[cut:]
+		lineLen = 0
+	}
+	return append(dst, '\r', '\n')
+}
+
+// InReplyTo sets the Message-ID of the message the receiver replies to, so that it is threaded
+// with that message by the mail clients of the recipients. The `id` may be given with or without
+// the angle brackets, e.g. as returned by MessageID.
+//
+// Unless set with References, the References header is set to the same id.
+func (m *Message) InReplyTo(id string) *Message {
+	m.Lock()
+	defer m.Unlock()
+	ids := m.msgIDs("In-Reply-To", id)
+	m.inReplyTo = ""
+	if len(ids) > 0 {
+		m.inReplyTo = ids[0]
+	}
+	return m
+}
+
+// References sets the Message-IDs of the messages in the thread of the receiver - usually the
+// References of the message it replies to, followed by the Message-ID of that message. The `ids`
+// may be given with or without the angle brackets. Last call overrides any previous calls.
+func (m *Message) References(ids ...string) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.references = m.msgIDs("References", ids...)
+	return m
+}
+
+// msgIDs normalizes the message identifiers in `values`, recording an error for the invalid ones.
+// The caller must hold the lock on the receiver.
+func (m *Message) msgIDs(name string, values ...string) []string {
+	var ids []string
+	for _, value := range values {
+		for _, id := range parseMsgIDs(value) {
+			if strings.ContainsAny(id, "<>\r\n") || !strings.Contains(id, "@") {
+				m.fail(HeaderError, name, errors.New("invalid message id in "+name+": "+id))
+				continue
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// threadHeaders returns the In-Reply-To and References headers of the receiver, if any.
+func (m *Message) threadHeaders() []byte {
+	refs := m.references
+	if len(refs) == 0 && m.inReplyTo != "" {
+		refs = []string{m.inReplyTo}
+	}
+	var buf []byte
+	if m.inReplyTo != "" {
+		buf = append(buf, "In-Reply-To: <"+m.inReplyTo+">\r\n"...)
+	}
+	if len(refs) > 0 {
+		buf = append(buf, encodeHeader("References", "<"+strings.Join(refs, "> <")+">")...)
+	}
+	return buf
+}
+
+// RequestReadReceipt asks the mail clients of the recipients to send a read receipt - a Message
+// Disposition Notification, as defined by RFC 8098 - to `addr` when the message is displayed. It
+// sets the Disposition-Notification-To header, along with the legacy Return-Receipt-To header.
+// A nil `addr` cancels the request.
+//
+// Mail clients commonly ask the user before sending a receipt, or ignore the request altogether, so
+// it cannot be relied on as a proof of delivery.
+func (m *Message) RequestReadReceipt(addr *Address) *Message {
+	m.Lock()
+	defer m.Unlock()
+	if addr != nil && !SeemsValidAddr(addr.Addr) {
+		m.fail(AddressError, "Disposition-Notification-To", errors.New("invalid read receipt address: "+addr.Addr))
+		return m
+	}
+	m.receiptTo = addr
+	return m
+}
+
+// receiptHeaders returns the read receipt request headers of the receiver, if any.
+func (m *Message) receiptHeaders() []byte {
+	if m.receiptTo == nil {
+		return nil
+	}
+	addr, _ := m.receiptTo.encode(29)
+	buf := append([]byte("Disposition-Notification-To: "), addr...)
+	addr, _ = m.receiptTo.encode(19)
+	buf = append(append(append(buf, "\r\nReturn-Receipt-To: "...), addr...), '\r', '\n')
+	return buf
+}
+
+// Priority represents the importance of a message, as signaled to the mail clients.
+type Priority byte
+
+const (
+	// DefaultPriority leaves the priority of the message unspecified.
+	DefaultPriority Priority = iota
+	// HighPriority flags the message as urgent.
+	HighPriority
+	// NormalPriority explicitly marks the message as having normal priority.
+	NormalPriority
+	// LowPriority marks the message as having low priority.
+	LowPriority
+)
+
+// Priority sets the importance of the message, emitting the X-Priority, Importance and
+// X-MSMail-Priority headers recognized by the various mail clients - e.g. so that urgent alerts are
+// flagged in Outlook and Gmail.
+func (m *Message) Priority(p Priority) *Message {
+	m.Lock()
+	defer m.Unlock()
+	if p > LowPriority {
+		m.fail(ArgumentError, "priority", errors.New("invalid priority: "+strconv.Itoa(int(p))))
+		return m
+	}
+	m.priority = p
+	return m
+}
+
+// priorityHeaders returns the priority headers of the receiver, if any.
+func (m *Message) priorityHeaders() []byte {
+	switch m.priority {
+	case HighPriority:
+		return []byte("X-Priority: 1 (Highest)\r\nImportance: high\r\nX-MSMail-Priority: High\r\n")
+	case NormalPriority:
+		return []byte("X-Priority: 3 (Normal)\r\nImportance: normal\r\nX-MSMail-Priority: Normal\r\n")
+	case LowPriority:
+		return []byte("X-Priority: 5 (Lowest)\r\nImportance: low\r\nX-MSMail-Priority: Low\r\n")
+	}
+	return nil
+}
+
+// SenderHeader sets the address of the agent actually responsible for transmitting the message, in
+// a Sender header, for when it differs from the author in From - e.g. when sending on behalf of a
+// user. A nil `addr` restores the default: the address of the Sender composing the message, if it
+// has one and it differs from the From address.
+//
+// Setting it accurately helps diagnosing DMARC and ARC alignment issues, and lets mail clients show
+// messages as sent "on behalf of" the author.
+func (m *Message) SenderHeader(addr *Address) *Message {
+	m.Lock()
+	defer m.Unlock()
+	if addr != nil && !SeemsValidAddr(addr.Addr) {
+		m.fail(AddressError, "Sender", errors.New("invalid Sender address: "+addr.Addr))
+		return m
+	}
+	m.senderAddr = addr
+	return m
+}
+
+// senderHeader returns the Sender header of the receiver, with the `from` address, composed by the
+// `sender`, if not nil - or nil if the header is not needed.
+func (m *Message) senderHeader(from *Address, sender *Sender) []byte {
+	addr := m.senderAddr
+	if addr == nil && sender != nil {
+		addr = sender.address
+	}
+	if addr == nil || strings.EqualFold(addr.Addr, from.Addr) {
+		return nil
+	}
+	dst, _ := addr.encode(8)
+	return append(append([]byte("Sender: "), dst...), '\r', '\n')
+}
@@ -0,0 +1,122 @@
+package email
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_encodeHeader(t *testing.T) {
+	cases := []struct {
+		name, value, exp string
+	}{
+		{"X-Test", "short value", "X-Test: short value\r\n"},
+		{"X-Test", "nåmé", "X-Test: =?utf-8?q?n=C3=A5m=C3=A9?=\r\n"},
+		{"X-Original-To", "first-recipient@example.com, second-recipient@example.com, third-recipient@example.com",
+			"X-Original-To: first-recipient@example.com, second-recipient@example.com,\r\n" +
+				" third-recipient@example.com\r\n"},
+		{"X-Test", "a-very-long-value-without-any-white-space-that-cannot-be-folded-anywhere-at-all",
+			"X-Test: a-very-long-value-without-any-white-space-that-cannot-be-folded-anywhere-at-all\r\n"},
+	}
+	for i, c := range cases {
+		if act := string(encodeHeader(c.name, c.value)); act != c.exp {
+			t.Errorf("encodeHeader [%d]: got\n%q\nwant\n%q", i, act, c.exp)
+		}
+	}
+}
+
+func Test_Header(t *testing.T) {
+	msg := NewMessage(nil).Header("X-Ok", "value").Header("Bad Name", "value").Header("X-Bad", "a\r\nBcc: x")
+	if len(msg.headers) != 1 || len(msg.Errors()) != 2 {
+		t.Errorf("(*Message).Header: got headers %v", msg.headers)
+	}
+}
+
+func Test_InReplyTo(t *testing.T) {
+	cases := []struct {
+		msg *Message
+		exp string
+	}{
+		{QuickMessage("Re: test", "body").InReplyTo("<parent@example.com>"),
+			"In-Reply-To: <parent@example.com>\r\nReferences: <parent@example.com>\r\n"},
+		{QuickMessage("Re: test", "body").InReplyTo("parent@example.com").
+			References("<root@example.com> <mid@example.com>", "parent@example.com"),
+			"In-Reply-To: <parent@example.com>\r\nReferences: <root@example.com> <mid@example.com> <parent@example.com>\r\n"},
+		{QuickMessage("test", "body"), ""},
+	}
+	for i, c := range cases {
+		if act := string(c.msg.threadHeaders()); act != c.exp {
+			t.Errorf("(*Message).threadHeaders [%d]: got\n%q\nwant\n%q", i, act, c.exp)
+		}
+	}
+	msg := QuickMessage("Re: test", "body").From(&Address{"", "test@example.com"}).InReplyTo("<parent@example.com>")
+	e, err := ReadEntity(bytes.NewReader(msg.Compose(nil)), nil)
+	if err != nil || e.Header.Get("In-Reply-To") != "<parent@example.com>" || e.Header.Get("References") != "<parent@example.com>" {
+		t.Errorf("(*Message).Compose: got header %v, error %v", e.Header, err)
+	}
+	if msg = NewMessage(nil).InReplyTo("not an id"); len(msg.Errors()) == 0 || msg.inReplyTo != "" {
+		t.Error("(*Message).InReplyTo: expected an error for an invalid id")
+	}
+}
+
+func Test_RequestReadReceipt(t *testing.T) {
+	msg := QuickMessage("Notice", "body").RequestReadReceipt(&Address{"HR", "hr@example.com"})
+	if act, exp := string(msg.receiptHeaders()), "Disposition-Notification-To: \"HR\" <hr@example.com>\r\n"+
+		"Return-Receipt-To: \"HR\" <hr@example.com>\r\n"; act != exp {
+		t.Errorf("(*Message).receiptHeaders: got\n%q\nwant\n%q", act, exp)
+	}
+	if msg.RequestReadReceipt(nil); msg.receiptHeaders() != nil {
+		t.Error("(*Message).RequestReadReceipt: expected no headers after cancelling")
+	}
+	if msg = NewMessage(nil).RequestReadReceipt(&Address{"", "invalid"}); len(msg.Errors()) == 0 {
+		t.Error("(*Message).RequestReadReceipt: expected an error for an invalid address")
+	}
+}
+
+func Test_Priority(t *testing.T) {
+	cases := []struct {
+		p   Priority
+		exp string
+	}{
+		{DefaultPriority, ""},
+		{HighPriority, "X-Priority: 1 (Highest)\r\nImportance: high\r\nX-MSMail-Priority: High\r\n"},
+		{NormalPriority, "X-Priority: 3 (Normal)\r\nImportance: normal\r\nX-MSMail-Priority: Normal\r\n"},
+		{LowPriority, "X-Priority: 5 (Lowest)\r\nImportance: low\r\nX-MSMail-Priority: Low\r\n"},
+	}
+	for i, c := range cases {
+		if act := string(QuickMessage("test", "body").Priority(c.p).priorityHeaders()); act != c.exp {
+			t.Errorf("(*Message).priorityHeaders [%d]: got\n%q\nwant\n%q", i, act, c.exp)
+		}
+	}
+	msg := QuickMessage("Alert", "body").From(&Address{"", "test@example.com"}).Priority(HighPriority)
+	e, err := ReadEntity(bytes.NewReader(msg.Compose(nil)), nil)
+	if err != nil || e.Header.Get("Importance") != "high" {
+		t.Errorf("(*Message).Compose: got header %v, error %v", e.Header, err)
+	}
+	if msg = NewMessage(nil).Priority(Priority(9)); len(msg.Errors()) == 0 {
+		t.Error("(*Message).Priority: expected an error for an invalid priority")
+	}
+}
+
+func Test_SenderHeader(t *testing.T) {
+	from := &Address{"Ann", "ann@example.com"}
+	s := &Sender{address: &Address{"", "mailer@example.com"}}
+	cases := []struct {
+		msg    *Message
+		sender *Sender
+		exp    string
+	}{
+		{NewMessage(nil), nil, ""},
+		{NewMessage(nil), s, "Sender: <mailer@example.com>\r\n"},
+		{NewMessage(nil), &Sender{address: &Address{"", "Ann@example.com"}}, ""},
+		{NewMessage(nil).SenderHeader(&Address{"Desk", "desk@example.com"}), s, "Sender: \"Desk\" <desk@example.com>\r\n"},
+		{NewMessage(nil).SenderHeader(from), s, ""},
+	}
+	for i, c := range cases {
+		if act := string(c.msg.senderHeader(from, c.sender)); act != c.exp {
+			t.Errorf("(*Message).senderHeader [%d]: got\n%q\nwant\n%q", i, act, c.exp)
+		}
+	}
+	if msg := NewMessage(nil).SenderHeader(&Address{"", "invalid"}); len(msg.Errors()) == 0 {
+		t.Error("(*Message).SenderHeader: expected an error for an invalid address")
+	}
+}
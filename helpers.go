@@ -25,7 +25,32 @@ var (
 	htmlToTextREAHref = regexp.MustCompile(`(?is)<a [^>]*href\s*=\s*"([^"]+)".*</a>`)
 )
 
-func htmlToText(src string) string {
+// TextConverter converts HTML content to plain text.
+type TextConverter func(html string) string
+
+// TextOptions configure the conversion of HTML content to plain text.
+type TextOptions struct {
+	// Links includes the URL of each link, in square brackets, after the link text.
+	Links bool
+	// AltText includes the alternate text of each image in place of the image.
+	AltText bool
+}
+
+// DefaultTextOptions are used for generating the text alternative of messages that do not have
+// their own TextConverter.
+var DefaultTextOptions = TextOptions{Links: true, AltText: true}
+
+// HTMLToText converts the HTML content `src` to plain text, using the DefaultTextOptions.
+func HTMLToText(src string) string {
+	return DefaultTextOptions.Convert(src)
+}
+
+// Convert converts the HTML content `src` to plain text, according to the receiver: the content
+// of the head, style and script elements is removed, line breaks are inserted for the block
+// elements, tags are stripped, entities are decoded and whitespace is collapsed.
+//
+// Convert is a TextConverter.
+func (o TextOptions) Convert(src string) string {
 	// reduce multiple whitespace chars to single space
 	src = htmlToTextREWhitespace.ReplaceAllLiteralString(src, " ")
 	// remove these tags completely, including contents
@@ -34,10 +59,14 @@ func htmlToText(src string) string {
 	src = htmlToTextRETagsLn.ReplaceAllString(src, "\n$0")
 	// make sure we have white space before these tags
 	src = htmlToTextRETagsSp.ReplaceAllString(src, " $0")
-	// extract the alt text from images
-	src = htmlToTextREImgAlt.ReplaceAllString(src, "$1$0")
-	// extract the "href" url from links
-	src = htmlToTextREAHref.ReplaceAllString(src, "$0 [ $1 ] ")
+	if o.AltText {
+		// extract the alt text from images
+		src = htmlToTextREImgAlt.ReplaceAllString(src, "$1$0")
+	}
+	if o.Links {
+		// extract the "href" url from links
+		src = htmlToTextREAHref.ReplaceAllString(src, "$0 [ $1 ] ")
+	}
 	// strip tags
 	src = reHtmlTags.ReplaceAllLiteralString(src, "")
 	// convert html entities to UTF-8 characters
@@ -51,3 +80,39 @@ func htmlToText(src string) string {
 	})
 	return strings.TrimSpace(src)
 }
+
+// AutoText enables or disables generating a text/plain alternative from the HTML body, when the
+// message has no text body; it is enabled by default.
+//
+// The alternative is generated each time the message is composed, from the HTML content as sent -
+// i.e. the output of the HTML template, if any, for the data given to Compose, after sanitizing and
+// inlining the CSS - using the converter set with TextConverter.
+func (m *Message) AutoText(enable bool) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.noAutoText = !enable
+	return m
+}
+
+// hasAutoText reports whether a text/plain alternative is generated from the HTML body of the
+// receiver.
+func (m *Message) hasAutoText() bool {
+	return m.html != nil && m.text == nil && !m.noAutoText
+}
+
+// toText converts the HTML content `html` to the text/plain alternative of the receiver.
+func (m *Message) toText(html []byte) string {
+	if m.textConverter == nil {
+		return HTMLToText(string(html))
+	}
+	return m.textConverter(string(html))
+}
+
+// TextConverter sets the function used for generating the text/plain alternative from the HTML
+// body - e.g. the Convert method of some TextOptions; a nil `conv` restores HTMLToText.
+func (m *Message) TextConverter(conv TextConverter) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.textConverter = conv
+	return m
+}
@@ -0,0 +1,76 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_TextOptionsConvert(t *testing.T) {
+	src := `<html><head><title>T</title></head><body><h1>Title</h1><p>See <a href="https://example.com">this</a>` +
+		` &amp; <img src="x.png" alt="that"></p><script>x()</script></body></html>`
+	cases := []struct {
+		opts TextOptions
+		exp  string
+	}{
+		{TextOptions{Links: true, AltText: true}, "Title\n\nSee this [ https://example.com ] & that"},
+		{TextOptions{}, "Title\n\nSee this &"},
+	}
+	for i, c := range cases {
+		act := c.opts.Convert(src)
+		if act != c.exp {
+			t.Errorf("TextOptions.Convert [%d]: got %q want %q", i, act, c.exp)
+		}
+	}
+}
+
+func Test_MessageAutoText(t *testing.T) {
+	newMsg := func() *Message {
+		return NewMessage(nil).From(&Address{"", "test@example.com"}).To(&Address{"", "to@example.com"}).
+			Subject("test").Html(`<p>Hello</p>`)
+	}
+	cases := []struct {
+		msg  *Message
+		text string
+		alt  bool
+	}{
+		{newMsg(), "Hello", true},
+		{newMsg().TextConverter(strings.ToUpper), "<P>HELLO</P>", true},
+		{newMsg().AutoText(false), "", false},
+	}
+	for i, c := range cases {
+		act := string(c.msg.Compose(nil))
+		if errs := c.msg.Errors(); len(errs) > 0 {
+			t.Fatalf("(*Message).AutoText [%d]: unexpected errors: %v", i, errs)
+		}
+		if alt := strings.Contains(act, "multipart/alternative"); alt != c.alt {
+			t.Errorf("(*Message).AutoText [%d]: got alternative %v want %v", i, alt, c.alt)
+		}
+		if hasText := strings.Contains(act, "text/plain"); hasText != (c.text != "") ||
+			c.text != "" && !strings.Contains(act, c.text) {
+			t.Errorf("(*Message).AutoText [%d]: got %q want text %q", i, act, c.text)
+		}
+	}
+}
+
+func Test_MessageAutoTextTemplate(t *testing.T) {
+	msg := NewMessage(nil).From(&Address{"", "test@example.com"}).To(&Address{"", "to@example.com"}).
+		Subject("test").HtmlTemplate(`<p>Hello, <b>{{.name}}</b></p>`)
+	for i, name := range []string{"Ann", "Bob"} {
+		act := string(msg.Compose(map[string]string{"name": name}))
+		if errs := msg.Errors(); len(errs) > 0 {
+			t.Fatalf("(*Message).AutoText [%d]: unexpected errors: %v", i, errs)
+		}
+		if exp := "Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nHello, " +
+			name + "\r\n"; !strings.Contains(act, exp) {
+			t.Errorf("(*Message).AutoText [%d]: got %q want text %q", i, act, exp)
+		}
+	}
+	r, _ := msg.TestRender(map[string]string{"name": "Cy"})
+	if r.Text != "Hello, Cy" {
+		t.Errorf("(*Message).AutoText: got rendered text %q want %q", r.Text, "Hello, Cy")
+	}
+	r, _ = msg.AutoText(false).TestRender(map[string]string{"name": "Cy"})
+	if r.Text != "" {
+		t.Errorf("(*Message).AutoText: got rendered text %q want none", r.Text)
+	}
+}
@@ -0,0 +1,146 @@
+package email
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// Punycode parameters, as defined by RFC 3492.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+// ToASCIIDomain converts the internationalized domain name `domain`, such as "bücher.example", to
+// its ASCII form, made of A-labels - e.g. "xn--bcher-kva.example" - as required for transmitting
+// it to the servers not supporting SMTPUTF8. The labels are lowercased and Punycode-encoded; the
+// full UTS #46 mapping is not applied, so the domain should be given in its normalized form. ASCII
+// domains are returned as they are.
+func ToASCIIDomain(domain string) (string, error) {
+	if isASCII(domain) {
+		return domain, nil
+	}
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		enc, err := punycodeEncode(strings.ToLower(label))
+		if err != nil {
+			return "", err
+		}
+		if labels[i] = "xn--" + enc; len(labels[i]) > 63 {
+			return "", errors.New("email: domain label too long: " + label)
+		}
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// ASCII returns the address of the receiver with its domain in ASCII form, as converted by
+// ToASCIIDomain, for transmitting it - while Addr keeps the original form, for display. The
+// address is returned as it is if its domain cannot be converted.
+func (a *Address) ASCII() string {
+	at := strings.LastIndexByte(a.Addr, '@')
+	if at < 0 || isASCII(a.Addr[at+1:]) {
+		return a.Addr
+	}
+	domain, err := ToASCIIDomain(a.Addr[at+1:])
+	if err != nil {
+		return a.Addr
+	}
+	return a.Addr[:at+1] + domain
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// punycodeEncode encodes the `label` with Punycode, as defined by RFC 3492.
+func punycodeEncode(label string) (string, error) {
+	runes := []rune(label)
+	out := make([]byte, 0, len(label)+8)
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			out = append(out, byte(r))
+		}
+	}
+	basic := len(out)
+	if basic > 0 {
+		out = append(out, '-')
+	}
+	n, delta, bias := rune(punyInitialN), 0, punyInitialBias
+	for h := basic; h < len(runes); n++ {
+		m := rune(utf8.MaxRune + 1)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		if int(m-n) > (1<<31-1-delta)/(h+1) {
+			return "", errors.New("email: cannot encode domain label: " + label)
+		}
+		delta += int(m-n) * (h + 1)
+		n = m
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := k - bias
+				if t < punyTMin {
+					t = punyTMin
+				} else if t > punyTMax {
+					t = punyTMax
+				}
+				if q < t {
+					break
+				}
+				out = append(out, punycodeDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punycodeDigit(q))
+			bias = punycodeAdapt(delta, h+1, h == basic)
+			delta = 0
+			h++
+		}
+		delta++
+	}
+	return string(out), nil
+}
+
+func punycodeDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punycodeAdapt(delta, points int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / points
+	k := 0
+	for delta > (punyBase-punyTMin)*punyTMax/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
@@ -0,0 +1,41 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_ToASCIIDomain(t *testing.T) {
+	cases := []struct {
+		src, exp string
+		err      bool
+	}{
+		{"example.com", "example.com", false},
+		{"bücher.example", "xn--bcher-kva.example", false},
+		{"München.example", "xn--mnchen-3ya.example", false},
+		{"例え.テスト", "xn--r8jz45g.xn--zckzah", false},
+		{strings.Repeat("ü", 60) + ".example", "", true},
+	}
+	for i, c := range cases {
+		act, err := ToASCIIDomain(c.src)
+		if act != c.exp || (err != nil) != c.err {
+			t.Errorf("ToASCIIDomain [%d]: got %q, %v want %q", i, act, err, c.exp)
+		}
+	}
+
+	a := &Address{"Ann", "ann@bücher.example"}
+	if !SeemsValidAddr(a.Addr) || a.ASCII() != "ann@xn--bcher-kva.example" || a.Domain() != "xn--bcher-kva.example" {
+		t.Errorf("(*Address).ASCII: got %q, %q", a.ASCII(), a.Domain())
+	}
+	msg := QuickMessage("test", "body").From(a).To(&Address{"", "bob@münchen.example"})
+	act := string(msg.Compose(nil))
+	for _, exp := range []string{"From: \"Ann\" <ann@xn--bcher-kva.example>", "To: <bob@xn--mnchen-3ya.example>",
+		"@xn--bcher-kva.example>\r\n"} {
+		if !strings.Contains(act, exp) {
+			t.Errorf("(*Address).ASCII: got %q, missing %q", act, exp)
+		}
+	}
+	if rcpts := msg.RecipientAddrs(); len(rcpts) != 1 || rcpts[0] != "bob@xn--mnchen-3ya.example" {
+		t.Errorf("(*Address).ASCII: got recipients %v", rcpts)
+	}
+}
@@ -0,0 +1,120 @@
+package email
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/agext/uuid"
+)
+
+// IDSource generates the unique identifiers used in the Message-ID header and, by default, the MIME
+// boundaries of the composed messages. The identifiers must only contain ASCII letters, digits and the
+// characters "-", "." and "_". Implementations must be safe for concurrent use.
+type IDSource interface {
+	NewID() string
+}
+
+// IDSourceFunc adapts a function to the IDSource interface.
+type IDSourceFunc func() string
+
+// NewID returns f().
+func (f IDSourceFunc) NewID() string {
+	return f()
+}
+
+var (
+	// UUIDv4 generates random (version 4) UUIDs, in hex form.
+	UUIDv4 IDSource = IDSourceFunc(func() string { return uuid.New().Hex() })
+	// UUIDv7 generates time-ordered (version 7) UUIDs, in hex form.
+	UUIDv7 IDSource = IDSourceFunc(newUUIDv7)
+	// ULID generates ULIDs - time-ordered identifiers in Crockford's base32 form.
+	ULID IDSource = IDSourceFunc(newULID)
+
+	// DefaultIDSource is used by the messages having no IDSource set, and not sent by a Sender
+	// having one.
+	DefaultIDSource = UUIDv4
+)
+
+// IDs sets the source of the unique identifiers used when composing the message, overriding the
+// one of the sender.
+func (m *Message) IDs(src IDSource) *Message {
+	m.Lock()
+	m.ids = src
+	m.Unlock()
+	return m
+}
+
+// IDs sets the source of the unique identifiers used when composing the messages sent by the
+// receiver, unless they have their own.
+func (s *Sender) IDs(src IDSource) *Sender {
+	s.mu.Lock()
+	s.ids = src
+	s.mu.Unlock()
+	return s
+}
+
+// idSource returns the IDSource for composing the receiver, sent by the `sender`, if not nil.
+// The caller must hold the lock on the receiver.
+func (m *Message) idSource(sender *Sender) IDSource {
+	if m.ids != nil {
+		return m.ids
+	}
+	if sender != nil {
+		sender.mu.RLock()
+		ids := sender.ids
+		sender.mu.RUnlock()
+		if ids != nil {
+			return ids
+		}
+	}
+	return DefaultIDSource
+}
+
+// SequenceIDs returns a deterministic IDSource, generating the `prefix` followed by a counter
+// starting from 1 - e.g. "test-1", "test-2" - for tests and fixtures.
+func SequenceIDs(prefix string) IDSource {
+	var n uint64
+	return IDSourceFunc(func() string {
+		return prefix + strconv.FormatUint(atomic.AddUint64(&n, 1), 10)
+	})
+}
+
+// FixedID returns an IDSource always generating the `id` - only suitable for tests and fixtures
+// composing one message at a time.
+func FixedID(id string) IDSource {
+	return IDSourceFunc(func() string { return id })
+}
+
+func newUUIDv7() string {
+	var b [16]byte
+	rand.Read(b[6:])
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	binary.BigEndian.PutUint16(b[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:6], uint32(ms))
+	b[6] = b[6]&0x0f | 0x70 // version 7
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return hex.EncodeToString(b[:])
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func newULID() string {
+	var b [16]byte
+	rand.Read(b[6:])
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	binary.BigEndian.PutUint16(b[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:6], uint32(ms))
+	// 128 bits in 26 characters of 5 bits each, the first one holding only 3 bits
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
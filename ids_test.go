@@ -0,0 +1,43 @@
+package email
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func Test_IDSource(t *testing.T) {
+	cases := []struct {
+		src IDSource
+		re  *regexp.Regexp
+	}{
+		{UUIDv4, regexp.MustCompile(`^[0-9a-f]{32}$`)},
+		{UUIDv7, regexp.MustCompile(`^[0-9a-f]{12}7[0-9a-f]{3}[89ab][0-9a-f]{15}$`)},
+		{ULID, regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)},
+	}
+	for i, c := range cases {
+		a, b := c.src.NewID(), c.src.NewID()
+		if !c.re.MatchString(a) || a == b {
+			t.Errorf("IDSource [%d]: got %q and %q", i, a, b)
+		}
+	}
+	if a, b := ULID.NewID(), ULID.NewID(); a[:10] > b[:10] {
+		t.Errorf("ULID: got %q after %q, want time-ordered ids", b, a)
+	}
+
+	seq := SequenceIDs("test-")
+	if a, b := seq.NewID(), seq.NewID(); a != "test-1" || b != "test-2" {
+		t.Errorf("SequenceIDs: got %q and %q", a, b)
+	}
+
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	s.IDs(SequenceIDs("sender-"))
+	msg := QuickMessage("test", "body").Sender(s)
+	if body := string(msg.Compose(nil)); msg.MessageID() != "<sender-1@example.com>" || !strings.Contains(body, "Message-ID: <sender-1@example.com>\r\n") {
+		t.Errorf("(*Sender).IDs: got message id %q", msg.MessageID())
+	}
+	msg.IDs(FixedID("fixed"))
+	if msg.Compose(nil); msg.MessageID() != "<fixed@example.com>" {
+		t.Errorf("(*Message).IDs: got message id %q", msg.MessageID())
+	}
+}
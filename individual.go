@@ -0,0 +1,87 @@
+package email
+
+import "strings"
+
+// IndividualDelivery enables or disables the individual delivery mode of the receiver: each message
+// is delivered to each of its recipients in a separate SMTP transaction, with only that recipient
+// in the envelope, so that large Bcc blasts do not expose correlations between the recipients, and
+// a failure for a recipient does not affect the others.
+//
+// If `addressTo` is true, the message is composed for each recipient, with that recipient as its
+// only To address and without Cc; otherwise, it is composed once, and its headers list the To and
+// Cc recipients, as usual. In sandbox mode, the message is delivered once, to the sandbox address.
+func (s *Sender) IndividualDelivery(enable, addressTo bool) *Sender {
+	s.mu.Lock()
+	s.individual, s.individualTo = enable, enable && addressTo
+	s.mu.Unlock()
+	return s
+}
+
+// RecipientErrors is returned when a message sent synchronously in the individual delivery mode
+// cannot be delivered to some of its recipients; it is still delivered to the other recipients.
+type RecipientErrors []*RecipientError
+
+func (e RecipientErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "email: delivery failed for " + strings.Join(msgs, "; ")
+}
+
+// deliveries composes `msg` using the `data`, returning the deliveries needed for sending it: a
+// single one, or one for each recipient in the individual delivery mode.
+func (s *Sender) deliveries(msg *Message, data interface{}) ([]delivery, error) {
+	s.mu.RLock()
+	individual, addressTo, sandboxed := s.individual, s.individualTo, s.sandbox != nil
+	s.mu.RUnlock()
+	if addressTo && !sandboxed && msg != nil {
+		if rcpts := msg.recipients(); len(rcpts) > 0 {
+			ds := make([]delivery, 0, len(rcpts))
+			for _, rcpt := range rcpts {
+				m := NewMessage(msg).To(rcpt).Cc().Bcc()
+				body, from, to, err := s.compose(m, data)
+				errs := m.Errors()
+				msg.Lock()
+				msg.errors = append(msg.errors, errs...)
+				msg.id, msg.fingerprint = m.MessageID(), m.Fingerprint()
+				msg.Unlock()
+				if err != nil {
+					return nil, err
+				}
+				ds = append(ds, delivery{msg, m.MessageID(), m.Fingerprint(), from, to, body, now()})
+			}
+			return ds, nil
+		}
+	}
+	body, from, to, err := s.compose(msg, data)
+	if err != nil {
+		return nil, err
+	}
+	if !individual || len(to) < 2 {
+		return []delivery{newDelivery(msg, from, to, body)}, nil
+	}
+	ds := make([]delivery, len(to))
+	for i, addr := range to {
+		ds[i] = newDelivery(msg, from, []string{addr}, body)
+	}
+	return ds, nil
+}
+
+// recipients returns the To, Cc and Bcc addresses of the receiver, listing only once the addresses
+// that are Equal.
+func (m *Message) recipients() []*Address {
+	m.RLock()
+	defer m.RUnlock()
+	var rcpts []*Address
+	seen := map[string]bool{}
+	for _, lst := range []addrList{m.to, m.cc, m.bcc} {
+		for _, a := range lst {
+			if key := a.key(); !seen[key] {
+				seen[key] = true
+				rcpts = append(rcpts, a)
+			}
+		}
+	}
+	return rcpts
+}
@@ -0,0 +1,110 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func Test_IndividualDelivery(t *testing.T) {
+	defer func(f func(string, smtp.Auth, string, []string, []byte) error) { sendMail = f }(sendMail)
+	var (
+		mu   sync.Mutex
+		sent = map[string]string{}
+	)
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		if len(to) != 1 {
+			t.Errorf("(*Sender).IndividualDelivery: got envelope recipients %v", to)
+		}
+		if to[0] == "fail@example.com" {
+			return errors.New("550 no such user")
+		}
+		mu.Lock()
+		sent[strings.Join(to, ",")] = string(msg)
+		mu.Unlock()
+		return nil
+	}
+	newMsg := func() *Message {
+		return QuickMessage("test", "body").From(&Address{"", "ann@example.com"}).
+			To(&Address{"Bob", "bob@example.com"}).Cc(&Address{"", "carl@example.com"}).
+			Bcc(&Address{"", "dan@example.com"}, &Address{"", "BOB@example.com"})
+	}
+	for _, addressTo := range []bool{false, true} {
+		sent = map[string]string{}
+		s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+		s.IndividualDelivery(true, addressTo)
+		if err := s.Send(newMsg(), nil); err != nil {
+			t.Fatalf("(*Sender).Send [%v]: got %v", addressTo, err)
+		}
+		s.Flush(context.Background())
+		var rcpts []string
+		for rcpt := range sent {
+			rcpts = append(rcpts, rcpt)
+		}
+		sort.Strings(rcpts)
+		if exp := []string{"bob@example.com", "carl@example.com", "dan@example.com"}; !reflect.DeepEqual(rcpts, exp) {
+			t.Errorf("(*Sender).IndividualDelivery [%v]: got %v want %v", addressTo, rcpts, exp)
+		}
+		toHeader := strings.Contains(sent["dan@example.com"], "\r\nTo: <dan@example.com>\r\n")
+		ccHeader := strings.Contains(sent["dan@example.com"], "\r\nCc: ")
+		if toHeader != addressTo || ccHeader == addressTo {
+			t.Errorf("(*Sender).IndividualDelivery [%v]: got message\n%s", addressTo, sent["dan@example.com"])
+		}
+	}
+
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	s.IndividualDelivery(true, false)
+	msg := newMsg().Bcc(&Address{"", "fail@example.com"})
+	err := s.sendSync(msg, nil)
+	var errs RecipientErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Addr != "fail@example.com" {
+		t.Errorf("(*Sender).sendSync: got %v", err)
+	}
+}
+
+func Test_IndividualDeliveryEvents(t *testing.T) {
+	defer func(f func(string, smtp.Auth, string, []string, []byte) error) { sendMail = f }(sendMail)
+	var (
+		mu     sync.Mutex
+		sent   = map[string]string{}
+		events = map[string]string{}
+	)
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		mu.Lock()
+		sent[to[0]] = string(msg)
+		mu.Unlock()
+		return nil
+	}
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	s.IndividualDelivery(true, true).Workers(1, 10)
+	s.Events(EventSinkFunc(func(ev *DeliveryEvent) error {
+		mu.Lock()
+		events[ev.Recipients[0]] = ev.MessageID
+		mu.Unlock()
+		return nil
+	}))
+	msg := QuickMessage("test", "body").From(&Address{"", "ann@example.com"}).
+		To(&Address{"", "bob@example.com"}, &Address{"", "carl@example.com"}, &Address{"", "dan@example.com"})
+	if err := s.Send(msg, nil); err != nil {
+		t.Fatalf("(*Sender).Send: got %v", err)
+	}
+	s.Flush(context.Background())
+	if len(events) != 3 {
+		t.Fatalf("(*Sender).IndividualDelivery: got events %v", events)
+	}
+	seen := map[string]bool{}
+	for rcpt, id := range events {
+		if seen[id] {
+			t.Errorf("(*Sender).IndividualDelivery: got Message-ID %s for more than one recipient", id)
+		}
+		seen[id] = true
+		if !strings.HasPrefix(sent[rcpt], "Message-ID: "+id+"\r\n") {
+			t.Errorf("(*Sender).IndividualDelivery: got event Message-ID %s for message\n%s", id, sent[rcpt])
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package email
+
+import (
+	"context"
+	"errors"
+)
+
+// errInheritanceCycle is recorded when a message would inherit from itself.
+var errInheritanceCycle = errors.New("message cannot inherit from itself")
+
+// Inherit makes the receiver inherit the content it does not set itself from the `base` message:
+// the subject, the plain-text, HTML and AMP bodies - along with their related and embedded items -
+// and the From and Reply-To addresses. A nil `base` removes the inheritance.
+//
+// Unlike NewMessage, which copies the base message once, Inherit looks up the content of the base
+// each time the receiver is composed, so that the later changes to the base - or to its own base,
+// if any - are picked up; e.g. a child message can override the subject and text body while using
+// the HTML layout of a base message that is maintained separately.
+func (m *Message) Inherit(base *Message) *Message {
+	m.Lock()
+	defer m.Unlock()
+	for b := base; b != nil; b = b.inheritsFrom() {
+		if b == m {
+			m.fail(ArgumentError, "base", errInheritanceCycle)
+			return m
+		}
+	}
+	m.base = base
+	return m
+}
+
+// inheritsFrom returns the base of the receiver, if any.
+func (m *Message) inheritsFrom() *Message {
+	m.RLock()
+	defer m.RUnlock()
+	return m.base
+}
+
+// inherit substitutes the content inherited from the bases of the receiver for the missing one,
+// returning the function that restores the receiver. The caller must hold the lock on the receiver.
+func (m *Message) inherit() (restore func()) {
+	if m.base == nil {
+		return func() {}
+	}
+	subject, subjectTpl, parts, text, html, amp := m.subject, m.subjectTpl, m.parts, m.text, m.html, m.amp
+	embeds, from, replyTo := m.embeds, m.from, m.replyTo
+	m.parts = append([]*part(nil), m.parts...)
+	inherited := func(p *part) *part {
+		q := *p
+		q.related = append([]Related(nil), p.related...)
+		m.parts = append(m.parts, &q)
+		return &q
+	}
+	for b := m.base; b != nil; {
+		b.Lock()
+		if err := b.prepareContext(context.Background(), false); err != nil {
+			m.fail(FileError, "base", err)
+		}
+		if m.subject == nil && m.subjectTpl == nil {
+			if b.subjectTpl != nil {
+				m.subjectTpl = b.subjectTpl
+			} else {
+				m.subject = b.subject
+			}
+		}
+		if m.text == nil && b.text != nil {
+			m.text = inherited(b.text)
+		}
+		if m.html == nil && b.html != nil {
+			m.html = inherited(b.html)
+			if len(m.embeds) == 0 {
+				m.embeds = b.embeds
+			}
+		}
+		if m.amp == nil && b.amp != nil {
+			m.amp = inherited(b.amp)
+		}
+		if m.from == nil {
+			m.from = b.from
+		}
+		if m.replyTo == nil {
+			m.replyTo = b.replyTo
+		}
+		next := b.base
+		b.Unlock()
+		b = next
+	}
+	return func() {
+		m.subject, m.subjectTpl, m.parts, m.text, m.html, m.amp = subject, subjectTpl, parts, text, html, amp
+		m.embeds, m.from, m.replyTo = embeds, from, replyTo
+	}
+}
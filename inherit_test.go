@@ -0,0 +1,45 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Inherit(t *testing.T) {
+	base := NewMessage(nil).From(&Address{"", "test@example.com"}).
+		SubjectTemplate("Hi {{.}}").TextTemplate("Hello, {{.}}").HtmlTemplate("<p>Hello, {{.}}</p>")
+	child := NewMessage(nil).Inherit(base).To(&Address{"", "to@example.com"}).TextTemplate("Welcome, {{.}}")
+	grandchild := NewMessage(nil).Inherit(child).To(&Address{"", "to@example.com"}).Subject("Welcome")
+
+	// changes to the base are picked up
+	base.HtmlTemplate("<p>Hi there, {{.}}</p>")
+	cases := []struct {
+		msg *Message
+		exp []string
+	}{
+		{child, []string{"From: <test@example.com>", "Subject: Hi Ann", "Welcome, Ann", "Hi there, Ann"}},
+		{grandchild, []string{"From: <test@example.com>", "Subject: Welcome", "Welcome, Ann", "Hi there, Ann"}},
+	}
+	for i, c := range cases {
+		act := string(c.msg.Compose("Ann"))
+		if errs := c.msg.Errors(); len(errs) > 0 {
+			t.Fatalf("(*Message).Inherit [%d]: unexpected errors: %v", i, errs)
+		}
+		for _, exp := range c.exp {
+			if !strings.Contains(act, exp) {
+				t.Errorf("(*Message).Inherit [%d]: got %q, missing %q", i, act, exp)
+			}
+		}
+		if info := c.msg.Inspect(); info.From != nil || len(info.Parts) > 1 {
+			t.Errorf("(*Message).Inherit [%d]: inherited content not restored: %+v", i, info)
+		}
+	}
+	if r, errs := child.TestRender("Bob"); len(errs) > 0 || r.Subject != "Hi Bob" || r.HTML != "<p>Hi there, Bob</p>" {
+		t.Errorf("(*Message).Inherit: got rendering %+v, errors %v", r, errs)
+	}
+
+	base.Inherit(grandchild)
+	if errs := base.Errors(); len(errs) != 1 || errs[0].(*MessageError).Err != errInheritanceCycle {
+		t.Errorf("(*Message).Inherit: got errors %v for a cycle", errs)
+	}
+}
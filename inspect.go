@@ -0,0 +1,101 @@
+package email
+
+// MessageInfo is a snapshot of the state of a Message, as returned by Inspect - e.g. for middleware,
+// tests or suppression filters. Changes to it do not affect the message.
+type MessageInfo struct {
+	// Subject is the subject of the message; for a subject template, it is the subject generated
+	// by the most recent call to Compose, if any.
+	Subject string
+	// SubjectTemplate is true if the subject is set from a template.
+	SubjectTemplate bool
+	// From and ReplyTo are the addresses set with the methods of the same names, if any.
+	From, ReplyTo *Address
+	// To, Cc and Bcc are the recipients set with the methods of the same names.
+	To, Cc, Bcc []*Address
+	// InReplyTo and References are the message ids set with the methods of the same names,
+	// without the angle brackets.
+	InReplyTo  string
+	References []string
+	// Headers are the additional headers set with Header.
+	Headers []HeaderField
+	// Parts are the alternative parts of the message body.
+	Parts []PartInfo
+	// Attachments are the attachments of the message.
+	Attachments []AttachmentInfo
+	// Errors are the errors recorded so far.
+	Errors []error
+}
+
+// HeaderField is a message header, as set with Header.
+type HeaderField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// PartInfo describes an alternative part of the message body.
+type PartInfo struct {
+	// ContentType is the content type of the part, e.g. "text/html; charset=utf-8".
+	ContentType string
+	// Template is true if the content of the part is generated from a template.
+	Template bool
+	// Content is the content of the part; for a template, it is the content generated by the
+	// most recent call to Compose, if any.
+	Content []byte
+	// Related are the related items of the part.
+	Related []RelatedInfo
+}
+
+// RelatedInfo describes a related item of a part.
+type RelatedInfo struct {
+	ID, ContentType, File string
+	Size                  int
+}
+
+// AttachmentInfo describes an attachment.
+type AttachmentInfo struct {
+	// Name is the file name presented to the recipients; it may be empty for a file attached with
+	// Attach, before the message is prepared.
+	Name string
+	// ContentType is the content type of the attachment, if known.
+	ContentType string
+	// File is the path of the attached file, if any.
+	File string
+	// Size is the size of the content, which is 0 for files not read yet.
+	Size int
+}
+
+// Inspect returns a snapshot of the state of the receiver.
+func (m *Message) Inspect() *MessageInfo {
+	m.RLock()
+	defer m.RUnlock()
+	info := &MessageInfo{
+		Subject:         string(m.subject),
+		SubjectTemplate: m.subjectTpl != nil,
+		From:            m.from.Clone(),
+		ReplyTo:         m.replyTo.Clone(),
+		To:              m.to.Clone(),
+		Cc:              m.cc.Clone(),
+		Bcc:             m.bcc.Clone(),
+		InReplyTo:       m.inReplyTo,
+		References:      append([]string(nil), m.references...),
+	}
+	for _, h := range m.headers {
+		info.Headers = append(info.Headers, HeaderField{h.name, h.value})
+	}
+	for _, p := range m.parts {
+		pi := PartInfo{
+			ContentType: p.ctype,
+			Template:    p.tpl != nil || p.htmlTpl != nil,
+			Content:     append([]byte(nil), p.bytes...),
+		}
+		for _, r := range m.partRelated(p) {
+			pi.Related = append(pi.Related, RelatedInfo{r.id, r.ctype, r.fileName, len(r.data)})
+		}
+		info.Parts = append(info.Parts, pi)
+	}
+	for _, a := range m.attachments {
+		info.Attachments = append(info.Attachments, AttachmentInfo{a.name, a.ctype, a.fileName, len(a.data)})
+	}
+	info.Errors = append(info.Errors, m.errors...)
+	return info
+}
@@ -0,0 +1,49 @@
+package email
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Inspect(t *testing.T) {
+	msg := NewMessage(nil).SubjectTemplate("Hi {{.}}").
+		From(&Address{"Test", "test@example.com"}).
+		To(&Address{"", "a@example.com"}, &Address{"", "b@example.com"}).
+		Bcc(&Address{"", "c@example.com"}).
+		Header("X-Campaign", "spring").
+		Text("Hello").
+		HtmlTemplate("<p>Hello {{.}}</p>", RelatedObject("logo", "image/png", []byte("PNG"))).
+		AttachObject("data.csv", "text/csv", []byte("a,b\n")).
+		Attach("/path/to/report.pdf")
+	msg.Compose("Ann")
+	info := msg.Inspect()
+
+	exp := &MessageInfo{
+		Subject:         "Hi Ann",
+		SubjectTemplate: true,
+		From:            &Address{"Test", "test@example.com"},
+		To:              []*Address{{"", "a@example.com"}, {"", "b@example.com"}},
+		Bcc:             []*Address{{"", "c@example.com"}},
+		Headers:         []HeaderField{{"X-Campaign", "spring"}},
+		Parts: []PartInfo{
+			{"text/plain; charset=utf-8", false, []byte("Hello"), nil},
+			{"text/html; charset=utf-8", true, []byte("<p>Hello Ann</p>"), []RelatedInfo{{"logo", "image/png", "", 3}}},
+		},
+		Attachments: []AttachmentInfo{
+			{"data.csv", "text/csv", "", 4},
+			{"", "", "/path/to/report.pdf", 0},
+		},
+	}
+	if len(info.Errors) != 1 {
+		t.Errorf("(*Message).Inspect: got errors %v, want the missing file", info.Errors)
+	}
+	info.Errors = nil
+	if !reflect.DeepEqual(info, exp) {
+		t.Errorf("(*Message).Inspect: got\n%+v\nwant\n%+v", info, exp)
+	}
+	info.To[0].Addr = "changed@example.com"
+	info.Parts[0].Content[0] = 'J'
+	if again := msg.Inspect(); again.To[0].Addr != "a@example.com" || string(again.Parts[0].Content) != "Hello" {
+		t.Error("(*Message).Inspect: changes to the snapshot affected the message")
+	}
+}
@@ -0,0 +1,115 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Direction represents the direction of the text in a message body.
+type Direction byte
+
+const (
+	// AutoDir indicates that the direction is derived from the language: right-to-left for
+	// languages such as Arabic or Hebrew, and unspecified otherwise.
+	AutoDir Direction = iota
+	// LTR indicates left-to-right text.
+	LTR
+	// RTL indicates right-to-left text.
+	RTL
+)
+
+// rtlLanguages are the primary language subtags of the languages written right-to-left.
+var rtlLanguages = map[string]bool{
+	"ar": true, "arc": true, "ckb": true, "dv": true, "fa": true, "he": true, "iw": true, "ks": true,
+	"ku": true, "ps": true, "sd": true, "syr": true, "ug": true, "ur": true, "yi": true,
+}
+
+// Language sets the language of the message body - a BCP 47 tag, such as "ar" or "he-IL" - and
+// the direction of its text. The language is declared in a Content-Language header for the text
+// parts, and in a lang attribute for the HTML part; the direction is declared in a dir attribute
+// for the HTML part, which improves the rendering of right-to-left content by mail clients.
+//
+// The attributes are added to the <html> element of the HTML part, if any, or else to its <body>
+// element; a fragment with neither is wrapped in a <div> element.
+//
+// A `lang` that does not have the shape of a BCP 47 tag - alphanumeric subtags of 1 to 8 characters
+// separated by hyphens - is recorded as an ArgumentError.
+func (m *Message) Language(lang string, dir Direction) *Message {
+	m.Lock()
+	defer m.Unlock()
+	if lang != "" && !reLanguageTag.MatchString(lang) {
+		m.fail(ArgumentError, "language", errors.New("invalid language: "+lang))
+		return m
+	}
+	m.lang, m.dir = lang, dir
+	return m
+}
+
+// direction resolves the direction of the text of the receiver.
+func (m *Message) direction() Direction {
+	if m.dir != AutoDir {
+		return m.dir
+	}
+	primary := strings.ToLower(strings.SplitN(m.lang, "-", 2)[0])
+	if rtlLanguages[primary] {
+		return RTL
+	}
+	return AutoDir
+}
+
+var (
+	reLanguageTag = regexp.MustCompile(`^[a-zA-Z0-9]{1,8}(?:-[a-zA-Z0-9]{1,8})*$`)
+	reHTMLElement = regexp.MustCompile(`(?i)<html\b[^>]*>`)
+	reBodyElement = regexp.MustCompile(`(?i)<body\b[^>]*>`)
+)
+
+// localizeHTML adds the lang and dir attributes to the `html`, unless already present.
+func localizeHTML(html []byte, lang string, dir Direction) []byte {
+	var attrs []string
+	if lang != "" {
+		attrs = append(attrs, "lang", lang)
+	}
+	switch dir {
+	case LTR:
+		attrs = append(attrs, "dir", "ltr")
+	case RTL:
+		attrs = append(attrs, "dir", "rtl")
+	}
+	if len(attrs) == 0 {
+		return html
+	}
+	for _, re := range []*regexp.Regexp{reHTMLElement, reBodyElement} {
+		if loc := re.FindIndex(html); loc != nil {
+			tag := string(html[loc[0]:loc[1]])
+			end := len(tag) - 1
+			if strings.HasSuffix(tag, "/>") {
+				end--
+			}
+			t, _ := parseTag([]byte(tag))
+			present := make(map[string]bool, len(t.attrs))
+			for _, a := range t.attrs {
+				present[a.name] = true
+			}
+			add := ""
+			for i := 0; i < len(attrs); i += 2 {
+				if !present[attrs[i]] {
+					add += " " + attrs[i] + `="` + attrs[i+1] + `"`
+				}
+			}
+			out := make([]byte, 0, len(html)+len(add))
+			out = append(append(append(out, html[:loc[0]+end]...), add...), html[loc[0]+end:]...)
+			return out
+		}
+	}
+	open := "<div"
+	for i := 0; i < len(attrs); i += 2 {
+		open += " " + attrs[i] + `="` + attrs[i+1] + `"`
+	}
+	if bytes.HasPrefix(html, []byte(open+">")) && bytes.HasSuffix(html, []byte("</div>")) {
+		// already wrapped
+		return html
+	}
+	return append(append([]byte(open+">"), html...), "</div>"...)
+}
@@ -0,0 +1,52 @@
+package email
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_localizeHTML(t *testing.T) {
+	tests := []struct {
+		html string
+		lang string
+		dir  Direction
+		want string
+	}{
+		{"<p>x</p>", "", AutoDir, "<p>x</p>"},
+		{"<p>x</p>", "ar", RTL, `<div lang="ar" dir="rtl"><p>x</p></div>`},
+		{"<HTML><body>x</body></HTML>", "he", RTL, `<HTML lang="he" dir="rtl"><body>x</body></HTML>`},
+		{`<body class="a">x</body>`, "", LTR, `<body class="a" dir="ltr">x</body>`},
+		{`<html lang="fa"><body>x</body></html>`, "fa", RTL, `<html lang="fa" dir="rtl"><body>x</body></html>`},
+		{`<html DIR='ltr'><body>x</body></html>`, "en", LTR, `<html DIR='ltr' lang="en"><body>x</body></html>`},
+	}
+	for i, test := range tests {
+		if got := string(localizeHTML([]byte(test.html), test.lang, test.dir)); got != test.want {
+			t.Errorf("localizeHTML [%d]: got %q want %q", i, got, test.want)
+		}
+	}
+}
+
+func Test_Language(t *testing.T) {
+	body := QuickMessage("test").From(&Address{"", "test@example.com"}).
+		Html("<p>مرحبا</p>").Language("ar-EG", AutoDir).Compose(nil)
+	for _, want := range []string{
+		"Content-Type: text/plain; charset=utf-8\r\nContent-Language: ar-EG\r\n",
+		"Content-Type: text/html; charset=utf-8\r\nContent-Language: ar-EG\r\n",
+		string(QuotedPrintableEncode([]byte(`<div lang="ar-EG" dir="rtl">`))),
+	} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("(*Message).Language: got\n%s\nwant %q", body, want)
+		}
+	}
+
+	for _, lang := range []string{"en\"", "en; x=y", "en us", "en-", "-en", "de-verylongtag"} {
+		if !QuickMessage("test").Language(lang, LTR).HasErrors() {
+			t.Errorf("(*Message).Language: got no error for the invalid language %q", lang)
+		}
+	}
+	for _, lang := range []string{"", "en", "zh-Hant-TW", "de-CH-1901", "x-private"} {
+		if msg := QuickMessage("test").Language(lang, LTR); msg.HasErrors() {
+			t.Errorf("(*Message).Language: got %v for the language %q", msg.Errors(), lang)
+		}
+	}
+}
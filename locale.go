@@ -0,0 +1,169 @@
+package email
+
+import (
+	htpl "html/template"
+	"strings"
+	ttpl "text/template"
+)
+
+// localeVariant holds the templates of a message for a locale.
+type localeVariant struct {
+	subject *ttpl.Template
+	text    *ttpl.Template
+	html    *htpl.Template
+}
+
+// Locale registers the subject, plain-text and HTML templates of the message for the `locale` - a
+// BCP 47 tag, such as "fr" or "pt-BR" - so that a single message serves all the languages of its
+// recipients. Any of the templates may be empty, in which case the corresponding template of the
+// message is used for the locale.
+//
+// The locale is selected when composing the message: the one given to ComposeLocale, or else the
+// value of the data field set with LocaleField, or else the default locale set with DefaultLocale.
+// A locale that is not registered falls back to its primary language - e.g. "fr-CA" to "fr" - then
+// to the default locale, and finally to the templates of the message. The selected locale is
+// declared as the language of the message, unless set with Language.
+func (m *Message) Locale(locale, subject, text, html string) *Message {
+	v := &localeVariant{}
+	var err error
+	if subject != "" {
+		if v.subject, err = ttpl.New("").Parse(subject); err != nil {
+			m.Lock()
+			m.fail(TemplateError, "subject", &ErrTemplateParse{"subject", subject, err})
+			m.Unlock()
+			return m
+		}
+	}
+	if text != "" {
+		if v.text, err = ttpl.New("").Parse(text); err != nil {
+			m.Lock()
+			m.fail(TemplateError, "text", &ErrTemplateParse{"text", text, err})
+			m.Unlock()
+			return m
+		}
+	}
+	if html != "" {
+		if v.html, err = htpl.New("").Parse(html); err != nil {
+			m.Lock()
+			m.fail(TemplateError, "html", &ErrTemplateParse{"html", html, err})
+			m.Unlock()
+			return m
+		}
+	}
+	m.Lock()
+	defer m.Unlock()
+	if m.strict {
+		for _, t := range []*ttpl.Template{v.subject, v.text} {
+			if t != nil {
+				t.Option(m.missingKey())
+			}
+		}
+		if v.html != nil {
+			v.html.Option(m.missingKey())
+		}
+	}
+	locales := make(map[string]*localeVariant, len(m.locales)+1)
+	for k, lv := range m.locales {
+		locales[k] = lv
+	}
+	locales[strings.ToLower(locale)] = v
+	m.locales = locales
+	return m
+}
+
+// DefaultLocale sets the locale used for composing the message when no other locale is selected,
+// or when the selected one is not registered with Locale.
+func (m *Message) DefaultLocale(locale string) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.defaultLocale = locale
+	return m
+}
+
+// LocaleField sets the name of the field of the template data that selects the locale used for
+// composing the message; it defaults to "Locale" for structs, and "locale" for maps.
+func (m *Message) LocaleField(name string) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.localeField = name
+	return m
+}
+
+// ComposeLocale composes the message like Compose, using the templates registered for the `locale`.
+func (m *Message) ComposeLocale(locale string, data interface{}) []byte {
+	m.Lock()
+	defer m.Unlock()
+	m.forcedLocale = locale
+	defer func() {
+		m.forcedLocale = ""
+	}()
+	return m.compose(data, nil)
+}
+
+// selectLocale returns the locale selected for composing the receiver with the `data`, and its
+// variant, if any.
+func (m *Message) selectLocale(data interface{}) (string, *localeVariant) {
+	locale := m.forcedLocale
+	if locale == "" {
+		if m.localeField != "" {
+			locale = localeValue(data, m.localeField)
+		} else if locale = localeValue(data, "Locale"); locale == "" {
+			locale = localeValue(data, "locale")
+		}
+	}
+	for _, l := range []string{locale, strings.SplitN(locale, "-", 2)[0], m.defaultLocale} {
+		if v, ok := m.locales[strings.ToLower(l)]; ok && l != "" {
+			return l, v
+		}
+	}
+	return "", nil
+}
+
+// localeValue returns the value of the `field` of the `data`, if it is a string.
+func localeValue(data interface{}, field string) string {
+	if v := dataField(data, field); v.IsValid() {
+		if s, ok := v.Interface().(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// localize substitutes the templates of the locale selected for the `data` for those of the
+// receiver, returning the function that restores them. The caller must hold the lock on the
+// receiver.
+func (m *Message) localize(data interface{}) (restore func()) {
+	locale, v := m.selectLocale(data)
+	if v == nil {
+		return func() {}
+	}
+	subject, subjectTpl, parts, text, html, lang := m.subject, m.subjectTpl, m.parts, m.text, m.html, m.lang
+	if v.subject != nil {
+		m.subject, m.subjectTpl = nil, v.subject
+	}
+	m.parts = append([]*part(nil), m.parts...)
+	if v.text != nil {
+		m.text = &part{ctype: "text/plain; charset=utf-8", cte: QuotedPrintable, tpl: v.text}
+		if text != nil {
+			m.text.charset = text.charset
+			m.parts[m.partIndex(text)] = m.text
+		} else {
+			m.parts = append(m.parts, m.text)
+		}
+	}
+	if v.html != nil {
+		m.html = &part{ctype: "text/html; charset=utf-8", cte: QuotedPrintable, htmlTpl: v.html}
+		if html != nil {
+			m.html.charset, m.html.related = html.charset, html.related
+			m.parts[m.partIndex(html)] = m.html
+		} else {
+			m.parts = append(m.parts, m.html)
+		}
+	}
+	if m.lang == "" {
+		m.lang = locale
+	}
+	return func() {
+		m.subject, m.subjectTpl, m.parts, m.text, m.html, m.lang = subject, subjectTpl, parts, text, html, lang
+	}
+}
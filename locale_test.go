@@ -0,0 +1,59 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Locale(t *testing.T) {
+	base := NewMessage(nil).From(&Address{"", "test@example.com"}).To(&Address{"", "to@example.com"}).
+		SubjectTemplate("Hello {{.name}}").TextTemplate("Welcome, {{.name}}").
+		Locale("fr", "Bonjour {{.name}}", "Bienvenue, {{.name}}", "").
+		Locale("es", "Hola {{.name}}", "", "<p>Bienvenido, {{.name}}</p>")
+	cases := []struct {
+		msg    *Message
+		locale string
+		data   map[string]interface{}
+		exp    []string
+		unexp  []string
+	}{
+		{NewMessage(base), "", map[string]interface{}{"name": "Ann"},
+			[]string{"Subject: Hello Ann", "Welcome, Ann"}, []string{"Content-Language"}},
+		{NewMessage(base), "", map[string]interface{}{"name": "Ann", "locale": "fr-CA"},
+			[]string{"Subject: Bonjour Ann", "Bienvenue, Ann", "Content-Language: fr\r\n"}, []string{"Welcome"}},
+		{NewMessage(base), "es", map[string]interface{}{"name": "Bob", "locale": "fr"},
+			[]string{"Subject: Hola Bob", "Welcome, Bob", "Bienvenido, Bob"}, []string{"Bonjour"}},
+		{NewMessage(base).DefaultLocale("fr"), "", map[string]interface{}{"name": "Cid", "locale": "de"},
+			[]string{"Subject: Bonjour Cid", "Content-Language: fr"}, nil},
+		{NewMessage(base).LocaleField("lang"), "", map[string]interface{}{"name": "Dee", "lang": "fr"},
+			[]string{"Subject: Bonjour Dee"}, nil},
+	}
+	for i, c := range cases {
+		var act string
+		if c.locale != "" {
+			act = string(c.msg.ComposeLocale(c.locale, c.data))
+		} else {
+			act = string(c.msg.Compose(c.data))
+		}
+		if errs := c.msg.Errors(); len(errs) > 0 {
+			t.Fatalf("(*Message).Locale [%d]: unexpected errors: %v", i, errs)
+		}
+		for _, exp := range c.exp {
+			if !strings.Contains(act, exp) {
+				t.Errorf("(*Message).Locale [%d]: got %q, missing %q", i, act, exp)
+			}
+		}
+		for _, unexp := range c.unexp {
+			if strings.Contains(act, unexp) {
+				t.Errorf("(*Message).Locale [%d]: got %q, unexpected %q", i, act, unexp)
+			}
+		}
+	}
+	// the templates of the message are restored after composing a variant
+	msg := NewMessage(base)
+	msg.ComposeLocale("es", map[string]interface{}{"name": "Eve"})
+	if act := string(msg.Compose(map[string]interface{}{"name": "Eve"})); strings.Contains(act, "Hola") ||
+		strings.Contains(act, "text/html") {
+		t.Errorf("(*Message).ComposeLocale: got %q after composing a variant", act)
+	}
+}
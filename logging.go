@@ -0,0 +1,63 @@
+package email
+
+import (
+	"errors"
+	"net/textproto"
+)
+
+// Logger receives structured log records about the messages handled by a Sender. The `args` are
+// alternating keys and values, e.g. "message_id", "<...>", "recipients", 2.
+//
+// The interface is satisfied by *slog.Logger, as well as by thin adapters around most structured
+// logging packages. Implementations must be safe for concurrent use.
+//
+// The keys used by this package are: "message_id", "from", "recipients" (count), "attempt",
+// "smtp_code" (only when the SMTP server replied with an error), "dry_run" (only in a dry-run
+// mode) and "error"; duplicate messages (see DuplicateCheck) are logged with "message_id",
+// "recipient" and "last_sent".
+type Logger interface {
+	Info(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// Logger sets the logger to be notified about the messages composed and sent by the receiver.
+// A nil `l` disables logging.
+func (s *Sender) Logger(l Logger) *Sender {
+	s.mu.Lock()
+	s.logger = l
+	s.mu.Unlock()
+	return s
+}
+
+// smtpCode extracts the SMTP reply code from `err`, if available.
+func smtpCode(err error) int {
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		return tpErr.Code
+	}
+	return 0
+}
+
+// logDelivery logs the outcome of an attempt to perform the delivery `d`.
+func logDelivery(l Logger, d delivery, attempt int, dryRun bool, err error) {
+	if l == nil {
+		return
+	}
+	args := []interface{}{
+		"message_id", d.id,
+		"from", d.from,
+		"recipients", len(d.to),
+		"attempt", attempt,
+	}
+	if dryRun {
+		args = append(args, "dry_run", true)
+	}
+	if err == nil {
+		l.Info("email: message sent", args...)
+		return
+	}
+	if code := smtpCode(err); code != 0 {
+		args = append(args, "smtp_code", code)
+	}
+	l.Error("email: delivery failed", append(args, "error", err)...)
+}
@@ -0,0 +1,149 @@
+package email
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// MboxReader reads the messages of an mbox file, in the mboxrd format, where the body lines starting
+// with "From ", or with ">" followed by "From ", are escaped with an extra ">" - e.g. for migrating
+// or archiving mailboxes.
+type MboxReader struct {
+	r    *bufio.Reader
+	from bool // has the "From " line of the next message been read?
+	err  error
+}
+
+// NewMboxReader creates an MboxReader reading from `r`.
+func NewMboxReader(r io.Reader) *MboxReader {
+	return &MboxReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the raw content of the next message of the mbox file, without its "From " line and
+// with the escaped "From " lines of its body restored, or io.EOF when there are no more messages.
+// The size of the messages is bounded by the MaxMessageSize of the DefaultLimits.
+func (r *MboxReader) Next() ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	for !r.from {
+		line, err := r.r.ReadBytes('\n')
+		if bytes.HasPrefix(line, []byte("From ")) {
+			r.from = true
+		} else if len(bytes.TrimSpace(line)) > 0 {
+			r.err = errors.New("email: invalid mbox file: missing From line")
+			return nil, r.err
+		}
+		if err != nil && !r.from {
+			r.err = err
+			return nil, err
+		}
+	}
+	var msg []byte
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if bytes.HasPrefix(line, []byte("From ")) {
+			break
+		}
+		if quotedFromLine(line) {
+			line = line[1:]
+		}
+		msg = append(msg, line...)
+		if max := DefaultLimits.MaxMessageSize; max > 0 && int64(len(msg)) > max {
+			r.err = &LimitError{MessageSizeLimit, max}
+			return nil, r.err
+		}
+		if err != nil {
+			if err != io.EOF {
+				r.err = err
+				return nil, err
+			}
+			r.from, r.err = false, io.EOF
+			break
+		}
+	}
+	// the empty line before the next "From " line separates the messages
+	if bytes.HasSuffix(msg, []byte("\r\n\r\n")) {
+		msg = msg[:len(msg)-2]
+	} else if bytes.HasSuffix(msg, []byte("\n\n")) {
+		msg = msg[:len(msg)-1]
+	}
+	return msg, nil
+}
+
+// NextMessage reads the next message of the mbox file, like Next, and parses it with Parse.
+func (r *MboxReader) NextMessage() (*Message, error) {
+	raw, err := r.Next()
+	if err != nil {
+		return nil, err
+	}
+	return Parse(bytes.NewReader(raw))
+}
+
+// quotedFromLine reports whether `line` is an escaped "From " line, such as ">From " or ">>From ".
+func quotedFromLine(line []byte) bool {
+	i := 0
+	for i < len(line) && line[i] == '>' {
+		i++
+	}
+	return i > 0 && bytes.HasPrefix(line[i:], []byte("From "))
+}
+
+// MaildirReader reads the messages of a Maildir directory - those in its "new" and "cur"
+// subdirectories, in the order of their file names, which start with their delivery time.
+type MaildirReader struct {
+	fsys  fs.FS
+	names []string
+	name  string
+}
+
+// NewMaildirReader creates a MaildirReader reading the messages of the Maildir `dir` in `fsys` -
+// e.g. os.DirFS("/home/ann"), and "Maildir".
+func NewMaildirReader(fsys fs.FS, dir string) (*MaildirReader, error) {
+	r := &MaildirReader{fsys: fsys}
+	for _, sub := range []string{"new", "cur"} {
+		entries, err := fs.ReadDir(fsys, path.Join(dir, sub))
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.Type().IsRegular() {
+				r.names = append(r.names, path.Join(dir, sub, e.Name()))
+			}
+		}
+	}
+	sort.Slice(r.names, func(i, j int) bool {
+		return path.Base(r.names[i]) < path.Base(r.names[j])
+	})
+	return r, nil
+}
+
+// Next returns the raw content of the next message of the Maildir, or io.EOF when there are no
+// more messages.
+func (r *MaildirReader) Next() ([]byte, error) {
+	if len(r.names) == 0 {
+		return nil, io.EOF
+	}
+	r.name, r.names = r.names[0], r.names[1:]
+	return fs.ReadFile(r.fsys, r.name)
+}
+
+// NextMessage reads the next message of the Maildir, like Next, and parses it with Parse.
+func (r *MaildirReader) NextMessage() (*Message, error) {
+	raw, err := r.Next()
+	if err != nil {
+		return nil, err
+	}
+	return Parse(bytes.NewReader(raw))
+}
+
+// Name returns the path, in the file system of the receiver, of the message read last - whose
+// info suffix, such as ":2,S", holds the flags of the message.
+func (r *MaildirReader) Name() string {
+	return r.name
+}
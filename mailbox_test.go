@@ -0,0 +1,64 @@
+package email
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_MboxReader(t *testing.T) {
+	mbox := "From ann@example.com Thu Mar  4 15:06:00 2021\n" +
+		"From: ann@example.com\nSubject: One\n\nHi\n>From here\n>>From there\n\n" +
+		"From bob@example.com Thu Mar  4 15:07:00 2021\n" +
+		"From: bob@example.com\nSubject: Two\n\nHello\n"
+	r := NewMboxReader(strings.NewReader(mbox))
+	exp := []string{
+		"From: ann@example.com\nSubject: One\n\nHi\nFrom here\n>From there\n",
+		"From: bob@example.com\nSubject: Two\n\nHello\n",
+	}
+	for i, e := range exp {
+		act, err := r.Next()
+		if err != nil || string(act) != e {
+			t.Errorf("(*MboxReader).Next [%d]: got %q, %v want %q", i, act, err, e)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("(*MboxReader).Next: got %v want io.EOF", err)
+	}
+
+	msg, err := NewMboxReader(strings.NewReader(mbox)).NextMessage()
+	if err != nil || msg.Inspect().Subject != "One" {
+		t.Errorf("(*MboxReader).NextMessage: got %v, %v", msg, err)
+	}
+	if _, err = NewMboxReader(strings.NewReader("Subject: x\n\nbody\n")).Next(); err == nil || err == io.EOF {
+		t.Errorf("(*MboxReader).Next: got %v for a file without From line", err)
+	}
+}
+
+func Test_MaildirReader(t *testing.T) {
+	fsys := fstest.MapFS{
+		"Maildir/cur/1614870360.1.host:2,S": {Data: []byte("Subject: One\r\n\r\nHi\r\n")},
+		"Maildir/new/1614870420.2.host":     {Data: []byte("Subject: Two\r\n\r\nHello\r\n")},
+		"Maildir/tmp/1614870480.3.host":     {Data: []byte("Subject: Three\r\n\r\nPartial\r\n")},
+	}
+	r, err := NewMaildirReader(fsys, "Maildir")
+	if err != nil {
+		t.Fatalf("NewMaildirReader: unexpected error: %v", err)
+	}
+	for i, exp := range []string{"One", "Two"} {
+		msg, err := r.NextMessage()
+		if err != nil || msg.Inspect().Subject != exp {
+			t.Errorf("(*MaildirReader).NextMessage [%d]: got %v, %v want %q", i, msg, err, exp)
+		}
+	}
+	if r.Name() != "Maildir/new/1614870420.2.host" {
+		t.Errorf("(*MaildirReader).Name: got %q", r.Name())
+	}
+	if _, err = r.Next(); err != io.EOF {
+		t.Errorf("(*MaildirReader).Next: got %v want io.EOF", err)
+	}
+	if _, err = NewMaildirReader(fsys, "missing"); err == nil {
+		t.Errorf("NewMaildirReader: got no error for a missing directory")
+	}
+}
@@ -0,0 +1,115 @@
+package email
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// addressJSON is the JSON form of an Address.
+type addressJSON struct {
+	Name string `json:"name,omitempty"`
+	Addr string `json:"addr"`
+}
+
+// MarshalJSON encodes the receiver as a JSON object, such as
+// {"name":"Ann Smith","addr":"ann@example.com"}.
+func (a Address) MarshalJSON() ([]byte, error) {
+	return json.Marshal(addressJSON{a.Name, a.Addr})
+}
+
+// UnmarshalJSON decodes the receiver from a JSON object, as encoded by MarshalJSON, or from a JSON
+// string holding an RFC 5322 address, as parsed by ParseAddress - e.g. "Ann Smith <ann@example.com>".
+func (a *Address) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte{'"'}) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		return a.UnmarshalText([]byte(s))
+	}
+	var v addressJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	a.Name, a.Addr = v.Name, v.Addr
+	return nil
+}
+
+// MarshalText encodes the receiver in its RFC 5322 form - see String.
+func (a Address) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText decodes the receiver from its RFC 5322 form, as parsed by ParseAddress.
+func (a *Address) UnmarshalText(text []byte) error {
+	p, err := ParseAddress(string(text))
+	if err != nil {
+		return err
+	}
+	*a = *p
+	return nil
+}
+
+// MessageMetadata is a view of the metadata of a Message - its subject, addresses and headers - that
+// can be marshaled, e.g. for storing message definitions in config files or databases. It is
+// returned by Metadata, and applied by SetMetadata.
+type MessageMetadata struct {
+	// Subject is the subject of the message, or the source of its template, if SubjectTemplate.
+	Subject         string `json:"subject,omitempty"`
+	SubjectTemplate bool   `json:"subject_template,omitempty"`
+	// From, ReplyTo, To, Cc and Bcc are the addresses of the message.
+	From    *Address   `json:"from,omitempty"`
+	ReplyTo *Address   `json:"reply_to,omitempty"`
+	To      []*Address `json:"to,omitempty"`
+	Cc      []*Address `json:"cc,omitempty"`
+	Bcc     []*Address `json:"bcc,omitempty"`
+	// InReplyTo and References are the message ids the message refers to, without the angle
+	// brackets.
+	InReplyTo  string   `json:"in_reply_to,omitempty"`
+	References []string `json:"references,omitempty"`
+	// Priority is the priority of the message.
+	Priority Priority `json:"priority,omitempty"`
+	// Headers are the additional headers of the message.
+	Headers []HeaderField `json:"headers,omitempty"`
+}
+
+// Metadata returns the metadata of the receiver.
+func (m *Message) Metadata() *MessageMetadata {
+	m.RLock()
+	defer m.RUnlock()
+	md := &MessageMetadata{
+		Subject:    string(m.subject),
+		From:       m.from.Clone(),
+		ReplyTo:    m.replyTo.Clone(),
+		To:         m.to.Clone(),
+		Cc:         m.cc.Clone(),
+		Bcc:        m.bcc.Clone(),
+		InReplyTo:  m.inReplyTo,
+		References: append([]string(nil), m.references...),
+		Priority:   m.priority,
+	}
+	if m.subjectTpl != nil && m.subjectTpl.Tree != nil {
+		md.Subject, md.SubjectTemplate = m.subjectTpl.Root.String(), true
+	}
+	for _, h := range m.headers {
+		md.Headers = append(md.Headers, HeaderField{h.name, h.value})
+	}
+	return md
+}
+
+// SetMetadata applies the metadata `md` to the receiver, as with the setters of the same names:
+// the subject, addresses, message ids and priority replace those of the receiver, while the
+// headers are added to its headers.
+func (m *Message) SetMetadata(md *MessageMetadata) *Message {
+	if md.SubjectTemplate {
+		m.SubjectTemplate(md.Subject)
+	} else {
+		m.Subject(md.Subject)
+	}
+	m.From(md.From).ReplyTo(md.ReplyTo).To(md.To...).Cc(md.Cc...).Bcc(md.Bcc...).
+		InReplyTo(md.InReplyTo).References(md.References...).Priority(md.Priority)
+	for _, h := range md.Headers {
+		m.Header(h.Name, h.Value)
+	}
+	return m
+}
@@ -0,0 +1,66 @@
+package email
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_AddressJSON(t *testing.T) {
+	b, err := json.Marshal([]*Address{{"Ann Smith", "ann@example.com"}, {"", "bob@example.com"}, nil})
+	if exp := `[{"name":"Ann Smith","addr":"ann@example.com"},{"addr":"bob@example.com"},null]`; err != nil || string(b) != exp {
+		t.Errorf("(Address).MarshalJSON: got %s, %v want %s", b, err, exp)
+	}
+
+	cases := []struct {
+		src string
+		exp Address
+		err bool
+	}{
+		{`{"name":"Ann Smith","addr":"ann@example.com"}`, Address{"Ann Smith", "ann@example.com"}, false},
+		{`"Ann Smith <ann@example.com>"`, Address{"Ann Smith", "ann@example.com"}, false},
+		{`"bob@example.com"`, Address{"", "bob@example.com"}, false},
+		{`"bob"`, Address{}, true},
+		{`42`, Address{}, true},
+	}
+	for i, c := range cases {
+		var a Address
+		err := json.Unmarshal([]byte(c.src), &a)
+		if a != c.exp || (err != nil) != c.err {
+			t.Errorf("(*Address).UnmarshalJSON [%d]: got %v, %v want %v", i, a, err, c.exp)
+		}
+	}
+
+	var m map[Address]int
+	if err := json.Unmarshal([]byte(`{"Ann <ann@example.com>":1}`), &m); err != nil || m[Address{"Ann", "ann@example.com"}] != 1 {
+		t.Errorf("(*Address).UnmarshalText: got %v, %v", m, err)
+	}
+}
+
+func Test_MessageMetadata(t *testing.T) {
+	msg := NewMessage(nil).SubjectTemplate("Hello, {{.Name}}").
+		From(&Address{"Ann", "ann@example.com"}).
+		To(&Address{"", "bob@example.com"}, &Address{"Carl", "carl@example.com"}).
+		Bcc(&Address{"", "log@example.com"}).
+		InReplyTo("<1@example.com>").References("<0@example.com>", "<1@example.com>").
+		Priority(HighPriority).
+		Header("X-Campaign", "spring")
+	b, err := json.Marshal(msg.Metadata())
+	if err != nil {
+		t.Fatalf("(*Message).Metadata: got %v", err)
+	}
+	var md MessageMetadata
+	if err = json.Unmarshal(b, &md); err != nil {
+		t.Fatalf("(*Message).Metadata: got %v unmarshaling %s", err, b)
+	}
+	if !reflect.DeepEqual(&md, msg.Metadata()) {
+		t.Errorf("(*Message).Metadata: got %+v want %+v", md, msg.Metadata())
+	}
+	dup := NewMessage(nil).SetMetadata(&md)
+	if errs := dup.Errors(); len(errs) > 0 {
+		t.Fatalf("(*Message).SetMetadata: got %v", errs)
+	}
+	if act, exp := dup.Metadata(), msg.Metadata(); !reflect.DeepEqual(act, exp) {
+		t.Errorf("(*Message).SetMetadata: got %+v want %+v", act, exp)
+	}
+}
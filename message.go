@@ -1,39 +1,56 @@
 package email
 
 import (
-	"bytes"
+	"context"
 	"errors"
-	"fmt"
 	htpl "html/template"
-	"io/ioutil"
-	"mime"
+	"io"
+	"io/fs"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	ttpl "text/template"
 	"time"
-
-	"github.com/agext/uuid"
 )
 
 // CTE represents a "Content-Transfer-Encoding" method identifier.
 type CTE byte
 
 const (
-	// AutoCTE leaves it up to the package to determine CTE
+	// AutoCTE leaves it up to the package to determine CTE, for each part, based on its content:
+	// SevenBit for ASCII text in short lines, QuotedPrintable for mostly ASCII text, and Base64
+	// for predominantly non-ASCII or binary content
 	AutoCTE CTE = iota
 	// QuotedPrintable indicates "quoted-printable" CTE
 	QuotedPrintable
 	// Base64 indicates "base64" CTE
 	Base64
+	// SevenBit indicates "7bit" CTE, for content that is already suitable for transport: ASCII only,
+	// in lines of at most 998 bytes, separated by CRLF
+	SevenBit
+	// EightBit indicates "8bit" CTE, for content like that suitable for SevenBit, but which may
+	// also include non-ASCII bytes
+	EightBit
 )
 
-var (
-	now     = time.Now
-	newUUID = func() []byte {
-		return []byte(uuid.New().Hex())
+// String returns the name of the content transfer encoding, as used in the
+// Content-Transfer-Encoding header; AutoCTE has no name.
+func (c CTE) String() string {
+	switch c {
+	case QuotedPrintable:
+		return "quoted-printable"
+	case Base64:
+		return "base64"
+	case SevenBit:
+		return "7bit"
+	case EightBit:
+		return "8bit"
 	}
-)
+	return ""
+}
+
+var now = time.Now
 
 // Message represents all the information necessary for composing an email message with optional
 // external data, and sending it via a Sender.
@@ -42,6 +59,7 @@ type Message struct {
 	domain        []byte
 	subject       []byte
 	subjectTpl    *ttpl.Template
+	subjectSource tplSource
 	sender        *Sender
 	from, replyTo *Address
 	to, cc, bcc   addrList
@@ -50,6 +68,59 @@ type Message struct {
 	attachments   []*attachment
 	errors        []error
 	prepared      bool
+	id            string
+	headers       []header
+
+	filenamePolicy *FilenamePolicy
+	composeMws     []ComposeMiddleware
+	fingerprint    Fingerprint
+	preview        bool
+	prepareWorkers int
+	root           string
+	inReplyTo      string
+	references     []string
+	ids            IDSource
+	clock          func() time.Time
+	date           time.Time
+	lang           string
+	dir            Direction
+	receiptTo      *Address
+	priority       Priority
+	senderAddr     *Address
+	lazy           bool
+	embeds         []Related
+	calendar       *part
+	amp            *part
+	boundaries     BoundaryGenerator
+	encrypt        CertificateLookup
+	htmlPolicy     *HTMLPolicy
+	inlineCSS      bool
+	noAutoText     bool
+	textConverter  TextConverter
+	charset        string
+	fsys           fs.FS
+	defaultData    map[string]interface{}
+	locales        map[string]*localeVariant
+	defaultLocale  string
+	localeField    string
+	forcedLocale   string
+	strict         bool
+	reload         bool
+	tplFile        *templatesFile
+	base           *Message
+	validation     ValidationLevel
+	composedAt     time.Time
+
+	maxAttachmentSize int64
+	maxMessageSize    int64
+	zipPolicy         *ZipPolicy
+	fetcher           urlFetcher
+	remoteHosts       []string
+	remoteCache       map[string]fileResult
+	remote            []Related
+	sniffer           ContentSniffer
+	attachmentPolicy  *AttachmentPolicy
+	scanner           *ScanFunc
 }
 
 // Domain sets the domain portion of the generated message Id.
@@ -82,15 +153,18 @@ func (m *Message) Subject(subject interface{}) *Message {
 	switch subject := subject.(type) {
 	case string:
 		m.subject = []byte(subject)
-		m.subjectTpl = nil
+		m.subjectTpl, m.subjectSource = nil, tplSource{}
 	case []byte:
 		m.subject = subject
-		m.subjectTpl = nil
+		m.subjectTpl, m.subjectSource = nil, tplSource{}
 	case *ttpl.Template:
 		m.subject = nil
-		m.subjectTpl = subject
+		m.subjectTpl, m.subjectSource = subject, textSource(subject)
+		if m.strict {
+			m.subjectTpl = m.strictText(subject)
+		}
 	default:
-		m.errors = append(m.errors, errors.New("invalid argument type"))
+		m.fail(ArgumentError, "subject", ErrInvalidArgument)
 	}
 	return m
 }
@@ -104,38 +178,42 @@ func (m *Message) SubjectTemplate(tpl string) *Message {
 	if tpl != "" {
 		t, err = ttpl.New("").Parse(tpl)
 		if err != nil {
-			m.errors = append(m.errors, errors.New("invalid subject template:\n"+tpl+"\nerror: "+err.Error()))
+			m.fail(TemplateError, "subject", &ErrTemplateParse{"subject", tpl, err})
 			return m
 		}
 	}
 	m.Lock()
 	defer m.Unlock()
-	m.subjectTpl = t
+	if m.strict {
+		t = m.strictText(t)
+	}
+	m.subjectTpl, m.subjectSource = t, tplSource{src: tpl}
 	return m
 }
 
-// From sets the From: email address.
+// From sets the From: email address. An invalid address is rejected - see Validation - and reported
+// with Errors.
 func (m *Message) From(addr *Address) *Message {
-	if addr != nil && !SeemsValidAddr(addr.Addr) {
-		addr = nil
-	}
 	m.Lock()
 	defer m.Unlock()
+	if addr != nil && !m.validAddr("from", addr) {
+		addr = nil
+	}
 	m.from = addr
 	return m
 }
 
 // To sets the To: email address(es). Last call overrides any previous calls, replacing rather than
-// adding to the list.
+// adding to the list. The invalid addresses are left out - see Validation - and reported with Errors.
 func (m *Message) To(addr ...*Address) *Message {
+	m.Lock()
+	defer m.Unlock()
 	lst := make(addrList, 0, len(addr))
 	for _, a := range addr {
-		if a != nil && SeemsValidAddr(a.Addr) {
+		if a != nil && m.validAddr("to", a) {
 			lst = append(lst, a)
 		}
 	}
-	m.Lock()
-	defer m.Unlock()
 	m.to = lst
 	return m
 }
@@ -143,14 +221,14 @@ func (m *Message) To(addr ...*Address) *Message {
 // Cc sets the (optional) Cc: email addresses. Last call overrides any previous calls, replacing rather than
 // adding to the list.
 func (m *Message) Cc(addr ...*Address) *Message {
+	m.Lock()
+	defer m.Unlock()
 	lst := make(addrList, 0, len(addr))
 	for _, a := range addr {
-		if a != nil && SeemsValidAddr(a.Addr) {
+		if a != nil && m.validAddr("cc", a) {
 			lst = append(lst, a)
 		}
 	}
-	m.Lock()
-	defer m.Unlock()
 	m.cc = lst
 	return m
 }
@@ -158,26 +236,75 @@ func (m *Message) Cc(addr ...*Address) *Message {
 // Bcc sets the (optional) Bcc: email addresses. Last call overrides any previous calls, replacing rather than
 // adding to the list.
 func (m *Message) Bcc(addr ...*Address) *Message {
+	m.Lock()
+	defer m.Unlock()
 	lst := make(addrList, 0, len(addr))
 	for _, a := range addr {
-		if a != nil && SeemsValidAddr(a.Addr) {
+		if a != nil && m.validAddr("bcc", a) {
 			lst = append(lst, a)
 		}
 	}
+	m.bcc = lst
+	return m
+}
+
+// AddTo adds email addresses to the To: list, skipping any that are already recipients of the message
+// - in any of the To:, Cc: or Bcc: lists.
+func (m *Message) AddTo(addr ...*Address) *Message {
 	m.Lock()
 	defer m.Unlock()
-	m.bcc = lst
+	m.to = m.addRecipients("to", m.to, addr)
 	return m
 }
 
+// AddCc adds email addresses to the Cc: list, skipping any that are already recipients of the message
+// - in any of the To:, Cc: or Bcc: lists.
+func (m *Message) AddCc(addr ...*Address) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.cc = m.addRecipients("cc", m.cc, addr)
+	return m
+}
+
+// AddBcc adds email addresses to the Bcc: list, skipping any that are already recipients of the
+// message - in any of the To:, Cc: or Bcc: lists.
+func (m *Message) AddBcc(addr ...*Address) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.bcc = m.addRecipients("bcc", m.bcc, addr)
+	return m
+}
+
+// addRecipients returns a new list with the valid addresses in `addr` appended to `lst` - the
+// `field` list - unless already present in the recipient lists of the receiver, compared
+// case-insensitively. The caller must hold the lock on the receiver.
+func (m *Message) addRecipients(field string, lst addrList, addr []*Address) addrList {
+	seen := map[string]bool{}
+	for _, l := range []addrList{m.to, m.cc, m.bcc} {
+		for _, a := range l {
+			seen[a.key()] = true
+		}
+	}
+	res := make(addrList, len(lst), len(lst)+len(addr))
+	copy(res, lst)
+	for _, a := range addr {
+		if a == nil || seen[a.key()] || !m.validAddr(field, a) {
+			continue
+		}
+		seen[a.key()] = true
+		res = append(res, a)
+	}
+	return res
+}
+
 // ReplyTo sets the (optional) Reply-To: email address. A `*Address` argument is expected for
 // consistency, although only the email address part is used.
 func (m *Message) ReplyTo(addr *Address) *Message {
-	if addr != nil && !SeemsValidAddr(addr.Addr) {
-		addr = nil
-	}
 	m.Lock()
 	defer m.Unlock()
+	if addr != nil && !m.validAddr("replyTo", addr) {
+		addr = nil
+	}
 	m.replyTo = addr
 	return m
 }
@@ -221,13 +348,17 @@ func (m *Message) Text(text interface{}) *Message {
 			bytes: text,
 		}
 	case *ttpl.Template:
+		if m.strict {
+			text = m.strictText(text)
+		}
 		*(m.text) = part{
-			ctype: "text/plain; charset=utf-8",
-			cte:   QuotedPrintable,
-			tpl:   text,
+			ctype:  "text/plain; charset=utf-8",
+			cte:    QuotedPrintable,
+			tpl:    text,
+			source: textSource(text),
 		}
 	default:
-		m.errors = append(m.errors, errors.New("invalid argument type"))
+		m.fail(ArgumentError, "text", ErrInvalidArgument)
 	}
 
 	return m
@@ -242,20 +373,24 @@ func (m *Message) TextTemplate(tpl string) *Message {
 	if tpl != "" {
 		t, err = ttpl.New("").Parse(tpl)
 		if err != nil {
-			m.errors = append(m.errors, errors.New("invalid text template:\n"+tpl+"\nerror: "+err.Error()))
+			m.fail(TemplateError, "text", &ErrTemplateParse{"text", tpl, err})
 			return m
 		}
 	}
 	m.Lock()
 	defer m.Unlock()
+	if m.strict {
+		t = m.strictText(t)
+	}
 	if m.text == nil {
 		m.text = &part{}
 		m.parts = append(m.parts, m.text)
 	}
 	*(m.text) = part{
-		ctype: "text/plain; charset=utf-8",
-		cte:   QuotedPrintable,
-		tpl:   t,
+		ctype:  "text/plain; charset=utf-8",
+		cte:    QuotedPrintable,
+		tpl:    t,
+		source: tplSource{src: tpl},
 	}
 	return m
 }
@@ -287,14 +422,18 @@ func (m *Message) Html(html interface{}, related ...Related) *Message {
 			related: related,
 		}
 	case *htpl.Template:
+		if m.strict {
+			html = m.strictHTML(html, m.partIndex(m.html))
+		}
 		*(m.html) = part{
 			ctype:   "text/html; charset=utf-8",
 			cte:     QuotedPrintable,
 			htmlTpl: html,
 			related: related,
+			source:  htmlSource(html),
 		}
 	default:
-		m.errors = append(m.errors, errors.New("invalid argument type"))
+		m.fail(ArgumentError, "html", ErrInvalidArgument)
 	}
 	m.prepared = false // related may include files
 	return m
@@ -310,7 +449,7 @@ func (m *Message) HtmlTemplate(tpl string, related ...Related) *Message {
 	if tpl != "" {
 		t, err = htpl.New("").Parse(tpl)
 		if err != nil {
-			m.errors = append(m.errors, errors.New("invalid html template:\n"+tpl+"\nerror: "+err.Error()))
+			m.fail(TemplateError, "html", &ErrTemplateParse{"html", tpl, err})
 			return m
 		}
 	}
@@ -320,118 +459,426 @@ func (m *Message) HtmlTemplate(tpl string, related ...Related) *Message {
 		m.html = &part{}
 		m.parts = append(m.parts, m.html)
 	}
+	if m.strict {
+		t = m.strictHTML(t, m.partIndex(m.html))
+	}
 	*(m.html) = part{
 		ctype:   "text/html; charset=utf-8",
 		cte:     QuotedPrintable,
 		htmlTpl: t,
 		related: related,
+		source:  tplSource{src: tpl},
 	}
 	m.prepared = false // related may include files
 	return m
 }
 
+// Templates sets the subject, plain-text and HTML templates of the message from a single source,
+// in which they are defined as blocks named "subject", "text" and "html", respectively - e.g.
+// `{{define "subject"}}Welcome, {{.name}}{{end}}`. The source is parsed only once for each
+// template flavor, and other blocks defined in it can be shared by the three templates.
+//
+// Any of the three blocks may be missing, in which case the corresponding template is left
+// unchanged. Optionally, related objects can be specified for inclusion with the HTML version.
+func (m *Message) Templates(src string, related ...Related) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.templates(src, related)
+	m.tplFile = nil
+	return m
+}
+
+// templates implements Templates, reporting whether the templates were set. The caller must hold
+// the lock on the receiver.
+func (m *Message) templates(src string, related []Related) bool {
+	tt, err := ttpl.New("").Parse(src)
+	if err == nil {
+		var ht *htpl.Template
+		if ht, err = htpl.New("").Parse(src); err == nil {
+			subject, text, html := tt.Lookup("subject"), tt.Lookup("text"), ht.Lookup("html")
+			if subject == nil && text == nil && html == nil {
+				m.fail(TemplateError, "templates", &ErrTemplateParse{"templates", src,
+					errors.New("none of the subject, text or html blocks is defined")})
+				return false
+			}
+			if m.strict {
+				tt.Option(m.missingKey())
+				ht.Option(m.missingKey())
+			}
+			if subject != nil {
+				m.subject = nil
+				m.subjectTpl, m.subjectSource = subject, tplSource{src, "subject"}
+			}
+			if text != nil {
+				if m.text == nil {
+					m.text = &part{}
+					m.parts = append(m.parts, m.text)
+				}
+				*(m.text) = part{
+					ctype:  "text/plain; charset=utf-8",
+					cte:    QuotedPrintable,
+					tpl:    text,
+					source: tplSource{src, "text"},
+				}
+			}
+			if html != nil {
+				if m.html == nil {
+					m.html = &part{}
+					m.parts = append(m.parts, m.html)
+				}
+				*(m.html) = part{
+					ctype:   "text/html; charset=utf-8",
+					cte:     QuotedPrintable,
+					htmlTpl: html,
+					related: related,
+					source:  tplSource{src, "html"},
+				}
+				m.prepared = false // related may include files
+			}
+			return true
+		}
+	}
+	m.fail(TemplateError, "templates", &ErrTemplateParse{"templates", src, err})
+	return false
+}
+
 // Attach attaches the files provided as filesystem paths.
 func (m *Message) Attach(file ...string) *Message {
 	m.Lock()
 	defer m.Unlock()
 	for _, fileName := range file {
-		m.attachments = append(m.attachments, &attachment{fileName: fileName})
+		a := &attachment{fileName: fileName, encoded: &encodedCache{}}
+		if m.screenAttachment(a, false) {
+			m.attachments = append(m.attachments, a)
+		}
 	}
 	m.prepared = false
 	return m
 }
 
 // AttachFile attaches a file specified by its filesystem path, setting its name and type
-// to the provided values.
+// to the provided values. An empty type is determined as for AttachObject.
 func (m *Message) AttachFile(name, ctype, file string) *Message {
 	m.Lock()
 	defer m.Unlock()
-	m.attachments = append(m.attachments, &attachment{
+	if name != "" {
+		name = m.sanitizeFilename(name)
+	}
+	a := &attachment{
 		name:     name,
 		ctype:    ctype,
 		fileName: file,
-	})
+		encoded:  &encodedCache{},
+	}
+	if m.screenAttachment(a, false) {
+		m.attachments = append(m.attachments, a)
+	}
 	m.prepared = false
 	return m
 }
 
-// AttachObject creates an attachment with the name, type and data provided.
+// AttachObject creates an attachment with the name, type and data provided. An empty type is
+// derived from the extension of the name, or else detected from the data - see SniffContent.
 func (m *Message) AttachObject(name, ctype string, data []byte) *Message {
 	m.Lock()
 	defer m.Unlock()
-	m.attachments = append(m.attachments, &attachment{
-		name:  name,
-		ctype: ctype,
-		data:  data,
-	})
+	if ctype == "" {
+		ctype = m.detectType(name, func() []byte { return data })
+	}
+	a := &attachment{
+		name:    m.sanitizeFilename(name),
+		ctype:   ctype,
+		data:    data,
+		encoded: &encodedCache{},
+	}
+	if m.screenAttachment(a, false) {
+		m.attachments = append(m.attachments, a)
+	}
 	return m
 }
 
 func (m *Message) prepare(force bool) {
+	m.prepareContext(context.Background(), force)
+}
+
+// prepareContext reads the files referenced by the receiver, within the concurrency limit set with
+// PrepareConcurrency. The errors reading the files are recorded, and the first of them returned;
+// if `ctx` is done first, its error is returned, and the receiver is left unchanged.
+func (m *Message) prepareContext(ctx context.Context, force bool) error {
 	if m.prepared && !force {
-		return
-	}
-	allOk := true
-	for _, p := range m.parts {
-		for _, r := range p.related {
-			if r.fileName != "" && (force || len(r.data) == 0) {
-				if file, err := ioutil.ReadFile(r.fileName); err == nil {
-					r.data = file
-				} else {
-					m.errors = append(m.errors, errors.New("cannot read file: "+r.fileName+": "+err.Error()))
-					allOk = false
-				}
+		return nil
+	}
+	var (
+		loads  []func() fileResult
+		apply  []func(res fileResult)
+		owners []MessageError
+	)
+	fileLoad := func(name string) func() fileResult {
+		return func() fileResult {
+			res := readFile(m.fsys, m.root, name)
+			if res.err != nil {
+				res.err = &ErrFileRead{name, res.err}
+			}
+			return res
+		}
+	}
+	fetcher := m.fetcher
+	urlLoad := func(url string) func() fileResult {
+		return func() fileResult {
+			return fetcher.fetch(ctx, url)
+		}
+	}
+	related := func(pn int, r *Related) {
+		switch {
+		case r.url != "" && (len(r.data) == 0 || force):
+			loads = append(loads, urlLoad(r.url))
+		case r.fileName != "" && (len(r.data) == 0 || force && fileChanged(m.fsys, m.root, r.fileName, r.modTime, r.size)):
+			loads = append(loads, fileLoad(r.fileName))
+		default:
+			return
+		}
+		owners = append(owners, MessageError{PartIndex: pn, AttachmentName: r.id})
+		apply = append(apply, func(res fileResult) {
+			r.data, r.modTime, r.size = res.data, res.modTime, res.size
+			if r.ctype == "" {
+				r.ctype = res.ctype
+			}
+			if r.ctype == "" {
+				r.ctype = "application/octet-stream"
 			}
+		})
+	}
+	for pn, p := range m.parts {
+		for i := range p.related {
+			related(pn, &p.related[i])
 		}
 	}
+	for i := range m.embeds {
+		related(-1, &m.embeds[i])
+	}
 	for _, a := range m.attachments {
-		if a.fileName != "" && (force || len(a.data) == 0) {
-			if file, err := ioutil.ReadFile(a.fileName); err == nil {
-				a.data = file
-				if a.name == "" {
-					a.name = filepath.Base(a.fileName)
+		if a.url != "" && (len(a.data) == 0 || force) {
+			a := a
+			loads = append(loads, urlLoad(a.url))
+			owners = append(owners, MessageError{PartIndex: -1, AttachmentName: a.name})
+			apply = append(apply, func(res fileResult) {
+				a.data, a.size = res.data, res.size
+				if a.ctype == "" {
+					a.ctype = res.ctype
 				}
 				if a.ctype == "" {
-					a.ctype = mime.TypeByExtension(filepath.Ext(a.fileName))
+					a.ctype = m.detectType(a.name, func() []byte { return a.data })
 				}
-			} else {
-				m.errors = append(m.errors, errors.New("cannot read file: "+a.fileName+": "+err.Error()))
-				allOk = false
+			})
+			continue
+		}
+		if a.fileName != "" && m.lazy {
+			m.describeAttachment(a)
+			if fi, err := statFile(m.fsys, m.root, a.fileName); err == nil {
+				a.modTime, a.size = fi.ModTime(), fi.Size()
 			}
+			continue
+		}
+		if a.fileName != "" && (len(a.data) == 0 || force && fileChanged(m.fsys, m.root, a.fileName, a.modTime, a.size)) {
+			a := a
+			loads = append(loads, fileLoad(a.fileName))
+			name := a.name
+			if name == "" {
+				name = filepath.Base(a.fileName)
+			}
+			owners = append(owners, MessageError{PartIndex: -1, AttachmentName: name})
+			apply = append(apply, func(res fileResult) {
+				a.data, a.modTime, a.size = res.data, res.modTime, res.size
+				m.describeAttachment(a)
+			})
 		}
 	}
-	m.prepared = allOk
+	results, err := loadAll(ctx, loads, m.prepareWorkers)
+	if err != nil {
+		return err
+	}
+	var first error
+	for i, res := range results {
+		if res.err != nil {
+			me := owners[i]
+			me.Kind, me.Err = FileError, res.err
+			err = &me
+			m.errors = append(m.errors, err)
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		apply[i](res)
+	}
+	m.prepared = first == nil
+	return first
 }
 
-// Prepare reads all the files referenced by the message at attachments or related items.
+// describeAttachment sets the name and the content type of the attachment `a`, if missing, from the
+// name of its file - or, for an unknown extension, from its content; see SniffContent.
+func (m *Message) describeAttachment(a *attachment) {
+	if a.name == "" {
+		a.name = m.sanitizeFilename(filepath.Base(a.fileName))
+	}
+	if a.ctype == "" {
+		head := fileHead(m.fsys, m.root, a.fileName)
+		if a.data != nil {
+			head = func() []byte { return a.data }
+		}
+		a.ctype = m.detectType(a.fileName, head)
+	}
+}
+
+type fileResult struct {
+	data    []byte
+	modTime time.Time
+	size    int64
+	ctype   string
+	err     error
+}
+
+// fileChanged reports whether the file with the `name` in `fsys` or the `root` may differ from the
+// one read when it had the `modTime` and `size`.
+func fileChanged(fsys fs.FS, root, name string, modTime time.Time, size int64) bool {
+	fi, err := statFile(fsys, root, name)
+	return err != nil || modTime.IsZero() || !fi.ModTime().Equal(modTime) || fi.Size() != size
+}
+
+// loadAll calls the `loads` functions - reading files or fetching URLs - using up to `workers`
+// goroutines. If `ctx` is done first, it returns the error of `ctx` without waiting for the loads in
+// progress.
+func loadAll(ctx context.Context, loads []func() fileResult, workers int) ([]fileResult, error) {
+	results := make([]fileResult, len(loads))
+	if len(loads) == 0 {
+		return results, nil
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	done := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, workers)
+	loop:
+		for i, load := range loads {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				break loop
+			}
+			wg.Add(1)
+			go func(i int, load func() fileResult) {
+				defer func() {
+					<-slots
+					wg.Done()
+				}()
+				results[i] = load()
+			}(i, load)
+		}
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return results, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// PrepareConcurrency sets the maximum number of files read, or URLs fetched, concurrently when
+// preparing the message, which cuts the latency for messages referencing many files or files on
+// slow network mounts. It defaults to 1, meaning that the files are read sequentially.
+func (m *Message) PrepareConcurrency(n int) *Message {
+	m.Lock()
+	m.prepareWorkers = n
+	m.Unlock()
+	return m
+}
+
+// Prepare reads all the files referenced by the message at attachments or related items, and
+// fetches those referenced by URLs - see AttachURL; the size limits of the attachments are then
+// checked - see SizeLimits - and the attachments not scanned yet are scanned - see
+// ScanAttachments.
 //
-// If the message was already prepared and no new files have been added, it is no-op.
+// If the message was already prepared and no new files have been added, it only checks the size
+// limits again.
 func (m *Message) Prepare() *Message {
 	m.Lock()
 	defer m.Unlock()
 	m.prepare(false)
+	m.checkAttachmentSizes(m.limitingSender())
+	m.scanAttachments(context.Background())
 	return m
 }
 
-// PrepareFresh forces a new preparation of the message, even if there were no changes to the referred
-// files since the previous one.
+// PrepareContext is like Prepare, but returns the first error reading the files, if any, and
+// stops when `ctx` is done, returning its error; it also checks the size limits of the attachments
+// - see SizeLimits - and returns the first veto of the scanner, if any. A canceled preparation
+// leaves the message unchanged, to be prepared again later.
+func (m *Message) PrepareContext(ctx context.Context) error {
+	m.Lock()
+	defer m.Unlock()
+	if err := m.prepareContext(ctx, false); err != nil {
+		return err
+	}
+	if err := m.checkAttachmentSizes(m.limitingSender()); err != nil {
+		return err
+	}
+	return m.scanAttachments(ctx)
+}
+
+// PrepareFresh forces a new preparation of the message, even if no files were added since the
+// previous one: all the referred files are checked again, and the ones whose size or modification
+// time changed since they were read are read again.
 func (m *Message) PrepareFresh() *Message {
 	m.Lock()
 	defer m.Unlock()
 	m.prepare(true)
+	m.checkAttachmentSizes(m.limitingSender())
+	m.scanAttachments(context.Background())
 	return m
 }
 
+// limitingSender returns the Sender whose size limits apply to the preparation of the receiver: the
+// one set with Sender, or else the default sender. The caller must hold the lock on the receiver.
+func (m *Message) limitingSender() *Sender {
+	if m.sender != nil {
+		return m.sender
+	}
+	return defaultSender
+}
+
 // Compose merges the `data` into the receiver's templates and creates the body of the SMTP message
 // to be sent.
 func (m *Message) Compose(data interface{}) []byte {
 	m.Lock()
 	defer m.Unlock()
+	return m.compose(data, nil)
+}
+
+// compose implements Compose. If `w` is not nil, the message is written to it instead of being
+// returned, with the lazy attachments streamed from their files. The caller must hold the lock on
+// the receiver.
+func (m *Message) compose(data interface{}, w io.Writer) []byte {
 	var (
 		from   *Address
 		recpts []*Address
-		buf    bytes.Buffer
+		buf    = getBuffer()
+		sender = m.sender
 	)
+	defer putBuffer(buf)
+	if m.reload {
+		m.reloadTemplates()
+	}
+	defer m.inherit()()
+	data = m.mergeData(data)
+	defer m.localize(data)()
+	m.id, m.fingerprint, m.composedAt = "", 0, time.Time{}
 	switch {
 	case m.from != nil:
 		from = m.from
@@ -440,168 +887,359 @@ func (m *Message) Compose(data interface{}) []byte {
 	case defaultSender != nil && defaultSender.address != nil:
 		from = defaultSender.address
 	}
+	if sender == nil {
+		sender = defaultSender
+	}
 	if from == nil {
-		m.errors = append(m.errors, errors.New("no From address"))
+		m.fail(AddressError, "from", ErrNoFrom)
 		return []byte{}
 	}
 	if m.subjectTpl != nil {
 		buf.Reset()
-		if err := m.subjectTpl.Execute(&buf, data); err != nil {
-			m.errors = append(m.errors, errors.New("failed Execute on subject template: "+err.Error()))
+		if err := m.subjectTpl.Execute(bufferWriter{buf}, data); err != nil {
+			m.fail(TemplateError, "subject", &ErrTemplateExec{"subject", err})
 		}
-		m.subject = make([]byte, buf.Len())
+		m.subject = make([]byte, len(buf.Bytes()))
 		copy(m.subject, buf.Bytes())
 	}
 	for partNo, partData := range m.parts {
 		switch {
 		case partData.tpl != nil:
 			buf.Reset()
-			if err := partData.tpl.Execute(&buf, data); err != nil {
-				m.errors = append(m.errors, errors.New("failed Execute on part["+strconv.Itoa(partNo)+"] template: "+err.Error()))
+			if err := partData.tpl.Execute(bufferWriter{buf}, data); err != nil {
+				m.failPart(TemplateError, partNo, "text", &ErrTemplateExec{"part[" + strconv.Itoa(partNo) + "]", err})
 			}
-			partData.bytes = make([]byte, buf.Len())
+			partData.bytes = make([]byte, len(buf.Bytes()))
 			copy(partData.bytes, buf.Bytes())
 		case partData.htmlTpl != nil:
 			buf.Reset()
-			if err := partData.htmlTpl.Execute(&buf, data); err != nil {
-				m.errors = append(m.errors, errors.New("failed Execute on part["+strconv.Itoa(partNo)+"] html template: "+err.Error()))
+			if err := partData.htmlTpl.Execute(bufferWriter{buf}, data); err != nil {
+				m.failPart(TemplateError, partNo, "html", &ErrTemplateExec{"part[" + strconv.Itoa(partNo) + "] html", err})
 			}
-			partData.bytes = make([]byte, buf.Len())
+			partData.bytes = make([]byte, len(buf.Bytes()))
 			copy(partData.bytes, buf.Bytes())
 		}
 	}
 	if len(m.parts) == 0 {
-		m.errors = append(m.errors, errors.New("message has no parts"))
+		m.fail(ContentError, "", ErrNoParts)
+	}
+	if m.amp != nil && m.html == nil {
+		m.fail(ContentError, "amp", errors.New("AMP part requires an HTML fallback"))
+	}
+	if len(m.embeds) > 0 && m.html == nil {
+		m.fail(ContentError, "html", errors.New("embedded items require an HTML body"))
+	}
+	subject, bodies := m.applyCompose(data, sender)
+	partBytes := func(p *part) []byte {
+		if b, ok := bodies[p]; ok {
+			return b
+		}
+		return p.bytes
 	}
 	m.prepare(false)
+	m.screenAttachments()
+	m.scanAttachments(context.Background())
+	defer m.zipAttachments()()
+	m.checkAttachmentSizes(sender)
 	if len(m.errors) != 0 {
 		return []byte{}
 	}
+	preview := m.attachmentPreview()
+	if preview != "" && m.text != nil {
+		if bodies == nil {
+			bodies = map[*part][]byte{}
+		}
+		bodies[m.text] = append(append([]byte{}, partBytes(m.text)...), "\r\n\r\n"+preview...)
+	}
+	if m.htmlPolicy != nil && m.html != nil {
+		if bodies == nil {
+			bodies = map[*part][]byte{}
+		}
+		bodies[m.html] = m.htmlPolicy.Sanitize(partBytes(m.html))
+	}
+	if m.inlineCSS && m.html != nil {
+		if bodies == nil {
+			bodies = map[*part][]byte{}
+		}
+		bodies[m.html] = InlineCSS(partBytes(m.html))
+	}
+	if dir := m.direction(); m.html != nil && (m.lang != "" || dir != AutoDir) {
+		if bodies == nil {
+			bodies = map[*part][]byte{}
+		}
+		bodies[m.html] = localizeHTML(partBytes(m.html), m.lang, dir)
+	}
+	if len(m.remoteHosts) > 0 && m.html != nil {
+		if bodies == nil {
+			bodies = map[*part][]byte{}
+		}
+		var restore func()
+		bodies[m.html], restore = m.embedRemoteImages(partBytes(m.html))
+		defer restore()
+		if len(m.errors) != 0 {
+			return []byte{}
+		}
+	}
+	langHeader := ""
+	if m.lang != "" {
+		langHeader = "Content-Language: " + m.lang + "\r\n"
+	}
 
 	domain := m.domain
 	if len(domain) == 0 {
 		domain = []byte(from.Domain())
 	}
 
-	ts := []byte(now().In(time.UTC).Format(time.RFC1123Z))
-	uid := newUUID()
+	m.composedAt = m.time(sender).In(time.UTC)
+	ts := FormatDate(m.composedAt)
+	uid := []byte(m.idSource(sender).NewID())
+	m.id = "<" + string(uid) + "@" + string(domain) + ">"
+	if m.text != nil {
+		m.fingerprint = NewFingerprint(string(subject) + "\n" + string(partBytes(m.text)))
+	} else if m.html != nil {
+		m.fingerprint = NewFingerprint(string(subject) + "\n" + m.toText(partBytes(m.html)))
+	}
 
-	msg := newBuffer(4096)
+	msg := getBuffer()
 	msg.Write("Message-ID: <", uid, '@', domain, ">\r\n")
 	msg.Write("Date: ", ts, "\r\n")
-	msg.Write("Subject: ", QEncodeIfNeeded(m.subject, 9), "\r\n")
+	msg.Write("Subject: ", m.encodeSubject(subject), "\r\n")
 	addr, _ := from.encode(6)
 	msg.Write("From: ", addr, "\r\n")
+	msg.Write(m.senderHeader(from, sender))
 	if m.replyTo != nil && m.replyTo.Addr != "" && m.replyTo.Addr != from.Addr {
 		addr, _ = m.replyTo.encode(10)
 		msg.Write("Reply-To: ", addr, "\r\n")
 	}
 
-	listAddrs := func(list []*Address, offset int) []byte {
-		addrs := newBuffer(1024)
+	writeAddrs := func(list []*Address, offset int) {
 		for i, item := range list {
 			if i > 0 {
 				switch {
 				case offset < 75:
-					addrs.Write(", ")
+					msg.Write(", ")
 					offset += 2
 				case offset < 76:
-					addrs.Write(",\r\n ")
+					msg.Write(",\r\n ")
 					offset = 1
 				default:
-					addrs.Write("\r\n , ")
+					msg.Write("\r\n , ")
 					offset = 3
 				}
 			}
 			addr, offset = item.encode(offset)
-			addrs.Write(addr)
+			msg.Write(addr)
 		}
-		return addrs.Bytes()
 	}
 
 	recpts = m.to
 	if len(recpts) == 0 {
 		recpts = []*Address{from}
 	}
-	msg.Write("To: ", listAddrs(recpts, 4), "\r\n")
+	msg.Write("To: ")
+	writeAddrs(recpts, 4)
+	msg.Write("\r\n")
 	if len(m.cc) > 0 {
-		msg.Write("Cc: ", listAddrs(m.cc, 4), "\r\n")
+		msg.Write("Cc: ")
+		writeAddrs(m.cc, 4)
+		msg.Write("\r\n")
 	}
 
 	// Do not add BCC addresses into the message - they will show up at all recipients!
 
+	msg.Write(m.threadHeaders())
+	msg.Write(m.receiptHeaders())
+	msg.Write(m.priorityHeaders())
+	for _, h := range m.headers {
+		msg.Write(encodeHeader(h.name, h.value))
+	}
+
 	msg.Write("MIME-Version: 1.0\r\n")
+	bodyStart := len(msg.Bytes())
+	stream := w
+	if m.encrypt != nil {
+		stream = nil // the whole body is needed for encrypting it
+	}
 
+	var bm, ba string
 	if len(m.attachments) > 0 {
-		msg.Write("Content-Type: multipart/mixed;\r\n\tboundary=B_m_", uid,
-			"\r\n\r\n--B_m_", uid, "\r\n")
+		bm = m.boundary(sender, MixedBoundary, 0, string(uid))
+	}
+	autoText := m.hasAutoText()
+	alt := autoText || len(m.parts) > 1
+	if alt {
+		ba = m.boundary(sender, AlternativeBoundary, 0, string(uid))
+	}
+	if len(m.errors) != 0 {
+		return []byte{}
 	}
 
-	alt := m.html != nil || len(m.parts) > 1
+	if len(m.attachments) > 0 {
+		msg.Write("Content-Type: multipart/mixed;\r\n\tboundary=", boundaryParam(bm),
+			"\r\n\r\n--", bm, "\r\n")
+	}
 
 	if alt {
-		msg.Write("Content-Type: multipart/alternative;\r\n\tboundary=B_a_", uid, "\r\n")
+		msg.Write("Content-Type: multipart/alternative;\r\n\tboundary=", boundaryParam(ba), "\r\n")
 	}
 
-	if m.html != nil && m.text == nil {
+	if autoText {
+		text := m.toText(partBytes(m.html))
+		if preview != "" {
+			text += "\r\n\r\n" + preview
+		}
+		ctype, body := "text/plain; charset=utf-8", []byte(text)
+		if m.charset != "" {
+			enc, err := fromUTF8(m.charset, body)
+			if err != nil {
+				m.fail(ContentError, "charset", err)
+				return []byte{}
+			}
+			ctype, body = withCharset(ctype, m.charset), enc
+		}
 		if alt {
-			msg.Write("\r\n--B_a_", uid, "\r\n")
+			msg.Write("\r\n--", ba, "\r\n")
 		}
-		msg.Write("Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n",
-			QuotedPrintableEncode([]byte(htmlToText(string(m.html.bytes)))), "\r\n")
+		msg.Write("Content-Type: ", ctype, "\r\n", langHeader, "Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		msg.writeQP(body)
+		msg.Write("\r\n")
 	}
-	for partNo, partData := range m.parts {
+	for partNo, partData := range m.alternatives() {
 		if alt {
-			msg.Write("\r\n--B_a_", uid, "\r\n")
+			msg.Write("\r\n--", ba, "\r\n")
 		}
 		pn := strconv.Itoa(partNo)
-		if len(partData.related) > 0 {
-			msg.Write("Content-Type: multipart/related;\r\n\tboundary=B_r_", pn, uid,
-				"\r\n\r\n--B_r_", pn, uid, "\r\n")
-			// ToDo: substitute the related Ids in content
+		related := m.partRelated(partData)
+		body := partBytes(partData)
+		var (
+			cids []string
+			br   string
+		)
+		if len(related) > 0 {
+			br = m.boundary(sender, RelatedBoundary, partNo, string(uid))
+			if len(m.errors) != 0 {
+				return []byte{}
+			}
+			msg.Write("Content-Type: multipart/related;\r\n\tboundary=", boundaryParam(br),
+				"\r\n\r\n--", br, "\r\n")
+			cids = relatedContentIDs(related, pn, string(uid), string(domain))
+			body = substituteContentIDs(body, related, cids)
+		}
+		ctype := partData.ctype
+		if cs := m.partCharset(partData); cs != "" {
+			enc, err := fromUTF8(cs, body)
+			if err != nil {
+				m.failPart(ContentError, m.partIndex(partData), "charset", err)
+				return []byte{}
+			}
+			ctype, body = withCharset(ctype, cs), enc
+		}
+		msg.Write("Content-Type: ", ctype, "\r\n")
+		if strings.HasPrefix(ctype, "text/") {
+			msg.Write(langHeader)
+		}
+		cte := partData.cte
+		if cte == AutoCTE {
+			cte = autoCTE(body)
 		}
-		switch partData.cte {
+		switch cte {
+		case SevenBit, EightBit:
+			if err := checkIdentityCTE(body, cte); err != nil {
+				m.failPart(ContentError, m.partIndex(partData), "cte", err)
+				return []byte{}
+			}
+			msg.Write("Content-Transfer-Encoding: ", cte.String(), "\r\n\r\n", body, "\r\n")
 		case Base64:
-			msg.Write("Content-Type: ", partData.ctype, "\r\nContent-Transfer-Encoding: base64\r\n\r\n",
-				Base64Encode(partData.bytes), "\r\n")
+			msg.Write("Content-Transfer-Encoding: base64\r\n\r\n")
+			msg.writeBase64(body)
+			msg.Write("\r\n")
 		default:
 			fallthrough
 		case QuotedPrintable:
-			msg.Write("Content-Type: ", partData.ctype, "\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n",
-				QuotedPrintableEncode(partData.bytes), "\r\n")
+			msg.Write("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+			msg.writeQP(body)
+			msg.Write("\r\n")
 		}
-		for _, relData := range partData.related {
-			msg.Write("\r\n--B_r_", pn, uid, "\r\n")
-			msg.Write("Content-Type: ", relData.ctype, "\r\nContent-Transfer-Encoding: base64\r\n\r\n",
-				Base64Encode(relData.data), "\r\n")
+		for i, relData := range related {
+			msg.Write("\r\n--", br, "\r\n")
+			msg.Write("Content-Type: ", relData.ctype, "\r\nContent-ID: <", cids[i], ">\r\n")
+			if relData.inline {
+				msg.Write("Content-Disposition: inline", filenameParams(relData.id), "\r\n")
+			} else {
+				msg.Write("Content-Disposition: inline\r\n")
+			}
+			msg.Write("Content-Transfer-Encoding: base64\r\n\r\n")
+			msg.writeBase64(relData.data)
+			msg.Write("\r\n")
 		}
-		if len(partData.related) > 0 {
-			msg.Write("\r\n--B_r_", pn, uid, "--\r\n")
+		if len(related) > 0 {
+			msg.Write("\r\n--", br, "--\r\n")
 		}
 	}
 	if alt {
-		msg.Write("\r\n--B_a_", uid, "--\r\n")
+		msg.Write("\r\n--", ba, "--\r\n")
 	}
 
 	for _, attData := range m.attachments {
-		msg.Write("\r\n--B_m_", uid, "\r\n")
-		msg.Write("Content-Type: ", attData.ctype,
-			"\r\nContent-Disposition: attachment;\r\n\tfilename=", fmt.Sprintf("%q", attData.name),
-			"\r\nContent-Transfer-Encoding: base64\r\n\r\n",
-			Base64Encode(attData.data), "\r\n")
+		msg.Write("\r\n--", bm, "\r\n")
+		msg.Write("Content-Type: ", attData.ctype, "\r\n", dispositionHeader(attData))
+		if attData.ctype == "message/rfc822" && attData.data != nil {
+			msg.Write("Content-Transfer-Encoding: ", identityCTE(attData.data), "\r\n\r\n", attData.data, "\r\n")
+			continue
+		}
+		msg.Write("Content-Transfer-Encoding: base64\r\n\r\n")
+		if m.lazy && attData.fileName != "" {
+			if err := m.streamAttachment(msg, stream, attData); err != nil {
+				m.failAttachment(FileError, attData.name, err)
+				return []byte{}
+			}
+			msg.Write("\r\n")
+			continue
+		}
+		msg.Write(attData.base64(), "\r\n")
 	}
 
 	if len(m.attachments) > 0 {
-		msg.Write("\r\n--B_m_", uid, "--\r\n")
+		msg.Write("\r\n--", bm, "--\r\n")
 	}
 
-	return msg.Bytes()
+	if m.encrypt != nil {
+		enc, err := m.encryptBody(m.encrypt, from, msg.Bytes()[bodyStart:])
+		if err != nil {
+			field := ""
+			if err == ErrEncryptBcc {
+				field = "bcc"
+			}
+			m.fail(EncryptionError, field, err)
+			return []byte{}
+		}
+		*msg = append((*msg)[:bodyStart], enc...)
+	}
+
+	defer putBuffer(msg)
+	if w != nil {
+		if _, err := w.Write(msg.Bytes()); err != nil {
+			m.fail(WriteError, "", err)
+		}
+		return nil
+	}
+	if _, limit := m.sizeLimits(sender); limit > 0 && int64(len(msg.Bytes())) > limit {
+		m.fail(ContentError, "", &ErrSizeLimit{Size: int64(len(msg.Bytes())), Limit: limit})
+		return []byte{}
+	}
+	// the buffer is reused, so the caller gets a copy of the right size
+	return append(make([]byte, 0, len(msg.Bytes())), msg.Bytes()...)
 }
 
 // FromAddr returns the email address that the message would be sent from.
 func (m *Message) FromAddr() string {
 	m.RLock()
 	defer m.RUnlock()
+	return m.fromAddr()
+}
+
+// fromAddr implements FromAddr. The caller must hold the lock on the receiver.
+func (m *Message) fromAddr() string {
 	var from *Address
 	switch {
 	case m.from != nil:
@@ -612,48 +1250,50 @@ func (m *Message) FromAddr() string {
 		from = defaultSender.address
 	}
 	if from != nil {
-		return from.Addr
+		return from.ASCII()
 	}
 	return ""
 }
 
 // RecipientAddrs returns a list of email addresses with all the recipients for the message.
 //
-// It includes addresses from the To, CC and BCC fields.
+// It includes addresses from the To, CC and BCC fields, listing only once the addresses that are
+// Equal.
 func (m *Message) RecipientAddrs() []string {
 	m.RLock()
 	defer m.RUnlock()
+	return m.recipientAddrs()
+}
+
+// recipientAddrs implements RecipientAddrs. The caller must hold the lock on the receiver.
+func (m *Message) recipientAddrs() []string {
 	to := make([]string, 0, len(m.to)+len(m.cc)+len(m.bcc)+1)
 	seen := map[string]struct{}{}
 	if len(m.to) == 0 {
-		addr := m.FromAddr()
+		addr := m.fromAddr()
 		to = append(to, addr)
-		seen[addr] = struct{}{}
-	}
-	for _, val := range m.to {
-		addr := val.Addr
-		if _, s := seen[addr]; !s {
-			to = append(to, addr)
-			seen[addr] = struct{}{}
-		}
-	}
-	for _, val := range m.cc {
-		addr := val.Addr
-		if _, s := seen[addr]; !s {
-			to = append(to, addr)
-			seen[addr] = struct{}{}
-		}
+		seen[strings.ToLower(addr)] = struct{}{}
 	}
-	for _, val := range m.bcc {
-		addr := val.Addr
-		if _, s := seen[addr]; !s {
-			to = append(to, addr)
-			seen[addr] = struct{}{}
+	for _, lst := range []addrList{m.to, m.cc, m.bcc} {
+		for _, val := range lst {
+			key := val.key()
+			if _, s := seen[key]; !s {
+				to = append(to, val.ASCII())
+				seen[key] = struct{}{}
+			}
 		}
 	}
 	return to
 }
 
+// MessageID returns the Message-ID header value, including the angle brackets, generated by the
+// most recent successful call to Compose - or an empty string if the message was never composed.
+func (m *Message) MessageID() string {
+	m.RLock()
+	defer m.RUnlock()
+	return m.id
+}
+
 // HasErrors checks if there are any errors associated with the receiver
 func (m *Message) HasErrors() bool {
 	m.RLock()
@@ -662,6 +1302,7 @@ func (m *Message) HasErrors() bool {
 }
 
 // Errors returns the list of errors associated with the receiver, then resets the internal list.
+// The errors are of type *MessageError, identifying the field, part or attachment they are about.
 func (m *Message) Errors() (errs []error) {
 	m.Lock()
 	defer m.Unlock()
@@ -670,6 +1311,11 @@ func (m *Message) Errors() (errs []error) {
 }
 
 // NewMessage creates a new Message, deep-copying from `msg`, if provided.
+//
+// The parsed templates are not copied but shared, since they are never changed once set - setting
+// a template replaces it - and can be executed concurrently; so are the attachments and the data
+// of the related items. Thus, creating a message for each recipient of a mail merge from a base
+// message does not parse its templates again.
 func NewMessage(msg *Message) *Message {
 	if msg == nil {
 		return &Message{prepared: true}
@@ -677,16 +1323,65 @@ func NewMessage(msg *Message) *Message {
 	msg.RLock()
 	defer msg.RUnlock()
 	m := &Message{
-		domain:     msg.domain,
-		sender:     msg.sender,
-		subject:    msg.subject,
-		subjectTpl: msg.subjectTpl,
-		from:       msg.from.Clone(),
-		replyTo:    msg.replyTo.Clone(),
-		to:         msg.to.Clone(),
-		cc:         msg.cc.Clone(),
-		bcc:        msg.bcc.Clone(),
-		prepared:   msg.prepared,
+		domain:        msg.domain,
+		sender:        msg.sender,
+		subject:       msg.subject,
+		subjectTpl:    msg.subjectTpl,
+		subjectSource: msg.subjectSource,
+		from:          msg.from.Clone(),
+		replyTo:       msg.replyTo.Clone(),
+		to:            msg.to.Clone(),
+		cc:            msg.cc.Clone(),
+		bcc:           msg.bcc.Clone(),
+		prepared:      msg.prepared,
+
+		filenamePolicy: msg.filenamePolicy,
+		composeMws:     msg.composeMws,
+		preview:        msg.preview,
+		prepareWorkers: msg.prepareWorkers,
+		root:           msg.root,
+		inReplyTo:      msg.inReplyTo,
+		references:     append([]string(nil), msg.references...),
+		ids:            msg.ids,
+		clock:          msg.clock,
+		date:           msg.date,
+		lang:           msg.lang,
+		dir:            msg.dir,
+		receiptTo:      msg.receiptTo,
+		priority:       msg.priority,
+		senderAddr:     msg.senderAddr,
+		lazy:           msg.lazy,
+		embeds:         append([]Related(nil), msg.embeds...),
+		boundaries:     msg.boundaries,
+		encrypt:        msg.encrypt,
+		htmlPolicy:     msg.htmlPolicy,
+		inlineCSS:      msg.inlineCSS,
+		noAutoText:     msg.noAutoText,
+		textConverter:  msg.textConverter,
+		charset:        msg.charset,
+		fsys:           msg.fsys,
+		defaultData:    msg.defaultData,
+		locales:        msg.locales,
+		defaultLocale:  msg.defaultLocale,
+		localeField:    msg.localeField,
+		strict:         msg.strict,
+		reload:         msg.reload,
+		tplFile:        msg.tplFile,
+		base:           msg.base,
+		validation:     msg.validation,
+
+		maxAttachmentSize: msg.maxAttachmentSize,
+		maxMessageSize:    msg.maxMessageSize,
+		zipPolicy:         msg.zipPolicy,
+		fetcher:           msg.fetcher,
+		remoteHosts:       msg.remoteHosts,
+		sniffer:           msg.sniffer,
+		attachmentPolicy:  msg.attachmentPolicy,
+		scanner:           msg.scanner,
+	}
+	if len(msg.headers) > 0 {
+		m.headers = make([]header, len(msg.headers))
+		copy(m.headers, msg.headers)
 	}
 	m.parts = make([]*part, len(msg.parts))
 	for i, partData := range msg.parts {
@@ -695,6 +1390,8 @@ func NewMessage(msg *Message) *Message {
 			cte:     partData.cte,
 			tpl:     partData.tpl,
 			htmlTpl: partData.htmlTpl,
+			source:  partData.source,
+			charset: partData.charset,
 			// related    []Related
 		}
 		if len(partData.bytes) > 0 {
@@ -712,6 +1409,12 @@ func NewMessage(msg *Message) *Message {
 		if msg.html == partData {
 			m.html = p
 		}
+		if msg.calendar == partData {
+			m.calendar = p
+		}
+		if msg.amp == partData {
+			m.amp = p
+		}
 		m.parts[i] = p
 	}
 	m.attachments = make([]*attachment, len(msg.attachments))
@@ -742,14 +1445,33 @@ type part struct {
 	tpl     *ttpl.Template
 	htmlTpl *htpl.Template
 	related []Related
+	charset string
+	source  tplSource
 }
 
-// Related represents a multipart/related item.
+// partIndex returns the index of the part `p` of the receiver, or -1.
+func (m *Message) partIndex(p *part) int {
+	for i, q := range m.parts {
+		if q == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// Related represents a multipart/related item. The part it is related to refers to it by its id,
+// as a quoted or parenthesized "cid:" URL - e.g. <img src="cid:logo"> - or as a quoted attribute
+// value - e.g. <img src="logo">; the references are replaced with the Content-ID generated for the
+// item when composing the message.
 type Related struct {
 	id       string
 	ctype    string
 	fileName string
+	url      string
 	data     []byte
+	modTime  time.Time
+	size     int64
+	inline   bool
 }
 
 // RelatedFile creates a Related structure from the provided file information.
@@ -771,8 +1493,13 @@ func RelatedObject(id, ctype string, data []byte) Related {
 }
 
 type attachment struct {
-	name     string
-	ctype    string
-	fileName string
-	data     []byte
+	name        string
+	ctype       string
+	fileName    string
+	url         string
+	data        []byte
+	modTime     time.Time
+	size        int64
+	disposition *Disposition
+	encoded     *encodedCache
 }
@@ -2,8 +2,12 @@ package email
 
 import (
 	"bytes"
+	"context"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -46,7 +50,6 @@ func Test_Compose(t *testing.T) {
 	date := time.Date(2013, 8, 30, 9, 10, 11, 0, time.UTC)
 	workDir, _ := os.Getwd()
 	uid := []byte(uuid.New().Hex())
-	newUUID = func() []byte { return uid }
 	cases := []messageTestCase{
 		{
 			src: messageIn{
@@ -252,7 +255,7 @@ func Test_Compose(t *testing.T) {
 	}
 
 	for i, c := range cases {
-		msg := NewMessage(nil).Domain(c.src.domain).Subject(c.src.subject).
+		msg := NewMessage(nil).IDs(FixedID(string(uid))).Domain(c.src.domain).Subject(c.src.subject).
 			setSender(c.src.sender).From(c.src.from).ReplyTo(c.src.replyTo).
 			To(c.src.to...).Cc(c.src.cc...).Bcc(c.src.bcc...)
 		if c.src.subjectTpl != "" {
@@ -301,3 +304,154 @@ func Test_Compose(t *testing.T) {
 		}
 	}
 }
+
+func Test_Templates(t *testing.T) {
+	uid := []byte(uuid.New().Hex())
+	forceNow(time.Date(2013, 8, 30, 9, 10, 11, 0, time.UTC).Unix())
+	msg := NewMessage(nil).IDs(FixedID(string(uid))).From(&Address{"", "test@example.com"}).Templates(
+		`{{define "greeting"}}Hi {{.name}}{{end}}` +
+			`{{define "subject"}}Welcome, {{.name}}{{end}}` +
+			`{{define "text"}}{{template "greeting" .}}!{{end}}` +
+			`{{define "html"}}<p>{{template "greeting" .}}!</p>{{end}}`)
+	exp := []byte("Message-ID: <" + string(uid) + "@example.com>\r\n" +
+		"Date: Fri, 30 Aug 2013 09:10:11 +0000\r\n" +
+		"Subject: Welcome, John & Jill\r\n" +
+		"From: <test@example.com>\r\n" +
+		"To: <test@example.com>\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/alternative;\r\n" +
+		"\tboundary=B_a_" + string(uid) + "\r\n\r\n" +
+		"--B_a_" + string(uid) + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n\r\n" +
+		"Hi John & Jill!\r\n\r\n" +
+		"--B_a_" + string(uid) + "\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n\r\n" +
+		"<p>Hi John &amp; Jill!</p>\r\n\r\n" +
+		"--B_a_" + string(uid) + "--\r\n")
+	if act := msg.Compose(map[string]string{"name": "John & Jill"}); !bytes.Equal(act, exp) {
+		t.Errorf("(*Message).Templates: got (len=%d)\n%s\nwant (len=%d)\n%s", len(act), act, len(exp), exp)
+	}
+
+	msg = NewMessage(nil).Templates(`{{define "footer"}}bye{{end}}`)
+	if errs := msg.Errors(); len(errs) != 1 {
+		t.Errorf("(*Message).Templates: got %d errors, want 1", len(errs))
+	}
+}
+
+func Test_PrepareContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "email-prepare")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	msg := QuickMessage("test", "body").PrepareConcurrency(3)
+	msg.Html("<img src=\"cid:logo\">", RelatedFile("logo", "image/png", filepath.Join(dir, "logo.png")))
+	for i := 0; i < 8; i++ {
+		name := filepath.Join(dir, "file"+strconv.Itoa(i)+".txt")
+		ioutil.WriteFile(name, []byte("content "+strconv.Itoa(i)), 0600)
+		msg.Attach(name)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "logo.png"), []byte("PNG"), 0600)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := msg.PrepareContext(ctx); err != context.Canceled || msg.prepared || msg.HasErrors() {
+		t.Errorf("(*Message).PrepareContext: got error %v, want a clean cancellation", err)
+	}
+	if err := msg.PrepareContext(context.Background()); err != nil {
+		t.Fatalf("(*Message).PrepareContext: unexpected error: %v", err)
+	}
+	for i, a := range msg.attachments {
+		if exp := "content " + strconv.Itoa(i); string(a.data) != exp || a.name != "file"+strconv.Itoa(i)+".txt" || a.ctype == "" {
+			t.Errorf("(*Message).PrepareContext: got attachment %q (%s) with %q, want %q", a.name, a.ctype, a.data, exp)
+		}
+	}
+	if data := msg.html.related[0].data; string(data) != "PNG" {
+		t.Errorf("(*Message).PrepareContext: got related data %q, want %q", data, "PNG")
+	}
+
+	msg.Attach(filepath.Join(dir, "missing.txt"))
+	if err := msg.PrepareContext(context.Background()); err == nil || !msg.HasErrors() {
+		t.Error("(*Message).PrepareContext: expected an error for a missing file")
+	}
+}
+
+func Test_PrepareFresh(t *testing.T) {
+	dir, err := ioutil.TempDir("", "email-prepare")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "data.txt")
+	ioutil.WriteFile(name, []byte("v1"), 0600)
+	msg := QuickMessage("test", "body").Attach(name).Prepare()
+	att := msg.attachments[0]
+	if string(att.data) != "v1" {
+		t.Fatalf("(*Message).Prepare: got %q", att.data)
+	}
+
+	// an unchanged file is not read again
+	att.data = []byte("cached")
+	if msg.PrepareFresh(); string(att.data) != "cached" {
+		t.Errorf("(*Message).PrepareFresh: got %q for an unchanged file, want %q", att.data, "cached")
+	}
+	// same size, different modification time
+	ioutil.WriteFile(name, []byte("v2"), 0600)
+	os.Chtimes(name, time.Now(), att.modTime.Add(time.Second))
+	if msg.PrepareFresh(); string(att.data) != "v2" {
+		t.Errorf("(*Message).PrepareFresh: got %q, want %q", att.data, "v2")
+	}
+	// different size
+	ioutil.WriteFile(name, []byte("v3 longer"), 0600)
+	os.Chtimes(name, time.Now(), att.modTime)
+	if msg.PrepareFresh(); string(att.data) != "v3 longer" {
+		t.Errorf("(*Message).PrepareFresh: got %q, want %q", att.data, "v3 longer")
+	}
+}
+
+func Test_AddRecipients(t *testing.T) {
+	base := NewMessage(nil).To(&Address{"", "a@example.com"})
+	msg := NewMessage(base).
+		AddTo(&Address{"", "b@example.com"}, &Address{"", "A@example.com"}, nil, &Address{"", "invalid"}).
+		AddCc(&Address{"", "c@example.com"}, &Address{"", "b@example.com"}).
+		AddBcc(&Address{"", "d@example.com"}, &Address{"", "d@example.com"})
+	if act, exp := strings.Join(msg.RecipientAddrs(), ","), "a@example.com,b@example.com,c@example.com,d@example.com"; act != exp {
+		t.Errorf("(*Message).AddTo: got %q want %q", act, exp)
+	}
+	if len(msg.to) != 2 || len(msg.cc) != 1 || len(msg.bcc) != 1 {
+		t.Errorf("(*Message).AddTo: got to %v, cc %v, bcc %v", msg.to, msg.cc, msg.bcc)
+	}
+	if len(base.to) != 1 {
+		t.Errorf("(*Message).AddTo: base message changed to %v", base.to)
+	}
+}
+
+func Test_NewMessageSharesTemplates(t *testing.T) {
+	base := NewMessage(nil).From(&Address{"", "test@example.com"}).To(&Address{"", "to@example.com"}).
+		SubjectTemplate("Hi {{.}}").TextTemplate("Hello, {{.}}").HtmlTemplate("<p>Hello, {{.}}</p>")
+	clones := make([]*Message, 8)
+	for i := range clones {
+		clones[i] = NewMessage(base)
+		if clones[i].subjectTpl != base.subjectTpl || clones[i].text.tpl != base.text.tpl ||
+			clones[i].html.htmlTpl != base.html.htmlTpl {
+			t.Fatalf("NewMessage: the parsed templates are not shared")
+		}
+	}
+	done := make(chan string)
+	for i, clone := range clones {
+		go func(i int, m *Message) {
+			done <- string(m.Compose(strconv.Itoa(i)))
+		}(i, clone)
+	}
+	for range clones {
+		if act := <-done; !strings.Contains(act, "Subject: Hi ") {
+			t.Errorf("NewMessage: got %q", act)
+		}
+	}
+	clones[0].TextTemplate("Changed, {{.}}")
+	if clones[0].text.tpl == base.text.tpl || !strings.Contains(string(base.Compose("x")), "Hello, x") {
+		t.Errorf("(*Message).TextTemplate: the change affects the base message")
+	}
+}
@@ -0,0 +1,32 @@
+package email
+
+import (
+	"time"
+)
+
+// MetricsCollector receives notifications about the messages handled by a Sender, so that
+// applications can feed counters and histograms (Prometheus, StatsD, etc.) without wrapping
+// every call site.
+//
+// The methods are called from the goroutine doing the work, which may not be the goroutine that
+// called Send, so implementations must be safe for concurrent use and should return quickly.
+type MetricsCollector interface {
+	// OnComposed is called after a message was successfully composed, with the size of the
+	// resulting SMTP body and the time it took to compose it.
+	OnComposed(msg *Message, size int, elapsed time.Duration)
+	// OnSent is called after a message was accepted by the SMTP server, with the size of the
+	// SMTP body and the time it took to deliver it.
+	OnSent(msg *Message, size int, elapsed time.Duration)
+	// OnFailed is called when either composing or delivering a message failed, with the time
+	// spent until the failure.
+	OnFailed(msg *Message, err error, elapsed time.Duration)
+}
+
+// Metrics sets the collector to be notified about the messages composed and sent by the receiver.
+// A nil `mc` disables the notifications.
+func (s *Sender) Metrics(mc MetricsCollector) *Sender {
+	s.mu.Lock()
+	s.metrics = mc
+	s.mu.Unlock()
+	return s
+}
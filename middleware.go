@@ -0,0 +1,32 @@
+package email
+
+// SendFunc is the signature of the functions handling the sending of a message, as wrapped by
+// Middleware.
+type SendFunc func(msg *Message, data interface{}) error
+
+// Middleware wraps a SendFunc with cross-cutting behavior - e.g. audit logging, suppression
+// checks, header stamping or A/B tagging. It may inspect or alter the message and data before
+// calling `next`, inspect the error returned by it, or skip calling it altogether to prevent the
+// message from being sent.
+type Middleware func(next SendFunc) SendFunc
+
+// Use installs middleware to be invoked around every send by the receiver. Middleware installed
+// first is outermost: it is called first, and sees the results of all the others.
+func (s *Sender) Use(mw ...Middleware) *Sender {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mws := make([]Middleware, 0, len(s.mws)+len(mw))
+	s.mws = append(append(mws, s.mws...), mw...)
+	return s
+}
+
+// chain wraps `core` in the middleware installed on the receiver.
+func (s *Sender) chain(core SendFunc) SendFunc {
+	s.mu.RLock()
+	mws := s.mws
+	s.mu.RUnlock()
+	for i := len(mws) - 1; i >= 0; i-- {
+		core = mws[i](core)
+	}
+	return core
+}
@@ -0,0 +1,59 @@
+package email
+
+import "strings"
+
+// NormalizeRule is a provider-specific rule applied by Normalize to the local part of the addresses
+// in the domains it applies to, returning the local part and the domain the address folds to.
+type NormalizeRule func(local, domain string) (string, string)
+
+// GmailRule folds the Gmail addresses - which ignore the dots and the "+tag" suffix of their local
+// part, and treat googlemail.com as an alias of gmail.com - so that e.g. "John.Doe+news@googlemail.com"
+// normalizes to "johndoe@gmail.com".
+func GmailRule(local, domain string) (string, string) {
+	if domain != "gmail.com" && domain != "googlemail.com" {
+		return local, domain
+	}
+	if i := strings.IndexByte(local, '+'); i >= 0 {
+		local = local[:i]
+	}
+	return strings.ToLower(strings.Replace(local, ".", "", -1)), "gmail.com"
+}
+
+// SubaddressRule folds the subaddresses, as defined by RFC 5233, of any domain, removing the "+tag"
+// suffix of the local part - so that e.g. "ann+news@example.com" normalizes to "ann@example.com".
+// Not all the domains support subaddressing, so the folded addresses may not be deliverable.
+func SubaddressRule(local, domain string) (string, string) {
+	if i := strings.IndexByte(local, '+'); i > 0 && local[0] != '"' {
+		local = local[:i]
+	}
+	return local, domain
+}
+
+// Normalize returns a copy of the receiver with the surrounding whitespace removed from its name and
+// address, and its domain lowercased, then applies the provider-specific `rules`, if any, in order.
+// The local part is kept as it is, since it may be case-sensitive.
+func (a *Address) Normalize(rules ...NormalizeRule) *Address {
+	addr := strings.TrimSpace(a.Addr)
+	if at := strings.LastIndexByte(addr, '@'); at >= 0 {
+		local, domain := addr[:at], strings.ToLower(addr[at+1:])
+		for _, rule := range rules {
+			local, domain = rule(local, domain)
+		}
+		addr = local + "@" + domain
+	}
+	return &Address{strings.TrimSpace(a.Name), addr}
+}
+
+// Equal reports whether the receiver and `b` are the same email address, regardless of their names:
+// they are compared normalized, case-insensitively, and with their domains in ASCII form.
+func (a *Address) Equal(b *Address) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.key() == b.key()
+}
+
+// key returns the address of the receiver in the form used for comparing it.
+func (a *Address) key() string {
+	return strings.ToLower(a.Normalize().ASCII())
+}
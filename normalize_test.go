@@ -0,0 +1,41 @@
+package email
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Normalize(t *testing.T) {
+	cases := []struct {
+		src   Address
+		rules []NormalizeRule
+		exp   Address
+	}{
+		{Address{" Ann ", " Ann.Smith@Example.COM "}, nil, Address{"Ann", "Ann.Smith@example.com"}},
+		{Address{"", "John.Doe+news@GoogleMail.com"}, nil, Address{"", "John.Doe+news@googlemail.com"}},
+		{Address{"", "John.Doe+news@GoogleMail.com"}, []NormalizeRule{GmailRule}, Address{"", "johndoe@gmail.com"}},
+		{Address{"", "ann.smith+news@example.com"}, []NormalizeRule{GmailRule}, Address{"", "ann.smith+news@example.com"}},
+		{Address{"", "ann.smith+news@Example.com"}, []NormalizeRule{SubaddressRule}, Address{"", "ann.smith@example.com"}},
+		{Address{"", "+news@example.com"}, []NormalizeRule{SubaddressRule}, Address{"", "+news@example.com"}},
+		{Address{"", "ann"}, []NormalizeRule{SubaddressRule}, Address{"", "ann"}},
+	}
+	for i, c := range cases {
+		if act := c.src.Normalize(c.rules...); *act != c.exp {
+			t.Errorf("(*Address).Normalize [%d]: got %v want %v", i, *act, c.exp)
+		}
+	}
+
+	if !(&Address{"Ann", "Ann@Example.com"}).Equal(&Address{"", "ann@example.COM"}) ||
+		!(&Address{"", "ann@BÜCHER.example"}).Equal(&Address{"", "ann@xn--bcher-kva.example"}) ||
+		(&Address{"", "ann@example.com"}).Equal(&Address{"", "bob@example.com"}) ||
+		(&Address{"", "ann@example.com"}).Equal(nil) {
+		t.Error("(*Address).Equal: unexpected result")
+	}
+
+	msg := NewMessage(nil).From(&Address{"", "me@example.com"}).
+		To(&Address{"", "User@Example.com"}, &Address{"", "user@example.com"}).
+		Cc(&Address{"", "USER@EXAMPLE.COM"}, &Address{"", "other@example.com"})
+	if act, exp := msg.RecipientAddrs(), []string{"User@Example.com", "other@example.com"}; !reflect.DeepEqual(act, exp) {
+		t.Errorf("(*Message).RecipientAddrs: got %v want %v", act, exp)
+	}
+}
@@ -0,0 +1,95 @@
+package email
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// ComposeOption is an option affecting the composition of a message, as set with Options.
+type ComposeOption func(m *Message)
+
+// Options applies the `opts` to the receiver.
+func (m *Message) Options(opts ...ComposeOption) *Message {
+	m.Lock()
+	defer m.Unlock()
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// WithClock sets the clock providing the time in the Date header of the message; a nil `clock`
+// restores the system clock.
+func WithClock(clock func() time.Time) ComposeOption {
+	return func(m *Message) {
+		m.clock = clock
+	}
+}
+
+// WithIDs sets the source of the unique identifiers of the message, like (*Message).IDs.
+func WithIDs(src IDSource) ComposeOption {
+	return func(m *Message) {
+		m.ids = src
+	}
+}
+
+// Deterministic makes the composition of the message reproducible: the Date header is set to the
+// time `t`, and the Message-ID and the MIME boundaries are derived from the `seed`, so identical
+// messages composed with identical data are byte-identical - e.g. for snapshot testing, or for
+// content-addressed archival.
+func Deterministic(t time.Time, seed string) ComposeOption {
+	sum := sha256.Sum256([]byte(seed))
+	id := hex.EncodeToString(sum[:16])
+	return func(m *Message) {
+		m.clock = func() time.Time { return t }
+		m.ids = FixedID(id)
+	}
+}
+
+// Date sets the time in the Date header of the message, overriding any clock; a zero `t` restores
+// the clock.
+func (m *Message) Date(t time.Time) *Message {
+	m.Lock()
+	m.date = t
+	m.Unlock()
+	return m
+}
+
+// Clock sets the clock providing the time in the Date header of the message, like WithClock.
+func (m *Message) Clock(clock func() time.Time) *Message {
+	m.Lock()
+	m.clock = clock
+	m.Unlock()
+	return m
+}
+
+// Clock sets the clock providing the time in the Date header of the messages sent by the receiver,
+// unless they have their own clock or date; a nil `clock` restores the system clock.
+func (s *Sender) Clock(clock func() time.Time) *Sender {
+	s.mu.Lock()
+	s.clock = clock
+	s.mu.Unlock()
+	return s
+}
+
+// time returns the time for the Date header of the receiver, sent by the `sender`, if not nil: the
+// date set on the receiver, or else the current time as provided by the clock of the receiver or of
+// the `sender`, if any. The caller must hold the lock on the receiver.
+func (m *Message) time(sender *Sender) time.Time {
+	if !m.date.IsZero() {
+		return m.date
+	}
+	if m.clock != nil {
+		return m.clock()
+	}
+	if sender != nil {
+		sender.mu.RLock()
+		clock := sender.clock
+		sender.mu.RUnlock()
+		if clock != nil {
+			return clock()
+		}
+	}
+	return now()
+}
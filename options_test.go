@@ -0,0 +1,60 @@
+package email
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func Test_Deterministic(t *testing.T) {
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	build := func(seed string) []byte {
+		return NewMessage(nil).Options(Deterministic(at, seed)).
+			From(&Address{"", "test@example.com"}).Subject("Fixture").
+			TextTemplate("Hi {{.}}").Html("<p>Hi</p>").
+			AttachObject("a.txt", "text/plain", []byte("a")).
+			Compose("Ann")
+	}
+	a, b := build("fixture-1"), build("fixture-1")
+	if !bytes.Equal(a, b) {
+		t.Errorf("Deterministic: got different outputs\n%s\n%s", a, b)
+	}
+	if !bytes.Contains(a, []byte("Date: Thu, 02 Jan 2020 03:04:05 +0000\r\n")) {
+		t.Errorf("Deterministic: got\n%s\nwant the fixed date", a)
+	}
+	if c := build("fixture-2"); bytes.Equal(a, c) {
+		t.Error("Deterministic: got identical outputs for different seeds")
+	}
+
+	msg := QuickMessage("test", "body").From(&Address{"", "test@example.com"}).
+		Options(WithClock(func() time.Time { return at }), WithIDs(FixedID("id")))
+	if body := msg.Compose(nil); !bytes.HasPrefix(body, []byte("Message-ID: <id@example.com>\r\nDate: Thu, 02 Jan 2020 03:04:05 +0000\r\n")) {
+		t.Errorf("(*Message).Options: got\n%s", body)
+	}
+}
+
+func Test_Date(t *testing.T) {
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := func() time.Time { return at.Add(time.Hour) }
+	s := &Sender{}
+	cases := []struct {
+		msg    *Message
+		sender *Sender
+		exp    time.Time
+	}{
+		{NewMessage(nil).Date(at), nil, at},
+		{NewMessage(nil).Date(at).Clock(clock), nil, at},
+		{NewMessage(nil).Clock(clock), nil, at.Add(time.Hour)},
+		{NewMessage(nil), s.Clock(func() time.Time { return at }), at},
+		{NewMessage(nil).Clock(clock), s, at.Add(time.Hour)},
+	}
+	for i, c := range cases {
+		if act := c.msg.time(c.sender); !act.Equal(c.exp) {
+			t.Errorf("(*Message).time [%d]: got %v want %v", i, act, c.exp)
+		}
+	}
+	body := QuickMessage("test", "body").From(&Address{"", "test@example.com"}).Date(at).Compose(nil)
+	if !bytes.Contains(body, []byte("Date: Thu, 02 Jan 2020 03:04:05 +0000\r\n")) {
+		t.Errorf("(*Message).Date: got\n%s", body)
+	}
+}
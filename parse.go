@@ -0,0 +1,515 @@
+package email
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Entity represents a MIME entity - a message, or a part of a multipart body - as read by
+// ReadEntity.
+type Entity struct {
+	// Header holds the header fields of the entity, as found in the message.
+	Header textproto.MIMEHeader
+	// MediaType is the lowercase media type from the Content-Type header, e.g. "text/plain".
+	MediaType string
+	// Params holds the parameters of the Content-Type header.
+	Params map[string]string
+	// Body holds the body of the entity, with the content transfer encoding removed. The body of
+	// text entities is transcoded to UTF-8. It is nil for multipart entities, and holds the raw
+	// message for embedded messages.
+	Body []byte
+	// Charset is the charset the body of a text entity was transcoded from - either the one from
+	// the Content-Type header or, if that is missing or not supported, a guessed one.
+	Charset string
+	// Parts holds the parts of a multipart entity, or the embedded message of a message/rfc822
+	// entity.
+	Parts []*Entity
+}
+
+// Limits bounds the resources used when parsing messages, so that malicious messages cannot
+// exhaust memory. A zero value for any of the fields means no limit.
+type Limits struct {
+	// MaxMessageSize is the maximum size of the raw message, in bytes.
+	MaxMessageSize int64
+	// MaxDepth is the maximum nesting depth of multipart entities and embedded messages.
+	MaxDepth int
+	// MaxParts is the maximum number of entities in the message.
+	MaxParts int
+	// MaxPartSize is the maximum decoded size of the body of a single entity, in bytes.
+	MaxPartSize int64
+	// MaxTotalSize is the maximum decoded size of all the entity bodies, in bytes.
+	MaxTotalSize int64
+}
+
+// DefaultLimits are the limits used when parsing with nil Limits.
+var DefaultLimits = Limits{
+	MaxMessageSize: 64 << 20,
+	MaxDepth:       20,
+	MaxParts:       1000,
+	MaxPartSize:    32 << 20,
+	MaxTotalSize:   128 << 20,
+}
+
+// LimitKind identifies one of the Limits.
+type LimitKind byte
+
+const (
+	// MessageSizeLimit identifies Limits.MaxMessageSize.
+	MessageSizeLimit LimitKind = iota
+	// DepthLimit identifies Limits.MaxDepth.
+	DepthLimit
+	// PartsLimit identifies Limits.MaxParts.
+	PartsLimit
+	// PartSizeLimit identifies Limits.MaxPartSize.
+	PartSizeLimit
+	// TotalSizeLimit identifies Limits.MaxTotalSize.
+	TotalSizeLimit
+)
+
+var limitNames = [...]string{"message size", "nesting depth", "number of parts", "part size", "total size"}
+
+func (k LimitKind) String() string {
+	if int(k) < len(limitNames) {
+		return limitNames[k]
+	}
+	return "unknown"
+}
+
+// LimitError reports that a message exceeded one of the Limits it was parsed with.
+type LimitError struct {
+	Kind LimitKind
+	Max  int64
+}
+
+func (e *LimitError) Error() string {
+	return "email: message exceeds the " + e.Kind.String() + " limit of " + strconv.FormatInt(e.Max, 10)
+}
+
+// ReadEntity reads and parses a MIME message from `r`, enforcing the `limits`, or DefaultLimits
+// if nil. If any of the limits is exceeded, the returned error is a *LimitError.
+func ReadEntity(r io.Reader, limits *Limits) (*Entity, error) {
+	if limits == nil {
+		limits = &DefaultLimits
+	}
+	p := &parser{lim: *limits}
+	if p.lim.MaxMessageSize > 0 {
+		r = &limitedReader{r, p.lim.MaxMessageSize, &LimitError{MessageSizeLimit, p.lim.MaxMessageSize}}
+	}
+	br := bufio.NewReader(r)
+	header, err := textproto.NewReader(br).ReadMIMEHeader()
+	if err != nil && !(err == io.EOF && len(header) > 0) {
+		return nil, p.wrapErr(err, "cannot read header")
+	}
+	return p.entity(header, br, 0)
+}
+
+type parser struct {
+	lim   Limits
+	parts int
+	total int64
+}
+
+// wrapErr returns the errors related to limits as they are, and others prefixed with `msg`.
+func (p *parser) wrapErr(err error, msg string) error {
+	var le *LimitError
+	if errors.As(err, &le) {
+		return le
+	}
+	return errors.New("email: " + msg + ": " + err.Error())
+}
+
+// entity parses the body of an entity with the given header.
+func (p *parser) entity(header textproto.MIMEHeader, body io.Reader, depth int) (*Entity, error) {
+	if p.lim.MaxDepth > 0 && depth > p.lim.MaxDepth {
+		return nil, &LimitError{DepthLimit, int64(p.lim.MaxDepth)}
+	}
+	if p.parts++; p.lim.MaxParts > 0 && p.parts > p.lim.MaxParts {
+		return nil, &LimitError{PartsLimit, int64(p.lim.MaxParts)}
+	}
+	e := &Entity{Header: header, MediaType: "text/plain", Params: map[string]string{}}
+	if ct := header.Get("Content-Type"); ct != "" {
+		if mt, params, err := mime.ParseMediaType(ct); err == nil {
+			e.MediaType, e.Params = mt, params
+		} else if i := strings.IndexByte(ct, ';'); i > 0 {
+			// keep the media type of a header with broken parameters
+			e.MediaType = strings.ToLower(strings.TrimSpace(ct[:i]))
+		} else {
+			e.MediaType = strings.ToLower(strings.TrimSpace(ct))
+		}
+	}
+	switch {
+	case strings.HasPrefix(e.MediaType, "multipart/") && e.Params["boundary"] != "":
+		mr := multipart.NewReader(body, e.Params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, p.wrapErr(err, "cannot read multipart body")
+			}
+			sub, err := p.entity(part.Header, part, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			e.Parts = append(e.Parts, sub)
+		}
+		return e, nil
+	case e.MediaType == "message/rfc822":
+		raw, err := p.readBody(p.decoder(header, body))
+		if err != nil {
+			return nil, err
+		}
+		r := bufio.NewReader(bytes.NewReader(raw))
+		h, err := textproto.NewReader(r).ReadMIMEHeader()
+		if err != nil && !(err == io.EOF && len(h) > 0) {
+			return nil, p.wrapErr(err, "cannot read embedded message header")
+		}
+		sub, err := p.entity(h, r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		e.Body, e.Parts = raw, []*Entity{sub}
+		return e, nil
+	}
+	data, err := p.readBody(p.decoder(header, body))
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(e.MediaType, "text/") {
+		data, e.Charset = toUTF8(e.Params["charset"], data)
+	}
+	e.Body = data
+	return e, nil
+}
+
+// decoder wraps `body` in a decoder for the content transfer encoding specified by `header`.
+func (p *parser) decoder(header textproto.MIMEHeader, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: body})
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+// readBody reads all of `r`, enforcing the part and total size limits.
+func (p *parser) readBody(r io.Reader) ([]byte, error) {
+	if max := p.lim.MaxPartSize; max > 0 {
+		r = &limitedReader{r, max, &LimitError{PartSizeLimit, max}}
+	}
+	if max := p.lim.MaxTotalSize; max > 0 {
+		r = &limitedReader{r, max - p.total, &LimitError{TotalSizeLimit, max}}
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, p.wrapErr(err, "cannot read body")
+	}
+	p.total += int64(len(data))
+	return data, nil
+}
+
+// limitedReader reads from r, failing with err once more than n bytes are read.
+type limitedReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (l *limitedReader) Read(b []byte) (int, error) {
+	if l.n < 0 {
+		return 0, l.err
+	}
+	if int64(len(b)) > l.n+1 {
+		b = b[:l.n+1]
+	}
+	n, err := l.r.Read(b)
+	if l.n -= int64(n); l.n < 0 {
+		return 0, l.err
+	}
+	return n, err
+}
+
+// base64Cleaner removes the characters outside the base64 alphabet, which are often found in
+// the wild (e.g. spaces and tabs) but make the standard decoder fail.
+type base64Cleaner struct {
+	r io.Reader
+}
+
+func (c *base64Cleaner) Read(b []byte) (int, error) {
+	for {
+		n, err := c.r.Read(b)
+		j := 0
+		for _, ch := range b[:n] {
+			if 'A' <= ch && ch <= 'Z' || 'a' <= ch && ch <= 'z' || '0' <= ch && ch <= '9' ||
+				ch == '+' || ch == '/' || ch == '=' {
+				b[j] = ch
+				j++
+			}
+		}
+		if j > 0 || err != nil {
+			return j, err
+		}
+	}
+}
+
+// composedHeaders are the headers of a message read by Parse that are set through the dedicated
+// methods of Message, or generated by Compose.
+var composedHeaders = map[string]bool{
+	"Message-Id": true, "Date": true, "Subject": true, "From": true, "Sender": true, "Reply-To": true,
+	"To": true, "Cc": true, "Bcc": true, "In-Reply-To": true, "References": true, "Mime-Version": true,
+	"Disposition-Notification-To": true, "Return-Receipt-To": true, "X-Priority": true,
+	"Importance": true, "X-Msmail-Priority": true,
+}
+
+// Parse reads a MIME message from `r`, with the DefaultLimits, and reconstructs a Message from it -
+// e.g. for replying to, forwarding or archiving received messages. The headers are decoded, and the
+// multipart structure is walked to set the plain-text, HTML, AMP and calendar versions of the body,
+// the items related to the HTML version, and the attachments; the Message-ID and Date are kept, so
+// that composing the message reproduces them.
+//
+// The other headers are added with Header, in alphabetical order; the text parts and attachments
+// are transcoded to UTF-8.
+func Parse(r io.Reader) (*Message, error) {
+	e, err := ReadEntity(r, nil)
+	if err != nil {
+		return nil, err
+	}
+	return entityMessage(e)
+}
+
+// entityMessage reconstructs a Message from the entity `e`.
+func entityMessage(e *Entity) (*Message, error) {
+	m := NewMessage(nil)
+	for _, name := range []string{"From", "Sender", "Reply-To", "To", "Cc", "Bcc", "Disposition-Notification-To"} {
+		list, err := ParseAddressList(strings.Join(e.Header[name], ", "))
+		if err != nil {
+			return nil, errors.New("email: invalid " + name + " header: " + err.Error())
+		}
+		if len(list) == 0 {
+			continue
+		}
+		switch name {
+		case "From":
+			m.From(list[0])
+		case "Sender":
+			m.SenderHeader(list[0])
+		case "Reply-To":
+			m.ReplyTo(list[0])
+		case "To":
+			m.To(list...)
+		case "Cc":
+			m.Cc(list...)
+		case "Bcc":
+			m.Bcc(list...)
+		case "Disposition-Notification-To":
+			m.RequestReadReceipt(list[0])
+		}
+	}
+	if v := e.Header.Get("Subject"); v != "" {
+		m.Subject(decodeHeader(v))
+	}
+	if v := e.Header.Get("Date"); v != "" {
+		if t, err := ParseDate(v); err == nil {
+			m.Date(t)
+		}
+	}
+	if ids := parseMsgIDs(e.Header.Get("Message-Id")); len(ids) > 0 {
+		if at := strings.LastIndexByte(ids[0], '@'); at > 0 {
+			m.Domain(ids[0][at+1:]).IDs(FixedID(ids[0][:at]))
+		}
+	}
+	if ids := parseMsgIDs(e.Header.Get("In-Reply-To")); len(ids) > 0 {
+		m.InReplyTo(ids[0])
+	}
+	if ids := parseMsgIDs(e.Header.Get("References")); len(ids) > 0 {
+		m.References(ids...)
+	}
+	m.Priority(parsePriority(e.Header))
+	names := make([]string, 0, len(e.Header))
+	for name := range e.Header {
+		if !composedHeaders[name] && !strings.HasPrefix(name, "Content-") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range e.Header[name] {
+			m.Header(name, decodeHeader(v))
+		}
+	}
+	if lang := findEntity(e, func(e *Entity) bool { return e.Header.Get("Content-Language") != "" }); lang != nil {
+		m.Language(lang.Header.Get("Content-Language"), AutoDir)
+	}
+	m.addEntity(e, false, nil)
+	m.normalizeParsed()
+	if errs := m.Errors(); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return m, nil
+}
+
+// addEntity adds the entity `e` of a parsed message to the receiver - within a
+// multipart/alternative entity if `alt` is true, and with the `related` items, if any.
+func (m *Message) addEntity(e *Entity, alt bool, related []Related) {
+	switch {
+	case e.MediaType == "multipart/related" && len(e.Parts) > 0:
+		root := 0
+		if start := strings.Trim(e.Params["start"], "<>"); start != "" {
+			for i, p := range e.Parts {
+				if contentID(p) == start {
+					root = i
+				}
+			}
+		}
+		for i, p := range e.Parts {
+			if id := contentID(p); i != root && id != "" && p.Body != nil {
+				r := RelatedObject(id, bodyType(p), p.Body)
+				// the embedded items are named after their Content-ID, minus the domain of the
+				// message appended to it
+				_, params, _ := mime.ParseMediaType(p.Header.Get("Content-Disposition"))
+				name := params["filename"]
+				if name != "" && len(m.domain) > 0 && id == name+"@"+string(m.domain) {
+					r.id = name
+				}
+				r.inline = name != "" && r.id == name
+				related = append(related, r)
+			} else if i != root {
+				m.addEntity(p, false, nil)
+			}
+		}
+		m.addEntity(e.Parts[root], alt, related)
+	case strings.HasPrefix(e.MediaType, "multipart/"):
+		for _, p := range e.Parts {
+			m.addEntity(p, e.MediaType == "multipart/alternative", related)
+		}
+	default:
+		disp, params, _ := mime.ParseMediaType(e.Header.Get("Content-Disposition"))
+		if disp != "attachment" {
+			body := bytes.TrimSuffix(e.Body, []byte("\r\n"))
+			switch {
+			case e.MediaType == "text/plain" && m.text == nil && params["filename"] == "":
+				m.Text(body)
+			case e.MediaType == "text/html" && m.html == nil && params["filename"] == "":
+				m.Html(body, related...)
+			case e.MediaType == "text/x-amp-html" && m.amp == nil:
+				m.Amp(body)
+			case e.MediaType == "text/calendar" && m.calendar == nil &&
+				calendarMethods[strings.ToUpper(e.Params["method"])]:
+				m.Calendar(body, e.Params["method"])
+				return
+			case alt:
+				cte := Base64
+				if strings.HasPrefix(e.MediaType, "text/") {
+					cte = QuotedPrintable
+				}
+				m.Part(bodyType(e), cte, body, related...)
+			default:
+				m.addAttachment(e, params)
+				return
+			}
+			if cs := e.Params["charset"]; strings.HasPrefix(e.MediaType, "text/") && cs != "" &&
+				!strings.EqualFold(cs, "utf-8") {
+				m.PartCharset(cs)
+			}
+			return
+		}
+		m.addAttachment(e, params)
+	}
+}
+
+// addAttachment adds the entity `e` of a parsed message, with the Content-Disposition `params`, as
+// an attachment of the receiver.
+func (m *Message) addAttachment(e *Entity, params map[string]string) {
+	name := params["filename"]
+	if name == "" {
+		name = decodeHeader(e.Params["name"])
+	}
+	if name == "" {
+		name = "attachment"
+		if exts, _ := mime.ExtensionsByType(e.MediaType); len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	data := e.Body
+	if e.MediaType == "message/rfc822" {
+		data = bytes.TrimSuffix(data, []byte("\r\n"))
+	}
+	m.AttachObject(name, bodyType(e), data)
+}
+
+// normalizeParsed sets the charset common to all the text parts of the receiver, reconstructed by
+// Parse, as the charset of the message.
+func (m *Message) normalizeParsed() {
+	charset := ""
+	for i, p := range m.parts {
+		if !strings.HasPrefix(p.ctype, "text/") || p == m.calendar {
+			continue
+		}
+		if p.charset == "" || i > 0 && p.charset != charset {
+			return
+		}
+		charset = p.charset
+	}
+	for _, p := range m.parts {
+		p.charset = ""
+	}
+	m.charset = charset
+}
+
+// contentID returns the Content-ID of the entity `e`, without the angle brackets.
+func contentID(e *Entity) string {
+	return strings.Trim(strings.TrimSpace(e.Header.Get("Content-ID")), "<>")
+}
+
+// bodyType returns the content type of the body of the entity `e`, as read by ReadEntity.
+func bodyType(e *Entity) string {
+	if strings.HasPrefix(e.MediaType, "text/") {
+		return e.MediaType + "; charset=utf-8"
+	}
+	return e.MediaType
+}
+
+// decodeHeader decodes the RFC 2047 encoded words of a header value, keeping the value as it is if
+// it cannot be decoded.
+func decodeHeader(v string) string {
+	if dec, err := wordDecoder.DecodeHeader(v); err == nil {
+		return dec
+	}
+	return v
+}
+
+// parsePriority returns the priority signaled by the `header`.
+func parsePriority(header textproto.MIMEHeader) Priority {
+	if v := strings.TrimSpace(header.Get("X-Priority")); v != "" {
+		switch v[0] {
+		case '1', '2':
+			return HighPriority
+		case '3':
+			return NormalPriority
+		case '4', '5':
+			return LowPriority
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(header.Get("Importance"))) {
+	case "high":
+		return HighPriority
+	case "normal":
+		return NormalPriority
+	case "low":
+		return LowPriority
+	}
+	return DefaultPriority
+}
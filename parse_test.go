@@ -0,0 +1,155 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ReadEntity(t *testing.T) {
+	msg := QuickMessage("Test", "Hi there", "<p>Hi there</p>").From(&Address{"", "test@example.com"}).
+		AttachObject("data.bin", "application/octet-stream", bytes.Repeat([]byte{0, 1, 2, 255}, 100))
+	e, err := ReadEntity(bytes.NewReader(msg.Compose(nil)), nil)
+	if err != nil {
+		t.Fatalf("ReadEntity: unexpected error: %v", err)
+	}
+	if e.MediaType != "multipart/mixed" || len(e.Parts) != 2 {
+		t.Fatalf("ReadEntity: got %s with %d parts", e.MediaType, len(e.Parts))
+	}
+	alt, att := e.Parts[0], e.Parts[1]
+	if alt.MediaType != "multipart/alternative" || len(alt.Parts) != 2 ||
+		string(alt.Parts[0].Body) != "Hi there\r\n" || string(alt.Parts[1].Body) != "<p>Hi there</p>\r\n" ||
+		alt.Parts[1].Params["charset"] != "utf-8" {
+		t.Errorf("ReadEntity: got alternative parts %+v", alt.Parts)
+	}
+	if !bytes.Equal(att.Body, bytes.Repeat([]byte{0, 1, 2, 255}, 100)) {
+		t.Errorf("ReadEntity: got attachment body %v", att.Body)
+	}
+	if e.Header.Get("Subject") != "Test" {
+		t.Errorf("ReadEntity: got header %v", e.Header)
+	}
+}
+
+func nestedMessage(depth int) string {
+	if depth == 0 {
+		return "Content-Type: text/plain\r\n\r\nleaf\r\n"
+	}
+	b := "b" + strings.Repeat("x", depth)
+	return "Content-Type: multipart/mixed; boundary=" + b + "\r\n\r\n--" + b + "\r\n" +
+		nestedMessage(depth-1) + "\r\n--" + b + "--\r\n"
+}
+
+func Test_ReadEntityLimits(t *testing.T) {
+	big := "Content-Type: multipart/mixed; boundary=b\r\n\r\n" +
+		"--b\r\nContent-Transfer-Encoding: base64\r\n\r\n" + strings.Repeat("QUFB", 100) + "\r\n" +
+		"--b\r\n\r\n" + strings.Repeat("a", 300) + "\r\n" +
+		"--b--\r\n"
+	cases := []struct {
+		src    string
+		limits Limits
+		kind   LimitKind
+	}{
+		{nestedMessage(5), Limits{MaxDepth: 4}, DepthLimit},
+		{nestedMessage(5), Limits{MaxParts: 5}, PartsLimit},
+		{big, Limits{MaxPartSize: 299}, PartSizeLimit},
+		{big, Limits{MaxTotalSize: 599}, TotalSizeLimit},
+		{big, Limits{MaxMessageSize: 500}, MessageSizeLimit},
+	}
+	for i, c := range cases {
+		_, err := ReadEntity(strings.NewReader(c.src), &c.limits)
+		var le *LimitError
+		if !errors.As(err, &le) || le.Kind != c.kind {
+			t.Errorf("ReadEntity [%d]: got error %v, want a %s limit error", i, err, c.kind)
+		}
+	}
+	if _, err := ReadEntity(strings.NewReader(nestedMessage(5)), &Limits{MaxDepth: 5, MaxParts: 6}); err != nil {
+		t.Errorf("ReadEntity: unexpected error: %v", err)
+	}
+	if _, err := ReadEntity(strings.NewReader(big), &Limits{MaxPartSize: 300, MaxTotalSize: 600}); err != nil {
+		t.Errorf("ReadEntity: unexpected error: %v", err)
+	}
+}
+
+func Test_Parse(t *testing.T) {
+	raw := "Message-ID: <abc.123@example.com>\r\n" +
+		"Date: Mon, 02 Jan 2006 15:04:05 +0000\r\n" +
+		"Subject: =?utf-8?q?Caf=C3=A9?= menu\r\n" +
+		"From: =?utf-8?q?Ren=C3=A9?= <rene@example.com>\r\n" +
+		"To: Ann <ann@example.com>, bob@example.com\r\n" +
+		"Cc: cy@example.com\r\n" +
+		"In-Reply-To: <prev@example.com>\r\n" +
+		"References: <first@example.com> <prev@example.com>\r\n" +
+		"X-Priority: 1\r\n" +
+		"X-Campaign: spring\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=mixed\r\n\r\n" +
+		"--mixed\r\n" +
+		"Content-Type: multipart/alternative; boundary=alt\r\n\r\n" +
+		"--alt\r\n" +
+		"Content-Type: text/plain; charset=iso-8859-1\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n\r\n" +
+		"Caf=E9\r\n" +
+		"--alt\r\n" +
+		"Content-Type: multipart/related; boundary=rel\r\n\r\n" +
+		"--rel\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n\r\n" +
+		"<p>Caf\xc3\xa9</p><img src=\"cid:logo@example.com\">\r\n" +
+		"--rel\r\n" +
+		"Content-Type: image/png\r\n" +
+		"Content-ID: <logo@example.com>\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\n" +
+		"UE5H\r\n" +
+		"--rel--\r\n" +
+		"--alt--\r\n" +
+		"--mixed\r\n" +
+		"Content-Type: application/pdf; name=\"menu.pdf\"\r\n" +
+		"Content-Disposition: attachment; filename=\"menu.pdf\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\n" +
+		"UERG\r\n" +
+		"--mixed--\r\n"
+	msg, err := Parse(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	info := msg.Inspect()
+	if info.Subject != "Café menu" || *info.From != (Address{"René", "rene@example.com"}) ||
+		len(info.To) != 2 || *info.To[0] != (Address{"Ann", "ann@example.com"}) || len(info.Cc) != 1 ||
+		info.InReplyTo != "prev@example.com" || len(info.References) != 2 {
+		t.Errorf("Parse: got %+v", info)
+	}
+	if len(info.Headers) != 1 || info.Headers[0] != (HeaderField{"X-Campaign", "spring"}) {
+		t.Errorf("Parse: got headers %+v", info.Headers)
+	}
+	if len(info.Parts) != 2 || string(info.Parts[0].Content) != "Café" ||
+		string(info.Parts[1].Content) != `<p>Café</p><img src="cid:logo@example.com">` ||
+		len(info.Parts[1].Related) != 1 || info.Parts[1].Related[0].ID != "logo@example.com" {
+		t.Errorf("Parse: got parts %+v", info.Parts)
+	}
+	if len(info.Attachments) != 1 || info.Attachments[0] != (AttachmentInfo{"menu.pdf", "application/pdf", "", 3}) {
+		t.Errorf("Parse: got attachments %+v", info.Attachments)
+	}
+	act := string(msg.Compose(nil))
+	if errs := msg.Errors(); len(errs) > 0 {
+		t.Fatalf("Parse: unexpected errors composing: %v", errs)
+	}
+	for _, exp := range []string{"Message-ID: <abc.123@example.com>\r\n", "Date: Mon, 02 Jan 2006 15:04:05 +0000\r\n",
+		"X-Priority: 1 (Highest)\r\n", "X-Campaign: spring\r\n"} {
+		if !strings.Contains(act, exp) {
+			t.Errorf("Parse: got %q, missing %q", act, exp)
+		}
+	}
+
+	msg, err = Parse(strings.NewReader("Date: Mon,  2 Jan 2006 16:04:05 +01:00 (CET)\r\n\r\nbody"))
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	if exp := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC); !msg.date.Equal(exp) {
+		t.Errorf("Parse: got date %v, want %v", msg.date, exp)
+	}
+
+	if _, err = Parse(strings.NewReader("From: <not an address\r\n\r\nbody")); err == nil {
+		t.Errorf("Parse: got no error for an invalid From header")
+	}
+}
@@ -0,0 +1,317 @@
+package email
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	htpl "html/template"
+	"sort"
+	"strings"
+	ttpl "text/template"
+	"text/template/parse"
+)
+
+// tplSource is the source of a template, kept for persisting the message definitions: the template
+// is the block named `block` of the source, if not empty.
+type tplSource struct {
+	src, block string
+}
+
+// textSource returns the source of the template `t`, rebuilt from its parse tree.
+func textSource(t *ttpl.Template) tplSource {
+	if t == nil {
+		return tplSource{}
+	}
+	trees := map[string]*parse.Tree{}
+	for _, a := range t.Templates() {
+		trees[a.Name()] = a.Tree
+	}
+	return treeSource(t.Name(), t.Tree, trees)
+}
+
+// htmlSource returns the source of the template `t`, rebuilt from its parse tree - which is only
+// possible before the template is executed.
+func htmlSource(t *htpl.Template) tplSource {
+	if t == nil {
+		return tplSource{}
+	}
+	trees := map[string]*parse.Tree{}
+	for _, a := range t.Templates() {
+		trees[a.Name()] = a.Tree
+	}
+	return treeSource(t.Name(), t.Tree, trees)
+}
+
+// treeSource returns the source of the template with the `name` and `tree`, along with the
+// templates associated with it, in `trees`.
+func treeSource(name string, tree *parse.Tree, trees map[string]*parse.Tree) tplSource {
+	if tree == nil || tree.Root == nil {
+		return tplSource{}
+	}
+	names := make([]string, 0, len(trees))
+	for n := range trees {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var src strings.Builder
+	for _, n := range names {
+		if t := trees[n]; t != nil && t.Root != nil && n != name {
+			src.WriteString(`{{define "` + n + `"}}` + t.Root.String() + "{{end}}")
+		}
+	}
+	src.WriteString(tree.Root.String())
+	return tplSource{src: src.String()}
+}
+
+// MessageDefinition is the complete definition of a Message - its metadata, subject and body
+// templates or content, and the references to its attachments and related items - that can be
+// stored, e.g. in a database or a job queue, and loaded in another process: it is returned by
+// Definition, and turned back into a message by NewMessageFromDefinition. Messages also marshal
+// to and from JSON and gob, through their definition.
+//
+// The files of the attachments and related items are referenced by their paths, while the data of
+// the others is included. The definition does not include the locales, the base message, the
+// sender, nor the policies and hooks of the message; and the templates using custom functions
+// cannot be loaded.
+type MessageDefinition struct {
+	MessageMetadata
+	// SubjectBlock is the name of the block of the source given to Templates defining the
+	// subject, if it was set that way.
+	SubjectBlock string `json:"subject_block,omitempty"`
+	// Strict is true for the messages in strict template mode - see StrictTemplates.
+	Strict bool `json:"strict,omitempty"`
+	// Parts are the alternative parts of the message body.
+	Parts []PartDefinition `json:"parts,omitempty"`
+	// Embeds are the items embedded in the HTML body, with Embed.
+	Embeds []RelatedDefinition `json:"embeds,omitempty"`
+	// Attachments are the attachments of the message.
+	Attachments []AttachmentDefinition `json:"attachments,omitempty"`
+}
+
+// PartDefinition is the definition of an alternative part of the message body.
+type PartDefinition struct {
+	// Role is the role of the part: "text", "html", "amp", "calendar", or empty for the parts
+	// added with Part.
+	Role        string `json:"role,omitempty"`
+	ContentType string `json:"content_type"`
+	CTE         CTE    `json:"cte,omitempty"`
+	Charset     string `json:"charset,omitempty"`
+	// Content is the content of the part, unless it is generated from a template.
+	Content []byte `json:"content,omitempty"`
+	// Template is the source of the template of the part, if any, and Block the name of the
+	// block of that source defining the template, if it was set with Templates.
+	Template string              `json:"template,omitempty"`
+	Block    string              `json:"block,omitempty"`
+	Related  []RelatedDefinition `json:"related,omitempty"`
+}
+
+// RelatedDefinition is the definition of a related or embedded item.
+type RelatedDefinition struct {
+	ID          string `json:"id"`
+	ContentType string `json:"content_type,omitempty"`
+	File        string `json:"file,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Data        []byte `json:"data,omitempty"`
+	Inline      bool   `json:"inline,omitempty"`
+}
+
+// AttachmentDefinition is the definition of an attachment.
+type AttachmentDefinition struct {
+	Name        string `json:"name,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	File        string `json:"file,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Data        []byte `json:"data,omitempty"`
+}
+
+// Definition returns the definition of the receiver.
+func (m *Message) Definition() *MessageDefinition {
+	d := &MessageDefinition{MessageMetadata: *m.Metadata()}
+	m.RLock()
+	defer m.RUnlock()
+	if m.subjectTpl != nil && m.subjectSource.src != "" {
+		d.Subject, d.SubjectBlock = m.subjectSource.src, m.subjectSource.block
+	}
+	d.Strict = m.strict
+	for _, p := range m.parts {
+		pd := PartDefinition{ContentType: p.ctype, CTE: p.cte, Charset: p.charset, Related: relatedDefinitions(p.related)}
+		switch p {
+		case m.text:
+			pd.Role = "text"
+		case m.html:
+			pd.Role = "html"
+		case m.amp:
+			pd.Role = "amp"
+		case m.calendar:
+			pd.Role = "calendar"
+		}
+		if p.tpl != nil || p.htmlTpl != nil {
+			pd.Template, pd.Block = p.source.src, p.source.block
+		} else {
+			pd.Content = append([]byte(nil), p.bytes...)
+		}
+		d.Parts = append(d.Parts, pd)
+	}
+	d.Embeds = relatedDefinitions(m.embeds)
+	for _, a := range m.attachments {
+		ad := AttachmentDefinition{Name: a.name, ContentType: a.ctype, File: a.fileName, URL: a.url}
+		if a.fileName == "" && a.url == "" {
+			ad.Data = append([]byte(nil), a.data...)
+		}
+		d.Attachments = append(d.Attachments, ad)
+	}
+	return d
+}
+
+// relatedDefinitions returns the definitions of the `related` items.
+func relatedDefinitions(related []Related) []RelatedDefinition {
+	var defs []RelatedDefinition
+	for _, r := range related {
+		rd := RelatedDefinition{ID: r.id, ContentType: r.ctype, File: r.fileName, URL: r.url, Inline: r.inline}
+		if r.fileName == "" && r.url == "" {
+			rd.Data = append([]byte(nil), r.data...)
+		}
+		defs = append(defs, rd)
+	}
+	return defs
+}
+
+// NewMessageFromDefinition creates a new Message from the definition `d`, as returned by
+// Definition, returning the first error recorded doing so, if any - e.g. a template that cannot be
+// parsed.
+func NewMessageFromDefinition(d *MessageDefinition) (*Message, error) {
+	m := NewMessage(nil)
+	if err := m.setDefinition(d); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// setDefinition sets up the receiver from the definition `d`, returning the first error recorded
+// doing so, if any.
+func (m *Message) setDefinition(d *MessageDefinition) error {
+	md := d.MessageMetadata
+	md.Subject, md.SubjectTemplate = "", false
+	return With(func(m *Message) *Message {
+		m.StrictTemplates(d.Strict).SetMetadata(&md)
+		m.Lock()
+		defer m.Unlock()
+		m.subject, m.subjectTpl, m.subjectSource = nil, nil, tplSource{}
+		if !d.SubjectTemplate {
+			m.subject = []byte(d.Subject)
+		} else if t, ok := m.parseText("subject", -1, tplSource{d.Subject, d.SubjectBlock}); ok {
+			m.subjectTpl, m.subjectSource = t, tplSource{d.Subject, d.SubjectBlock}
+		}
+		m.parts, m.text, m.html, m.amp, m.calendar = nil, nil, nil, nil, nil
+		for i, pd := range d.Parts {
+			p := &part{ctype: pd.ContentType, cte: pd.CTE, charset: pd.Charset, related: relatedItems(pd.Related)}
+			src := tplSource{pd.Template, pd.Block}
+			switch {
+			case pd.Template == "":
+				p.bytes = pd.Content
+			case pd.Role == "html" || pd.Role == "amp":
+				p.htmlTpl, _ = m.parseHTML(pd.Role, i, src)
+				p.source = src
+			default:
+				p.tpl, _ = m.parseText(pd.Role, i, src)
+				p.source = src
+			}
+			switch pd.Role {
+			case "text":
+				m.text = p
+			case "html":
+				m.html = p
+			case "amp":
+				m.amp = p
+			case "calendar":
+				m.calendar = p
+			}
+			m.parts = append(m.parts, p)
+		}
+		m.embeds = relatedItems(d.Embeds)
+		m.attachments = nil
+		for _, ad := range d.Attachments {
+			m.attachments = append(m.attachments, &attachment{name: ad.Name, ctype: ad.ContentType,
+				fileName: ad.File, url: ad.URL, data: ad.Data, encoded: &encodedCache{}})
+		}
+		m.prepared = false
+		return m
+	})(m)
+}
+
+// parseText parses the text template source `src` of the `field` of the part with the `index`,
+// recording an error if it fails. The caller must hold the lock on the receiver.
+func (m *Message) parseText(field string, index int, src tplSource) (*ttpl.Template, bool) {
+	t, err := ttpl.New("").Option(m.missingKey()).Parse(src.src)
+	if err == nil && src.block != "" {
+		if t = t.Lookup(src.block); t == nil {
+			err = errors.New("no " + src.block + " block defined")
+		}
+	}
+	if err != nil {
+		m.failPart(TemplateError, index, field, &ErrTemplateParse{field, src.src, err})
+		return nil, false
+	}
+	return t, true
+}
+
+// parseHTML parses the HTML template source `src` of the `field` of the part with the `index`,
+// recording an error if it fails. The caller must hold the lock on the receiver.
+func (m *Message) parseHTML(field string, index int, src tplSource) (*htpl.Template, bool) {
+	t, err := htpl.New("").Option(m.missingKey()).Parse(src.src)
+	if err == nil && src.block != "" {
+		if t = t.Lookup(src.block); t == nil {
+			err = errors.New("no " + src.block + " block defined")
+		}
+	}
+	if err != nil {
+		m.failPart(TemplateError, index, field, &ErrTemplateParse{field, src.src, err})
+		return nil, false
+	}
+	return t, true
+}
+
+// relatedItems returns the related items with the definitions `defs`.
+func relatedItems(defs []RelatedDefinition) []Related {
+	var related []Related
+	for _, rd := range defs {
+		related = append(related, Related{id: rd.ID, ctype: rd.ContentType, fileName: rd.File, url: rd.URL,
+			data: rd.Data, inline: rd.Inline})
+	}
+	return related
+}
+
+// MarshalJSON encodes the definition of the receiver as JSON - see MessageDefinition.
+func (m *Message) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Definition())
+}
+
+// UnmarshalJSON sets up the receiver from its definition encoded as JSON, as with
+// NewMessageFromDefinition.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var d MessageDefinition
+	if err := json.Unmarshal(data, &d); err != nil {
+		return err
+	}
+	return m.setDefinition(&d)
+}
+
+// GobEncode encodes the definition of the receiver with encoding/gob - see MessageDefinition.
+func (m *Message) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(m.Definition()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode sets up the receiver from its definition encoded with encoding/gob, as with
+// NewMessageFromDefinition.
+func (m *Message) GobDecode(data []byte) error {
+	var d MessageDefinition
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&d); err != nil {
+		return err
+	}
+	return m.setDefinition(&d)
+}
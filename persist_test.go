@@ -0,0 +1,69 @@
+package email
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	htpl "html/template"
+	"testing"
+	"time"
+)
+
+func Test_MessageDefinition(t *testing.T) {
+	det := Deterministic(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), "seed")
+	html := htpl.Must(htpl.New("").Parse(`{{define "name"}}<b>{{.}}</b>{{end}}<p>Hi {{template "name" .}} <img src="cid:logo"></p>`))
+	msgs := []*Message{
+		QuickMessage("Hello", "Hi!", "<p>Hi!</p>").From(&Address{"Ann", "ann@example.com"}).
+			To(&Address{"", "bob@example.com"}).Cc(&Address{"", "carl@example.com"}).
+			AttachObject("a.txt", "text/plain", []byte("attached")).
+			AttachFile("b.txt", "text/plain", "test-file.txt"),
+		NewMessage(nil).From(&Address{"", "ann@example.com"}).To(&Address{"", "bob@example.com"}).
+			SubjectTemplate("Hello, {{.}}").TextTemplate("Hi {{.}}!").
+			Html(html, RelatedObject("logo", "image/png", []byte("png"))).
+			Embed("icon.png", "image/png", []byte("icon")).
+			StrictTemplates(true).Header("X-Campaign", "spring"),
+		NewMessage(nil).From(&Address{"", "ann@example.com"}).To(&Address{"", "bob@example.com"}).
+			Templates(`{{define "subject"}}Welcome, {{.}}{{end}}
+{{define "text"}}Hi {{.}}!{{end}}
+{{define "html"}}<p>Hi {{.}}!</p>{{end}}`),
+	}
+	for i, msg := range msgs {
+		exp := string(msg.Options(det).Compose("Bob"))
+		if errs := msg.Errors(); len(errs) > 0 {
+			t.Fatalf("(*Message).Definition [%d]: got %v", i, errs)
+		}
+
+		b, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("(*Message).MarshalJSON [%d]: got %v", i, err)
+		}
+		var fromJSON Message
+		if err = json.Unmarshal(b, &fromJSON); err != nil {
+			t.Fatalf("(*Message).UnmarshalJSON [%d]: got %v from %s", i, err, b)
+		}
+		if act := string(fromJSON.Options(det).Compose("Bob")); act != exp {
+			t.Errorf("(*Message).UnmarshalJSON [%d]: got\n%s\nwant\n%s", i, act, exp)
+		}
+
+		var buf bytes.Buffer
+		if err = gob.NewEncoder(&buf).Encode(msg); err != nil {
+			t.Fatalf("(*Message).GobEncode [%d]: got %v", i, err)
+		}
+		fromGob := NewMessage(nil)
+		if err = gob.NewDecoder(&buf).Decode(fromGob); err != nil {
+			t.Fatalf("(*Message).GobDecode [%d]: got %v", i, err)
+		}
+		if act := string(fromGob.Options(det).Compose("Bob")); act != exp {
+			t.Errorf("(*Message).GobDecode [%d]: got\n%s\nwant\n%s", i, act, exp)
+		}
+	}
+
+	d := msgs[1].Definition()
+	if !d.Strict || !d.SubjectTemplate || len(d.Parts) != 2 || d.Parts[1].Role != "html" || len(d.Embeds) != 1 {
+		t.Errorf("(*Message).Definition: got %+v", d)
+	}
+	d.Parts[0].Template = "{{.Name"
+	if m, err := NewMessageFromDefinition(d); m != nil || err == nil {
+		t.Errorf("NewMessageFromDefinition: got %v, %v want an error", m, err)
+	}
+}
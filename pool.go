@@ -0,0 +1,140 @@
+package email
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// PoolStrategy represents the way a SenderPool distributes messages among its senders.
+type PoolStrategy byte
+
+const (
+	// RoundRobin indicates that the senders in a pool take turns, regardless of their weights.
+	RoundRobin PoolStrategy = iota
+	// Weighted indicates that each sender in a pool gets a share of the messages proportional
+	// to its weight.
+	Weighted
+)
+
+// SenderPool distributes messages across multiple senders - typically for different SMTP relay
+// accounts - in order to spread the volume over all of them, while respecting the rate limit of
+// each account.
+type SenderPool struct {
+	mu       sync.Mutex
+	strategy PoolStrategy
+	members  []*poolMember
+	next     int
+}
+
+type poolMember struct {
+	sender   *Sender
+	weight   int
+	current  int
+	interval time.Duration
+	ready    time.Time
+}
+
+// NewSenderPool creates an empty SenderPool using the `strategy`. Senders are added with Add.
+func NewSenderPool(strategy PoolStrategy) *SenderPool {
+	return &SenderPool{strategy: strategy}
+}
+
+// Add adds the sender `s` to the receiver, with the `weight` used by the Weighted strategy
+// (defaults to 1) and a limit of `rate` messages per second (0 means no limit).
+func (p *SenderPool) Add(s *Sender, weight int, rate float64) *SenderPool {
+	if s == nil {
+		return p
+	}
+	if weight < 1 {
+		weight = 1
+	}
+	m := &poolMember{sender: s, weight: weight}
+	if rate > 0 {
+		m.interval = time.Duration(float64(time.Second) / rate)
+	}
+	p.mu.Lock()
+	p.members = append(p.members, m)
+	p.mu.Unlock()
+	return p
+}
+
+// Send sends the message using the next sender in the receiver, as selected by the strategy of the
+// pool among the senders available within their rate limits. If all the senders reached their
+// limits, Send waits for the first one to become available.
+func (p *SenderPool) Send(msg *Message, data interface{}) error {
+	if msg == nil {
+		return errors.New("SenderPool.Send: no message to send")
+	}
+	s, wait := p.pick(time.Now())
+	if s == nil {
+		return errors.New("SenderPool.Send: no senders in the pool")
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return s.Send(msg, data)
+}
+
+// pick selects the sender for a message sent at time `t`, and reserves its slot within the rate
+// limit. Along with the sender, it returns the time to wait for that slot.
+func (p *SenderPool) pick(t time.Time) (*Sender, time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(p.members)
+	if n == 0 {
+		return nil, 0
+	}
+	// only the senders within their rate limits are eligible; if there are none, the one
+	// available first is
+	available := func(m *poolMember) bool { return !m.ready.After(t) }
+	if !p.anyAvailable(t) {
+		first := p.members[0]
+		for _, m := range p.members[1:] {
+			if m.ready.Before(first.ready) {
+				first = m
+			}
+		}
+		available = func(m *poolMember) bool { return m == first }
+	}
+	var chosen *poolMember
+	if p.strategy == Weighted {
+		// smooth weighted round-robin, as in nginx
+		total := 0
+		for _, m := range p.members {
+			if available(m) {
+				m.current += m.weight
+				total += m.weight
+				if chosen == nil || m.current > chosen.current {
+					chosen = m
+				}
+			}
+		}
+		chosen.current -= total
+	} else {
+		for i := 0; i < n; i++ {
+			m := p.members[(p.next+i)%n]
+			if available(m) {
+				chosen = m
+				p.next = (p.next + i + 1) % n
+				break
+			}
+		}
+	}
+	var wait time.Duration
+	if chosen.ready.After(t) {
+		wait = chosen.ready.Sub(t)
+		t = chosen.ready
+	}
+	chosen.ready = t.Add(chosen.interval)
+	return chosen.sender, wait
+}
+
+func (p *SenderPool) anyAvailable(t time.Time) bool {
+	for _, m := range p.members {
+		if !m.ready.After(t) {
+			return true
+		}
+	}
+	return false
+}
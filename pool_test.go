@@ -0,0 +1,42 @@
+package email
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_SenderPoolPick(t *testing.T) {
+	a, _ := NewSender("a.example.com", "user", "pass")
+	b, _ := NewSender("b.example.com", "user", "pass")
+	c, _ := NewSender("c.example.com", "user", "pass")
+	names := map[*Sender]string{a: "a", b: "b", c: "c"}
+	t0 := time.Unix(1000, 0)
+	sequence := func(p *SenderPool, n int, step time.Duration) (seq string, waits []time.Duration) {
+		for i := 0; i < n; i++ {
+			s, wait := p.pick(t0.Add(time.Duration(i) * step))
+			seq += names[s]
+			waits = append(waits, wait)
+		}
+		return
+	}
+
+	if seq, _ := sequence(NewSenderPool(RoundRobin).Add(a, 5, 0).Add(b, 1, 0).Add(c, 1, 0), 7, 0); seq != "abcabca" {
+		t.Errorf("(*SenderPool).pick: got round-robin sequence %q", seq)
+	}
+	if seq, _ := sequence(NewSenderPool(Weighted).Add(a, 5, 0).Add(b, 1, 0).Add(c, 1, 0), 7, 0); seq != "aabacaa" {
+		t.Errorf("(*SenderPool).pick: got weighted sequence %q", seq)
+	}
+	// b is limited to 1 message/s, so it is skipped while a is available
+	if seq, _ := sequence(NewSenderPool(RoundRobin).Add(a, 1, 0).Add(b, 1, 1), 5, 100*time.Millisecond); seq != "abaaa" {
+		t.Errorf("(*SenderPool).pick: got rate-limited sequence %q", seq)
+	}
+	// both are limited; the caller has to wait for the first one available
+	seq, waits := sequence(NewSenderPool(RoundRobin).Add(a, 1, 2).Add(b, 1, 1), 4, 0)
+	if exp := []time.Duration{0, 0, 500 * time.Millisecond, time.Second}; seq != "abaa" ||
+		waits[2] != exp[2] || waits[3] != exp[3] {
+		t.Errorf("(*SenderPool).pick: got sequence %q with waits %v", seq, waits)
+	}
+	if s, _ := NewSenderPool(RoundRobin).pick(t0); s != nil {
+		t.Errorf("(*SenderPool).pick: got %v from an empty pool", s)
+	}
+}
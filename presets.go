@@ -0,0 +1,229 @@
+package email
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Preset describes a ready-made transactional message, such as a password reset or an email
+// verification, with its templates in several languages and the data it requires.
+//
+// The messages built from a preset are meant to be used as base messages with NewMessage, or to be
+// customized further with the usual setters - e.g. From, or Templates to replace the content.
+type Preset struct {
+	// Name identifies the preset, e.g. "password-reset".
+	Name string
+	// Required lists the data fields that must be provided when composing the message - as keys of
+	// a map, or as exported fields of a struct.
+	Required []string
+	// Locales holds the templates of the preset, by language tag. The "en" locale is used as the
+	// fallback for missing languages, and must be present.
+	Locales map[string]PresetLocale
+}
+
+// PresetLocale holds the templates of a Preset for one language.
+type PresetLocale struct {
+	Subject string
+	Text    string
+	Html    string
+}
+
+// The built-in presets. The templates expect a URL field, along with an optional Name and an
+// optional Expires - e.g. "24 hours"; the magic link and verification presets also accept a
+// Product field, naming the application. The data is best passed as a map, since a struct needs to
+// have all the fields used by the templates, including the optional ones.
+var (
+	PasswordReset = &Preset{
+		Name:     "password-reset",
+		Required: []string{"URL"},
+		Locales: map[string]PresetLocale{
+			"en": {
+				Subject: "Reset your password",
+				Text:    "{{with .Name}}Hi {{.}},\n\n{{end}}We received a request to reset your password. Use the link below to choose a new one:\n\n{{.URL}}\n\n{{with .Expires}}The link expires in {{.}}. {{end}}If you did not request a password reset, you can safely ignore this email.\n",
+				Html:    `{{with .Name}}<p>Hi {{.}},</p>{{end}}<p>We received a request to reset your password. Use the link below to choose a new one:</p><p><a href="{{.URL}}">Reset password</a></p><p>{{with .Expires}}The link expires in {{.}}. {{end}}If you did not request a password reset, you can safely ignore this email.</p>`,
+			},
+			"es": {
+				Subject: "Restablece tu contraseña",
+				Text:    "{{with .Name}}Hola {{.}}:\n\n{{end}}Hemos recibido una solicitud para restablecer tu contraseña. Usa el siguiente enlace para elegir una nueva:\n\n{{.URL}}\n\n{{with .Expires}}El enlace caduca en {{.}}. {{end}}Si no lo has solicitado, puedes ignorar este mensaje.\n",
+				Html:    `{{with .Name}}<p>Hola {{.}}:</p>{{end}}<p>Hemos recibido una solicitud para restablecer tu contraseña. Usa el siguiente enlace para elegir una nueva:</p><p><a href="{{.URL}}">Restablecer contraseña</a></p><p>{{with .Expires}}El enlace caduca en {{.}}. {{end}}Si no lo has solicitado, puedes ignorar este mensaje.</p>`,
+			},
+			"fr": {
+				Subject: "Réinitialisez votre mot de passe",
+				Text:    "{{with .Name}}Bonjour {{.}},\n\n{{end}}Nous avons reçu une demande de réinitialisation de votre mot de passe. Utilisez le lien ci-dessous pour en choisir un nouveau :\n\n{{.URL}}\n\n{{with .Expires}}Le lien expire dans {{.}}. {{end}}Si vous n'êtes pas à l'origine de cette demande, vous pouvez ignorer ce message.\n",
+				Html:    `{{with .Name}}<p>Bonjour {{.}},</p>{{end}}<p>Nous avons reçu une demande de réinitialisation de votre mot de passe. Utilisez le lien ci-dessous pour en choisir un nouveau :</p><p><a href="{{.URL}}">Réinitialiser le mot de passe</a></p><p>{{with .Expires}}Le lien expire dans {{.}}. {{end}}Si vous n'êtes pas à l'origine de cette demande, vous pouvez ignorer ce message.</p>`,
+			},
+			"de": {
+				Subject: "Setzen Sie Ihr Passwort zurück",
+				Text:    "{{with .Name}}Hallo {{.}},\n\n{{end}}wir haben eine Anfrage zum Zurücksetzen Ihres Passworts erhalten. Über den folgenden Link können Sie ein neues wählen:\n\n{{.URL}}\n\n{{with .Expires}}Der Link ist {{.}} gültig. {{end}}Falls Sie dies nicht angefordert haben, können Sie diese E-Mail ignorieren.\n",
+				Html:    `{{with .Name}}<p>Hallo {{.}},</p>{{end}}<p>wir haben eine Anfrage zum Zurücksetzen Ihres Passworts erhalten. Über den folgenden Link können Sie ein neues wählen:</p><p><a href="{{.URL}}">Passwort zurücksetzen</a></p><p>{{with .Expires}}Der Link ist {{.}} gültig. {{end}}Falls Sie dies nicht angefordert haben, können Sie diese E-Mail ignorieren.</p>`,
+			},
+		},
+	}
+
+	EmailVerification = &Preset{
+		Name:     "email-verification",
+		Required: []string{"URL"},
+		Locales: map[string]PresetLocale{
+			"en": {
+				Subject: "Verify your email address",
+				Text:    "{{with .Name}}Hi {{.}},\n\n{{end}}Please confirm your email address{{with .Product}} for {{.}}{{end}} by opening the link below:\n\n{{.URL}}\n\n{{with .Expires}}The link expires in {{.}}. {{end}}If you did not sign up, you can safely ignore this email.\n",
+				Html:    `{{with .Name}}<p>Hi {{.}},</p>{{end}}<p>Please confirm your email address{{with .Product}} for {{.}}{{end}} by opening the link below:</p><p><a href="{{.URL}}">Verify email address</a></p><p>{{with .Expires}}The link expires in {{.}}. {{end}}If you did not sign up, you can safely ignore this email.</p>`,
+			},
+			"es": {
+				Subject: "Verifica tu dirección de correo",
+				Text:    "{{with .Name}}Hola {{.}}:\n\n{{end}}Confirma tu dirección de correo{{with .Product}} para {{.}}{{end}} abriendo el siguiente enlace:\n\n{{.URL}}\n\n{{with .Expires}}El enlace caduca en {{.}}. {{end}}Si no te has registrado, puedes ignorar este mensaje.\n",
+				Html:    `{{with .Name}}<p>Hola {{.}}:</p>{{end}}<p>Confirma tu dirección de correo{{with .Product}} para {{.}}{{end}} abriendo el siguiente enlace:</p><p><a href="{{.URL}}">Verificar dirección</a></p><p>{{with .Expires}}El enlace caduca en {{.}}. {{end}}Si no te has registrado, puedes ignorar este mensaje.</p>`,
+			},
+			"fr": {
+				Subject: "Vérifiez votre adresse e-mail",
+				Text:    "{{with .Name}}Bonjour {{.}},\n\n{{end}}Veuillez confirmer votre adresse e-mail{{with .Product}} pour {{.}}{{end}} en ouvrant le lien ci-dessous :\n\n{{.URL}}\n\n{{with .Expires}}Le lien expire dans {{.}}. {{end}}Si vous ne vous êtes pas inscrit, vous pouvez ignorer ce message.\n",
+				Html:    `{{with .Name}}<p>Bonjour {{.}},</p>{{end}}<p>Veuillez confirmer votre adresse e-mail{{with .Product}} pour {{.}}{{end}} en ouvrant le lien ci-dessous :</p><p><a href="{{.URL}}">Vérifier l'adresse</a></p><p>{{with .Expires}}Le lien expire dans {{.}}. {{end}}Si vous ne vous êtes pas inscrit, vous pouvez ignorer ce message.</p>`,
+			},
+			"de": {
+				Subject: "Bestätigen Sie Ihre E-Mail-Adresse",
+				Text:    "{{with .Name}}Hallo {{.}},\n\n{{end}}bitte bestätigen Sie Ihre E-Mail-Adresse{{with .Product}} für {{.}}{{end}} über den folgenden Link:\n\n{{.URL}}\n\n{{with .Expires}}Der Link ist {{.}} gültig. {{end}}Falls Sie sich nicht registriert haben, können Sie diese E-Mail ignorieren.\n",
+				Html:    `{{with .Name}}<p>Hallo {{.}},</p>{{end}}<p>bitte bestätigen Sie Ihre E-Mail-Adresse{{with .Product}} für {{.}}{{end}} über den folgenden Link:</p><p><a href="{{.URL}}">E-Mail-Adresse bestätigen</a></p><p>{{with .Expires}}Der Link ist {{.}} gültig. {{end}}Falls Sie sich nicht registriert haben, können Sie diese E-Mail ignorieren.</p>`,
+			},
+		},
+	}
+
+	MagicLink = &Preset{
+		Name:     "magic-link",
+		Required: []string{"URL"},
+		Locales: map[string]PresetLocale{
+			"en": {
+				Subject: "Your sign-in link{{with .Product}} for {{.}}{{end}}",
+				Text:    "{{with .Name}}Hi {{.}},\n\n{{end}}Use the link below to sign in:\n\n{{.URL}}\n\n{{with .Expires}}The link expires in {{.}} and{{else}}The link{{end}} can only be used once. If you did not try to sign in, you can safely ignore this email.\n",
+				Html:    `{{with .Name}}<p>Hi {{.}},</p>{{end}}<p>Use the link below to sign in:</p><p><a href="{{.URL}}">Sign in</a></p><p>{{with .Expires}}The link expires in {{.}} and{{else}}The link{{end}} can only be used once. If you did not try to sign in, you can safely ignore this email.</p>`,
+			},
+			"es": {
+				Subject: "Tu enlace de acceso{{with .Product}} a {{.}}{{end}}",
+				Text:    "{{with .Name}}Hola {{.}}:\n\n{{end}}Usa el siguiente enlace para iniciar sesión:\n\n{{.URL}}\n\n{{with .Expires}}El enlace caduca en {{.}} y{{else}}El enlace{{end}} solo puede usarse una vez. Si no has intentado iniciar sesión, puedes ignorar este mensaje.\n",
+				Html:    `{{with .Name}}<p>Hola {{.}}:</p>{{end}}<p>Usa el siguiente enlace para iniciar sesión:</p><p><a href="{{.URL}}">Iniciar sesión</a></p><p>{{with .Expires}}El enlace caduca en {{.}} y{{else}}El enlace{{end}} solo puede usarse una vez. Si no has intentado iniciar sesión, puedes ignorar este mensaje.</p>`,
+			},
+			"fr": {
+				Subject: "Votre lien de connexion{{with .Product}} à {{.}}{{end}}",
+				Text:    "{{with .Name}}Bonjour {{.}},\n\n{{end}}Utilisez le lien ci-dessous pour vous connecter :\n\n{{.URL}}\n\n{{with .Expires}}Le lien expire dans {{.}} et{{else}}Le lien{{end}} ne peut être utilisé qu'une fois. Si vous n'avez pas essayé de vous connecter, vous pouvez ignorer ce message.\n",
+				Html:    `{{with .Name}}<p>Bonjour {{.}},</p>{{end}}<p>Utilisez le lien ci-dessous pour vous connecter :</p><p><a href="{{.URL}}">Se connecter</a></p><p>{{with .Expires}}Le lien expire dans {{.}} et{{else}}Le lien{{end}} ne peut être utilisé qu'une fois. Si vous n'avez pas essayé de vous connecter, vous pouvez ignorer ce message.</p>`,
+			},
+			"de": {
+				Subject: "Ihr Anmeldelink{{with .Product}} für {{.}}{{end}}",
+				Text:    "{{with .Name}}Hallo {{.}},\n\n{{end}}über den folgenden Link können Sie sich anmelden:\n\n{{.URL}}\n\n{{with .Expires}}Der Link ist {{.}} gültig und{{else}}Der Link{{end}} kann nur einmal verwendet werden. Falls Sie sich nicht anmelden wollten, können Sie diese E-Mail ignorieren.\n",
+				Html:    `{{with .Name}}<p>Hallo {{.}},</p>{{end}}<p>über den folgenden Link können Sie sich anmelden:</p><p><a href="{{.URL}}">Anmelden</a></p><p>{{with .Expires}}Der Link ist {{.}} gültig und{{else}}Der Link{{end}} kann nur einmal verwendet werden. Falls Sie sich nicht anmelden wollten, können Sie diese E-Mail ignorieren.</p>`,
+			},
+		},
+	}
+)
+
+var (
+	presets = map[string]*Preset{
+		PasswordReset.Name:     PasswordReset,
+		EmailVerification.Name: EmailVerification,
+		MagicLink.Name:         MagicLink,
+	}
+	presetsMutex sync.RWMutex
+)
+
+// RegisterPreset makes a custom preset available by its name to LookupPreset, replacing any
+// preset previously registered with the same name - including the built-in ones.
+func RegisterPreset(p *Preset) error {
+	if p == nil || p.Name == "" {
+		return errors.New("RegisterPreset: missing preset name")
+	}
+	if _, ok := p.Locales["en"]; !ok {
+		return errors.New("RegisterPreset: missing fallback locale: " + p.Name)
+	}
+	presetsMutex.Lock()
+	presets[p.Name] = p
+	presetsMutex.Unlock()
+	return nil
+}
+
+// LookupPreset returns the preset registered with the given name, or nil if there is none.
+func LookupPreset(name string) *Preset {
+	presetsMutex.RLock()
+	defer presetsMutex.RUnlock()
+	return presets[name]
+}
+
+// Presets returns the names of the registered presets, in alphabetical order.
+func Presets() []string {
+	presetsMutex.RLock()
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	presetsMutex.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// Message builds a new message from the receiver, localized for the language `lang` - e.g. "fr" or
+// "es-MX". The closest available locale is used, falling back to "en".
+//
+// The message is marked as automatically generated ("Auto-Submitted: auto-generated"), and asks
+// servers not to send automatic replies, such as out-of-office notices, back to it. Since such
+// messages are usually sent from unattended addresses, set a ReplyTo if the recipients should be
+// able to reach a person by replying. Composing the message fails if any of the required data
+// fields are missing or empty, so that a message with a broken link is never sent - and the
+// composition can be safely retried once the data is fixed.
+func (p *Preset) Message(lang string) *Message {
+	tag, loc := p.locale(lang)
+	m := NewMessage(nil).
+		SubjectTemplate(loc.Subject).
+		TextTemplate(loc.Text).
+		HtmlTemplate(loc.Html).
+		Header("Auto-Submitted", "auto-generated").
+		Header("X-Auto-Response-Suppress", "All").
+		Language(tag, AutoDir)
+	if len(p.Required) > 0 {
+		m.UseCompose(RequireData(p.Required...))
+	}
+	return m
+}
+
+// locale returns the tag and the templates of the receiver closest to `lang`.
+func (p *Preset) locale(lang string) (string, PresetLocale) {
+	lang = strings.Replace(lang, "_", "-", -1)
+	for tag := lang; tag != ""; {
+		for t, loc := range p.Locales {
+			if strings.EqualFold(t, tag) {
+				return t, loc
+			}
+		}
+		i := strings.LastIndexByte(tag, '-')
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+	return "en", p.Locales["en"]
+}
+
+// RequireData returns compose middleware that makes the composition of messages fail if any of the
+// named `fields` are missing from the data, or have the zero value. The data may be a map with
+// string keys, or a struct or a pointer to one.
+func RequireData(fields ...string) ComposeMiddleware {
+	return func(next ComposeFunc) ComposeFunc {
+		return func(c *Content) error {
+			var missing []string
+			for _, f := range fields {
+				if !hasField(c.Data, f) {
+					missing = append(missing, f)
+				}
+			}
+			if len(missing) > 0 {
+				return errors.New("missing required data: " + strings.Join(missing, ", "))
+			}
+			return next(c)
+		}
+	}
+}
+
+// hasField reports whether `data` has a non-zero value for the `field`.
+func hasField(data interface{}, field string) bool {
+	v := dataField(data, field)
+	return v.IsValid() && !v.IsZero()
+}
@@ -0,0 +1,90 @@
+package email
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_PresetLocale(t *testing.T) {
+	tests := []struct {
+		lang, want string
+	}{
+		{"fr", "fr"},
+		{"es_MX", "es"},
+		{"DE-at", "de"},
+		{"ja", "en"},
+		{"", "en"},
+	}
+	for i, test := range tests {
+		if got, _ := PasswordReset.locale(test.lang); got != test.want {
+			t.Errorf("(*Preset).locale [%d]: got %q want %q", i, got, test.want)
+		}
+	}
+}
+
+func Test_PresetMessage(t *testing.T) {
+	msg := PasswordReset.Message("fr").From(&Address{"", "no-reply@example.com"})
+	body := msg.Compose(map[string]string{"URL": "https://example.com/reset?t=1", "Name": "Ann"})
+	if msg.HasErrors() {
+		t.Fatalf("(*Preset).Message: got errors %v", msg.Errors())
+	}
+	for _, want := range []string{
+		"Auto-Submitted: auto-generated\r\n",
+		"X-Auto-Response-Suppress: All\r\n",
+		"Content-Language: fr\r\n",
+		"Bonjour Ann,",
+	} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("(*Preset).Message: got\n%s\nwant %q", body, want)
+		}
+	}
+
+	msg = MagicLink.Message("en").From(&Address{"", "no-reply@example.com"})
+	msg.Compose(map[string]interface{}{"Name": "Ann"})
+	if !msg.HasErrors() {
+		t.Error("(*Preset).Message: got no error for missing required data")
+	}
+}
+
+func Test_RequireData(t *testing.T) {
+	type data struct{ URL, Name string }
+	tests := []struct {
+		data interface{}
+		ok   bool
+	}{
+		{nil, false},
+		{map[string]string{"URL": "x"}, true},
+		{map[string]string{"URL": ""}, false},
+		{map[string]interface{}{"URL": nil}, false},
+		{data{URL: "x"}, true},
+		{&data{Name: "x"}, false},
+		{"x", false},
+	}
+	for i, test := range tests {
+		err := RequireData("URL")(func(*Content) error { return nil })(&Content{Data: test.data})
+		if (err == nil) != test.ok {
+			t.Errorf("RequireData [%d]: got %v want ok %v", i, err, test.ok)
+		}
+	}
+}
+
+func Test_RegisterPreset(t *testing.T) {
+	if err := RegisterPreset(&Preset{Name: "welcome"}); err == nil {
+		t.Error("RegisterPreset: got no error for a preset without the fallback locale")
+	}
+	p := &Preset{Name: "welcome", Locales: map[string]PresetLocale{"en": {Subject: "Welcome"}}}
+	if err := RegisterPreset(p); err != nil {
+		t.Fatalf("RegisterPreset: got error %v", err)
+	}
+	defer func() {
+		presetsMutex.Lock()
+		delete(presets, "welcome")
+		presetsMutex.Unlock()
+	}()
+	if LookupPreset("welcome") != p {
+		t.Error("LookupPreset: got a different preset")
+	}
+	if names := Presets(); len(names) != 4 || names[3] != "welcome" {
+		t.Errorf("Presets: got %v", names)
+	}
+}
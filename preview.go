@@ -0,0 +1,110 @@
+package email
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"  // register the GIF format for the image preview
+	_ "image/jpeg" // register the JPEG format for the image preview
+	_ "image/png"  // register the PNG format for the image preview
+	"mime"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PreviewExtractor returns a short description of the contents of an attachment - e.g. "2 pages"
+// for a PDF document - or an empty string if it cannot provide one.
+type PreviewExtractor func(name, ctype string, data []byte) string
+
+var (
+	previewExtractors = map[string]PreviewExtractor{
+		"application/pdf": pdfPreview,
+		"image/gif":       imagePreview,
+		"image/jpeg":      imagePreview,
+		"image/png":       imagePreview,
+		"text/csv":        csvPreview,
+	}
+	previewExtractorsMutex sync.RWMutex
+)
+
+// RegisterPreviewExtractor registers the `fn` for the attachments of the `mediaType` (e.g.
+// "application/pdf"), replacing the built-in or previously registered one, if any. A nil `fn`
+// unregisters the extractor for the `mediaType`.
+//
+// Built-in extractors provide the number of pages of PDF documents, the size of GIF, JPEG and PNG
+// images, and the number of rows of CSV files - not counting the header.
+func RegisterPreviewExtractor(mediaType string, fn PreviewExtractor) {
+	mediaType = strings.ToLower(mediaType)
+	previewExtractorsMutex.Lock()
+	defer previewExtractorsMutex.Unlock()
+	if fn == nil {
+		delete(previewExtractors, mediaType)
+	} else {
+		previewExtractors[mediaType] = fn
+	}
+}
+
+// AttachmentPreview sets whether a short preview of the attachments - e.g. "Attached: invoice.pdf
+// (2 pages), photo.jpg (800x600)" - is appended to the plain-text version of the message body,
+// using the extractors registered with RegisterPreviewExtractor.
+func (m *Message) AttachmentPreview(enable bool) *Message {
+	m.Lock()
+	m.preview = enable
+	m.Unlock()
+	return m
+}
+
+// attachmentPreview returns the preview of the attachments of the receiver, which must be prepared.
+func (m *Message) attachmentPreview() string {
+	if !m.preview || len(m.attachments) == 0 {
+		return ""
+	}
+	items := make([]string, len(m.attachments))
+	for i, a := range m.attachments {
+		items[i] = a.name
+		mediaType, _, _ := mime.ParseMediaType(a.ctype)
+		previewExtractorsMutex.RLock()
+		fn := previewExtractors[mediaType]
+		previewExtractorsMutex.RUnlock()
+		if fn != nil {
+			if desc := fn(a.name, a.ctype, a.data); desc != "" {
+				items[i] += " (" + desc + ")"
+			}
+		}
+	}
+	return "Attached: " + strings.Join(items, ", ")
+}
+
+var rePDFPage = regexp.MustCompile(`/Type\s*/Page\b`)
+
+func pdfPreview(name, ctype string, data []byte) string {
+	switch n := len(rePDFPage.FindAllIndex(data, -1)); n {
+	case 0:
+		return ""
+	case 1:
+		return "1 page"
+	default:
+		return strconv.Itoa(n) + " pages"
+	}
+}
+
+func imagePreview(name, ctype string, data []byte) string {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+	return strconv.Itoa(cfg.Width) + "x" + strconv.Itoa(cfg.Height)
+}
+
+func csvPreview(name, ctype string, data []byte) string {
+	rows := bytes.Count(bytes.TrimRight(data, "\r\n"), []byte{'\n'})
+	switch {
+	case len(data) == 0:
+		return ""
+	case rows == 1:
+		return "1 row"
+	default:
+		return strconv.Itoa(rows) + " rows"
+	}
+}
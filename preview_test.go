@@ -0,0 +1,48 @@
+package email
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func Test_AttachmentPreview(t *testing.T) {
+	var img bytes.Buffer
+	png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 80, 60)))
+	pdf := []byte("%PDF-1.4\n1 0 obj << /Type /Pages /Kids [2 0 R 3 0 R] >>\n2 0 obj << /Type /Page >>\n3 0 obj <</Type/Page>>\n")
+	RegisterPreviewExtractor("application/x-invoice", func(name, ctype string, data []byte) string {
+		return "total " + string(data)
+	})
+	defer RegisterPreviewExtractor("application/x-invoice", nil)
+
+	msg := QuickMessage("Documents", "See attached.").From(&Address{"", "test@example.com"}).
+		AttachObject("report.pdf", "application/pdf", pdf).
+		AttachObject("chart.png", "image/png", img.Bytes()).
+		AttachObject("data.csv", "text/csv; charset=utf-8", []byte("a,b\n1,2\n3,4\n")).
+		AttachObject("invoice-123.inv", "application/x-invoice", []byte("€540")).
+		AttachObject("notes.bin", "application/octet-stream", []byte{1, 2, 3})
+	exp := "Attached: report.pdf (2 pages), chart.png (80x60), data.csv (2 rows), invoice-123.inv (total €540), notes.bin"
+
+	e, err := ReadEntity(bytes.NewReader(msg.Compose(nil)), nil)
+	if err != nil {
+		t.Fatalf("ReadEntity: unexpected error: %v", err)
+	}
+	if text := string(e.Parts[0].Body); strings.Contains(text, "Attached:") {
+		t.Errorf("(*Message).Compose: got a preview without AttachmentPreview: %q", text)
+	}
+
+	msg.AttachmentPreview(true)
+	e, _ = ReadEntity(bytes.NewReader(msg.Compose(nil)), nil)
+	if text, expText := string(e.Parts[0].Body), "See attached.\r\n\r\n"+exp+"\r\n"; text != expText {
+		t.Errorf("(*Message).Compose: got text %q, want %q", text, expText)
+	}
+
+	msg = QuickMessage("Documents").Html("<p>See attached.</p>").From(&Address{"", "test@example.com"}).
+		AttachObject("report.pdf", "application/pdf", pdf).AttachmentPreview(true)
+	e, _ = ReadEntity(bytes.NewReader(msg.Compose(nil)), nil)
+	if text := string(e.Parts[0].Parts[0].Body); !strings.HasSuffix(text, "\r\n\r\nAttached: report.pdf (2 pages)\r\n") {
+		t.Errorf("(*Message).Compose: got text %q, want it to end with the preview", text)
+	}
+}
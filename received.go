@@ -0,0 +1,185 @@
+package email
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Hop represents a relay step of a message, as recorded in a Received header.
+type Hop struct {
+	// From is the name of the sending host, as it introduced itself.
+	From string
+	// IP is the address of the sending host, when recorded by the receiving host.
+	IP string
+	// By is the name of the receiving host.
+	By string
+	// With is the protocol used for the transfer, e.g. "ESMTPS".
+	With string
+	// ID is the identifier assigned to the message by the receiving host.
+	ID string
+	// For is the recipient address the message was received for.
+	For string
+	// Time is the moment the message was received.
+	Time time.Time
+}
+
+var (
+	reReceivedIPBracket = regexp.MustCompile(`\[(?:IPv6:)?([0-9A-Fa-f.:]+)\]`)
+	reReceivedIPv4      = regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`)
+)
+
+// ParseReceived parses the value of a Received header, as described in RFC 5321 section 4.4.
+// Only the timestamp is required; the other fields are filled in as far as they can be recognized.
+func ParseReceived(value string) (*Hop, error) {
+	i := strings.LastIndexByte(value, ';')
+	if i < 0 {
+		return nil, errors.New("invalid Received header: missing date: " + value)
+	}
+	ts, err := ParseDate(value[i+1:])
+	if err != nil {
+		return nil, errors.New("invalid Received header: " + err.Error())
+	}
+	hop := &Hop{Time: ts}
+	var (
+		clause   string
+		fromText []string
+	)
+	for _, tok := range receivedTokens(value[:i]) {
+		if tok[0] == '(' {
+			if clause == "from" {
+				fromText = append(fromText, tok)
+			}
+			continue
+		}
+		switch kw := strings.ToLower(tok); kw {
+		case "from", "by", "via", "with", "id", "for":
+			clause = kw
+			continue
+		}
+		switch clause {
+		case "from":
+			if hop.From == "" {
+				hop.From = tok
+			}
+			fromText = append(fromText, tok)
+		case "by":
+			if hop.By == "" {
+				hop.By = tok
+			}
+		case "with":
+			if hop.With == "" {
+				hop.With = tok
+			}
+		case "id":
+			if hop.ID == "" {
+				hop.ID = strings.Trim(tok, "<>")
+			}
+		case "for":
+			if hop.For == "" {
+				hop.For = strings.Trim(tok, "<>")
+			}
+		}
+	}
+	hop.IP = findIP(strings.Join(fromText, " "))
+	return hop, nil
+}
+
+// receivedTokens splits `value` at white space, keeping each top-level parenthesized comment
+// as a single token.
+func receivedTokens(value string) (tokens []string) {
+	start, depth := -1, 0
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case depth > 0:
+			switch c {
+			case '\\':
+				i++
+			case '(':
+				depth++
+			case ')':
+				if depth--; depth == 0 {
+					tokens = append(tokens, value[start:i+1])
+					start = -1
+				}
+			}
+		case c == '(':
+			if start >= 0 {
+				tokens = append(tokens, value[start:i])
+			}
+			start, depth = i, 1
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			if start >= 0 {
+				tokens = append(tokens, value[start:i])
+				start = -1
+			}
+		default:
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, value[start:])
+	}
+	return
+}
+
+// findIP returns the first IP address found in `text`, preferring address literals in brackets.
+func findIP(text string) string {
+	for _, m := range reReceivedIPBracket.FindAllStringSubmatch(text, -1) {
+		if ip := net.ParseIP(m[1]); ip != nil {
+			return ip.String()
+		}
+	}
+	for _, m := range reReceivedIPv4.FindAllString(text, -1) {
+		if ip := net.ParseIP(m); ip != nil {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+// ReceivedChain represents the relay steps of a message, in chronological order.
+type ReceivedChain []*Hop
+
+// ParseReceivedChain parses the values of the Received headers of a message, given in the order
+// they appear in the message - that is, most recent first - into a chain of hops in chronological
+// order. The headers that cannot be parsed are skipped, and reported in the returned error.
+func ParseReceivedChain(values []string) (ReceivedChain, error) {
+	chain := make(ReceivedChain, 0, len(values))
+	var msgs []string
+	for i := len(values) - 1; i >= 0; i-- {
+		hop, err := ParseReceived(values[i])
+		if err != nil {
+			msgs = append(msgs, err.Error())
+			continue
+		}
+		chain = append(chain, hop)
+	}
+	if len(msgs) > 0 {
+		return chain, errors.New(strings.Join(msgs, "\n"))
+	}
+	return chain, nil
+}
+
+// TransitTime returns the time elapsed between the first and the last hop of the chain.
+func (c ReceivedChain) TransitTime() time.Duration {
+	if len(c) < 2 {
+		return 0
+	}
+	return c[len(c)-1].Time.Sub(c[0].Time)
+}
+
+// Delays returns the time elapsed from the previous hop to each hop of the chain; the first
+// element is always 0. Negative values indicate clock skew between the relays.
+func (c ReceivedChain) Delays() []time.Duration {
+	delays := make([]time.Duration, len(c))
+	for i := 1; i < len(c); i++ {
+		delays[i] = c[i].Time.Sub(c[i-1].Time)
+	}
+	return delays
+}
@@ -0,0 +1,45 @@
+package email
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_ParseReceivedChain(t *testing.T) {
+	values := []string{
+		"from mx.example.net (mx.example.net [2001:db8::25])\r\n\tby mail.example.org with ESMTPS id 4Xy7\r\n\tfor <user@example.org>; Fri, 30 Aug 2013 09:10:15 +0000 (UTC)",
+		"from [10.0.0.7] (helo=client) by mx.example.net with esmtpa (Exim 4.92)\r\n\t(envelope-from <app@example.com>) id 1qz-0007; Fri, 30 Aug 2013 11:10:12 +0200",
+		"by localhost (Postfix, from userid 1000) id 42; Fri, 30 Aug 2013 09:10:11 +0000",
+		"garbage without date",
+	}
+	chain, err := ParseReceivedChain(values)
+	if err == nil {
+		t.Error("ParseReceivedChain: expected an error for the invalid header")
+	}
+	exp := ReceivedChain{
+		{By: "localhost", ID: "42", Time: time.Date(2013, 8, 30, 9, 10, 11, 0, time.UTC)},
+		{From: "[10.0.0.7]", IP: "10.0.0.7", By: "mx.example.net", With: "esmtpa", ID: "1qz-0007",
+			Time: time.Date(2013, 8, 30, 9, 10, 12, 0, time.UTC)},
+		{From: "mx.example.net", IP: "2001:db8::25", By: "mail.example.org", With: "ESMTPS", ID: "4Xy7",
+			For: "user@example.org", Time: time.Date(2013, 8, 30, 9, 10, 15, 0, time.UTC)},
+	}
+	if len(chain) != len(exp) {
+		t.Fatalf("ParseReceivedChain: got %d hops, want %d", len(chain), len(exp))
+	}
+	for i, hop := range chain {
+		if !hop.Time.Equal(exp[i].Time) {
+			t.Errorf("ParseReceivedChain [%d]: got time %v, want %v", i, hop.Time, exp[i].Time)
+		}
+		hop.Time = exp[i].Time
+		if !reflect.DeepEqual(hop, exp[i]) {
+			t.Errorf("ParseReceivedChain [%d]: got\n%+v\nwant\n%+v", i, hop, exp[i])
+		}
+	}
+	if d := chain.TransitTime(); d != 4*time.Second {
+		t.Errorf("(ReceivedChain).TransitTime: got %v, want 4s", d)
+	}
+	if d := chain.Delays(); !reflect.DeepEqual(d, []time.Duration{0, time.Second, 3 * time.Second}) {
+		t.Errorf("(ReceivedChain).Delays: got %v", d)
+	}
+}
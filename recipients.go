@@ -0,0 +1,179 @@
+package email
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// Recipient represents a single recipient of a bulk send, along with the data to be merged into
+// the message templates for that recipient.
+type Recipient struct {
+	Addr *Address
+	Data interface{}
+}
+
+// RecipientSource provides a stream of recipients, one at a time, so that large recipient lists
+// do not need to be loaded into memory.
+//
+// Next returns io.EOF when there are no more recipients. A *RowError indicates that the current
+// entry was invalid and was skipped; the source can still be used to read the following entries.
+// Any other error is final.
+type RecipientSource interface {
+	Next() (*Recipient, error)
+}
+
+// FieldMap describes how the fields of a structured recipient source map to a Recipient.
+type FieldMap struct {
+	// Addr is the name of the field holding the email address; defaults to "email".
+	Addr string
+	// Name is the name of the (optional) field holding the display name.
+	Name string
+	// Data maps source field names to template data keys. If nil, all the fields are included
+	// in the template data, under their own names.
+	Data map[string]string
+}
+
+func (fm FieldMap) addrField() string {
+	if fm.Addr == "" {
+		return "email"
+	}
+	return fm.Addr
+}
+
+// recipient validates the address and name found in `fields` and builds a Recipient with its
+// template data.
+func (fm FieldMap) recipient(fields map[string]interface{}) (*Recipient, error) {
+	addr, _ := fields[fm.addrField()].(string)
+	if addr == "" {
+		return nil, errors.New("missing address")
+	}
+	var name string
+	if fm.Name != "" {
+		name, _ = fields[fm.Name].(string)
+	}
+	a, err := NewAddress(name, addr)
+	if err != nil {
+		return nil, err
+	}
+	data := fields
+	if fm.Data != nil {
+		data = make(map[string]interface{}, len(fm.Data))
+		for src, key := range fm.Data {
+			if val, ok := fields[src]; ok {
+				data[key] = val
+			}
+		}
+	}
+	return &Recipient{Addr: a, Data: data}, nil
+}
+
+// RowError reports an invalid entry in a RecipientSource. Row is the 1-based index of the entry,
+// not counting any header.
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return "row " + strconv.Itoa(e.Row) + ": " + e.Err.Error()
+}
+
+type csvSource struct {
+	r      *csv.Reader
+	fields FieldMap
+	header []string
+	row    int
+}
+
+// NewCSVSource creates a RecipientSource reading from CSV data with a header line, which provides
+// the field names used by `fields`.
+//
+// An error is returned if the header cannot be read or lacks any of the fields referred to by
+// `fields`.
+func NewCSVSource(r io.Reader, fields FieldMap) (RecipientSource, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.New("NewCSVSource: cannot read header: " + err.Error())
+	}
+	cols := make(map[string]struct{}, len(header))
+	for _, col := range header {
+		cols[col] = struct{}{}
+	}
+	required := []string{fields.addrField()}
+	if fields.Name != "" {
+		required = append(required, fields.Name)
+	}
+	for src := range fields.Data {
+		required = append(required, src)
+	}
+	for _, col := range required {
+		if _, ok := cols[col]; !ok {
+			return nil, errors.New("NewCSVSource: missing column: " + col)
+		}
+	}
+	return &csvSource{r: cr, fields: fields, header: header}, nil
+}
+
+func (s *csvSource) Next() (*Recipient, error) {
+	record, err := s.r.Read()
+	if err == io.EOF {
+		return nil, err
+	}
+	s.row++
+	if err != nil {
+		if _, ok := err.(*csv.ParseError); ok {
+			return nil, &RowError{s.row, err}
+		}
+		return nil, err
+	}
+	fields := make(map[string]interface{}, len(record))
+	for i, val := range record {
+		fields[s.header[i]] = val
+	}
+	rcpt, err := s.fields.recipient(fields)
+	if err != nil {
+		return nil, &RowError{s.row, err}
+	}
+	return rcpt, nil
+}
+
+type jsonSource struct {
+	r      *bufio.Reader
+	fields FieldMap
+	row    int
+}
+
+// NewJSONSource creates a RecipientSource reading from newline-delimited JSON data, with one
+// object per line. Blank lines are ignored.
+func NewJSONSource(r io.Reader, fields FieldMap) RecipientSource {
+	return &jsonSource{r: bufio.NewReader(r), fields: fields}
+}
+
+func (s *jsonSource) Next() (*Recipient, error) {
+	for {
+		line, err := s.r.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return nil, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		s.row++
+		var fields map[string]interface{}
+		if err := json.Unmarshal(line, &fields); err != nil {
+			return nil, &RowError{s.row, err}
+		}
+		rcpt, err := s.fields.recipient(fields)
+		if err != nil {
+			return nil, &RowError{s.row, err}
+		}
+		return rcpt, nil
+	}
+}
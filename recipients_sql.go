@@ -0,0 +1,113 @@
+package email
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+)
+
+// SQLQuery describes a paginated database query for a SQL recipient source.
+//
+// The Query is executed once per page with two arguments: the value of the Key column in the
+// last row of the previous page (or Start, for the first page) and the PageSize. It must return
+// the rows with key values strictly greater than the first argument, ordered ascending by the Key
+// column, and at most PageSize of them - e.g.
+//
+//	SELECT id, email, name FROM users WHERE id > $1 ORDER BY id LIMIT $2
+type SQLQuery struct {
+	Query string
+	// Key is the name of the result column used for keyset pagination.
+	Key string
+	// Start is the key value preceding the first row to be returned.
+	Start interface{}
+	// PageSize is the maximum number of rows fetched at once; defaults to 1000.
+	PageSize int
+}
+
+type sqlSource struct {
+	db     *sql.DB
+	query  SQLQuery
+	fields FieldMap
+	last   interface{}
+	page   []map[string]interface{}
+	done   bool
+	row    int
+}
+
+// NewSQLSource creates a RecipientSource reading recipients from `db`, one page at a time, so that
+// campaigns can be driven directly from application databases. The result columns are mapped to
+// recipients according to `fields`.
+func NewSQLSource(db *sql.DB, query SQLQuery, fields FieldMap) RecipientSource {
+	if query.PageSize <= 0 {
+		query.PageSize = 1000
+	}
+	return &sqlSource{db: db, query: query, fields: fields, last: query.Start}
+}
+
+func (s *sqlSource) fetch() error {
+	rows, err := s.db.Query(s.query.Query, s.last, s.query.PageSize)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	keyCol := -1
+	for i, col := range cols {
+		if col == s.query.Key {
+			keyCol = i
+		}
+	}
+	if keyCol < 0 {
+		return errors.New("NewSQLSource: missing key column: " + s.query.Key)
+	}
+	vals := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		fields := make(map[string]interface{}, len(cols))
+		for i, col := range cols {
+			if b, ok := vals[i].([]byte); ok {
+				fields[col] = string(b)
+			} else {
+				fields[col] = vals[i]
+			}
+		}
+		s.page = append(s.page, fields)
+		s.last = vals[keyCol]
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	s.done = len(s.page) < s.query.PageSize
+	return nil
+}
+
+func (s *sqlSource) Next() (*Recipient, error) {
+	if len(s.page) == 0 {
+		if s.done {
+			return nil, io.EOF
+		}
+		if err := s.fetch(); err != nil {
+			return nil, err
+		}
+		if len(s.page) == 0 {
+			return nil, io.EOF
+		}
+	}
+	fields := s.page[0]
+	s.page = s.page[1:]
+	s.row++
+	rcpt, err := s.fields.recipient(fields)
+	if err != nil {
+		return nil, &RowError{s.row, err}
+	}
+	return rcpt, nil
+}
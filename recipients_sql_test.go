@@ -0,0 +1,91 @@
+package email
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// testDriver serves the rows of testTable, interpreting any query as
+// "SELECT * WHERE id > $1 ORDER BY id LIMIT $2".
+type testDriver struct{}
+
+var (
+	testTableCols = []string{"id", "email", "name"}
+	testTable     = [][]driver.Value{
+		{int64(1), []byte("a@example.com"), []byte("Ann")},
+		{int64(2), []byte("b@example.com"), []byte("Bob")},
+		{int64(3), []byte("invalid"), []byte("Cid")},
+		{int64(4), []byte("d@example.com"), []byte("Dee")},
+		{int64(5), []byte("e@example.com"), []byte("Eve")},
+	}
+	testQueries int
+)
+
+func (testDriver) Open(name string) (driver.Conn, error) { return testConn{}, nil }
+
+type testConn struct{}
+
+func (testConn) Prepare(query string) (driver.Stmt, error) { return testStmt{}, nil }
+func (testConn) Close() error                              { return nil }
+func (testConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type testStmt struct{}
+
+func (testStmt) Close() error  { return nil }
+func (testStmt) NumInput() int { return 2 }
+func (testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	testQueries++
+	after, limit := args[0].(int64), args[1].(int64)
+	rows := &testRows{}
+	for _, row := range testTable {
+		if row[0].(int64) > after && int64(len(rows.rows)) < limit {
+			rows.rows = append(rows.rows, row)
+		}
+	}
+	return rows, nil
+}
+
+type testRows struct{ rows [][]driver.Value }
+
+func (r *testRows) Columns() []string { return testTableCols }
+func (r *testRows) Close() error      { return nil }
+func (r *testRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func init() {
+	sql.Register("email-test", testDriver{})
+}
+
+func Test_SQLSource(t *testing.T) {
+	db, _ := sql.Open("email-test", "")
+	defer db.Close()
+	testQueries = 0
+	src := NewSQLSource(db, SQLQuery{Query: "test", Key: "id", Start: int64(0), PageSize: 2},
+		FieldMap{Name: "name", Data: map[string]string{"id": "id"}})
+	exp := []sourceResult{
+		{"a@example.com", "Ann", map[string]interface{}{"id": int64(1)}, 0},
+		{"b@example.com", "Bob", map[string]interface{}{"id": int64(2)}, 0},
+		{rowErr: 3},
+		{"d@example.com", "Dee", map[string]interface{}{"id": int64(4)}, 0},
+		{"e@example.com", "Eve", map[string]interface{}{"id": int64(5)}, 0},
+	}
+	if act := drainSource(t, src); !reflect.DeepEqual(act, exp) {
+		t.Errorf("NewSQLSource: got\n%+v\nwant\n%+v", act, exp)
+	}
+	if testQueries != 3 {
+		t.Errorf("NewSQLSource: got %d queries, want 3", testQueries)
+	}
+}
@@ -0,0 +1,78 @@
+package email
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type sourceResult struct {
+	addr, name string
+	data       map[string]interface{}
+	rowErr     int
+}
+
+func drainSource(t *testing.T, src RecipientSource) (res []sourceResult) {
+	for {
+		rcpt, err := src.Next()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			if re, ok := err.(*RowError); ok {
+				res = append(res, sourceResult{rowErr: re.Row})
+				continue
+			}
+			t.Fatalf("Next: unexpected error: %v", err)
+		}
+		res = append(res, sourceResult{rcpt.Addr.Addr, rcpt.Addr.Name, rcpt.Data.(map[string]interface{}), 0})
+	}
+}
+
+func Test_CSVSource(t *testing.T) {
+	csvData := "mail,full name,plan\n" +
+		"a@example.com,Ann,gold\n" +
+		"not-an-address,Bob,silver\n" +
+		"c@example.com,Cid\n" +
+		"d@example.com,Dee,bronze\n"
+	src, err := NewCSVSource(strings.NewReader(csvData), FieldMap{
+		Addr: "mail",
+		Name: "full name",
+		Data: map[string]string{"full name": "name", "plan": "tier"},
+	})
+	if err != nil {
+		t.Fatalf("NewCSVSource: unexpected error: %v", err)
+	}
+	exp := []sourceResult{
+		{"a@example.com", "Ann", map[string]interface{}{"name": "Ann", "tier": "gold"}, 0},
+		{rowErr: 2},
+		{rowErr: 3},
+		{"d@example.com", "Dee", map[string]interface{}{"name": "Dee", "tier": "bronze"}, 0},
+	}
+	if act := drainSource(t, src); !reflect.DeepEqual(act, exp) {
+		t.Errorf("NewCSVSource: got\n%+v\nwant\n%+v", act, exp)
+	}
+
+	if _, err := NewCSVSource(strings.NewReader("name,plan\n"), FieldMap{}); err == nil {
+		t.Error("NewCSVSource: expected an error for a header without the address column")
+	}
+}
+
+func Test_JSONSource(t *testing.T) {
+	jsonData := `{"email": "a@example.com", "n": 1}
+
+{"email": "b@example
+{"n": 3}
+{"email": "d@example.com", "n": 4}`
+	src := NewJSONSource(strings.NewReader(jsonData), FieldMap{})
+	exp := []sourceResult{
+		{"a@example.com", "", map[string]interface{}{"email": "a@example.com", "n": 1.0}, 0},
+		{rowErr: 2},
+		{rowErr: 3},
+		{"d@example.com", "", map[string]interface{}{"email": "d@example.com", "n": 4.0}, 0},
+	}
+	if act := drainSource(t, src); !reflect.DeepEqual(act, exp) {
+		t.Errorf("NewJSONSource: got\n%+v\nwant\n%+v", act, exp)
+	}
+}
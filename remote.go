@@ -0,0 +1,120 @@
+package email
+
+import (
+	"context"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var reRemoteImage = regexp.MustCompile(`(?i)(<img\b[^>]*?\ssrc\s*=\s*)("https://[^"]*"|'https://[^']*')`)
+
+// EmbedRemoteImages enables the embedding of the remote images of the HTML part when composing the
+// message, so that it renders with its images even in the mail clients that block remote content:
+// the images referenced as <img src="https://..."> from the `hosts` are downloaded, added as related
+// items, and their src attributes rewritten to the matching "cid:" URLs. A host starting with "*."
+// matches its subdomains - e.g. "*.example.com" allows "cdn.example.com". Calling it with no hosts
+// disables the embedding.
+//
+// The images are fetched as set with FetchURLs, and kept for composing the message again; the
+// errors fetching them are recorded as FileError errors of the HTML part.
+func (m *Message) EmbedRemoteImages(hosts ...string) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.remoteHosts = nil
+	for _, h := range hosts {
+		m.remoteHosts = append(m.remoteHosts, strings.ToLower(h))
+	}
+	m.remoteCache = nil
+	return m
+}
+
+// remoteHostAllowed reports whether the images from the `host` may be embedded in the receiver.
+func (m *Message) remoteHostAllowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, h := range m.remoteHosts {
+		if h == host || strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// embedRemoteImages fetches the remote images from the allowed hosts referenced by the HTML `body`,
+// returning the body with their src attributes rewritten to "cid:" URLs, and the function that
+// removes the related items added for them to the receiver. The caller must hold the lock on the
+// receiver.
+func (m *Message) embedRemoteImages(body []byte) ([]byte, func()) {
+	if len(m.remoteHosts) == 0 || m.html == nil {
+		return body, func() {}
+	}
+	matches := reRemoteImage.FindAllSubmatchIndex(body, -1)
+	var (
+		srcs   = map[string]string{} // fetched URLs, by quoted attribute value
+		urls   []string
+		loads  []func() fileResult
+		queued = map[string]bool{}
+	)
+	fetcher := m.fetcher
+	for _, sub := range matches {
+		src := string(body[sub[4]:sub[5]])
+		rawURL := html.UnescapeString(src[1 : len(src)-1])
+		u, err := url.Parse(rawURL)
+		if err != nil || !m.remoteHostAllowed(u.Hostname()) {
+			continue
+		}
+		srcs[src] = rawURL
+		if _, ok := m.remoteCache[rawURL]; ok || queued[rawURL] {
+			continue
+		}
+		queued[rawURL] = true
+		urls = append(urls, rawURL)
+		loads = append(loads, func() fileResult {
+			return fetcher.fetch(context.Background(), rawURL)
+		})
+	}
+	results, _ := loadAll(context.Background(), loads, m.prepareWorkers)
+	for i, res := range results {
+		if res.err != nil {
+			m.failPart(FileError, m.partIndex(m.html), "html", res.err)
+			continue
+		}
+		if res.ctype == "" {
+			res.ctype = "application/octet-stream"
+		}
+		if m.remoteCache == nil {
+			m.remoteCache = map[string]fileResult{}
+		}
+		m.remoteCache[urls[i]] = res
+	}
+	ids := map[string]string{}
+	for src, rawURL := range srcs {
+		if _, ok := m.remoteCache[rawURL]; ok {
+			ids[src] = "remote:" + src[1:len(src)-1]
+		}
+	}
+	if len(ids) == 0 {
+		return body, func() {}
+	}
+	// the rewritten references are substituted with the Content-IDs of the items, like those to
+	// the other related items
+	added := map[string]bool{}
+	body = reRemoteImage.ReplaceAllFunc(body, func(tag []byte) []byte {
+		sub := reRemoteImage.FindSubmatchIndex(tag)
+		src := string(tag[sub[4]:sub[5]])
+		id, ok := ids[src]
+		if !ok {
+			return tag
+		}
+		if !added[id] {
+			added[id] = true
+			res := m.remoteCache[srcs[src]]
+			m.remote = append(m.remote, Related{id: id, ctype: res.ctype, data: res.data})
+		}
+		return append(append([]byte(nil), tag[:sub[4]]...), src[:1]+"cid:"+id+src[:1]...)
+	})
+	return body, func() {
+		m.remote = nil
+	}
+}
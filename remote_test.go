@@ -0,0 +1,65 @@
+package email
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_EmbedRemoteImages(t *testing.T) {
+	hits := 0
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.URL.Path != "/logo.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	}))
+	defer srv.Close()
+
+	src := srv.URL + "/logo.png"
+	msg := QuickMessage("test").From(&Address{"", "ann@example.com"}).
+		Html(`<p><img alt="logo" src="`+src+`"> <img src='`+src+`'> <img src="https://example.com/x.png"> <a href="`+src+`">logo</a></p>`).
+		FetchURLs(srv.Client(), 0, 0).
+		EmbedRemoteImages("127.0.0.1")
+	for i := 0; i < 2; i++ {
+		e, err := ReadEntity(bytes.NewReader(msg.Compose(nil)), nil)
+		if err != nil {
+			t.Fatalf("(*Message).EmbedRemoteImages [%d]: got %v, %v", i, err, msg.Errors())
+		}
+		img := findEntity(e, func(e *Entity) bool { return e.MediaType == "image/png" })
+		if img == nil || string(img.Body) != "png" {
+			t.Fatalf("(*Message).EmbedRemoteImages [%d]: got related item %v", i, img)
+		}
+		cid := "cid:" + strings.Trim(img.Header.Get("Content-ID"), "<>")
+		body := string(findEntity(e, func(e *Entity) bool { return e.MediaType == "text/html" }).Body)
+		want := `<p><img alt="logo" src="` + cid + `"> <img src='` + cid + `'> <img src="https://example.com/x.png"> <a href="` + src + `">logo</a></p>`
+		if strings.TrimSpace(body) != want {
+			t.Errorf("(*Message).EmbedRemoteImages [%d]: got %q want %q", i, body, want)
+		}
+		if n := strings.Count(string(msg.Compose(nil)), "Content-Type: image/png"); n != 1 {
+			t.Errorf("(*Message).EmbedRemoteImages [%d]: got %d related items want 1", i, n)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("(*Message).EmbedRemoteImages: got %d requests want 1", hits)
+	}
+
+	msg = QuickMessage("test").From(&Address{"", "ann@example.com"}).
+		Html(`<img src="`+srv.URL+`/missing.png">`).
+		FetchURLs(srv.Client(), 0, 0).
+		EmbedRemoteImages("*.example.com", "127.0.0.1")
+	if b := msg.Compose(nil); len(b) != 0 {
+		t.Errorf("(*Message).EmbedRemoteImages: got message with missing image")
+	}
+	if errs := msg.Errors(); len(errs) != 1 || errs[0].(*MessageError).Kind != FileError {
+		t.Errorf("(*Message).EmbedRemoteImages: got %v want 1 file error", errs)
+	}
+	if !msg.remoteHostAllowed("cdn.example.com") || msg.remoteHostAllowed("example.com.evil") {
+		t.Errorf("(*Message).EmbedRemoteImages: wildcard host mismatch")
+	}
+}
@@ -0,0 +1,173 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rendering holds the content generated by TestRender from the templates of a message.
+type Rendering struct {
+	// Subject is the subject of the message.
+	Subject string
+	// Text, HTML and Amp are the contents of the plain-text, HTML and AMP parts of the message,
+	// if any; Text is generated from the HTML content as when composing the message, if there is
+	// no plain-text part - see AutoText.
+	Text, HTML, Amp string
+	// Locales holds the renderings of the locales registered with Locale, by locale; the
+	// templates that are not provided for a locale are the ones of the message.
+	Locales map[string]*Rendering
+}
+
+// TestRender executes all the templates of the receiver - subject, text, HTML and AMP, including
+// those of the locales registered with Locale - with the sample `data`, returning the generated
+// content along with all the errors, if any: those recorded when setting up the message, followed
+// by those executing the templates. It is meant for verifying the messages before deploying them -
+// e.g. in CI - without sending anything.
+//
+// Unlike Compose, TestRender does not change the receiver, nor does it clear its errors; the data
+// is merged with the default data, and the content inherited from the base, if any, is rendered,
+// as when composing the message.
+func (m *Message) TestRender(data interface{}) (*Rendering, []error) {
+	m.Lock()
+	defer m.Unlock()
+	n := len(m.errors)
+	defer m.inherit()()
+	data = m.mergeData(data)
+	errs := append([]error(nil), m.errors...)
+	m.errors = m.errors[:n]
+	r := &Rendering{}
+	var buf bytes.Buffer
+	render := func(p *part, name string, index int, field string) string {
+		buf.Reset()
+		var err error
+		switch {
+		case p == nil:
+			return ""
+		case p.tpl != nil:
+			err = p.tpl.Execute(&buf, data)
+		case p.htmlTpl != nil:
+			err = p.htmlTpl.Execute(&buf, data)
+		default:
+			return string(p.bytes)
+		}
+		if err != nil {
+			errs = append(errs, &MessageError{Kind: TemplateError, Field: field, PartIndex: index,
+				Err: &ErrTemplateExec{name, err}})
+		}
+		return buf.String()
+	}
+	if m.subjectTpl != nil {
+		r.Subject = render(&part{tpl: m.subjectTpl}, "subject", -1, "subject")
+	} else {
+		r.Subject = string(m.subject)
+	}
+	for i, p := range m.parts {
+		name := "part[" + strconv.Itoa(i) + "]"
+		switch p {
+		case m.text:
+			r.Text = render(p, name, i, "text")
+		case m.html:
+			r.HTML = render(p, name+" html", i, "html")
+		case m.amp:
+			r.Amp = render(p, name+" html", i, "amp")
+		}
+	}
+	autoText := m.hasAutoText()
+	toText := func(html string) string {
+		b := []byte(html)
+		if m.htmlPolicy != nil {
+			b = m.htmlPolicy.Sanitize(b)
+		}
+		if m.inlineCSS {
+			b = InlineCSS(b)
+		}
+		return m.toText(b)
+	}
+	if autoText {
+		r.Text = toText(r.HTML)
+	}
+	locales := make([]string, 0, len(m.locales))
+	for locale := range m.locales {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	for _, locale := range locales {
+		v := m.locales[locale]
+		lr := &Rendering{Subject: r.Subject, Text: r.Text, HTML: r.HTML, Amp: r.Amp}
+		if v.subject != nil {
+			lr.Subject = render(&part{tpl: v.subject}, locale+" subject", -1, "subject")
+		}
+		if v.text != nil {
+			lr.Text = render(&part{tpl: v.text}, locale+" text", m.partIndex(m.text), "text")
+		}
+		if v.html != nil {
+			lr.HTML = render(&part{htmlTpl: v.html}, locale+" html", m.partIndex(m.html), "html")
+			if autoText && v.text == nil {
+				lr.Text = toText(lr.HTML)
+			}
+		}
+		if r.Locales == nil {
+			r.Locales = make(map[string]*Rendering, len(m.locales))
+		}
+		r.Locales[locale] = lr
+	}
+	return r, errs
+}
+
+// Preview composes the receiver with the `data`, like Compose, and returns its content as shown to
+// the recipients - e.g. for a "preview this email" page: the HTML body, with the "cid:" references
+// to the related and embedded items replaced with "data:" URIs, and the plain-text body. If the
+// message has no HTML body, the returned document presents the plain-text body.
+//
+// Both are empty if the message cannot be composed; the errors are available with Errors, as for
+// Compose.
+func (m *Message) Preview(data interface{}) (doc, text string) {
+	b := m.Compose(data)
+	if len(b) == 0 {
+		return "", ""
+	}
+	e, err := ReadEntity(bytes.NewReader(b), nil)
+	if err != nil {
+		m.Lock()
+		m.fail(ContentError, "", err)
+		m.Unlock()
+		return "", ""
+	}
+	body := func(mediaType string) *Entity {
+		return findEntity(e, func(e *Entity) bool {
+			d, _, _ := mime.ParseMediaType(e.Header.Get("Content-Disposition"))
+			return e.MediaType == mediaType && d != "attachment"
+		})
+	}
+	if t := body("text/plain"); t != nil {
+		text = strings.TrimSuffix(string(t.Body), "\r\n")
+	}
+	h := body("text/html")
+	if h == nil {
+		return "<!DOCTYPE html>\n<html><body><pre>" + html.EscapeString(text) + "</pre></body></html>\n", text
+	}
+	var ids []string
+	uris := map[string]string{}
+	findEntity(e, func(e *Entity) bool {
+		if id := strings.Trim(e.Header.Get("Content-ID"), "<>"); id != "" && len(e.Parts) == 0 {
+			ids = append(ids, id)
+			uris[id] = "data:" + e.MediaType + ";base64," + base64.StdEncoding.EncodeToString(e.Body)
+		}
+		return false
+	})
+	// replace the longer ids first, in case some are prefixes of others
+	sort.Slice(ids, func(i, j int) bool {
+		return len(ids[i]) > len(ids[j])
+	})
+	oldnew := make([]string, 0, 2*len(ids))
+	for _, id := range ids {
+		oldnew = append(oldnew, "cid:"+id, uris[id])
+	}
+	doc = strings.TrimSuffix(string(h.Body), "\r\n")
+	return strings.NewReplacer(oldnew...).Replace(doc), text
+}
@@ -0,0 +1,62 @@
+package email
+
+import (
+	"testing"
+)
+
+func Test_TestRender(t *testing.T) {
+	msg := NewMessage(nil).From(&Address{"", "test@example.com"}).To(&Address{"", "to@example.com"}).
+		SubjectTemplate("Hi {{.name}}").TextTemplate("Hello, {{.name}}").
+		HtmlTemplate("<p>Hello, {{.name}}</p>").
+		Locale("fr", "Salut {{.name}}", "", "<p>Bonjour, {{.name.x}}</p>")
+	r, errs := msg.TestRender(map[string]interface{}{"name": "Ann"})
+	exp := Rendering{Subject: "Hi Ann", Text: "Hello, Ann", HTML: "<p>Hello, Ann</p>"}
+	if r.Subject != exp.Subject || r.Text != exp.Text || r.HTML != exp.HTML || r.Amp != "" {
+		t.Errorf("(*Message).TestRender: got %+v want %+v", *r, exp)
+	}
+	fr := r.Locales["fr"]
+	if fr == nil || fr.Subject != "Salut Ann" || fr.Text != "Hello, Ann" || fr.HTML != "<p>Bonjour, " {
+		t.Errorf("(*Message).TestRender: got locale %+v", fr)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("(*Message).TestRender: got errors %v want 1", errs)
+	}
+	if me := errs[0].(*MessageError); me.Kind != TemplateError || me.Field != "html" || me.PartIndex != 1 {
+		t.Errorf("(*Message).TestRender: got error %#v", me)
+	}
+	// the receiver is unchanged
+	if msg.subject != nil || len(msg.Errors()) != 0 {
+		t.Errorf("(*Message).TestRender: changed the message")
+	}
+	// the errors recorded when setting up the message are included
+	_, errs = NewMessage(nil).TextTemplate("{{.x").TestRender(nil)
+	if len(errs) != 1 {
+		t.Errorf("(*Message).TestRender: got errors %v want 1", errs)
+	}
+}
+
+func Test_Preview(t *testing.T) {
+	newMsg := func() *Message {
+		return NewMessage(nil).From(&Address{"", "test@example.com"}).To(&Address{"", "to@example.com"}).
+			Subject("test")
+	}
+	msg := newMsg().HtmlTemplate(`<p>Hello, {{.name}}</p><img src="cid:logo.png">`).
+		Embed("logo.png", "image/png", []byte("PNG"))
+	doc, text := msg.Preview(map[string]string{"name": "Ann"})
+	if errs := msg.Errors(); len(errs) > 0 {
+		t.Fatalf("(*Message).Preview: unexpected errors: %v", errs)
+	}
+	if exp := `<p>Hello, Ann</p><img src="data:image/png;base64,UE5H">`; doc != exp {
+		t.Errorf("(*Message).Preview: got %q want %q", doc, exp)
+	}
+	if text != "Hello, Ann" {
+		t.Errorf("(*Message).Preview: got text %q want %q", text, "Hello, Ann")
+	}
+	doc, text = newMsg().Text("1 < 2").Preview(nil)
+	if exp := "<!DOCTYPE html>\n<html><body><pre>1 &lt; 2</pre></body></html>\n"; doc != exp || text != "1 < 2" {
+		t.Errorf("(*Message).Preview: got %q, %q want %q", doc, text, exp)
+	}
+	if doc, text = NewMessage(nil).Text("x").Preview(nil); doc != "" || text != "" {
+		t.Errorf("(*Message).Preview: got %q, %q for a message without From", doc, text)
+	}
+}
@@ -0,0 +1,60 @@
+package email
+
+import (
+	"strings"
+	"time"
+)
+
+// QuoteReply returns the `reply` text followed by the `original` text, quoted for a conversation -
+// with each line prefixed with "> " - under an attribution line, such as "On Mon, 2 Jan 2006 at
+// 15:04, Ann wrote:". The attribution names the `author` by name, or else by address - or as
+// "someone" if nil - and omits the date if `date` is zero.
+func QuoteReply(reply, original string, date time.Time, author *Address) string {
+	var buf strings.Builder
+	buf.WriteString(strings.TrimRight(reply, "\r\n"))
+	if reply != "" {
+		buf.WriteString("\n\n")
+	}
+	if !date.IsZero() {
+		buf.WriteString("On " + date.Format("Mon, 2 Jan 2006 at 15:04") + ", ")
+	}
+	switch {
+	case author != nil && author.Name != "":
+		buf.WriteString(author.Name)
+	case author != nil:
+		buf.WriteString(author.Addr)
+	default:
+		buf.WriteString("someone")
+	}
+	buf.WriteString(" wrote:\n")
+	original = strings.TrimRight(strings.ReplaceAll(original, "\r\n", "\n"), "\n")
+	for _, line := range strings.Split(original, "\n") {
+		switch {
+		case line == "":
+			buf.WriteString(">\n")
+		case line[0] == '>':
+			// nested quotes are compacted, as in ">> "
+			buf.WriteString(">" + line + "\n")
+		default:
+			buf.WriteString("> " + line + "\n")
+		}
+	}
+	return buf.String()
+}
+
+// QuoteReply returns the `reply` text followed by the text of the receiver - e.g. a message read
+// with Parse - quoted as with QuoteReply, with the date and the From address of the receiver. The
+// text of the receiver is the content of its plain-text part or, if it has none, the conversion of
+// its HTML part; for templates, it is the content generated by the most recent call to Compose.
+func (m *Message) QuoteReply(reply string) string {
+	m.RLock()
+	defer m.RUnlock()
+	var text string
+	switch {
+	case m.text != nil:
+		text = string(m.text.bytes)
+	case m.html != nil:
+		text = m.toText(m.html.bytes)
+	}
+	return QuoteReply(reply, text, m.date, m.from)
+}
@@ -0,0 +1,31 @@
+package email
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_QuoteReply(t *testing.T) {
+	date := time.Date(2021, 3, 4, 15, 6, 0, 0, time.UTC)
+	cases := []struct {
+		reply, original string
+		date            time.Time
+		author          *Address
+		exp             string
+	}{
+		{"Sounds good.\n", "Lunch at noon?\r\n\r\n> Are you free?\r\n", date, &Address{"Ann", "ann@example.com"},
+			"Sounds good.\n\nOn Thu, 4 Mar 2021 at 15:06, Ann wrote:\n> Lunch at noon?\n>\n>> Are you free?\n"},
+		{"", "Hi", time.Time{}, &Address{"", "ann@example.com"}, "ann@example.com wrote:\n> Hi\n"},
+		{"Yes", "Hi", time.Time{}, nil, "Yes\n\nsomeone wrote:\n> Hi\n"},
+	}
+	for i, c := range cases {
+		if act := QuoteReply(c.reply, c.original, c.date, c.author); act != c.exp {
+			t.Errorf("QuoteReply [%d]: got %q want %q", i, act, c.exp)
+		}
+	}
+
+	msg := NewMessage(nil).From(&Address{"Ann", "ann@example.com"}).Date(date).Html("<p>Lunch at noon?</p>")
+	if act, exp := msg.QuoteReply("Sure"), "Sure\n\nOn Thu, 4 Mar 2021 at 15:06, Ann wrote:\n> Lunch at noon?\n"; act != exp {
+		t.Errorf("(*Message).QuoteReply: got %q want %q", act, exp)
+	}
+}
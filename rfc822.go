@@ -0,0 +1,71 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+)
+
+// AttachMessage attaches an email message, given either as a composed message in a []byte, or as a
+// *Message to be composed with no data. The message is attached as "message/rfc822", which is what
+// mail clients display as a forwarded message, and what abuse reports (RFC 5965) and journaling
+// systems expect.
+//
+// As required for this type, the message is attached without any encoding, but with its line
+// endings normalized to CRLF.
+func (m *Message) AttachMessage(msg interface{}) *Message {
+	var raw []byte
+	switch msg := msg.(type) {
+	case []byte:
+		raw = msg
+	case *Message:
+		if msg == m {
+			m.Lock()
+			m.failAttachment(ArgumentError, "message.eml", errors.New("cannot attach a message to itself"))
+			m.Unlock()
+			return m
+		}
+		raw = msg.Compose(nil)
+		if errs := msg.Errors(); len(errs) > 0 {
+			m.Lock()
+			m.failAttachment(ContentError, "message.eml", errors.New("cannot attach message: "+errs[0].Error()))
+			m.Unlock()
+			return m
+		}
+	default:
+		m.Lock()
+		m.failAttachment(ArgumentError, "message.eml", ErrInvalidArgument)
+		m.Unlock()
+		return m
+	}
+	m.Lock()
+	defer m.Unlock()
+	if len(raw) == 0 {
+		m.failAttachment(ContentError, "message.eml", errors.New("cannot attach an empty message"))
+		return m
+	}
+	m.attachments = append(m.attachments, &attachment{
+		name:    "message.eml",
+		ctype:   "message/rfc822",
+		data:    normalizeCRLF(raw),
+		encoded: &encodedCache{},
+	})
+	return m
+}
+
+// normalizeCRLF returns `data` with all the line endings converted to CRLF.
+func normalizeCRLF(data []byte) []byte {
+	data = bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
+	data = bytes.Replace(data, []byte("\r"), []byte("\n"), -1)
+	return bytes.Replace(data, []byte("\n"), []byte("\r\n"), -1)
+}
+
+// identityCTE returns the content transfer encoding for the `data` of an entity that cannot be
+// encoded, such as "message/rfc822": "7bit" if the data is all ASCII, or "8bit" otherwise.
+func identityCTE(data []byte) string {
+	for _, c := range data {
+		if c >= 0x80 {
+			return "8bit"
+		}
+	}
+	return "7bit"
+}
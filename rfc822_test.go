@@ -0,0 +1,67 @@
+package email
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_AttachMessage(t *testing.T) {
+	orig := QuickMessage("Spam", "Buy now").From(&Address{"", "spammer@example.com"})
+	msg := QuickMessage("Abuse report", "See attached").From(&Address{"", "abuse@example.com"}).
+		AttachMessage(orig).AttachMessage([]byte("Subject: Naïve\nFrom: <a@example.com>\n\nbody\n"))
+	e, err := ReadEntity(bytes.NewReader(msg.Compose(nil)), nil)
+	if err != nil {
+		t.Fatalf("(*Message).AttachMessage: got error %v", err)
+	}
+	if len(e.Parts) != 3 {
+		t.Fatalf("(*Message).AttachMessage: got %d parts, want 3", len(e.Parts))
+	}
+	cases := []struct {
+		cte, subject string
+	}{
+		{"7bit", "Spam"},
+		{"8bit", "Naïve"},
+	}
+	for i, c := range cases {
+		att := e.Parts[i+1]
+		if att.MediaType != "message/rfc822" || att.Header.Get("Content-Transfer-Encoding") != c.cte {
+			t.Errorf("(*Message).AttachMessage [%d]: got header %v", i, att.Header)
+			continue
+		}
+		if len(att.Parts) != 1 || att.Parts[0].Header.Get("Subject") != c.subject {
+			t.Errorf("(*Message).AttachMessage [%d]: got embedded message %v", i, att.Parts)
+		}
+	}
+
+	if msg = NewMessage(nil).AttachMessage(NewMessage(nil)); len(msg.Errors()) == 0 {
+		t.Error("(*Message).AttachMessage: expected an error for a message that cannot be composed")
+	}
+	if msg = NewMessage(nil).AttachMessage("raw"); len(msg.Errors()) == 0 {
+		t.Error("(*Message).AttachMessage: expected an error for an invalid argument")
+	}
+}
+
+func Test_normalizeCRLF(t *testing.T) {
+	if act, exp := string(normalizeCRLF([]byte("a\nb\r\nc\rd"))), "a\r\nb\r\nc\r\nd"; act != exp {
+		t.Errorf("normalizeCRLF: got %q want %q", act, exp)
+	}
+}
+
+func Test_PartIdentityCTE(t *testing.T) {
+	msg := NewMessage(nil).From(&Address{"", "test@example.com"}).To(&Address{"", "to@example.com"}).
+		Subject("test").Part("text/plain; charset=utf-8", EightBit, []byte("Crème\r\nbrûlée"))
+	act := string(msg.Compose(nil))
+	if errs := msg.Errors(); len(errs) > 0 {
+		t.Fatalf("(*Message).Part: unexpected errors: %v", errs)
+	}
+	if !strings.Contains(act, "Content-Transfer-Encoding: 8bit\r\n\r\nCrème\r\nbrûlée\r\n") {
+		t.Errorf("(*Message).Part: got %q", act)
+	}
+	msg = NewMessage(nil).From(&Address{"", "test@example.com"}).To(&Address{"", "to@example.com"}).
+		Subject("test").Part("text/plain; charset=utf-8", SevenBit, []byte("Crème"))
+	msg.Compose(nil)
+	if errs := msg.Errors(); len(errs) != 1 {
+		t.Errorf("(*Message).Part: got errors %v want 1", errs)
+	}
+}
@@ -0,0 +1,58 @@
+package email
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrEscapesRoot is reported for the files referenced by a message that are outside the root
+// directory set with AttachmentRoot.
+var ErrEscapesRoot = errors.New("path escapes the attachment root")
+
+// AttachmentRoot confines the files attached to the message, or referenced by its related items,
+// to the `dir` directory: relative paths are resolved against `dir`, and any path leading outside
+// of it - e.g. "../../etc/passwd", or through a symbolic link - fails to be read, with
+// ErrEscapesRoot. This is important when the paths come from untrusted input, such as request
+// parameters. An empty `dir` removes the confinement.
+func (m *Message) AttachmentRoot(dir string) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.root = dir
+	m.prepared = false
+	return m
+}
+
+// confine resolves the file `name` within the `root` directory, failing with ErrEscapesRoot if it
+// is outside of it. An empty `root` allows any file.
+func confine(root, name string) (string, error) {
+	if root == "" {
+		return name, nil
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		if os.IsNotExist(err) && !within(realRoot, filepath.Clean(path)) {
+			return "", ErrEscapesRoot
+		}
+		return "", err
+	}
+	if !within(realRoot, realPath) {
+		return "", ErrEscapesRoot
+	}
+	return realPath, nil
+}
+
+// within reports whether `path` is inside the `dir` directory.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
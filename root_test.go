@@ -0,0 +1,50 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_AttachmentRoot(t *testing.T) {
+	base, err := ioutil.TempDir("", "email-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+	root := filepath.Join(base, "root")
+	os.MkdirAll(filepath.Join(root, "sub"), 0700)
+	ioutil.WriteFile(filepath.Join(root, "sub", "ok.txt"), []byte("ok"), 0600)
+	ioutil.WriteFile(filepath.Join(base, "secret.txt"), []byte("secret"), 0600)
+	symlinks := os.Symlink(filepath.Join(base, "secret.txt"), filepath.Join(root, "link.txt")) == nil
+
+	cases := []struct {
+		file string
+		ok   bool
+	}{
+		{"sub/ok.txt", true},
+		{filepath.Join(root, "sub", "ok.txt"), true},
+		{"sub/../sub/ok.txt", true},
+		{"../secret.txt", false},
+		{"sub/../../secret.txt", false},
+		{filepath.Join(base, "secret.txt"), false},
+		{"../missing.txt", false},
+		{"link.txt", false},
+	}
+	for i, c := range cases {
+		if c.file == "link.txt" && !symlinks {
+			continue
+		}
+		msg := QuickMessage("test", "body").AttachmentRoot(root).Attach(c.file)
+		err := msg.PrepareContext(context.Background())
+		if c.ok && (err != nil || string(msg.attachments[0].data) != "ok") {
+			t.Errorf("(*Message).AttachmentRoot [%d]: unexpected error: %v", i, err)
+		}
+		if !c.ok && !errors.Is(err, ErrEscapesRoot) {
+			t.Errorf("(*Message).AttachmentRoot [%d]: got error %v, want %v", i, err, ErrEscapesRoot)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// RoundTrip verifies that the receiver survives being stored as a composed message and then
+// reconstructed with Parse - as in store-and-forward pipelines. It composes the receiver with the
+// `data`, parses the result, and composes the parsed message again, returning the parsed message
+// along with an error if the second composition differs from the first in any byte.
+//
+// The Bcc recipients are not part of the composed message, hence not reproduced; nor are the
+// encrypted messages, whose content cannot be parsed. The errors composing the receiver are
+// returned as such, and remain recorded as with Compose.
+func (m *Message) RoundTrip(data interface{}) (*Message, error) {
+	composed := m.Compose(data)
+	if len(composed) == 0 {
+		if errs := m.Inspect().Errors; len(errs) > 0 {
+			return nil, errs[0]
+		}
+	}
+	parsed, err := Parse(bytes.NewReader(composed))
+	if err != nil {
+		return nil, err
+	}
+	m.RLock()
+	parsed.boundaries = m.boundaries
+	m.RUnlock()
+	again := parsed.Compose(nil)
+	if errs := parsed.Errors(); len(errs) > 0 {
+		return parsed, errs[0]
+	}
+	if bytes.Equal(composed, again) {
+		return parsed, nil
+	}
+	// the text part may have been generated from the HTML part, which changes the numbering of
+	// the parts, hence their related items
+	if auto := parsed.withoutAutoText(); auto != nil {
+		if b := auto.Compose(nil); bytes.Equal(composed, b) && len(auto.Errors()) == 0 {
+			return auto, nil
+		}
+	}
+	return parsed, roundTripMismatch(composed, again)
+}
+
+// withoutAutoText returns a copy of the receiver without its text part, if the text part is the
+// one generated from its HTML part, or else nil.
+func (m *Message) withoutAutoText() *Message {
+	m.RLock()
+	auto := m.text != nil && m.html != nil && m.text.charset == m.html.charset &&
+		strings.ReplaceAll(string(m.text.bytes), "\r\n", "\n") == m.toText(m.html.bytes)
+	m.RUnlock()
+	if !auto {
+		return nil
+	}
+	c := NewMessage(m)
+	for i, p := range c.parts {
+		if p == c.text {
+			c.parts = append(c.parts[:i], c.parts[i+1:]...)
+			break
+		}
+	}
+	c.text = nil
+	return c
+}
+
+// roundTripMismatch returns the error describing the first difference between the `composed` and
+// the `again` composed messages.
+func roundTripMismatch(composed, again []byte) error {
+	i := 0
+	for i < len(composed) && i < len(again) && composed[i] == again[i] {
+		i++
+	}
+	line := bytes.Count(composed[:i], []byte("\n")) + 1
+	start := bytes.LastIndexByte(composed[:i], '\n') + 1
+	excerpt := func(b []byte) string {
+		end := bytes.IndexByte(b[start:], '\n')
+		if end < 0 {
+			return strconv.Quote(string(b[start:]))
+		}
+		return strconv.Quote(string(b[start : start+end]))
+	}
+	return errors.New("email: message not reproduced by Parse, at line " + strconv.Itoa(line) + ": " +
+		excerpt(composed) + " became " + excerpt(again))
+}
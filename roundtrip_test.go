@@ -0,0 +1,46 @@
+package email
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_RoundTrip(t *testing.T) {
+	newMsg := func() *Message {
+		return NewMessage(nil).From(&Address{"René", "rene@example.com"}).
+			To(&Address{"Ann", "ann@example.com"}, &Address{"", "bob@example.com"}).Cc(&Address{"", "cy@example.com"}).
+			Subject("Café menu").Date(time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))
+	}
+	ics := []byte("BEGIN:VCALENDAR\r\nMETHOD:REQUEST\r\nEND:VCALENDAR\r\n")
+	cases := []*Message{
+		newMsg().Text("Hello,\nthere"),
+		newMsg().Html("<p>Hello</p>"),
+		newMsg().Text("Hello").Html(`<p>Hello</p><img src="cid:logo">`, RelatedObject("logo", "image/png", []byte("PNG"))),
+		newMsg().HtmlTemplate(`<p>Hello, {{.}}</p><img src="cid:logo.png">`).Embed("logo.png", "image/png", []byte("PNG")),
+		newMsg().Text("Hello").AttachObject("menu.pdf", "application/pdf", []byte("%PDF")).
+			AttachObject("notes.txt", "text/plain; charset=utf-8", []byte("Notes\r\n")),
+		newMsg().Text("Hello").ReplyTo(&Address{"", "reply@example.com"}).InReplyTo("prev@example.com").
+			References("first@example.com", "prev@example.com").Priority(HighPriority).
+			RequestReadReceipt(&Address{"", "rene@example.com"}).SenderHeader(&Address{"", "agent@example.com"}).
+			Header("X-Campaign", "spring").Header("X-Note", "déjà vu"),
+		newMsg().Html("<p>Hello</p>").Language("fr", AutoDir),
+		newMsg().Html("<html><body><p>שלום</p></body></html>").Language("he", AutoDir),
+		newMsg().Text("Hello").Calendar(ics, "REQUEST"),
+		newMsg().Text("Café").Html("<p>Café</p>").Charset("ISO-8859-1"),
+		newMsg().Text("Café").PartCharset("ISO-8859-1").Html("<p>Café</p>"),
+		newMsg().Html(`<p>Hello</p>`).Amp(`<!doctype html><html ⚡4email><body>Hello</body></html>`),
+		newMsg().Text("Hello").AttachObject("inner.eml", "message/rfc822",
+			QuickMessage("Inner", "Hi").From(&Address{"", "x@example.com"}).Compose(nil)),
+	}
+	for i, msg := range cases {
+		parsed, err := msg.RoundTrip("Ann")
+		if err != nil {
+			t.Errorf("(*Message).RoundTrip [%d]: %v", i, err)
+			continue
+		}
+		if act, exp := parsed.Inspect(), msg.Inspect(); act.Subject != exp.Subject || *act.From != *exp.From ||
+			len(act.To) != len(exp.To) || len(act.Cc) != len(exp.Cc) || len(act.Attachments) != len(exp.Attachments) {
+			t.Errorf("(*Message).RoundTrip [%d]: got %+v want %+v", i, act, exp)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package email
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// Router selects the Sender for each message based on the domain of its From address or of its
+// recipients, so that an application can send on behalf of several brands or domains, each with
+// its own relay and setup.
+type Router struct {
+	mu       sync.RWMutex
+	byFrom   map[string]*Sender
+	byRcpt   map[string]*Sender
+	fallback *Sender
+}
+
+// NewRouter creates a Router using the `fallback` Sender for the messages matching no route. If
+// the `fallback` is nil, the default Sender is used for them.
+func NewRouter(fallback *Sender) *Router {
+	return &Router{byFrom: map[string]*Sender{}, byRcpt: map[string]*Sender{}, fallback: fallback}
+}
+
+// FromDomain routes the messages sent from addresses in the `domain` - or any of its subdomains -
+// through the Sender `s`.
+func (r *Router) FromDomain(domain string, s *Sender) *Router {
+	r.mu.Lock()
+	r.byFrom[normalizeDomain(domain)] = s
+	r.mu.Unlock()
+	return r
+}
+
+// RecipientDomain routes the messages sent to addresses in the `domain` - or any of its
+// subdomains - through the Sender `s`. The From domain routes take precedence over these.
+func (r *Router) RecipientDomain(domain string, s *Sender) *Router {
+	r.mu.Lock()
+	r.byRcpt[normalizeDomain(domain)] = s
+	r.mu.Unlock()
+	return r
+}
+
+// Route returns the Sender for `msg`: the one routed for the domain of its From address, if any;
+// otherwise, the one routed for the domain of its first recipient having a route; otherwise, the
+// fallback Sender of the receiver or the default Sender. It returns nil if there is none.
+func (r *Router) Route(msg *Message) *Sender {
+	msg.RLock()
+	from := msg.from
+	rcpts := make([]*Address, 0, len(msg.to)+len(msg.cc)+len(msg.bcc))
+	rcpts = append(append(append(rcpts, msg.to...), msg.cc...), msg.bcc...)
+	msg.RUnlock()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if from != nil {
+		if s := lookupDomain(r.byFrom, from.Domain()); s != nil {
+			return s
+		}
+	}
+	for _, a := range rcpts {
+		if a == nil {
+			continue
+		}
+		if s := lookupDomain(r.byRcpt, a.Domain()); s != nil {
+			return s
+		}
+	}
+	if r.fallback != nil {
+		return r.fallback
+	}
+	defaultSenderMutex.RLock()
+	defer defaultSenderMutex.RUnlock()
+	return defaultSender
+}
+
+// Send composes the provided message using the `data`, and sends it using the Sender selected by
+// Route.
+func (r *Router) Send(msg *Message, data interface{}) error {
+	if msg == nil {
+		return errors.New("Router.Send: no message to send")
+	}
+	s := r.Route(msg)
+	if s == nil {
+		return errors.New("Router.Send: no sender for the message")
+	}
+	return s.Send(msg, data)
+}
+
+// lookupDomain returns the Sender routed for `domain` or for the closest of its parent domains.
+func lookupDomain(routes map[string]*Sender, domain string) *Sender {
+	domain = normalizeDomain(domain)
+	for domain != "" {
+		if s := routes[domain]; s != nil {
+			return s
+		}
+		i := strings.IndexByte(domain, '.')
+		if i < 0 {
+			break
+		}
+		domain = domain[i+1:]
+	}
+	return nil
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
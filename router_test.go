@@ -0,0 +1,37 @@
+package email
+
+import "testing"
+
+func Test_RouterRoute(t *testing.T) {
+	brandA, _ := NewSender("smtp.brand-a.com", "user", "pass")
+	brandB, _ := NewSender("smtp.brand-b.net", "user", "pass")
+	intl, _ := NewSender("smtp.example.de", "user", "pass")
+	fallback, _ := NewSender("smtp.example.com", "user", "pass")
+	r := NewRouter(fallback).
+		FromDomain("brand-a.com", brandA).
+		FromDomain("Brand-B.net.", brandB).
+		RecipientDomain("example.de", intl)
+	cases := []struct {
+		msg *Message
+		exp *Sender
+	}{
+		{NewMessage(nil).From(&Address{"", "news@brand-a.com"}).To(&Address{"", "x@example.de"}), brandA},
+		{NewMessage(nil).From(&Address{"", "news@Brand-B.net"}), brandB},
+		{NewMessage(nil).From(&Address{"", "news@brand-c.com"}).To(&Address{"", "x@example.org"}).Cc(&Address{"", "y@Example.DE"}), intl},
+		{NewMessage(nil).From(&Address{"", "news@brand-c.com"}).To(&Address{"", "x@example.org"}), fallback},
+		{NewMessage(nil), fallback},
+	}
+	for i, c := range cases {
+		if act := r.Route(c.msg); act != c.exp {
+			t.Errorf("(*Router).Route [%d]: got sender %s, want %s", i, act.host, c.exp.host)
+		}
+	}
+	if s := lookupDomain(r.byRcpt, "shop.example.de"); s != intl {
+		t.Errorf("lookupDomain: got %v for a subdomain, want %v", s, intl)
+	}
+	defer func(s *Sender) { defaultSender = s }(defaultSender)
+	defaultSender = nil
+	if err := NewRouter(nil).Send(NewMessage(nil), nil); err == nil {
+		t.Error("(*Router).Send: expected an error without a sender")
+	}
+}
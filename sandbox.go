@@ -0,0 +1,36 @@
+package email
+
+import (
+	"strings"
+)
+
+// Sandbox sets the receiver to redirect all the messages to `addr`: every To, Cc and Bcc address
+// is replaced with it, both in the message headers and in the SMTP envelope, so that staging
+// environments never email real people, even if production data leaks in. If `origTo` is true, the
+// original recipients are listed in an "X-Original-To" header.
+//
+// A nil `addr` disables the sandbox mode.
+func (s *Sender) Sandbox(addr *Address, origTo bool) *Sender {
+	s.mu.Lock()
+	s.sandbox = addr.Clone()
+	s.origTo = origTo
+	s.mu.Unlock()
+	return s
+}
+
+// composeSandboxed composes a copy of `msg` addressed to `sandbox` only. The Message-ID, the
+// fingerprint and any errors of the copy are transferred to `msg`.
+func (s *Sender) composeSandboxed(msg *Message, data interface{}, sandbox *Address, origTo bool) []byte {
+	orig := msg.RecipientAddrs()
+	m := NewMessage(msg).To(sandbox).Cc().Bcc()
+	if origTo {
+		m.Header("X-Original-To", strings.Join(orig, ", "))
+	}
+	body := m.Compose(data)
+	errs := m.Errors()
+	msg.Lock()
+	msg.errors = append(msg.errors, errs...)
+	msg.id, msg.fingerprint = m.MessageID(), m.Fingerprint()
+	msg.Unlock()
+	return body
+}
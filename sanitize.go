@@ -0,0 +1,387 @@
+package email
+
+import (
+	"bytes"
+	"html"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// HTMLPolicy defines how HTML content is sanitized, to avoid shipping active content - typically
+// originating from user input - that mail clients would run or security gateways would quarantine.
+//
+// Sanitize keeps only the Elements in the allow-list, each with its allowed attributes and the
+// Attributes allowed for all elements; event handler attributes (on*) are never allowed. URL
+// attributes are dropped when their scheme is not among the URLSchemes; relative URLs are always
+// allowed. The content of the elements that are not allowed is preserved, except for the elements
+// whose content is not meant to be displayed, such as <script>, which are removed entirely.
+// Comments, processing instructions and style attributes or sheets containing script are removed.
+type HTMLPolicy struct {
+	// Elements maps the names of the allowed elements to the names of the attributes allowed,
+	// specifically, for each of them.
+	Elements map[string][]string
+	// Attributes lists the names of the attributes allowed for all the allowed elements.
+	Attributes []string
+	// URLSchemes lists the allowed URL schemes, such as "https" or "mailto".
+	URLSchemes []string
+}
+
+// DefaultHTMLPolicy allows the elements and attributes commonly used for formatting email.
+var DefaultHTMLPolicy = HTMLPolicy{
+	Elements: map[string][]string{
+		"a": {"href", "name", "target", "rel"}, "abbr": nil, "address": nil, "area": {"href", "alt", "shape", "coords"},
+		"b": nil, "bdi": nil, "bdo": nil, "big": nil, "blockquote": {"cite"}, "body": {"background"}, "br": nil,
+		"caption": nil, "center": nil, "cite": nil, "code": nil, "col": {"span"}, "colgroup": {"span"}, "dd": nil,
+		"del": {"cite"}, "dfn": nil, "div": nil, "dl": nil, "dt": nil, "em": nil, "font": {"face", "size"},
+		"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil, "head": nil, "hr": nil, "html": nil,
+		"i": nil, "img": {"src", "alt", "hspace", "vspace"}, "ins": {"cite"}, "kbd": nil, "li": {"value"},
+		"map": {"name"}, "meta": {"charset", "name", "content"}, "ol": {"start", "type"}, "p": nil, "pre": nil,
+		"q": {"cite"}, "s": nil, "samp": nil, "small": nil, "span": nil, "strike": nil, "strong": nil,
+		"style": {"type", "media"}, "sub": nil, "sup": nil, "table": {"cellpadding", "cellspacing", "background"},
+		"tbody": nil, "td": {"colspan", "rowspan", "nowrap", "background"}, "tfoot": nil,
+		"th": {"colspan", "rowspan", "nowrap", "background", "scope"}, "thead": nil, "title": nil, "tr": nil,
+		"tt": nil, "u": nil, "ul": {"type"}, "var": nil, "wbr": nil,
+	},
+	Attributes: []string{"align", "bgcolor", "border", "class", "color", "dir", "height", "id", "lang", "style",
+		"title", "valign", "width"},
+	URLSchemes: []string{"http", "https", "mailto", "tel", "cid"},
+}
+
+// hiddenElements are the elements whose content is removed along with them, when not allowed.
+var hiddenElements = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true, "applet": true,
+	"noscript": true, "noembed": true, "noframes": true, "template": true, "textarea": true, "title": true,
+	"xmp": true, "svg": true, "math": true,
+}
+
+// rawTextElements are the elements whose content is not parsed as HTML.
+var rawTextElements = map[string]bool{
+	"script": true, "style": true, "iframe": true, "noembed": true, "noframes": true, "noscript": true,
+	"textarea": true, "title": true, "xmp": true,
+}
+
+// urlAttributes are the attributes holding URLs.
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "background": true, "cite": true, "action": true, "formaction": true,
+	"longdesc": true, "poster": true, "lowsrc": true, "dynsrc": true, "xlink:href": true,
+}
+
+// Sanitize returns a version of the HTML content `src` that complies with the receiver.
+func (p *HTMLPolicy) Sanitize(src []byte) []byte {
+	var (
+		dst    bytes.Buffer
+		hidden string // the hidden element being skipped, if any
+		depth  int    // the nesting depth of the hidden element being skipped
+	)
+	dst.Grow(len(src))
+	for i := 0; i < len(src); {
+		lt := bytes.IndexByte(src[i:], '<')
+		if lt < 0 {
+			if hidden == "" {
+				dst.Write(src[i:])
+			}
+			break
+		}
+		if hidden == "" {
+			dst.Write(src[i : i+lt])
+		}
+		i += lt
+		rest := src[i:]
+		switch {
+		case bytes.HasPrefix(rest, []byte("<!--")):
+			end := bytes.Index(rest[4:], []byte("-->"))
+			if end < 0 {
+				return dst.Bytes()
+			}
+			i += 4 + end + 3
+			continue
+		case len(rest) > 1 && (rest[1] == '!' || rest[1] == '?'):
+			end := bytes.IndexByte(rest, '>')
+			if end < 0 {
+				return dst.Bytes()
+			}
+			if hidden == "" && len(rest) > 9 && strings.EqualFold(string(rest[2:9]), "doctype") {
+				dst.Write(rest[:end+1])
+			}
+			i += end + 1
+			continue
+		}
+		t, n := parseTag(rest)
+		if n == 0 {
+			// not a tag
+			if hidden == "" {
+				dst.WriteString("&lt;")
+			}
+			i++
+			continue
+		}
+		i += n
+		if hidden != "" {
+			if t.name == hidden {
+				if t.closing {
+					depth--
+				} else if !t.selfClosing {
+					depth++
+				}
+				if depth == 0 {
+					hidden = ""
+				}
+			}
+			continue
+		}
+		attrs, allowed := p.Elements[t.name]
+		if !allowed {
+			if hiddenElements[t.name] && !t.closing && !t.selfClosing {
+				if rawTextElements[t.name] {
+					i += rawTextEnd(src[i:], t.name)
+				} else {
+					hidden, depth = t.name, 1
+				}
+			}
+			continue
+		}
+		if t.closing {
+			dst.WriteString("</" + t.name + ">")
+			continue
+		}
+		dst.WriteString("<" + t.name)
+		for _, a := range t.attrs {
+			if !p.allowAttr(attrs, a.name, a.value) {
+				continue
+			}
+			dst.WriteString(" " + a.name + `="` + html.EscapeString(a.value) + `"`)
+		}
+		if t.selfClosing {
+			dst.WriteString(" /")
+		}
+		dst.WriteByte('>')
+		if rawTextElements[t.name] && !t.selfClosing {
+			n := rawTextEnd(src[i:], t.name)
+			content := src[i : i+n]
+			if t.name == "style" && scriptInCSS(string(content)) {
+				content = nil
+			}
+			dst.Write(content)
+			i += n
+		}
+	}
+	return dst.Bytes()
+}
+
+// allowAttr reports whether the attribute with the `name` and the `value` is allowed on an
+// element which specifically allows the `attrs`.
+func (p *HTMLPolicy) allowAttr(attrs []string, name, value string) bool {
+	if strings.HasPrefix(name, "on") || !containsName(attrs, name) && !containsName(p.Attributes, name) {
+		return false
+	}
+	switch {
+	case urlAttributes[name]:
+		return p.allowURL(value)
+	case name == "style":
+		return !scriptInCSS(value)
+	}
+	return true
+}
+
+// allowURL reports whether the scheme of `url`, if any, is allowed.
+func (p *HTMLPolicy) allowURL(url string) bool {
+	// browsers ignore whitespace and control characters in the scheme
+	url = strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, url)
+	colon := strings.IndexByte(url, ':')
+	if colon < 0 || strings.IndexAny(url[:colon], "/?#") >= 0 {
+		return true
+	}
+	return containsName(p.URLSchemes, strings.ToLower(url[:colon]))
+}
+
+// containsName reports whether `names` contains `name`, case-insensitively.
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// scriptInCSS reports whether the CSS code `css` may run script, or import remote style sheets.
+func scriptInCSS(css string) bool {
+	css = strings.ToLower(decodeCSS(html.UnescapeString(css)))
+	css = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '\\' {
+			return -1
+		}
+		return r
+	}, css)
+	for _, s := range []string{"expression(", "javascript:", "vbscript:", "behavior:", "-moz-binding", "@import"} {
+		if strings.Contains(css, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeCSS returns the CSS code `css` with its comments removed and its escapes decoded, so that
+// neither can hide a keyword - e.g. "@\\69mport" and "java/**/script:".
+func decodeCSS(css string) string {
+	var b strings.Builder
+	for i := 0; i < len(css); i++ {
+		switch c := css[i]; {
+		case c == '/' && i+1 < len(css) && css[i+1] == '*':
+			end := strings.Index(css[i+2:], "*/")
+			if end < 0 {
+				return b.String()
+			}
+			i += end + 3
+		case c == '\\' && i+1 < len(css):
+			j := i + 1
+			for j < len(css) && j < i+7 && isHexDigit(css[j]) {
+				j++
+			}
+			if j == i+1 {
+				// an escaped newline is removed, any other character stands for itself
+				if css[j] != '\n' {
+					b.WriteByte(css[j])
+				}
+				i = j
+				continue
+			}
+			r, _ := strconv.ParseUint(css[i+1:j], 16, 32)
+			if r == 0 || r > utf8.MaxRune || r >= 0xd800 && r <= 0xdfff {
+				r = utf8.RuneError
+			}
+			b.WriteRune(rune(r))
+			if j < len(css) && isHTMLSpace(css[j]) {
+				if css[j] == '\r' && j+1 < len(css) && css[j+1] == '\n' {
+					j++
+				}
+				j++
+			}
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// htmlTag is a tag parsed from HTML content.
+type htmlTag struct {
+	name        string
+	closing     bool
+	selfClosing bool
+	attrs       []htmlAttr
+}
+
+// htmlAttr is an attribute of an htmlTag, with its value unescaped.
+type htmlAttr struct {
+	name, value string
+}
+
+// parseTag parses the tag at the beginning of `src`, returning it along with its length in bytes;
+// the length is 0 if `src` does not begin with a tag.
+func parseTag(src []byte) (t htmlTag, n int) {
+	i := 1
+	if i < len(src) && src[i] == '/' {
+		t.closing = true
+		i++
+	}
+	if i >= len(src) || !isASCIILetter(src[i]) {
+		return t, 0
+	}
+	start := i
+	for i < len(src) && !isHTMLSpace(src[i]) && src[i] != '/' && src[i] != '>' {
+		i++
+	}
+	t.name = strings.ToLower(string(src[start:i]))
+	for i < len(src) {
+		switch c := src[i]; {
+		case c == '>':
+			return t, i + 1
+		case c == '/':
+			t.selfClosing = i+1 < len(src) && src[i+1] == '>'
+			i++
+		case isHTMLSpace(c):
+			i++
+		default:
+			start := i
+			for i < len(src) && !isHTMLSpace(src[i]) && src[i] != '/' && src[i] != '>' && src[i] != '=' {
+				i++
+			}
+			a := htmlAttr{name: strings.ToLower(string(src[start:i]))}
+			for i < len(src) && isHTMLSpace(src[i]) {
+				i++
+			}
+			if i < len(src) && src[i] == '=' {
+				i++
+				for i < len(src) && isHTMLSpace(src[i]) {
+					i++
+				}
+				if i < len(src) && (src[i] == '"' || src[i] == '\'') {
+					end := bytes.IndexByte(src[i+1:], src[i])
+					if end < 0 {
+						return t, 0
+					}
+					a.value = string(src[i+1 : i+1+end])
+					i += end + 2
+				} else {
+					start := i
+					for i < len(src) && !isHTMLSpace(src[i]) && src[i] != '>' {
+						i++
+					}
+					a.value = string(src[start:i])
+				}
+			}
+			if !t.closing && a.name != "" {
+				a.value = html.UnescapeString(a.value)
+				t.attrs = append(t.attrs, a)
+			}
+		}
+	}
+	return t, 0
+}
+
+// rawTextEnd returns the length of the raw text content of an element with the `name`, at the
+// beginning of `src`.
+func rawTextEnd(src []byte, name string) int {
+	for i := 0; ; {
+		lt := bytes.Index(src[i:], []byte("</"))
+		if lt < 0 {
+			return len(src)
+		}
+		i += lt
+		if end := i + 2 + len(name); end <= len(src) && strings.EqualFold(string(src[i+2:end]), name) &&
+			(end == len(src) || isHTMLSpace(src[end]) || src[end] == '>' || src[end] == '/') {
+			return i
+		}
+		i += 2
+	}
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// SanitizeHTML sets the policy used to sanitize the HTML content of the message - including the
+// output of HTML templates - when composing it, e.g. &DefaultHTMLPolicy. If `p` is nil, which is
+// the default, the HTML content is used as is.
+func (m *Message) SanitizeHTML(p *HTMLPolicy) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.htmlPolicy = p
+	return m
+}
@@ -0,0 +1,61 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_HTMLPolicySanitize(t *testing.T) {
+	cases := []struct {
+		src string
+		exp string
+	}{
+		{`<p>Hello <b>world</b></p>`, `<p>Hello <b>world</b></p>`},
+		{`<p>a<script>alert("x")</script>b</p>`, `<p>ab</p>`},
+		{`<SCRIPT type="text/javascript">x</SCRIPT >ok`, `ok`},
+		{`<a href="javascript:alert(1)" onclick="x()">link</a>`, `<a>link</a>`},
+		{`<a href=" jav&#x09;ascript:alert(1)">link</a>`, `<a>link</a>`},
+		{`<a href="https://example.com/?a=1&amp;b=2" target=_blank>link</a>`,
+			`<a href="https://example.com/?a=1&amp;b=2" target="_blank">link</a>`},
+		{`<a href="/path:x">rel</a><img src="cid:logo" alt='Logo'/>`, `<a href="/path:x">rel</a><img src="cid:logo" alt="Logo" />`},
+		{`<img src=x onerror=alert(1)>`, `<img src="x">`},
+		{`<div style="width: expression(alert(1))">x</div>`, `<div>x</div>`},
+		{`<style>p { color: red }</style><style>@import "x.css";</style>`, `<style>p { color: red }</style><style></style>`},
+		{`<style>@\69mport url(http://evil/x.css);</style>`, `<style></style>`},
+		{`<style>@\000069 mport url(http://evil/x.css);</style>`, `<style></style>`},
+		{`<style>@im/* x */port url(http://evil/x.css);</style>`, `<style></style>`},
+		{`<div style="background: url(java\73 cript:alert(1))">x</div>`, `<div>x</div>`},
+		{`<div style="background: url(java/**/script:alert(1))">x</div>`, `<div>x</div>`},
+		{`<div style="width: e\78pression(alert(1))">x</div>`, `<div>x</div>`},
+		{`<div style="content: '\2014'; color: red /* ok */">x</div>`, `<div style="content: &#39;\2014&#39;; color: red /* ok */">x</div>`},
+		{`<iframe src="https://example.com"><b>x</b></iframe>y`, `y`},
+		{`<object><param name="a"><embed></object>y`, `y`},
+		{`<!DOCTYPE html><!-- hidden --><blink>text</blink>`, `<!DOCTYPE html>text`},
+		{`1 < 2 and <3`, `1 &lt; 2 and &lt;3`},
+		{`<meta http-equiv="refresh" content="0;url=https://example.com">`, `<meta content="0;url=https://example.com">`},
+	}
+	for i, c := range cases {
+		act := string(DefaultHTMLPolicy.Sanitize([]byte(c.src)))
+		if act != c.exp {
+			t.Errorf("(*HTMLPolicy).Sanitize [%d]: got %q want %q", i, act, c.exp)
+		}
+	}
+}
+
+func Test_MessageSanitizeHTML(t *testing.T) {
+	msg := NewMessage(nil).From(&Address{"", "test@example.com"}).To(&Address{"", "to@example.com"}).
+		Subject("test").HtmlTemplate(`<p onclick="x()">{{.}}</p>`).SanitizeHTML(&DefaultHTMLPolicy)
+	act := string(msg.Compose(`<script>alert(1)</script>`))
+	if errs := msg.Errors(); len(errs) > 0 {
+		t.Fatalf("(*Message).SanitizeHTML: unexpected errors: %v", errs)
+	}
+	if strings.Contains(act, "onclick") || !strings.Contains(act, "&lt;script&gt;") {
+		t.Errorf("(*Message).SanitizeHTML: got %q", act)
+	}
+	msg = NewMessage(nil).From(&Address{"", "test@example.com"}).To(&Address{"", "to@example.com"}).
+		Subject("test").Html(`<p>a<script>alert(1)</script>b</p>`).SanitizeHTML(&DefaultHTMLPolicy)
+	act = string(msg.Compose(nil))
+	if strings.Contains(act, "script") {
+		t.Errorf("(*Message).SanitizeHTML: got %q", act)
+	}
+}
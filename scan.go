@@ -0,0 +1,97 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+)
+
+// ScanFunc inspects the content of an attachment with the `name` and content type `ctype` - e.g.
+// with an antivirus or data loss prevention engine - returning a non-nil error to veto it.
+type ScanFunc func(ctx context.Context, name, ctype string, content io.Reader) error
+
+// ScanAttachments sets the function scanning the attachments of the message when it is prepared or
+// composed; a nil `fn` disables the scanning. The content of each attachment is scanned once for the
+// message and its copies made with NewMessage or Clone, as long as it does not change, unless
+// vetoed: a vetoed attachment is recorded as an error wrapping an *ErrAttachmentRejected each time,
+// so that the message cannot be composed until it is removed or accepted. The lazy attachments are
+// scanned by reading their files, and scanned again if their size or modification time changes.
+func (m *Message) ScanAttachments(fn ScanFunc) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.scanner = nil
+	if fn != nil {
+		m.scanner = &fn
+	}
+	return m
+}
+
+// scanRecord identifies the content of an attachment accepted by a scanner.
+type scanRecord struct {
+	scanner *ScanFunc
+	sum     uint64
+	size    int64
+	modTime time.Time
+}
+
+// scanAttachments scans the attachments of the receiver that were not scanned yet with its scanner,
+// if any, recording the vetoed ones as errors and returning the first of them; if `ctx` is done,
+// its error is returned. The caller must hold the lock on the receiver.
+func (m *Message) scanAttachments(ctx context.Context) error {
+	if m.scanner == nil {
+		return nil
+	}
+	var first error
+	for _, a := range m.attachments {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := m.scanAttachment(ctx, a); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// scanAttachment scans the attachment `a` with the scanner of the receiver, unless its content was
+// already accepted by it, returning the error recorded if the content cannot be read or is vetoed.
+// The caller must hold the lock on the receiver.
+func (m *Message) scanAttachment(ctx context.Context, a *attachment) error {
+	rec := scanRecord{scanner: m.scanner, sum: a.sum(), size: int64(len(a.data))}
+	if a.data == nil {
+		rec.size, rec.modTime = a.size, a.modTime
+	}
+	c := a.encoded
+	if c != nil {
+		c.Lock()
+		defer c.Unlock()
+		if c.scanned == rec {
+			return nil
+		}
+	}
+	var (
+		content io.Reader = bytes.NewReader(a.data)
+		closer  io.Closer
+	)
+	if a.data == nil && a.fileName != "" {
+		f, err := openFile(m.fsys, m.root, a.fileName)
+		if err != nil {
+			m.failAttachment(FileError, a.name, &ErrFileRead{a.fileName, err})
+			return m.errors[len(m.errors)-1]
+		}
+		content, closer = f, f
+	}
+	err := (*m.scanner)(ctx, a.name, a.ctype, content)
+	if closer != nil {
+		closer.Close()
+	}
+	if err != nil {
+		m.failAttachment(ContentError, a.name, &ErrAttachmentRejected{a.name, err})
+		return m.errors[len(m.errors)-1]
+	}
+	if c != nil {
+		c.scanned = rec
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ScanAttachments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "email-scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "infected.txt")
+	if err = ioutil.WriteFile(file, []byte("X5O!P%@AP EICAR"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	errVirus := errors.New("virus found")
+	var scanned []string
+	scan := func(ctx context.Context, name, ctype string, content io.Reader) error {
+		scanned = append(scanned, name)
+		b, err := ioutil.ReadAll(content)
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(b, []byte("EICAR")) {
+			return errVirus
+		}
+		return nil
+	}
+
+	msg := QuickMessage("test", "body").From(&Address{"", "ann@example.com"}).
+		ScanAttachments(scan).
+		AttachObject("clean.txt", "", []byte("clean"))
+	for i := 0; i < 2; i++ {
+		if len(msg.Compose(nil)) == 0 {
+			t.Fatalf("(*Message).ScanAttachments [%d]: got %v", i, msg.Errors())
+		}
+	}
+	if len(scanned) != 1 || scanned[0] != "clean.txt" {
+		t.Errorf("(*Message).ScanAttachments: scanned %v want [clean.txt]", scanned)
+	}
+
+	for i, lazy := range []bool{false, true} {
+		scanned = nil
+		msg = QuickMessage("test", "body").From(&Address{"", "ann@example.com"}).
+			ScanAttachments(scan).LazyAttachments(lazy).
+			AttachObject("clean.txt", "", []byte("clean")).
+			Attach(file)
+		err := msg.PrepareContext(context.Background())
+		var re *ErrAttachmentRejected
+		if !errors.As(err, &re) || re.Name != "infected.txt" || !errors.Is(err, errVirus) {
+			t.Errorf("(*Message).ScanAttachments [%d]: got %v want *ErrAttachmentRejected", i, err)
+		}
+		if msg.Errors(); len(msg.Compose(nil)) != 0 {
+			t.Errorf("(*Message).ScanAttachments [%d]: composed a message with a rejected attachment", i)
+		}
+		if errs := msg.Errors(); len(errs) != 1 || errs[0].(*MessageError).Kind != ContentError {
+			t.Errorf("(*Message).ScanAttachments [%d]: got %v want 1 content error", i, errs)
+		}
+		if want := []string{"clean.txt", "infected.txt", "infected.txt"}; len(scanned) != len(want) {
+			t.Errorf("(*Message).ScanAttachments [%d]: scanned %v want %v", i, scanned, want)
+		}
+	}
+}
+
+func Test_ScanAttachmentsCopies(t *testing.T) {
+	var scanned int
+	scan := func(ctx context.Context, name, ctype string, content io.Reader) error {
+		scanned++
+		return nil
+	}
+	msg := QuickMessage("test", "body").From(&Address{"", "ann@example.com"}).
+		ScanAttachments(scan).
+		AttachObject("report.txt", "", []byte("report"))
+	for i, m := range []*Message{msg, NewMessage(msg), msg.Clone(), NewMessage(msg).To(&Address{"", "bob@example.com"})} {
+		if len(m.Compose(nil)) == 0 {
+			t.Fatalf("(*Message).ScanAttachments [%d]: got %v", i, m.Errors())
+		}
+	}
+	if scanned != 1 {
+		t.Errorf("(*Message).ScanAttachments: scanned %d times want 1", scanned)
+	}
+
+	if msg.ScanAttachments(scan).Compose(nil); scanned != 2 {
+		t.Errorf("(*Message).ScanAttachments: scanned %d times after setting the scanner want 2", scanned)
+	}
+	if msg.DeepClone().Compose(nil); scanned != 3 {
+		t.Errorf("(*Message).DeepClone: scanned %d times want 3", scanned)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/smtp"
 	"strconv"
 	"sync"
+	"time"
 )
 
 // Sender represents the SMTP credentials along with the (optional) Address of a sender.
@@ -14,11 +15,52 @@ type Sender struct {
 	username string
 	password string
 	address  *Address
+	mu       sync.RWMutex
+	metrics  MetricsCollector
+	logger   Logger
+	mws      []Middleware
+	dryRun   DryRunMode
+	sandbox  *Address
+	origTo   bool
+
+	individual   bool
+	individualTo bool
+
+	composeMws []ComposeMiddleware
+
+	dupStore    FingerprintStore
+	dupWindow   time.Duration
+	onDuplicate func(msg *Message, addr string, last time.Time) error
+
+	events EventSink
+	ids    IDSource
+	clock  func() time.Time
+
+	boundaries BoundaryGenerator
+
+	maxSize           int64
+	serverMaxSize     int64
+	maxAttachmentSize int64
+
+	bulkConcurrency int
+	bulkRate        float64
+
+	workers   int
+	queueSize int
+	queue     chan delivery
+	startOnce sync.Once
+	pending   int
+	drained   chan struct{}
+	closed    bool
+	stopped   bool
 }
 
 var (
 	defaultSender      *Sender
 	defaultSenderMutex sync.RWMutex
+
+	sendMail      = smtp.SendMail
+	negotiateMail = negotiate
 )
 
 // NewSender creates a new Sender from the provided information.
@@ -63,7 +105,23 @@ func NewSender(host, user, pass string, addr ...string) (*Sender, error) {
 	if err != nil {
 		return nil, errors.New("NewSender: " + err.Error())
 	}
-	return &Sender{host, port, user, pass, address}, nil
+	return &Sender{
+		host:     host,
+		port:     port,
+		username: user,
+		password: pass,
+		address:  address,
+	}, nil
+}
+
+// addr returns the "host:port" address of the SMTP server.
+func (s *Sender) addr() string {
+	return s.host + ":" + strconv.Itoa(s.port)
+}
+
+// auth returns the authentication mechanism for the SMTP server.
+func (s *Sender) auth() smtp.Auth {
+	return smtp.PlainAuth("", s.username, s.password, s.host)
 }
 
 // SetDefault sets the receiver as the default sender.
@@ -74,28 +132,131 @@ func (s *Sender) SetDefault() *Sender {
 	return s
 }
 
-// Send composes the provided message using the `data`, and sends it.
+// Send composes the provided message using the `data`, and queues it for delivery by the workers
+// of the receiver - see Workers. Use Flush or Close to wait for the queued messages to be
+// delivered, e.g. before the program exits.
+//
+// The middleware installed with Use, if any, is invoked around the actual composition and sending.
 func (s *Sender) Send(msg *Message, data interface{}) error {
 	if msg == nil {
 		return errors.New("Sender.Send: no message to send")
 	}
-	body := msg.setSender(s).Compose(data)
+	return s.chain(s.send)(msg, data)
+}
+
+// send composes `msg` using the `data`, and queues it for asynchronous delivery.
+func (s *Sender) send(msg *Message, data interface{}) error {
+	ds, err := s.deliveries(msg, data)
+	if err != nil {
+		return err
+	}
+	for _, d := range ds {
+		if err = s.enqueue(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendSync composes `msg` using the `data`, and delivers it, waiting for the outcome.
+func (s *Sender) sendSync(msg *Message, data interface{}) error {
+	ds, err := s.deliveries(msg, data)
+	if err != nil {
+		return err
+	}
+	if len(ds) == 1 {
+		return s.deliver(ds[0], 1)
+	}
+	var errs RecipientErrors
+	for _, d := range ds {
+		if err = s.deliver(d, 1); err != nil {
+			errs = append(errs, &RecipientError{d.to[0], err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// compose composes `msg` using the `data`, notifying the metrics collector and the logger, if any,
+// about the outcome. Along with the body, it returns the envelope addresses for the message.
+func (s *Sender) compose(msg *Message, data interface{}) (body []byte, from string, to []string, err error) {
+	if msg == nil {
+		return nil, "", nil, errors.New("Sender.Send: no message to send")
+	}
+	s.mu.RLock()
+	mc, l, sandbox, origTo := s.metrics, s.logger, s.sandbox, s.origTo
+	s.mu.RUnlock()
+	start := time.Now()
+	msg.setSender(s)
+	if sandbox != nil {
+		body = s.composeSandboxed(msg, data, sandbox, origTo)
+		to = []string{sandbox.ASCII()}
+	} else {
+		body = msg.Compose(data)
+		to = msg.RecipientAddrs()
+	}
+	from = msg.FromAddr()
 	if msg.HasErrors() {
-		return errors.New("Sender.Send: failed to compose message")
-	}
-	go smtp.SendMail(
-		s.host+":"+strconv.Itoa(s.port),
-		smtp.PlainAuth(
-			"",
-			s.username,
-			s.password,
-			s.host,
-		),
-		msg.FromAddr(),
-		msg.RecipientAddrs(),
-		body,
+		err = errors.New("Sender.Send: failed to compose message")
+		if mc != nil {
+			mc.OnFailed(msg, err, time.Since(start))
+		}
+		if l != nil {
+			l.Error("email: compose failed", "from", from, "error", err)
+		}
+		return nil, "", nil, err
+	}
+	if err = s.checkSize(len(body)); err != nil {
+		if mc != nil {
+			mc.OnFailed(msg, err, time.Since(start))
+		}
+		if l != nil {
+			l.Error("email: message too large", "from", from, "size", len(body), "error", err)
+		}
+		return nil, "", nil, err
+	}
+	if err = s.checkDuplicates(msg); err != nil {
+		return nil, "", nil, err
+	}
+	if mc != nil {
+		mc.OnComposed(msg, len(body), time.Since(start))
+	}
+	return body, from, to, nil
+}
+
+// deliver transmits the composed message `d` to the SMTP server, notifying the metrics collector,
+// the logger and the event sink, if any, about the outcome.
+func (s *Sender) deliver(d delivery, attempt int) error {
+	s.mu.RLock()
+	mc, l, dryRun, sink := s.metrics, s.logger, s.dryRun, s.events
+	s.mu.RUnlock()
+	msg, from, to, body := d.msg, d.from, d.to, d.body
+	start := time.Now()
+	var (
+		addr = s.addr()
+		auth = s.auth()
+		err  error
 	)
-	return nil
+	switch dryRun {
+	case NoDryRun:
+		err = sendMail(addr, auth, from, to, body)
+	case DryRunNegotiate:
+		err = negotiateMail(addr, auth, from, to)
+	}
+	if mc != nil {
+		if err != nil {
+			mc.OnFailed(msg, err, time.Since(start))
+		} else if dryRun == NoDryRun {
+			mc.OnSent(msg, len(body), time.Since(start))
+		}
+	}
+	logDelivery(l, d, attempt, dryRun != NoDryRun, err)
+	if sink != nil && dryRun == NoDryRun {
+		s.emit(sink, l, d, err)
+	}
+	return err
 }
 
 // Send composes the provided message using the `data`, and sends it using the default Sender.
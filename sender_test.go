@@ -0,0 +1,297 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type metricsEvent struct {
+	kind string
+	size int
+	err  error
+}
+
+type testCollector chan metricsEvent
+
+func (c testCollector) OnComposed(msg *Message, size int, elapsed time.Duration) {
+	c <- metricsEvent{kind: "composed", size: size}
+}
+
+func (c testCollector) OnSent(msg *Message, size int, elapsed time.Duration) {
+	c <- metricsEvent{kind: "sent", size: size}
+}
+
+func (c testCollector) OnFailed(msg *Message, err error, elapsed time.Duration) {
+	c <- metricsEvent{kind: "failed", err: err}
+}
+
+func forceSendMail(err error) {
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		return err
+	}
+}
+
+func Test_SenderMetrics(t *testing.T) {
+	defer func(f func(string, smtp.Auth, string, []string, []byte) error) { sendMail = f }(sendMail)
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	mc := make(testCollector, 4)
+	s.Metrics(mc)
+
+	forceSendMail(nil)
+	if err := s.Send(QuickMessage("test", "body"), nil); err != nil {
+		t.Fatalf("(*Sender).Send: unexpected error: %v", err)
+	}
+	if ev := <-mc; ev.kind != "composed" || ev.size == 0 {
+		t.Errorf("(*Sender).Send: got %+v, want a non-empty composed event", ev)
+	}
+	if ev := <-mc; ev.kind != "sent" || ev.size == 0 {
+		t.Errorf("(*Sender).Send: got %+v, want a non-empty sent event", ev)
+	}
+
+	errSMTP := errors.New("550 rejected")
+	forceSendMail(errSMTP)
+	s.Send(QuickMessage("test", "body"), nil)
+	<-mc
+	if ev := <-mc; ev.kind != "failed" || ev.err != errSMTP {
+		t.Errorf("(*Sender).Send: got %+v, want a failed event with %v", ev, errSMTP)
+	}
+
+	if err := s.Send(NewMessage(nil), nil); err == nil {
+		t.Error("(*Sender).Send: expected a compose error for a message without parts")
+	}
+	if ev := <-mc; ev.kind != "failed" || ev.err == nil {
+		t.Errorf("(*Sender).Send: got %+v, want a failed event", ev)
+	}
+}
+
+type logRecord struct {
+	level, msg string
+	fields     map[string]interface{}
+}
+
+type testLogger chan logRecord
+
+func (l testLogger) record(level, msg string, args []interface{}) {
+	fields := map[string]interface{}{}
+	for i := 0; i+1 < len(args); i += 2 {
+		fields[args[i].(string)] = args[i+1]
+	}
+	l <- logRecord{level, msg, fields}
+}
+
+func (l testLogger) Info(msg string, args ...interface{})  { l.record("info", msg, args) }
+func (l testLogger) Error(msg string, args ...interface{}) { l.record("error", msg, args) }
+
+func Test_SenderLogger(t *testing.T) {
+	defer func(f func(string, smtp.Auth, string, []string, []byte) error) { sendMail = f }(sendMail)
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	l := make(testLogger, 1)
+	s.Logger(l)
+
+	forceSendMail(nil)
+	msg := QuickMessage("test", "body").To(&Address{"", "a@example.com"}, &Address{"", "b@example.com"})
+	s.Send(msg, nil)
+	rec := <-l
+	if rec.level != "info" || rec.fields["message_id"] != msg.MessageID() || rec.fields["from"] != "test@example.com" ||
+		rec.fields["recipients"] != 2 || rec.fields["attempt"] != 1 {
+		t.Errorf("(*Sender).Send: got log record %+v", rec)
+	}
+
+	forceSendMail(&textproto.Error{Code: 550, Msg: "mailbox unavailable"})
+	s.Send(msg, nil)
+	rec = <-l
+	if rec.level != "error" || rec.fields["smtp_code"] != 550 || rec.fields["error"] == nil {
+		t.Errorf("(*Sender).Send: got log record %+v", rec)
+	}
+}
+
+func Test_SenderUse(t *testing.T) {
+	defer func(f func(string, smtp.Auth, string, []string, []byte) error) { sendMail = f }(sendMail)
+	forceSendMail(nil)
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	defer s.Flush(context.Background())
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next SendFunc) SendFunc {
+			return func(msg *Message, data interface{}) error {
+				calls = append(calls, name+">")
+				err := next(msg, data)
+				calls = append(calls, "<"+name)
+				return err
+			}
+		}
+	}
+	errSuppressed := errors.New("suppressed")
+	s.Use(trace("a"), trace("b")).Use(func(next SendFunc) SendFunc {
+		return func(msg *Message, data interface{}) error {
+			if data == "suppress" {
+				return errSuppressed
+			}
+			return next(msg, data)
+		}
+	})
+
+	if err := s.Send(QuickMessage("test", "body"), nil); err != nil {
+		t.Errorf("(*Sender).Send: unexpected error: %v", err)
+	}
+	if exp := []string{"a>", "b>", "<b", "<a"}; !reflect.DeepEqual(calls, exp) {
+		t.Errorf("(*Sender).Use: got calls %v, want %v", calls, exp)
+	}
+	if err := s.Send(QuickMessage("test", "body"), "suppress"); err != errSuppressed {
+		t.Errorf("(*Sender).Send: got error %v, want %v", err, errSuppressed)
+	}
+}
+
+func Test_SenderUseDryRun(t *testing.T) {
+	defer func(f func(string, smtp.Auth, string, []string, []byte) error) { sendMail = f }(sendMail)
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		t.Errorf("(*Sender).Send: DryRunCompose should not send the message")
+		return nil
+	}
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	s.DryRun(DryRunCompose)
+	var calls []string
+	s.Use(func(next SendFunc) SendFunc {
+		return func(msg *Message, data interface{}) error {
+			calls = append(calls, "a>")
+			err := next(msg, data)
+			calls = append(calls, "<a")
+			return err
+		}
+	})
+	if err := s.Send(QuickMessage("test", "body"), nil); err != nil {
+		t.Errorf("(*Sender).Send: unexpected error: %v", err)
+	}
+	s.Flush(context.Background())
+	if exp := []string{"a>", "<a"}; !reflect.DeepEqual(calls, exp) {
+		t.Errorf("(*Sender).Use: got calls %v, want %v", calls, exp)
+	}
+}
+
+func Test_SenderSandbox(t *testing.T) {
+	defer func(f func(string, smtp.Auth, string, []string, []byte) error) { sendMail = f }(sendMail)
+	type delivery struct {
+		to   []string
+		body []byte
+	}
+	deliveries := make(chan delivery, 1)
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		deliveries <- delivery{to, msg}
+		return nil
+	}
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	s.Sandbox(&Address{"Sandbox", "sandbox@example.com"}, true)
+	msg := QuickMessage("test", "body").To(&Address{"", "a@example.com"}).
+		Cc(&Address{"", "b@example.com"}).Bcc(&Address{"", "c@example.com"})
+	if err := s.Send(msg, nil); err != nil {
+		t.Fatalf("(*Sender).Send: unexpected error: %v", err)
+	}
+	d := <-deliveries
+	if !reflect.DeepEqual(d.to, []string{"sandbox@example.com"}) {
+		t.Errorf("(*Sender).Send: got envelope recipients %v, want only the sandbox", d.to)
+	}
+	body := string(d.body)
+	if !strings.Contains(body, "\r\nTo: \"Sandbox\" <sandbox@example.com>\r\n") || strings.Contains(body, "\r\nCc:") ||
+		!strings.Contains(body, "\r\nX-Original-To: a@example.com, b@example.com, c@example.com\r\n") {
+		t.Errorf("(*Sender).Send: got sandboxed message\n%s", body)
+	}
+	if id := msg.MessageID(); id == "" || !strings.Contains(body, "Message-ID: "+id) {
+		t.Errorf("(*Sender).Send: got Message-ID %q", id)
+	}
+	if to := msg.RecipientAddrs(); len(to) != 3 {
+		t.Errorf("(*Sender).Send: the original message should not be altered, got recipients %v", to)
+	}
+
+	s.Sandbox(&Address{"", "sandbox@bücher.example"}, false)
+	if err := s.Send(msg, nil); err != nil {
+		t.Fatalf("(*Sender).Send: unexpected error: %v", err)
+	}
+	if d = <-deliveries; !reflect.DeepEqual(d.to, []string{"sandbox@xn--bcher-kva.example"}) {
+		t.Errorf("(*Sender).Send: got envelope recipients %v, want the ASCII sandbox address", d.to)
+	}
+}
+
+func Test_SenderWorkers(t *testing.T) {
+	defer func(f func(string, smtp.Auth, string, []string, []byte) error) { sendMail = f }(sendMail)
+	var (
+		mu                sync.Mutex
+		active, maxActive int
+		done              = make(chan struct{}, 6)
+	)
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		mu.Lock()
+		if active++; active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		done <- struct{}{}
+		return nil
+	}
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	s.Workers(2, 1)
+	for i := 0; i < 6; i++ {
+		if err := s.Send(QuickMessage("test", "body"), nil); err != nil {
+			t.Fatalf("(*Sender).Send: unexpected error: %v", err)
+		}
+	}
+	for i := 0; i < 6; i++ {
+		<-done
+	}
+	if maxActive != 2 {
+		t.Errorf("(*Sender).Workers: got %d concurrent deliveries, want 2", maxActive)
+	}
+}
+
+func Test_SenderClose(t *testing.T) {
+	defer func(f func(string, smtp.Auth, string, []string, []byte) error) { sendMail = f }(sendMail)
+	var (
+		mu        sync.Mutex
+		delivered int
+		release   = make(chan struct{})
+	)
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		<-release
+		mu.Lock()
+		delivered++
+		mu.Unlock()
+		return nil
+	}
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	if err := s.Flush(context.Background()); err != nil {
+		t.Errorf("(*Sender).Flush: unexpected error with nothing sent: %v", err)
+	}
+	s.Workers(2, 5)
+	for i := 0; i < 5; i++ {
+		if err := s.Send(QuickMessage("test", "body"), nil); err != nil {
+			t.Fatalf("(*Sender).Send: unexpected error: %v", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("(*Sender).Close: got error %v, want a timeout", err)
+	}
+	if err := s.Send(QuickMessage("test", "body"), nil); err == nil {
+		t.Error("(*Sender).Send: expected an error after Close")
+	}
+	close(release)
+	if err := s.Close(context.Background()); err != nil {
+		t.Errorf("(*Sender).Close: unexpected error: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if delivered != 5 {
+		t.Errorf("(*Sender).Close: got %d messages delivered, want 5", delivered)
+	}
+}
@@ -0,0 +1,214 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+)
+
+// ErrMessageTooLarge is wrapped by the errors returned for sending the messages that exceed the size
+// limit of the Sender - see MaxMessageSize.
+var ErrMessageTooLarge = errors.New("message too large")
+
+// ErrAttachmentTooLarge is wrapped by the errors recorded for the attachments that exceed their size
+// limit - see SizeLimits.
+var ErrAttachmentTooLarge = errors.New("attachment too large")
+
+// ErrSizeLimit is recorded when composing a message that exceeds a size limit set with SizeLimits,
+// or one of its attachments. It wraps ErrMessageTooLarge or ErrAttachmentTooLarge, respectively.
+type ErrSizeLimit struct {
+	// Attachment is the name of the attachment exceeding the limit, or empty for the message.
+	Attachment string
+	// Size is the size of the attachment or the message, and Limit the limit, in bytes.
+	Size, Limit int64
+}
+
+func (e *ErrSizeLimit) Error() string {
+	if e.Attachment != "" {
+		return fmt.Sprintf("%v: %s: %d bytes, the limit is %d", ErrAttachmentTooLarge, e.Attachment, e.Size, e.Limit)
+	}
+	return fmt.Sprintf("%v: %d bytes, the limit is %d", ErrMessageTooLarge, e.Size, e.Limit)
+}
+
+// Unwrap returns ErrAttachmentTooLarge or ErrMessageTooLarge.
+func (e *ErrSizeLimit) Unwrap() error {
+	if e.Attachment != "" {
+		return ErrAttachmentTooLarge
+	}
+	return ErrMessageTooLarge
+}
+
+// SizeLimits sets the maximum size of each attachment of the message, and of the whole composed
+// message, in bytes; zero means no limit - or, for the attachments, the limit set on the Sender of
+// the message with MaxAttachmentSize. The messages sent by a Sender are also subject to its
+// MaxMessageSize. The limits are checked by PrepareContext and when composing
+// the message, which records an *ErrSizeLimit for each attachment and for the message exceeding its
+// limit, so that the oversize messages are caught before the SMTP server rejects them.
+//
+// The size of the attachments is that of their content, before encoding. When composing the
+// message with ComposeTo with LazyAttachments, the size of the whole message is not checked.
+func (m *Message) SizeLimits(attachment, message int64) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.maxAttachmentSize, m.maxMessageSize = attachment, message
+	return m
+}
+
+// sizeLimits returns the size limits of the receiver, the attachment limit defaulting to that of the
+// `sender`, if not nil. The message limit of the sender is checked when sending.
+func (m *Message) sizeLimits(sender *Sender) (attachment, message int64) {
+	attachment, message = m.maxAttachmentSize, m.maxMessageSize
+	if sender != nil && attachment == 0 {
+		sender.mu.RLock()
+		attachment = sender.maxAttachmentSize
+		sender.mu.RUnlock()
+	}
+	return attachment, message
+}
+
+// checkAttachmentSizes records an *ErrSizeLimit for each attachment of the receiver exceeding the
+// size limit of the receiver or of the `sender`, returning the first one. The caller must hold the
+// lock on the receiver.
+func (m *Message) checkAttachmentSizes(sender *Sender) error {
+	limit, _ := m.sizeLimits(sender)
+	if limit <= 0 {
+		return nil
+	}
+	var first error
+	for _, a := range m.attachments {
+		size := int64(len(a.data))
+		if a.data == nil {
+			size = a.size
+		}
+		if size > limit {
+			name := a.name
+			if name == "" {
+				name = filepath.Base(a.fileName)
+			}
+			err := &ErrSizeLimit{name, size, limit}
+			m.failAttachment(ContentError, name, err)
+			if first == nil {
+				first = m.errors[len(m.errors)-1]
+			}
+		}
+	}
+	return first
+}
+
+// sizeOverhead is the estimated size of the headers of a message, and of the headers and boundaries
+// of each of its MIME entities.
+const sizeOverhead = 512
+
+// EstimateSize returns an estimate of the size of the composed message, in bytes, without composing
+// it - e.g. for checking it against the size limit of the SMTP server before rendering the
+// templates for each recipient. The files referenced by the message are not read; the sizes of the
+// ones not read yet are taken from the file system.
+//
+// The parts set from templates are estimated from their most recent rendering, if any, so the
+// estimate is more accurate after composing the message once.
+func (m *Message) EstimateSize() int64 {
+	m.RLock()
+	defer m.RUnlock()
+	size := int64(sizeOverhead + len(m.subject))
+	for _, p := range m.parts {
+		n := int64(len(p.bytes))
+		cte := p.cte
+		if cte == AutoCTE {
+			cte = autoCTE(p.bytes)
+		}
+		switch cte {
+		case Base64:
+			size += sizeOverhead + base64Size(n)
+		case SevenBit, EightBit:
+			size += sizeOverhead + n
+		default:
+			size += sizeOverhead + qpSize(p.bytes)
+		}
+		for _, r := range m.partRelated(p) {
+			size += sizeOverhead + base64Size(m.fileSize(r.data, r.fileName))
+		}
+	}
+	if m.hasAutoText() {
+		size += sizeOverhead + qpSize(m.html.bytes)
+	}
+	for _, a := range m.attachments {
+		n := m.fileSize(a.data, a.fileName)
+		if a.data == nil && a.size > 0 {
+			n = a.size
+		}
+		if a.ctype == "message/rfc822" {
+			size += sizeOverhead + n
+		} else {
+			size += sizeOverhead + base64Size(n)
+		}
+	}
+	return size
+}
+
+// fileSize returns the size of `data` or, if empty, the size of the file with the `name`, if any.
+func (m *Message) fileSize(data []byte, name string) int64 {
+	if len(data) > 0 || name == "" {
+		return int64(len(data))
+	}
+	fi, err := statFile(m.fsys, m.root, name)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+// base64Size returns the size of `n` bytes of data, base64-encoded in lines of 76 characters.
+func base64Size(n int64) int64 {
+	enc := (n + 2) / 3 * 4
+	return enc + enc/76*2
+}
+
+// qpSize returns the size of `data`, quoted-printable-encoded.
+func qpSize(data []byte) int64 {
+	return int64(len(QuotedPrintableEncode(data)))
+}
+
+// MaxMessageSize sets the maximum size of the messages sent by the receiver, in bytes: larger
+// messages are rejected with an error wrapping ErrMessageTooLarge, rather than attempting their
+// delivery. A zero `n` means using the limit advertised by the SMTP server, if found out by
+// Verify, and otherwise no limit.
+func (s *Sender) MaxMessageSize(n int64) *Sender {
+	s.mu.Lock()
+	s.maxSize = n
+	s.mu.Unlock()
+	return s
+}
+
+// MaxAttachmentSize sets the maximum size of each attachment of the messages sent by the receiver,
+// in bytes, for the messages without their own limit - see SizeLimits. A zero `n` means no limit.
+func (s *Sender) MaxAttachmentSize(n int64) *Sender {
+	s.mu.Lock()
+	s.maxAttachmentSize = n
+	s.mu.Unlock()
+	return s
+}
+
+// checkSize returns an error wrapping ErrMessageTooLarge if the `size` of a message exceeds the
+// size limit of the receiver.
+func (s *Sender) checkSize(size int) error {
+	s.mu.RLock()
+	limit := s.maxSize
+	if limit == 0 {
+		limit = s.serverMaxSize
+	}
+	s.mu.RUnlock()
+	if limit > 0 && int64(size) > limit {
+		return fmt.Errorf("Sender.Send: %w: %d bytes, the limit is %d", ErrMessageTooLarge, size, limit)
+	}
+	return nil
+}
+
+// setServerMaxSize records the limit advertised by the SMTP server in the SIZE extension `param`.
+func (s *Sender) setServerMaxSize(param string) {
+	if n, err := strconv.ParseInt(param, 10, 64); err == nil && n > 0 {
+		s.mu.Lock()
+		s.serverMaxSize = n
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,99 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func Test_EstimateSize(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	cases := []*Message{
+		QuickMessage("test", "short body"),
+		QuickMessage("test", string(data)),
+		QuickMessage("test").Html("<p>" + string(data) + "</p>"),
+		QuickMessage("test", "body").AttachObject("a.bin", "application/octet-stream", data),
+		QuickMessage("test", "body").Attach("test-file.txt"),
+	}
+	for i, msg := range cases {
+		est := msg.From(&Address{"", "test@example.com"}).EstimateSize()
+		act := int64(len(msg.Compose(nil)))
+		if est < act || est > act*2+4*sizeOverhead {
+			t.Errorf("(*Message).EstimateSize [%d]: got %d for a size of %d", i, est, act)
+		}
+	}
+	if act, exp := base64Size(int64(len(data))), int64(len(Base64Encode(data))); act < exp || act > exp+2 {
+		t.Errorf("base64Size: got %d want %d", act, exp)
+	}
+}
+
+func Test_MaxMessageSize(t *testing.T) {
+	s := &Sender{}
+	if err := s.checkSize(1 << 30); err != nil {
+		t.Errorf("(*Sender).checkSize: got error %v without a limit", err)
+	}
+	s.setServerMaxSize("2000")
+	if err := s.checkSize(2001); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("(*Sender).checkSize: got error %v, want the server limit", err)
+	}
+	s.MaxMessageSize(100)
+	if err := s.checkSize(101); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("(*Sender).checkSize: got error %v, want the configured limit", err)
+	}
+	if err := s.checkSize(100); err != nil {
+		t.Errorf("(*Sender).checkSize: got error %v within the limit", err)
+	}
+
+	s, _ = NewSender("localhost", "user", "pass", "test@example.com")
+	s.MaxMessageSize(100).DryRun(DryRunCompose)
+	if err := s.sendSync(QuickMessage("test", "a body that does not fit"), nil); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("(*Sender).sendSync: got error %v, want ErrMessageTooLarge", err)
+	}
+}
+
+func Test_SizeLimits(t *testing.T) {
+	msg := QuickMessage("test", "body").From(&Address{"", "ann@example.com"}).
+		AttachObject("small.txt", "text/plain", make([]byte, 10)).
+		AttachObject("big.bin", "application/octet-stream", make([]byte, 1000)).
+		SizeLimits(100, 0)
+	err := msg.PrepareContext(context.Background())
+	var se *ErrSizeLimit
+	if !errors.Is(err, ErrAttachmentTooLarge) || !errors.As(err, &se) || se.Attachment != "big.bin" || se.Size != 1000 || se.Limit != 100 {
+		t.Errorf("(*Message).PrepareContext: got %v", err)
+	}
+	msg.Errors()
+	for _, prepare := range []func() *Message{msg.Prepare, msg.PrepareFresh} {
+		if errs := prepare().Errors(); len(errs) != 1 || !errors.Is(errs[0], ErrAttachmentTooLarge) ||
+			errs[0].(*MessageError).AttachmentName != "big.bin" {
+			t.Errorf("(*Message).Prepare: got %v", errs)
+		}
+	}
+	if b := msg.Compose(nil); len(b) != 0 {
+		t.Errorf("(*Message).Compose: got %d bytes, want none", len(b))
+	}
+	if errs := msg.Errors(); len(errs) != 1 || !errors.Is(errs[0], ErrAttachmentTooLarge) || errs[0].(*MessageError).AttachmentName != "big.bin" {
+		t.Errorf("(*Message).Compose: got %v", errs)
+	}
+
+	msg.SizeLimits(0, 1000)
+	if b := msg.Compose(nil); len(b) != 0 {
+		t.Errorf("(*Message).Compose: got %d bytes, want none", len(b))
+	}
+	if errs := msg.Errors(); len(errs) != 1 || !errors.Is(errs[0], ErrMessageTooLarge) {
+		t.Errorf("(*Message).Compose: got %v", errs)
+	}
+	msg.SizeLimits(0, 0)
+	if b := msg.Compose(nil); len(b) == 0 {
+		t.Errorf("(*Message).Compose: got %v", msg.Errors())
+	}
+
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	s.MaxAttachmentSize(500)
+	if err := s.sendSync(msg, nil); err == nil {
+		t.Error("(*Sender).sendSync: got no error for an oversize attachment")
+	}
+	if errs := msg.Errors(); len(errs) != 1 || !errors.Is(errs[0], ErrAttachmentTooLarge) {
+		t.Errorf("(*Sender).sendSync: got %v", errs)
+	}
+}
@@ -0,0 +1,176 @@
+package email
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// CertificateLookup returns the certificate to encrypt messages to the email address `addr` with.
+// It may return a nil certificate and a nil error for an address that has no certificate, which is
+// only acceptable for the From address.
+type CertificateLookup func(addr string) (*x509.Certificate, error)
+
+// ErrEncryptBcc is recorded when composing an encrypted message that has Bcc recipients.
+var ErrEncryptBcc = errors.New("cannot encrypt a message with Bcc recipients")
+
+// Encrypt makes the message encrypted with S/MIME (RFC 8551), so that only its recipients can read
+// it: the MIME body is encrypted to the certificates returned by the `lookup` for each of the To
+// and Cc recipients, producing an "application/pkcs7-mime; smime-type=enveloped-data" body. The
+// `lookup` is also called for the From address, so that the sender can read the message as well if
+// it returns a certificate. A nil `lookup` disables the encryption.
+//
+// Since the encrypted body identifies the certificates of all the recipients it is encrypted to,
+// composing an encrypted message with Bcc recipients records ErrEncryptBcc, rather than disclosing
+// them to the other recipients. Such messages can be sent with the individual delivery mode of the
+// Sender, addressing each recipient separately - see (*Sender).IndividualDelivery.
+//
+// The top-level headers, including the Subject, are not encrypted. Only RSA certificates are
+// supported; the content is encrypted with AES-256-CBC.
+func (m *Message) Encrypt(lookup CertificateLookup) *Message {
+	m.Lock()
+	m.encrypt = lookup
+	m.Unlock()
+	return m
+}
+
+var (
+	oidData                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEnvelopedData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidRSAEncryption          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidAES256CBC              = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	errUnsupportedCertificate = errors.New("only RSA certificates are supported")
+)
+
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     cmsEnvelopedData `asn1:"explicit,tag:0"`
+}
+
+type cmsEnvelopedData struct {
+	Version              int
+	RecipientInfos       []cmsRecipientInfo `asn1:"set"`
+	EncryptedContentInfo cmsEncryptedContentInfo
+}
+
+type cmsRecipientInfo struct {
+	Version                int
+	IssuerAndSerialNumber  cmsIssuerAndSerial
+	KeyEncryptionAlgorithm cmsAlgorithm
+	EncryptedKey           []byte
+}
+
+type cmsIssuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type cmsAlgorithm struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type cmsEncryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm cmsAlgorithm
+	EncryptedContent           []byte `asn1:"optional,tag:0"`
+}
+
+// encryptEnveloped returns the DER-encoded CMS EnvelopedData of the `content`, encrypted to the
+// `certs`.
+func encryptEnveloped(content []byte, certs []*x509.Certificate) ([]byte, error) {
+	key := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	pad := aes.BlockSize - len(content)%aes.BlockSize
+	encrypted := append(append([]byte(nil), content...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	env := cmsEnvelopedData{}
+	for _, cert := range certs {
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, errUnsupportedCertificate
+		}
+		ek, err := rsa.EncryptPKCS1v15(rand.Reader, pub, key)
+		if err != nil {
+			return nil, err
+		}
+		env.RecipientInfos = append(env.RecipientInfos, cmsRecipientInfo{
+			IssuerAndSerialNumber:  cmsIssuerAndSerial{asn1.RawValue{FullBytes: cert.RawIssuer}, cert.SerialNumber},
+			KeyEncryptionAlgorithm: cmsAlgorithm{oidRSAEncryption, asn1.RawValue{Tag: asn1.TagNull}},
+			EncryptedKey:           ek,
+		})
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	env.EncryptedContentInfo = cmsEncryptedContentInfo{
+		ContentType:                oidData,
+		ContentEncryptionAlgorithm: cmsAlgorithm{oidAES256CBC, asn1.RawValue{FullBytes: ivParam}},
+		EncryptedContent:           encrypted,
+	}
+	return asn1.Marshal(cmsContentInfo{oidEnvelopedData, env})
+}
+
+// encryptBody returns the S/MIME entity replacing the MIME `body` of the receiver, with the `from`
+// address, encrypted to the certificates returned by the `lookup`. The caller must hold the lock on
+// the receiver.
+func (m *Message) encryptBody(lookup CertificateLookup, from *Address, body []byte) ([]byte, error) {
+	var (
+		certs []*x509.Certificate
+		seen  = map[string]bool{}
+	)
+	if len(m.bcc) > 0 {
+		return nil, ErrEncryptBcc
+	}
+	for i, list := range []addrList{{from}, m.to, m.cc} {
+		for _, a := range list {
+			addr := strings.ToLower(a.Addr)
+			if seen[addr] {
+				continue
+			}
+			seen[addr] = true
+			cert, err := lookup(a.Addr)
+			if err != nil {
+				return nil, errors.New("cannot find certificate for " + a.Addr + ": " + err.Error())
+			}
+			if cert == nil {
+				if i == 0 {
+					continue
+				}
+				return nil, errors.New("no certificate for " + a.Addr)
+			}
+			certs = append(certs, cert)
+		}
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate for " + from.Addr)
+	}
+	der, err := encryptEnveloped(body, certs)
+	if err != nil {
+		return nil, errors.New("cannot encrypt message: " + err.Error())
+	}
+	dst := newBuffer(len(der)*4/3 + 256)
+	dst.Write("Content-Type: application/pkcs7-mime; smime-type=enveloped-data;\r\n\tname=\"smime.p7m\"\r\n",
+		"Content-Disposition: attachment;\r\n\tfilename=\"smime.p7m\"\r\n",
+		"Content-Transfer-Encoding: base64\r\n\r\n", Base64Encode(der), "\r\n")
+	return dst.Bytes(), nil
+}
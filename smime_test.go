@@ -0,0 +1,145 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"net/smtp"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCertificate(t *testing.T, addr string) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(time.Now().UnixNano()),
+		Subject:        pkix.Name{CommonName: addr},
+		EmailAddresses: []string{addr},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// decryptEnveloped decrypts the CMS EnvelopedData `der` with the `key` of the `cert`.
+func decryptEnveloped(der []byte, cert *x509.Certificate, key *rsa.PrivateKey) ([]byte, error) {
+	var ci cmsContentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, err
+	}
+	for _, ri := range ci.Content.RecipientInfos {
+		if ri.IssuerAndSerialNumber.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+			continue
+		}
+		cek, err := rsa.DecryptPKCS1v15(rand.Reader, key, ri.EncryptedKey)
+		if err != nil {
+			return nil, err
+		}
+		var iv []byte
+		if _, err = asn1.Unmarshal(ci.Content.EncryptedContentInfo.ContentEncryptionAlgorithm.Parameters.FullBytes, &iv); err != nil {
+			return nil, err
+		}
+		block, err := aes.NewCipher(cek)
+		if err != nil {
+			return nil, err
+		}
+		data := append([]byte(nil), ci.Content.EncryptedContentInfo.EncryptedContent...)
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, data)
+		return data[:len(data)-int(data[len(data)-1])], nil
+	}
+	return nil, errors.New("not a recipient")
+}
+
+func Test_Encrypt(t *testing.T) {
+	rcptCert, rcptKey := newTestCertificate(t, "rcpt@example.com")
+	fromCert, fromKey := newTestCertificate(t, "test@example.com")
+	lookup := func(addr string) (*x509.Certificate, error) {
+		switch addr {
+		case "rcpt@example.com":
+			return rcptCert, nil
+		case "test@example.com":
+			return fromCert, nil
+		}
+		return nil, nil
+	}
+	msg := QuickMessage("Secret", "the secret text").From(&Address{"", "test@example.com"}).
+		To(&Address{"", "rcpt@example.com"}).AttachObject("a.txt", "text/plain", []byte("attached")).
+		IDs(FixedID("id")).Encrypt(lookup)
+	body := msg.Compose(nil)
+	if msg.HasErrors() {
+		t.Fatalf("(*Message).Encrypt: got errors %v", msg.Errors())
+	}
+	if bytes.Contains(body, []byte("the secret text")) || bytes.Contains(body, Base64Encode([]byte("attached"))) {
+		t.Errorf("(*Message).Encrypt: got plain content in\n%s", body)
+	}
+	e, err := ReadEntity(bytes.NewReader(body), nil)
+	if err != nil || e.MediaType != "application/pkcs7-mime" || e.Params["smime-type"] != "enveloped-data" {
+		t.Fatalf("(*Message).Encrypt: got error %v, header %v", err, e.Header)
+	}
+	if e.Header.Get("Subject") != "Secret" {
+		t.Errorf("(*Message).Encrypt: got header %v", e.Header)
+	}
+	exp := msg.Encrypt(nil).Compose(nil)
+	exp = exp[bytes.Index(exp, []byte("MIME-Version: 1.0\r\n"))+19:]
+	for i, key := range []*rsa.PrivateKey{rcptKey, fromKey} {
+		cert := []*x509.Certificate{rcptCert, fromCert}[i]
+		inner, err := decryptEnveloped(e.Body, cert, key)
+		if err != nil || !bytes.Equal(inner, exp) {
+			t.Errorf("(*Message).Encrypt [%d]: got error %v and\n%s\nwant\n%s", i, err, inner, exp)
+		}
+	}
+
+	msg = QuickMessage("Secret", "text").From(&Address{"", "test@example.com"}).
+		To(&Address{"", "other@example.com"}).Encrypt(lookup)
+	msg.Compose(nil)
+	if errs := msg.Errors(); len(errs) == 0 || !strings.Contains(errs[0].Error(), "other@example.com") {
+		t.Error("(*Message).Encrypt: expected an error for a recipient without certificate")
+	}
+
+	msg = QuickMessage("Secret", "text").From(&Address{"", "test@example.com"}).
+		To(&Address{"", "rcpt@example.com"}).Bcc(&Address{"", "test@example.com"}).Encrypt(lookup)
+	if body := msg.Compose(nil); len(body) != 0 {
+		t.Error("(*Message).Encrypt: composed an encrypted message with Bcc recipients")
+	}
+	if errs := msg.Errors(); len(errs) != 1 || !errors.Is(errs[0], ErrEncryptBcc) || errs[0].(*MessageError).Field != "bcc" {
+		t.Errorf("(*Message).Encrypt: got %v want ErrEncryptBcc", errs)
+	}
+
+	defer func(f func(string, smtp.Auth, string, []string, []byte) error) { sendMail = f }(sendMail)
+	var sent []string
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sent = append(sent, to...)
+		return nil
+	}
+	s, _ := NewSender("smtp.example.com", "user", "pass", "test@example.com")
+	s.IndividualDelivery(true, true)
+	s.Workers(1, 2)
+	if err := s.Send(msg, nil); err != nil {
+		t.Errorf("(*Sender).IndividualDelivery: got %v for an encrypted message with Bcc recipients", err)
+	}
+	s.Flush(context.Background())
+	if exp := []string{"rcpt@example.com", "test@example.com"}; !reflect.DeepEqual(sent, exp) {
+		t.Errorf("(*Sender).IndividualDelivery: delivered to %v want %v", sent, exp)
+	}
+}
@@ -0,0 +1,165 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/smtp"
+	"sync"
+	"time"
+)
+
+// SMTPError reports the step of an SMTP session that failed - one of "dial", "hello", "starttls",
+// "auth", "noop" and "quit", or an SMTP command such as "MAIL". The underlying error is usually a
+// *textproto.Error holding the reply of the server.
+type SMTPError struct {
+	Step string
+	Err  error
+}
+
+func (e *SMTPError) Error() string {
+	return "SMTP " + e.Step + " failed: " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *SMTPError) Unwrap() error {
+	return e.Err
+}
+
+// ctxConn is a connection interrupted when a context is done, until it is closed.
+type ctxConn struct {
+	net.Conn
+	once sync.Once
+	stop chan struct{}
+}
+
+func (c *ctxConn) Close() error {
+	c.once.Do(func() { close(c.stop) })
+	return c.Conn.Close()
+}
+
+// dialSMTP connects to the SMTP server at `addr`, then completes the EHLO, STARTTLS (if supported
+// by the server) and AUTH (if `a` is not nil) steps. If `strict`, the server not supporting AUTH
+// is an error. The connection is interrupted when `ctx` is done, until the client is closed.
+func dialSMTP(ctx context.Context, addr, host string, a smtp.Auth, strict bool) (*smtp.Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, &SMTPError{"dial", err}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if ctx.Done() != nil {
+		cc := &ctxConn{Conn: conn, stop: make(chan struct{})}
+		go func() {
+			select {
+			case <-ctx.Done():
+				cc.SetDeadline(time.Unix(1, 0))
+			case <-cc.stop:
+			}
+		}()
+		conn = cc
+	}
+	fail := func(c *smtp.Client, step string, err error) (*smtp.Client, error) {
+		if c != nil {
+			c.Close()
+		} else {
+			conn.Close()
+		}
+		return nil, &SMTPError{step, contextErr(ctx, err)}
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return fail(nil, "dial", err)
+	}
+	if err = c.Hello("localhost"); err != nil {
+		return fail(c, "hello", err)
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fail(c, "starttls", err)
+		}
+	}
+	if a != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err = c.Auth(a); err != nil {
+				return fail(c, "auth", err)
+			}
+		} else if strict {
+			return fail(c, "auth", errors.New("the server does not support authentication"))
+		}
+	}
+	return c, nil
+}
+
+// contextErr returns the error of `ctx` if it is done - or its deadline passed, which may be
+// noticed by the connection first - and `err` otherwise.
+func contextErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// negotiate connects to the SMTP server at `addr` and goes through the MAIL and RCPT commands for
+// the envelope, but then issues RSET instead of DATA, so no message is sent.
+func negotiate(addr string, a smtp.Auth, from string, to []string) error {
+	host := addr
+	for i := len(addr) - 1; i >= 0; i-- {
+		if addr[i] == ':' {
+			host = addr[:i]
+			break
+		}
+	}
+	c, err := dialSMTP(context.Background(), addr, host, a, false)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err = c.Mail(from); err != nil {
+		return &SMTPError{"MAIL", err}
+	}
+	for _, rcpt := range to {
+		if err = c.Rcpt(rcpt); err != nil {
+			return &SMTPError{"RCPT", err}
+		}
+	}
+	if err = c.Reset(); err != nil {
+		return &SMTPError{"RSET", err}
+	}
+	if err = c.Quit(); err != nil {
+		return &SMTPError{"quit", err}
+	}
+	return nil
+}
+
+// Verify checks the connection to the SMTP server of the receiver and its credentials, by going
+// through the EHLO, STARTTLS (if supported by the server) and AUTH steps, then issuing NOOP and
+// QUIT. It is meant for validating the configuration at start-up, rather than finding out about
+// problems from failed deliveries. The message size limit advertised by the server, if any, is
+// recorded for MaxMessageSize.
+//
+// The returned error, if any, is a *SMTPError identifying the step that failed. The session is
+// interrupted, and the error of `ctx` is reported, when `ctx` is done.
+func (s *Sender) Verify(ctx context.Context) error {
+	c, err := dialSMTP(ctx, s.addr(), s.host, s.auth(), true)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, param := c.Extension("SIZE"); ok {
+		s.setServerMaxSize(param)
+	}
+	if err = c.Noop(); err != nil {
+		return &SMTPError{"noop", contextErr(ctx, err)}
+	}
+	if err = c.Quit(); err != nil {
+		return &SMTPError{"quit", contextErr(ctx, err)}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSMTPServer is a minimal SMTP server recording the commands it receives. It rejects the
+// recipients starting with "reject" and the AUTH attempts with a password other than "pass".
+type testSMTPServer struct {
+	net.Listener
+	mu   sync.Mutex
+	cmds []string
+}
+
+func newTestSMTPServer(t *testing.T) *testSMTPServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	srv := &testSMTPServer{Listener: l}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	return srv
+}
+
+func (srv *testSMTPServer) commands() []string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return append([]string(nil), srv.cmds...)
+}
+
+func (srv *testSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 test ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		srv.mu.Lock()
+		srv.cmds = append(srv.cmds, cmd)
+		srv.mu.Unlock()
+		switch cmd {
+		case "EHLO":
+			tp.PrintfLine("250-test\r\n250-SIZE 1000\r\n250 AUTH PLAIN")
+		case "AUTH":
+			// "AUTH PLAIN base64(\x00user\x00pass)"
+			if strings.HasSuffix(line, "AHVzZXIAcGFzcw==") {
+				tp.PrintfLine("235 authenticated")
+			} else {
+				tp.PrintfLine("535 authentication failed")
+			}
+		case "RCPT":
+			if strings.Contains(line, "<reject") {
+				tp.PrintfLine("550 no such user")
+			} else {
+				tp.PrintfLine("250 ok")
+			}
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			tp.ReadDotBytes()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+func Test_negotiate(t *testing.T) {
+	srv := newTestSMTPServer(t)
+	defer srv.Close()
+	s, _ := NewSender(srv.Addr().String(), "user", "pass", "test@example.com")
+	addr := srv.Addr().String()
+	auth := s.auth()
+
+	if err := negotiate(addr, auth, "test@example.com", []string{"a@example.com", "b@example.com"}); err != nil {
+		t.Fatalf("negotiate: unexpected error: %v", err)
+	}
+	exp := []string{"EHLO", "AUTH", "MAIL", "RCPT", "RCPT", "RSET", "QUIT"}
+	if act := srv.commands(); !reflect.DeepEqual(act, exp) {
+		t.Errorf("negotiate: got commands %v, want %v", act, exp)
+	}
+
+	err := negotiate(addr, auth, "test@example.com", []string{"reject@example.com"})
+	if code := smtpCode(err); code != 550 {
+		t.Errorf("negotiate: got error %v, want a 550 reply", err)
+	}
+}
+
+func Test_SenderDryRun(t *testing.T) {
+	srv := newTestSMTPServer(t)
+	defer srv.Close()
+	s, _ := NewSender(srv.Addr().String(), "user", "pass", "test@example.com")
+	msg := QuickMessage("test", "body")
+
+	s.DryRun(DryRunCompose)
+	if body := msg.Compose(nil); s.deliver(newDelivery(msg, "test@example.com", []string{"a@example.com"}, body), 1) != nil ||
+		len(srv.commands()) != 0 {
+		t.Errorf("(*Sender).deliver: DryRunCompose should not contact the server, got %v", srv.commands())
+	}
+
+	s.DryRun(DryRunNegotiate)
+	if err := s.deliver(newDelivery(msg, "test@example.com", []string{"a@example.com"}, msg.Compose(nil)), 1); err != nil {
+		t.Errorf("(*Sender).deliver: unexpected error: %v", err)
+	}
+	for _, cmd := range srv.commands() {
+		if cmd == "DATA" {
+			t.Error("(*Sender).deliver: DryRunNegotiate should not send DATA")
+		}
+	}
+
+	s.DryRun(NoDryRun)
+	if err := s.deliver(newDelivery(msg, "test@example.com", []string{"a@example.com"}, msg.Compose(nil)), 1); err != nil {
+		t.Errorf("(*Sender).deliver: unexpected error: %v", err)
+	}
+	if cmds := srv.commands(); cmds[len(cmds)-2] != "DATA" {
+		t.Errorf("(*Sender).deliver: got commands %v, want DATA to be sent", cmds)
+	}
+}
+
+func Test_SenderVerify(t *testing.T) {
+	srv := newTestSMTPServer(t)
+	defer srv.Close()
+	s, _ := NewSender(srv.Addr().String(), "user", "pass")
+	if err := s.Verify(context.Background()); err != nil {
+		t.Fatalf("(*Sender).Verify: unexpected error: %v", err)
+	}
+	if act, exp := srv.commands(), []string{"EHLO", "AUTH", "NOOP", "QUIT"}; !reflect.DeepEqual(act, exp) {
+		t.Errorf("(*Sender).Verify: got commands %v, want %v", act, exp)
+	}
+	if err := s.checkSize(1001); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("(*Sender).Verify: got size check error %v, want the advertised limit", err)
+	}
+
+	s, _ = NewSender(srv.Addr().String(), "user", "wrong")
+	var smtpErr *SMTPError
+	if err := s.Verify(context.Background()); !errors.As(err, &smtpErr) || smtpErr.Step != "auth" || smtpCode(err) != 535 {
+		t.Errorf("(*Sender).Verify: got error %v, want an auth failure", err)
+	}
+
+	// a server that never greets
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+	s, _ = NewSender(l.Addr().String(), "user", "pass")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Verify(ctx); !errors.Is(err, context.DeadlineExceeded) || !errors.As(err, &smtpErr) || smtpErr.Step != "dial" {
+		t.Errorf("(*Sender).Verify: got error %v, want a dial timeout", err)
+	}
+}
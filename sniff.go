@@ -0,0 +1,60 @@
+package email
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path/filepath"
+)
+
+// sniffLen is the number of bytes of content used for detecting its type.
+const sniffLen = 512
+
+// ContentSniffer returns the content type of an attachment with the `name`, given the first bytes
+// of its content - up to 512 - or an empty string if it cannot tell.
+type ContentSniffer func(name string, head []byte) string
+
+// SniffContent sets the function detecting the content type of the attachments added without one,
+// whose name has no extension, or one that mime.TypeByExtension does not know. By default, and when
+// `fn` returns an empty string, the type is detected with http.DetectContentType, which falls back
+// to "application/octet-stream".
+func (m *Message) SniffContent(fn ContentSniffer) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.sniffer = fn
+	return m
+}
+
+// detectType returns the content type of the content with the `name`, derived from its extension
+// or else detected from the first bytes of the content, as returned by `head`.
+func (m *Message) detectType(name string, head func() []byte) string {
+	if ctype := mime.TypeByExtension(filepath.Ext(name)); ctype != "" {
+		return ctype
+	}
+	h := head()
+	if len(h) > sniffLen {
+		h = h[:sniffLen]
+	}
+	if m.sniffer != nil {
+		if ctype := m.sniffer(name, h); ctype != "" {
+			return ctype
+		}
+	}
+	return http.DetectContentType(h)
+}
+
+// fileHead returns a function that reads the first bytes of the file with the `name`, like
+// openFile, for detecting its type; it returns nil if the file cannot be read.
+func fileHead(fsys fs.FS, root, name string) func() []byte {
+	return func() []byte {
+		f, err := openFile(fsys, root, name)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		head := make([]byte, sniffLen)
+		n, _ := io.ReadFull(f, head)
+		return head[:n]
+	}
+}
@@ -0,0 +1,47 @@
+package email
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_SniffContent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "email-sniff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "report")
+	if err = ioutil.WriteFile(file, []byte("%PDF-1.4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	custom := func(name string, head []byte) string {
+		if name == "blob" {
+			return "application/x-blob"
+		}
+		return ""
+	}
+	cases := []struct {
+		msg  *Message
+		want string
+	}{
+		{QuickMessage("test").Attach(file), "application/pdf"},
+		{QuickMessage("test").LazyAttachments(true).Attach(file), "application/pdf"},
+		{QuickMessage("test").AttachObject("notes", "", []byte("hello")), "text/plain; charset=utf-8"},
+		{QuickMessage("test").AttachObject("notes.html", "", []byte("hello")), "text/html; charset=utf-8"},
+		{QuickMessage("test").AttachObject("data", "", []byte{0, 1, 2}), "application/octet-stream"},
+		{QuickMessage("test").SniffContent(custom).AttachObject("blob", "", []byte("hello")), "application/x-blob"},
+		{QuickMessage("test").SniffContent(custom).AttachObject("other", "", []byte("hello")), "text/plain; charset=utf-8"},
+	}
+	for i, c := range cases {
+		c.msg.Prepare()
+		if got := c.msg.Attachments()[0].ContentType; got != c.want {
+			t.Errorf("(*Message).SniffContent [%d]: got %q want %q", i, got, c.want)
+		}
+		if got := c.msg.attachments[0].ctype; got != c.want {
+			t.Errorf("(*Message).SniffContent [%d]: got %q want %q", i, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package email
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Warning describes a potential deliverability problem found by a SubjectRule.
+type Warning struct {
+	// Rule is the name of the rule that issued the warning.
+	Rule string
+	// Message describes the problem.
+	Message string
+}
+
+func (w Warning) String() string {
+	return w.Rule + ": " + w.Message
+}
+
+// SubjectRule is a heuristic check of message subjects. Check returns the description of the
+// problem found in the `subject`, or an empty string if there is none.
+type SubjectRule struct {
+	Name  string
+	Check func(subject string) string
+}
+
+// SpamTriggerPhrases are the phrases flagged by the "trigger-phrase" rule in DefaultSubjectRules.
+var SpamTriggerPhrases = []string{
+	"100% free", "act now", "apply now", "buy now", "cash bonus", "click here", "congratulations",
+	"double your", "earn money", "extra income", "free gift", "free access", "guaranteed",
+	"limited time", "make money", "no cost", "once in a lifetime", "order now", "risk-free",
+	"special promotion", "urgent", "winner", "you have been selected", "$$$",
+}
+
+// DefaultSubjectRules are the rules used by CheckSubject when none are provided: ALL-CAPS subjects,
+// excessive punctuation, excessive emoji and common spam-trigger phrases.
+var DefaultSubjectRules = []SubjectRule{
+	{"all-caps", checkAllCaps},
+	{"punctuation", checkPunctuation},
+	{"emoji", checkEmoji},
+	PhraseRule("trigger-phrase", SpamTriggerPhrases...),
+}
+
+// CheckSubject checks the `subject` against the `rules`, or DefaultSubjectRules if none are
+// provided, and returns the warnings issued by them. Custom rules can be combined with the default
+// ones, as in CheckSubject(subj, append(DefaultSubjectRules, myRule)...).
+func CheckSubject(subject string, rules ...SubjectRule) []Warning {
+	if len(rules) == 0 {
+		rules = DefaultSubjectRules
+	}
+	var warnings []Warning
+	for _, r := range rules {
+		if msg := r.Check(subject); msg != "" {
+			warnings = append(warnings, Warning{r.Name, msg})
+		}
+	}
+	return warnings
+}
+
+// CheckSubjects returns compose middleware checking the rendered subject of each message against
+// the `rules`, or DefaultSubjectRules if none are provided, and passing the warnings, if any, to
+// `report`. The warnings do not prevent the message from being sent.
+func CheckSubjects(report func(c *Content, warnings []Warning), rules ...SubjectRule) ComposeMiddleware {
+	return func(next ComposeFunc) ComposeFunc {
+		return func(c *Content) error {
+			if warnings := CheckSubject(c.Subject, rules...); len(warnings) > 0 {
+				report(c, warnings)
+			}
+			return next(c)
+		}
+	}
+}
+
+// PhraseRule creates a SubjectRule flagging the subjects that contain any of the `phrases`, as
+// whole words and regardless of case.
+func PhraseRule(name string, phrases ...string) SubjectRule {
+	quoted := make([]string, len(phrases))
+	for i, p := range phrases {
+		quoted[i] = regexp.QuoteMeta(strings.ToLower(p))
+	}
+	re := regexp.MustCompile(`(?:^|\W)(` + strings.Join(quoted, "|") + `)(?:\W|$)`)
+	return SubjectRule{name, func(subject string) string {
+		if m := re.FindStringSubmatch(strings.ToLower(subject)); m != nil {
+			return "contains the phrase " + strconv.Quote(m[1])
+		}
+		return ""
+	}}
+}
+
+func checkAllCaps(subject string) string {
+	var letters, upper int
+	for _, r := range subject {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	if letters >= 8 && upper*10 >= letters*8 {
+		return "mostly in capital letters"
+	}
+	return ""
+}
+
+var reRepeatedPunct = regexp.MustCompile(`[!?]{2,}|\${2,}|\.{4,}`)
+
+func checkPunctuation(subject string) string {
+	if m := reRepeatedPunct.FindString(subject); m != "" {
+		return "contains repeated punctuation " + strconv.Quote(m)
+	}
+	if n := strings.Count(subject, "!"); n > 2 {
+		return "contains " + strconv.Itoa(n) + " exclamation marks"
+	}
+	return ""
+}
+
+func checkEmoji(subject string) string {
+	n := 0
+	for _, r := range subject {
+		if isEmoji(r) {
+			n++
+		}
+	}
+	if n > 2 {
+		return "contains " + strconv.Itoa(n) + " emoji"
+	}
+	return ""
+}
+
+// isEmoji reports whether `r` is in one of the main emoji and pictograph blocks.
+func isEmoji(r rune) bool {
+	return 0x1F300 <= r && r <= 0x1FAFF || 0x2600 <= r && r <= 0x27BF || 0x1F1E6 <= r && r <= 0x1F1FF
+}
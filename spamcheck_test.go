@@ -0,0 +1,46 @@
+package email
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_CheckSubject(t *testing.T) {
+	cases := []struct {
+		subject string
+		rules   []string
+	}{
+		{"Your invoice for March", nil},
+		{"Meeting at 10am: agenda & notes", nil},
+		{"HUGE SALE THIS WEEKEND", []string{"all-caps"}},
+		{"New arrivals!!", []string{"punctuation"}},
+		{"Wow! Really! Amazing!", []string{"punctuation"}},
+		{"Summer 🌞🏖️🍹 deals", []string{"emoji"}},
+		{"Act now, you are a WINNER", []string{"trigger-phrase"}},
+		{"A guaranteedly fine subject", nil},
+		{"FREE GIFT!!! 🎁🎁🎁", []string{"all-caps", "punctuation", "emoji", "trigger-phrase"}},
+	}
+	for i, c := range cases {
+		var act []string
+		for _, w := range CheckSubject(c.subject) {
+			act = append(act, w.Rule)
+		}
+		if !reflect.DeepEqual(act, c.rules) {
+			t.Errorf("CheckSubject [%d]: got %v, want %v", i, act, c.rules)
+		}
+	}
+
+	custom := PhraseRule("brand", "competitor")
+	if w := CheckSubject("Better than Competitor", custom); len(w) != 1 || w[0].String() != `brand: contains the phrase "competitor"` {
+		t.Errorf("CheckSubject: got %v for a custom rule", w)
+	}
+
+	var reported []Warning
+	msg := QuickMessage("", "body").From(&Address{"", "test@example.com"}).
+		SubjectTemplate("URGENT: {{.}}").
+		UseCompose(CheckSubjects(func(c *Content, w []Warning) { reported = w }))
+	if body := msg.Compose("read this"); len(body) == 0 || len(reported) != 1 || !strings.Contains(reported[0].Message, "urgent") {
+		t.Errorf("CheckSubjects: got %v", reported)
+	}
+}
@@ -0,0 +1,72 @@
+package email
+
+import (
+	"fmt"
+	"io"
+)
+
+// LazyAttachments sets whether the files attached to the message are read lazily: instead of being
+// loaded into memory by Prepare, they are read and base64-encoded chunk by chunk while composing
+// the message. Combined with ComposeTo, this keeps the memory usage low regardless of the size of
+// the attachments, as their content is streamed straight to the writer.
+//
+// Sending the message with a Sender gains nothing from it: Send composes the whole message into
+// memory, including the base64-encoded attachments, before queuing it for delivery. For streaming a
+// message to an SMTP server, write it with ComposeTo to the data writer of an smtp.Client.
+//
+// The attachments created with AttachObject are not affected, and neither are the related items.
+func (m *Message) LazyAttachments(enable bool) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.lazy = enable
+	return m
+}
+
+// ComposeTo is like Compose, but writes the message to `w` - e.g. the data writer of an SMTP
+// client, or a file - rather than returning it. It returns the first error composing or writing the
+// message, in which case part of the message may have been written already.
+func (m *Message) ComposeTo(w io.Writer, data interface{}) error {
+	m.Lock()
+	defer m.Unlock()
+	n := len(m.errors)
+	m.compose(data, w)
+	if len(m.errors) > n {
+		return m.errors[n]
+	}
+	if n > 0 {
+		return m.errors[0]
+	}
+	return nil
+}
+
+// streamAttachment reads the file of the attachment `a` and writes its content, base64-encoded, to
+// `w` after the content of `msg`, if `w` is not nil, or else to `msg`.
+func (m *Message) streamAttachment(msg *buffer, w io.Writer, a *attachment) error {
+	f, err := openFile(m.fsys, m.root, a.fileName)
+	if err != nil {
+		return &ErrFileRead{a.fileName, err}
+	}
+	defer f.Close()
+	if w == nil {
+		w = bufferWriter{msg}
+	} else {
+		if _, err = w.Write(msg.Bytes()); err != nil {
+			return err
+		}
+		*msg = (*msg)[:0]
+	}
+	if err = base64Stream(w, f); err != nil {
+		return fmt.Errorf("cannot stream file: %s: %w", a.fileName, err)
+	}
+	return nil
+}
+
+// base64Stream writes the content of `src` to `dst`, base64-encoded in lines of 76 characters
+// separated by CRLF, like Base64Encode, but reading and encoding it chunk by chunk.
+func base64Stream(dst io.Writer, src io.Reader) error {
+	enc := NewBase64Encoder(dst)
+	if _, err := io.Copy(enc, src); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,68 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_base64Stream(t *testing.T) {
+	for i, n := range []int{0, 1, 56, 57, 58, 3647, 3648, 3649, 10000} {
+		src := make([]byte, n)
+		for j := range src {
+			src[j] = byte(j * 7)
+		}
+		var dst bytes.Buffer
+		if err := base64Stream(&dst, bytes.NewReader(src)); err != nil {
+			t.Errorf("base64Stream [%d]: got error %v", i, err)
+		}
+		if act, exp := dst.Bytes(), Base64Encode(src); !bytes.Equal(act, exp) {
+			t.Errorf("base64Stream [%d]: got\n%s\nwant\n%s", i, act, exp)
+		}
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) { return 0, errors.New("write failed") }
+
+func Test_LazyAttachments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "email-lazy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "data.bin")
+	data := bytes.Repeat([]byte("lazy attachment data\n"), 500)
+	if err = ioutil.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	build := func(lazy bool) *Message {
+		return QuickMessage("test", "body").From(&Address{"", "test@example.com"}).
+			IDs(FixedID("id")).Options(WithClock(func() time.Time { return time.Unix(0, 0) })).
+			LazyAttachments(lazy).Attach(file)
+	}
+	exp := build(false).Compose(nil)
+	msg := build(true)
+	if act := msg.Compose(nil); !bytes.Equal(act, exp) {
+		t.Errorf("(*Message).LazyAttachments: got\n%s\nwant\n%s", act, exp)
+	}
+	if len(msg.attachments[0].data) != 0 {
+		t.Error("(*Message).LazyAttachments: got the attachment loaded into memory")
+	}
+	var buf bytes.Buffer
+	if err = msg.ComposeTo(&buf, nil); err != nil || !bytes.Equal(buf.Bytes(), exp) {
+		t.Errorf("(*Message).ComposeTo: got error %v and\n%s\nwant\n%s", err, buf.Bytes(), exp)
+	}
+	if err = msg.ComposeTo(failWriter{}, nil); err == nil {
+		t.Error("(*Message).ComposeTo: got no error for a failed write")
+	}
+	msg = build(true).Attach(filepath.Join(dir, "missing.bin"))
+	if err = msg.ComposeTo(&bytes.Buffer{}, nil); err == nil {
+		t.Error("(*Message).ComposeTo: got no error for a missing file")
+	}
+}
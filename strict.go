@@ -0,0 +1,93 @@
+package email
+
+import (
+	htpl "html/template"
+	ttpl "text/template"
+)
+
+// StrictTemplates enables or disables the strict mode for all the templates of the message, set
+// before or after: in strict mode, a template referring to a map key missing from the data fails
+// to execute, like with Option("missingkey=error"), so that typos in the data keys surface as
+// compose errors instead of rendering "<no value>" to the recipients.
+//
+// Since the templates may be shared with other messages, they are cloned before their option is
+// changed. An HTML template that has already been executed cannot be cloned, though; such
+// templates must be set again after changing the mode.
+func (m *Message) StrictTemplates(enable bool) *Message {
+	m.Lock()
+	defer m.Unlock()
+	if m.strict == enable {
+		return m
+	}
+	m.strict = enable
+	if m.subjectTpl != nil {
+		m.subjectTpl = m.strictText(m.subjectTpl)
+	}
+	for i, p := range m.parts {
+		if p.tpl == nil && p.htmlTpl == nil {
+			continue
+		}
+		q := *p
+		if q.tpl != nil {
+			q.tpl = m.strictText(q.tpl)
+		}
+		if q.htmlTpl != nil {
+			q.htmlTpl = m.strictHTML(q.htmlTpl, i)
+		}
+		*p = q
+	}
+	if len(m.locales) > 0 {
+		locales := make(map[string]*localeVariant, len(m.locales))
+		for k, v := range m.locales {
+			locales[k] = m.strictVariant(v)
+		}
+		m.locales = locales
+	}
+	return m
+}
+
+// missingKey returns the missingkey option of the templates of the receiver.
+func (m *Message) missingKey() string {
+	if m.strict {
+		return "missingkey=error"
+	}
+	return "missingkey=default"
+}
+
+// strictText returns a clone of `t` with the missingkey option of the receiver. The caller must
+// hold the lock on the receiver.
+func (m *Message) strictText(t *ttpl.Template) *ttpl.Template {
+	if t == nil {
+		return nil
+	}
+	c, err := t.Clone()
+	if err != nil {
+		return t
+	}
+	return c.Option(m.missingKey())
+}
+
+// strictHTML returns a clone of `t` with the missingkey option of the receiver, recording an error
+// about the part with the `index` if `t` cannot be cloned. The caller must hold the lock on the
+// receiver.
+func (m *Message) strictHTML(t *htpl.Template, index int) *htpl.Template {
+	if t == nil {
+		return nil
+	}
+	c, err := t.Clone()
+	if err != nil {
+		m.failPart(TemplateError, index, "html", err)
+		return t
+	}
+	return c.Option(m.missingKey())
+}
+
+// strictVariant returns a copy of the locale variant `v` with the missingkey option of the
+// receiver.
+func (m *Message) strictVariant(v *localeVariant) *localeVariant {
+	return &localeVariant{
+		subject: m.strictText(v.subject),
+		text:    m.strictText(v.text),
+		html:    m.strictHTML(v.html, -1),
+	}
+}
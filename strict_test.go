@@ -0,0 +1,41 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_StrictTemplates(t *testing.T) {
+	data := map[string]interface{}{"name": "Ann"}
+	base := NewMessage(nil).From(&Address{"", "test@example.com"}).To(&Address{"", "to@example.com"}).
+		SubjectTemplate("Hi {{.name}}").TextTemplate("Hello, {{.nmae}}")
+	if act := string(NewMessage(base).Compose(data)); !strings.Contains(act, "Hello, <no value>") {
+		t.Errorf("(*Message).StrictTemplates: got %q", act)
+	}
+	cases := []*Message{
+		NewMessage(base).StrictTemplates(true),
+		NewMessage(nil).StrictTemplates(true).From(&Address{"", "test@example.com"}).
+			To(&Address{"", "to@example.com"}).Subject("Hi").HtmlTemplate("<p>Hello, {{.nmae}}</p>"),
+		NewMessage(nil).StrictTemplates(true).From(&Address{"", "test@example.com"}).
+			To(&Address{"", "to@example.com"}).Templates(`{{define "subject"}}Hi{{end}}{{define "text"}}Hello, {{.nmae}}{{end}}`),
+	}
+	for i, msg := range cases {
+		msg.Compose(data)
+		errs := msg.Errors()
+		if len(errs) != 1 {
+			t.Errorf("(*Message).StrictTemplates [%d]: got errors %v", i, errs)
+			continue
+		}
+		if _, ok := errs[0].(*MessageError).Err.(*ErrTemplateExec); !ok {
+			t.Errorf("(*Message).StrictTemplates [%d]: got errors %v", i, errs)
+		}
+	}
+	// the base message is not affected, and the mode can be disabled again
+	if act := string(NewMessage(base).Compose(data)); !strings.Contains(act, "Hello, <no value>") {
+		t.Errorf("(*Message).StrictTemplates: got %q", act)
+	}
+	msg := NewMessage(base).StrictTemplates(true).StrictTemplates(false)
+	if act := string(msg.Compose(data)); !strings.Contains(act, "Hello, <no value>") {
+		t.Errorf("(*Message).StrictTemplates: got %q", act)
+	}
+}
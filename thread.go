@@ -0,0 +1,273 @@
+package email
+
+import (
+	"mime"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ThreadNode represents a message in a conversation thread, as arranged by a Threader.
+type ThreadNode struct {
+	// MessageID is the message identifier, without angle brackets.
+	MessageID string
+	// Subject is the decoded subject of the message.
+	Subject string
+	// Date is the date of the message, or the zero time if unknown.
+	Date time.Time
+	// Value is the value provided to Threader.Add for the message, or nil for the messages that
+	// are referred to by others but were never added.
+	Value interface{}
+	// Parent is the message being replied to, or nil for the root of a thread.
+	Parent *ThreadNode
+	// Children are the replies to the message, ordered by date.
+	Children []*ThreadNode
+
+	key   string // normalized subject, for matching
+	reply bool   // the subject has a reply/forward prefix
+}
+
+// Threader groups messages into conversation threads, based on their Message-ID, In-Reply-To and
+// References headers, falling back to matching the subjects for the messages that lack those
+// headers - using a simplified version of the algorithm by Jamie Zawinski, also described in
+// RFC 5256.
+type Threader struct {
+	nodes map[string]*ThreadNode
+	seq   int
+}
+
+// NewThreader creates a new, empty Threader.
+func NewThreader() *Threader {
+	return &Threader{nodes: map[string]*ThreadNode{}}
+}
+
+// node returns the node for `id`, creating an empty one if needed.
+func (t *Threader) node(id string) *ThreadNode {
+	n := t.nodes[id]
+	if n == nil {
+		n = &ThreadNode{MessageID: id}
+		t.nodes[id] = n
+	}
+	return n
+}
+
+// Add adds a message, represented by its header and an arbitrary value, to the receiver.
+func (t *Threader) Add(h mail.Header, value interface{}) *Threader {
+	var id string
+	if ids := parseMsgIDs(h.Get("Message-Id")); len(ids) > 0 {
+		id = ids[0]
+	}
+	if n := t.nodes[id]; id == "" || n != nil && n.Value != nil {
+		// missing or duplicate id; make up a unique one
+		t.seq++
+		id = "\x00" + strconv.Itoa(t.seq) + id
+	}
+	n := t.node(id)
+	n.Value = value
+	n.Subject = h.Get("Subject")
+	if dec, err := wordDecoder.DecodeHeader(n.Subject); err == nil {
+		n.Subject = dec
+	}
+	n.key, n.reply = threadSubject(n.Subject)
+	n.Date, _ = ParseDate(h.Get("Date"))
+
+	refs := parseMsgIDs(h.Get("References"))
+	if irt := parseMsgIDs(h.Get("In-Reply-To")); len(irt) > 0 && (len(refs) == 0 || refs[len(refs)-1] != irt[0]) {
+		refs = append(refs, irt[0])
+	}
+	var prev *ThreadNode
+	for _, ref := range refs {
+		if ref == id {
+			continue
+		}
+		r := t.node(ref)
+		if prev != nil && r.Parent == nil && !r.isAncestorOf(prev) {
+			r.setParent(prev)
+		}
+		prev = r
+	}
+	if prev != nil && !n.isAncestorOf(prev) {
+		n.setParent(prev)
+	}
+	return t
+}
+
+func (n *ThreadNode) isAncestorOf(other *ThreadNode) bool {
+	for p := other; p != nil; p = p.Parent {
+		if p == n {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *ThreadNode) setParent(p *ThreadNode) {
+	if n.Parent == p {
+		return
+	}
+	if n.Parent != nil {
+		n.Parent.removeChild(n)
+	}
+	n.Parent = p
+	p.Children = append(p.Children, n)
+}
+
+func (n *ThreadNode) removeChild(c *ThreadNode) {
+	for i, child := range n.Children {
+		if child == c {
+			n.Children = append(n.Children[:i:i], n.Children[i+1:]...)
+			return
+		}
+	}
+}
+
+// firstDate returns the date of the node, or the earliest date among its descendants if the
+// node is a placeholder.
+func (n *ThreadNode) firstDate() time.Time {
+	if n.Value != nil || len(n.Children) == 0 {
+		return n.Date
+	}
+	d := n.Children[0].firstDate()
+	for _, c := range n.Children[1:] {
+		if cd := c.firstDate(); cd.Before(d) {
+			d = cd
+		}
+	}
+	return d
+}
+
+// Threads returns the roots of the conversation threads, ordered by date. A root may be a
+// placeholder (with a nil Value) for a missing message replied to by several present messages.
+func (t *Threader) Threads() []*ThreadNode {
+	var tops, roots []*ThreadNode
+	for _, n := range t.nodes {
+		if n.Parent == nil {
+			tops = append(tops, n)
+		}
+	}
+	for _, n := range tops {
+		roots = append(roots, prune(n)...)
+	}
+	roots = groupBySubject(roots)
+	for _, r := range roots {
+		sortThread(r)
+	}
+	sort.Slice(roots, func(i, j int) bool { return threadLess(roots[i], roots[j]) })
+	return roots
+}
+
+// prune removes the placeholders that are not needed to hold a thread together from the subtree of
+// `n`, returning the nodes that should take its place.
+func prune(n *ThreadNode) []*ThreadNode {
+	var children []*ThreadNode
+	for _, c := range n.Children {
+		children = append(children, prune(c)...)
+	}
+	n.Children = children
+	for _, c := range children {
+		c.Parent = n
+	}
+	if n.Value != nil {
+		return []*ThreadNode{n}
+	}
+	// a placeholder can only be dropped if its children do not become several roots
+	if n.Parent != nil || len(children) <= 1 {
+		for _, c := range children {
+			c.Parent = nil
+		}
+		n.Children = nil
+		return children
+	}
+	return []*ThreadNode{n}
+}
+
+// groupBySubject merges the threads with the same subject, for messages lacking references.
+func groupBySubject(roots []*ThreadNode) []*ThreadNode {
+	bySubject := map[string]*ThreadNode{}
+	for _, r := range roots {
+		subj := r.subjectKey()
+		if subj == "" {
+			continue
+		}
+		// prefer the earliest non-reply as the root of the merged thread
+		if cur, ok := bySubject[subj]; !ok || cur.reply && !r.reply ||
+			cur.reply == r.reply && threadLess(r, cur) {
+			bySubject[subj] = r
+		}
+	}
+	merged := roots[:0]
+	for _, r := range roots {
+		subj := r.subjectKey()
+		if main, ok := bySubject[subj]; ok && main != r && subj != "" {
+			r.Parent = main
+			main.Children = append(main.Children, r)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// subjectKey returns the normalized subject of the node, or of its first child for placeholders.
+func (n *ThreadNode) subjectKey() string {
+	if n.Value == nil && len(n.Children) > 0 {
+		return n.Children[0].key
+	}
+	return n.key
+}
+
+// threadLess orders nodes by date, then by message id for a deterministic order.
+func threadLess(a, b *ThreadNode) bool {
+	da, db := a.firstDate(), b.firstDate()
+	if da.Equal(db) {
+		return a.MessageID < b.MessageID
+	}
+	return da.Before(db)
+}
+
+func sortThread(n *ThreadNode) {
+	sort.Slice(n.Children, func(i, j int) bool { return threadLess(n.Children[i], n.Children[j]) })
+	for _, c := range n.Children {
+		sortThread(c)
+	}
+}
+
+var (
+	reMsgID         = regexp.MustCompile(`<([^<>\s]+)>`)
+	reSubjectPrefix = regexp.MustCompile(`(?i)^\s*(?:(?:re|fwd?|aw|wg|sv|antw)\s*(?:\[\d+\])?\s*:|\[[^\]]*\])\s*`)
+	wordDecoder     = &mime.WordDecoder{CharsetReader: charsetReader}
+)
+
+// parseMsgIDs extracts the message identifiers, without angle brackets, from a header value such
+// as References. Values lacking the angle brackets are split at white space.
+func parseMsgIDs(value string) []string {
+	matches := reMsgID.FindAllStringSubmatch(value, -1)
+	if len(matches) == 0 {
+		return strings.Fields(value)
+	}
+	ids := make([]string, len(matches))
+	for i, m := range matches {
+		ids[i] = m[1]
+	}
+	return ids
+}
+
+// threadSubject normalizes a subject for comparison, removing any reply or forward prefixes and
+// list tags, and reports whether there were any reply or forward prefixes.
+func threadSubject(subject string) (string, bool) {
+	reply := false
+	for {
+		loc := reSubjectPrefix.FindStringIndex(subject)
+		if loc == nil {
+			break
+		}
+		if prefix := strings.TrimSpace(subject[loc[0]:loc[1]]); prefix[0] != '[' {
+			reply = true
+		}
+		subject = subject[loc[1]:]
+	}
+	return strings.ToLower(strings.Join(strings.Fields(subject), " ")), reply
+}
@@ -0,0 +1,60 @@
+package email
+
+import (
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func threadHeader(id, subject, date, inReplyTo, refs string) mail.Header {
+	h := mail.Header{"Subject": {subject}, "Date": {date}}
+	if id != "" {
+		h["Message-Id"] = []string{"<" + id + ">"}
+	}
+	if inReplyTo != "" {
+		h["In-Reply-To"] = []string{inReplyTo}
+	}
+	if refs != "" {
+		h["References"] = []string{refs}
+	}
+	return h
+}
+
+// dumpThreads renders the threads as "id(child,child)" lists, with "-" for placeholders.
+func dumpThreads(nodes []*ThreadNode) string {
+	parts := make([]string, len(nodes))
+	for i, n := range nodes {
+		id := "-"
+		if n.Value != nil {
+			id = n.Value.(string)
+		}
+		if len(n.Children) > 0 {
+			id += "(" + dumpThreads(n.Children) + ")"
+		}
+		parts[i] = id
+	}
+	return strings.Join(parts, ",")
+}
+
+func Test_Threader(t *testing.T) {
+	th := NewThreader().
+		Add(threadHeader("c@x", "Re: Plans", "Fri, 30 Aug 2013 09:12:00 +0000", "<b@x>", "<a@x> <b@x>"), "c").
+		Add(threadHeader("a@x", "Plans", "Fri, 30 Aug 2013 09:10:00 +0000", "", ""), "a").
+		Add(threadHeader("b@x", "Re: Plans", "Fri, 30 Aug 2013 09:11:00 +0000", "<a@x>", ""), "b").
+		Add(threadHeader("d@x", "RE: [team] Plans", "Fri, 30 Aug 2013 09:13:00 +0000", "", ""), "d").
+		Add(threadHeader("e@x", "Other", "Fri, 30 Aug 2013 09:00:00 +0000", "", ""), "e").
+		// two replies to a missing message
+		Add(threadHeader("f@x", "Re: Lost", "Fri, 30 Aug 2013 09:20:00 +0000", "<missing@x>", ""), "f").
+		Add(threadHeader("g@x", "Re: Lost", "Fri, 30 Aug 2013 09:21:00 +0000", "<missing@x>", ""), "g").
+		// a lone reply to a missing message
+		Add(threadHeader("h@x", "Re: Gone", "Fri, 30 Aug 2013 09:30:00 +0000", "", "<gone1@x> <gone2@x>"), "h").
+		// no id at all
+		Add(threadHeader("", "Fwd: Other", "Fri, 30 Aug 2013 09:40:00 +0000", "", ""), "i")
+	exp := "e(i),a(b(c),d),-(f,g),h"
+	if act := dumpThreads(th.Threads()); act != exp {
+		t.Errorf("(*Threader).Threads: got %s, want %s", act, exp)
+	}
+	if act := dumpThreads(th.Threads()); act != exp {
+		t.Errorf("(*Threader).Threads: second call got %s, want %s", act, exp)
+	}
+}
@@ -0,0 +1,148 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+)
+
+const (
+	// DefaultFetchTimeout is the time allowed for fetching each resource referenced by a URL, unless
+	// set with FetchURLs.
+	DefaultFetchTimeout = 30 * time.Second
+	// DefaultMaxFetchSize is the maximum size of each resource referenced by a URL, unless set with
+	// FetchURLs.
+	DefaultMaxFetchSize = 25 << 20
+)
+
+// ErrFetchTooLarge is wrapped by the errors recorded for the resources referenced by URLs whose
+// content exceeds the maximum size set with FetchURLs.
+var ErrFetchTooLarge = errors.New("resource too large")
+
+// urlFetcher fetches the resources referenced by URLs.
+type urlFetcher struct {
+	client  *http.Client
+	timeout time.Duration
+	maxSize int64
+}
+
+// FetchURLs sets the HTTP client used to fetch the attachments and related items referenced by
+// URLs when preparing the message, the time allowed for fetching each of them, and the maximum size
+// of their content. A nil `client` selects http.DefaultClient, and a zero `timeout` or `maxSize`
+// the default DefaultFetchTimeout or DefaultMaxFetchSize; a negative one disables the limit.
+func (m *Message) FetchURLs(client *http.Client, timeout time.Duration, maxSize int64) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.fetcher = urlFetcher{client, timeout, maxSize}
+	return m
+}
+
+// AttachURL attaches the resource fetched from the http or https `rawURL` when preparing the
+// message - e.g. a PDF generated by another service - without going through a temporary file. The
+// `name` defaults to the last element of the path of the URL; the content type is the one returned
+// by the server, or else derived from the extension of the name. See FetchURLs.
+func (m *Message) AttachURL(name, rawURL string) *Message {
+	m.Lock()
+	defer m.Unlock()
+	u, err := parseFetchURL(rawURL)
+	if err != nil {
+		m.failAttachment(ArgumentError, name, err)
+		return m
+	}
+	if name == "" {
+		name = path.Base(u.Path)
+		if name == "/" || name == "." {
+			name = u.Hostname()
+		}
+	}
+	a := &attachment{
+		name:    m.sanitizeFilename(name),
+		url:     rawURL,
+		encoded: &encodedCache{},
+	}
+	if m.screenAttachment(a, false) {
+		m.attachments = append(m.attachments, a)
+	}
+	m.prepared = false
+	return m
+}
+
+// RelatedURL creates a Related structure for the resource fetched from the http or https `rawURL`
+// when preparing the message - e.g. a hosted image; its content type is the one returned by the
+// server. An invalid URL is recorded as an error of the message when it is prepared.
+func RelatedURL(id, rawURL string) Related {
+	return Related{
+		id:  id,
+		url: rawURL,
+	}
+}
+
+// parseFetchURL parses the `rawURL` of a resource to fetch, which must be an absolute http or https
+// URL.
+func parseFetchURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, errors.New("invalid resource URL: " + rawURL)
+	}
+	return u, nil
+}
+
+// fetch fetches the resource at the `rawURL`, within the time and size limits of the receiver.
+func (f urlFetcher) fetch(ctx context.Context, rawURL string) fileResult {
+	fail := func(err error) fileResult {
+		return fileResult{err: &ErrFetch{rawURL, err}}
+	}
+	if _, err := parseFetchURL(rawURL); err != nil {
+		return fail(err)
+	}
+	timeout, maxSize, client := f.timeout, f.maxSize, f.client
+	if timeout == 0 {
+		timeout = DefaultFetchTimeout
+	}
+	if maxSize == 0 {
+		maxSize = DefaultMaxFetchSize
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return fail(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fail(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fail(errors.New("unexpected status " + resp.Status))
+	}
+	var body io.Reader = resp.Body
+	if maxSize > 0 {
+		if resp.ContentLength > maxSize {
+			return fail(ErrFetchTooLarge)
+		}
+		body = io.LimitReader(body, maxSize+1)
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return fail(err)
+	}
+	if maxSize > 0 && int64(len(data)) > maxSize {
+		return fail(ErrFetchTooLarge)
+	}
+	return fileResult{data: data, size: int64(len(data)), ctype: resp.Header.Get("Content-Type")}
+}
@@ -0,0 +1,81 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_AttachURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/reports/q3.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write([]byte("%PDF-1.4"))
+		case "/logo":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png"))
+		case "/large":
+			w.Write(bytes.Repeat([]byte("x"), 100))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	msg := QuickMessage("test").From(&Address{"", "ann@example.com"}).
+		Html(`<img src="cid:logo">`, RelatedURL("logo", srv.URL+"/logo")).
+		AttachURL("", srv.URL+"/reports/q3.pdf").
+		FetchURLs(srv.Client(), 0, 0)
+	e, err := ReadEntity(bytes.NewReader(msg.Compose(nil)), nil)
+	if err != nil {
+		t.Fatalf("(*Message).AttachURL: got %v, %v", err, msg.Errors())
+	}
+	pdf := findEntity(e, func(e *Entity) bool { return e.MediaType == "application/pdf" })
+	if pdf == nil || string(pdf.Body) != "%PDF-1.4" || !strings.Contains(pdf.Header.Get("Content-Disposition"), "q3.pdf") {
+		t.Errorf("(*Message).AttachURL: got attachment %v", pdf)
+	}
+	if img := findEntity(e, func(e *Entity) bool { return e.MediaType == "image/png" }); img == nil || string(img.Body) != "png" {
+		t.Errorf("RelatedURL: got related item %v", img)
+	}
+
+	cases := []struct {
+		path    string
+		timeout time.Duration
+		maxSize int64
+		ok      bool
+		err     error
+	}{
+		{"/missing", 0, 0, false, nil},
+		{"/large", 0, 50, false, ErrFetchTooLarge},
+		{"/large", 0, -1, true, nil},
+		{"/slow", 50 * time.Millisecond, 0, false, context.DeadlineExceeded},
+	}
+	for i, c := range cases {
+		msg := QuickMessage("test", "body").From(&Address{"", "ann@example.com"}).
+			AttachURL("file", srv.URL+c.path).FetchURLs(srv.Client(), c.timeout, c.maxSize)
+		err := msg.PrepareContext(context.Background())
+		var fe *ErrFetch
+		switch {
+		case c.ok:
+			if err != nil {
+				t.Errorf("(*Message).FetchURLs [%d]: got %v want no error", i, err)
+			}
+		case !errors.As(err, &fe) || fe.URL != srv.URL+c.path:
+			t.Errorf("(*Message).FetchURLs [%d]: got %v want *ErrFetch", i, err)
+		case c.err != nil && !errors.Is(err, c.err):
+			t.Errorf("(*Message).FetchURLs [%d]: got %v want %v", i, err, c.err)
+		}
+	}
+
+	if errs := QuickMessage("test").AttachURL("x", "file:///etc/passwd").Errors(); len(errs) != 1 {
+		t.Errorf("(*Message).AttachURL: got %v want 1 error", errs)
+	}
+}
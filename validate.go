@@ -0,0 +1,191 @@
+package email
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// ValidationLevel is the level of the validation of the addresses given to a message - see
+// (*Message).Validation.
+type ValidationLevel byte
+
+const (
+	// LooseValidation only weeds out obviously invalid addresses, as SeemsValidAddr does.
+	LooseValidation ValidationLevel = iota
+	// StrictValidation enforces the syntax and length limits of RFC 5321, as ValidateAddr does.
+	StrictValidation
+)
+
+// Validation sets the level of the validation of the addresses given to From, To, Cc, Bcc, their
+// Add variants, and ReplyTo, set after; it defaults to LooseValidation. The rejected addresses are
+// reported with Errors, as an *ErrInvalidAddr - explaining what is wrong with them, with
+// StrictValidation.
+func (m *Message) Validation(level ValidationLevel) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.validation = level
+	return m
+}
+
+// validAddr reports whether the `addr` is valid at the validation level of the receiver, recording
+// an error about the `field` if it is not. The caller must hold the lock on the receiver.
+func (m *Message) validAddr(field string, addr *Address) bool {
+	var err error
+	if m.validation == StrictValidation {
+		err = ValidateAddr(addr.Addr)
+	} else if !SeemsValidAddr(addr.Addr) {
+		err = &ErrInvalidAddr{Addr: addr.Addr}
+	}
+	if err != nil {
+		m.fail(AddressError, field, err)
+		return false
+	}
+	return true
+}
+
+// ValidateAddr checks that `addr` is a valid RFC 5321 mailbox, returning an error describing what
+// is wrong with it otherwise, as an *ErrInvalidAddr - unlike SeemsValidAddr, which is intentionally loose:
+//
+//   - the local part is a dot-atom - atoms of letters, digits and "!#$%&'*+-/=?^_`{|}~" separated
+//     by single dots, with no leading or trailing dot - or a quoted string, of at most 64
+//     characters;
+//   - the domain is made of labels of letters, digits and hyphens, neither starting nor ending
+//     with a hyphen, of at most 63 characters, and has a TLD part - or it is an address literal,
+//     such as "[192.0.2.1]" or "[IPv6:2001:db8::1]";
+//   - the whole address has at most 254 characters.
+//
+// Internationalized domain names are checked in their ASCII form - see ToASCIIDomain.
+func ValidateAddr(addr string) error {
+	at := strings.LastIndexByte(addr, '@')
+	if at < 0 {
+		return addrError(addr, "missing '@'")
+	}
+	local, domain := addr[:at], addr[at+1:]
+	if err := validateLocalPart(local); err != nil {
+		return addrError(addr, err.Error())
+	}
+	if domain == "" {
+		return addrError(addr, "empty domain")
+	}
+	if domain[0] == '[' {
+		if err := validateAddrLiteral(domain); err != nil {
+			return addrError(addr, err.Error())
+		}
+		if len(addr) > 254 {
+			return addrError(addr, "address longer than 254 characters")
+		}
+		return nil
+	}
+	domain, err := ToASCIIDomain(domain)
+	if err != nil {
+		return addrError(addr, "invalid internationalized domain")
+	}
+	if len(domain) > 253 {
+		return addrError(addr, "domain longer than 253 characters")
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return addrError(addr, "domain without TLD")
+	}
+	for _, label := range labels {
+		switch {
+		case label == "":
+			return addrError(addr, "empty domain label")
+		case len(label) > 63:
+			return addrError(addr, "domain label longer than 63 characters")
+		case label[0] == '-' || label[len(label)-1] == '-':
+			return addrError(addr, "domain label starting or ending with '-'")
+		}
+		for i := 0; i < len(label); i++ {
+			if c := label[i]; !isLetterDigit(c) && c != '-' {
+				return addrError(addr, "invalid character "+quoteChar(c)+" in domain")
+			}
+		}
+	}
+	if len(local)+1+len(domain) > 254 {
+		return addrError(addr, "address longer than 254 characters")
+	}
+	return nil
+}
+
+// addrError returns the error about the invalid `addr`, for the `reason`.
+func addrError(addr, reason string) error {
+	return &ErrInvalidAddr{addr, reason}
+}
+
+// validateLocalPart checks the local part of an address.
+func validateLocalPart(local string) error {
+	switch {
+	case local == "":
+		return errors.New("empty local part")
+	case len(local) > 64:
+		return errors.New("local part longer than 64 characters")
+	case local[0] == '"':
+		return validateQuotedString(local)
+	case local[0] == '.' || local[len(local)-1] == '.':
+		return errors.New("local part starting or ending with '.'")
+	case strings.Contains(local, ".."):
+		return errors.New("consecutive dots in local part")
+	}
+	for i := 0; i < len(local); i++ {
+		if c := local[i]; c != '.' && !isAtext(c) {
+			return errors.New("invalid character " + quoteChar(c) + " in local part")
+		}
+	}
+	return nil
+}
+
+// validateQuotedString checks a quoted local part, such as `"john doe"`.
+func validateQuotedString(local string) error {
+	if len(local) < 2 || local[len(local)-1] != '"' {
+		return errors.New("unterminated quoted local part")
+	}
+	for i := 1; i < len(local)-1; i++ {
+		c := local[i]
+		switch {
+		case c == '\\':
+			if i++; i == len(local)-1 || local[i] < ' ' || local[i] > '~' {
+				return errors.New("invalid quoted pair in local part")
+			}
+		case c == '"':
+			return errors.New("unescaped '\"' in quoted local part")
+		case c < ' ' || c > '~':
+			return errors.New("invalid character " + quoteChar(c) + " in quoted local part")
+		}
+	}
+	return nil
+}
+
+// validateAddrLiteral checks an address literal, such as "[192.0.2.1]" or "[IPv6:2001:db8::1]".
+func validateAddrLiteral(domain string) error {
+	if domain[len(domain)-1] != ']' {
+		return errors.New("unterminated address literal")
+	}
+	lit := domain[1 : len(domain)-1]
+	if strings.HasPrefix(lit, "IPv6:") {
+		if ip := net.ParseIP(lit[5:]); ip != nil && ip.To4() == nil {
+			return nil
+		}
+	} else if ip := net.ParseIP(lit); ip != nil && ip.To4() != nil && !strings.Contains(lit, ":") {
+		return nil
+	}
+	return errors.New("invalid address literal")
+}
+
+func isLetterDigit(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// isAtext reports whether `c` may appear in an atom, as defined by RFC 5322.
+func isAtext(c byte) bool {
+	return isLetterDigit(c) || strings.IndexByte("!#$%&'*+-/=?^_`{|}~", c) >= 0
+}
+
+// quoteChar returns the character `c` quoted for an error message.
+func quoteChar(c byte) string {
+	if c < ' ' || c > '~' {
+		return "0x" + string(hextable[c>>4]) + string(hextable[c&0x0f])
+	}
+	return "'" + string(c) + "'"
+}
@@ -0,0 +1,74 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_ValidateAddr(t *testing.T) {
+	cases := []struct {
+		addr, err string
+	}{
+		{"ann@example.com", ""},
+		{"ann.o'neil+tag@mail.example.com", ""},
+		{`"ann smith"@example.com`, ""},
+		{`"a\"b"@example.com`, ""},
+		{"ann@bücher.example", ""},
+		{"ann@[192.0.2.1]", ""},
+		{"ann@[IPv6:2001:db8::1]", ""},
+		{"ann.example.com", "missing '@'"},
+		{"@example.com", "empty local part"},
+		{".ann@example.com", "local part starting or ending with '.'"},
+		{"ann.@example.com", "local part starting or ending with '.'"},
+		{"ann..smith@example.com", "consecutive dots in local part"},
+		{"ann smith@example.com", "invalid character ' ' in local part"},
+		{"ann(x)@example.com", "invalid character '(' in local part"},
+		{`"ann@example.com`, "unterminated quoted local part"},
+		{`"a"b"@example.com`, "unescaped '\"' in quoted local part"},
+		{strings.Repeat("a", 65) + "@example.com", "local part longer than 64 characters"},
+		{"ann@", "empty domain"},
+		{"ann@localhost", "domain without TLD"},
+		{"ann@example..com", "empty domain label"},
+		{"ann@-example.com", "domain label starting or ending with '-'"},
+		{"ann@exa_mple.com", "invalid character '_' in domain"},
+		{"ann@" + strings.Repeat("a", 64) + ".com", "domain label longer than 63 characters"},
+		{"ann@" + strings.Repeat(strings.Repeat("a", 63)+".", 4) + "com", "domain longer than 253 characters"},
+		{strings.Repeat("a", 64) + "@" + strings.Repeat(strings.Repeat("a", 62)+".", 3) + "example", "address longer than 254 characters"},
+		{"ann@[192.0.2]", "invalid address literal"},
+		{"ann@[IPv6:192.0.2.1]", "invalid address literal"},
+	}
+	for i, c := range cases {
+		err := ValidateAddr(c.addr)
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("ValidateAddr [%d]: got %v want no error", i, err)
+		case c.err != "" && (err == nil || !strings.HasSuffix(err.Error(), ": "+c.err)):
+			t.Errorf("ValidateAddr [%d]: got %v want %q", i, err, c.err)
+		}
+	}
+}
+
+func Test_MessageValidation(t *testing.T) {
+	msg := NewMessage(nil).Validation(StrictValidation).
+		From(&Address{"", "ann..smith@example.com"}).
+		To(&Address{"", "bob@example.com"}, &Address{"", "bob@localhost"}).
+		AddCc(&Address{"", "carl@exa_mple.com"})
+	errs := msg.Errors()
+	if len(errs) != 3 || msg.from != nil || len(msg.to) != 1 || len(msg.cc) != 0 {
+		t.Fatalf("(*Message).Validation: got %v, %v, %v, %v", errs, msg.from, msg.to, msg.cc)
+	}
+	for i, field := range []string{"from", "to", "cc"} {
+		if e, ok := errs[i].(*MessageError); !ok || e.Kind != AddressError || e.Field != field {
+			t.Errorf("(*Message).Validation [%d]: got %#v want %s address error", i, errs[i], field)
+		}
+	}
+
+	msg = NewMessage(nil).To(&Address{"", "bob@localhost"}, &Address{"", "ann..smith@example.com"}, nil)
+	errs = msg.Errors()
+	if len(errs) != 1 || len(msg.to) != 1 {
+		t.Fatalf("(*Message).Validation: got %v, %v want loose validation", errs, msg.to)
+	}
+	if e, ok := errs[0].(*MessageError).Err.(*ErrInvalidAddr); !ok || e.Addr != "bob@localhost" || e.Reason != "" {
+		t.Errorf("(*Message).Validation: got %#v want rejected bob@localhost", errs[0])
+	}
+}
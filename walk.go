@@ -0,0 +1,78 @@
+package email
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+)
+
+// ErrStopWalk can be returned by a WalkFunc to stop Walk without an error - e.g. once the part of
+// interest was found.
+var ErrStopWalk = errors.New("email: stop walk")
+
+// WalkFunc is called by Walk for each entity of a message, with its `path` - the indexes of the
+// entity and its ancestors within their multipart parents, so the message itself has an empty
+// path, and the second part of its first part has the path [0 1] - its `header`, and its `body`,
+// with the content transfer encoding removed. The `body` is nil for multipart entities, whose
+// parts are walked next; it must not be used after the function returns, nor the `path` retained.
+//
+// Returning an error stops the walk, and Walk returns the error, unless it is ErrStopWalk.
+type WalkFunc func(path []int, header textproto.MIMEHeader, body io.Reader) error
+
+// Walk reads a MIME message from `r` and calls `fn` for each of its entities, in depth-first
+// order, streaming their bodies instead of holding the whole message in memory - so that the
+// caller can extract exactly the parts it needs, e.g. the first text/calendar part. Embedded
+// messages (message/rfc822) are not walked into; their body can be walked separately.
+//
+// The nesting depth and the number of entities are bounded by the DefaultLimits.
+func Walk(r io.Reader, fn WalkFunc) error {
+	br := bufio.NewReader(r)
+	header, err := textproto.NewReader(br).ReadMIMEHeader()
+	if err != nil && !(err == io.EOF && len(header) > 0) {
+		return errors.New("email: cannot read header: " + err.Error())
+	}
+	w := &walker{fn: fn}
+	if err = w.walk(nil, header, br); err == ErrStopWalk {
+		return nil
+	}
+	return err
+}
+
+type walker struct {
+	fn    WalkFunc
+	parts int
+}
+
+// walk walks the entity with the `path`, `header` and `body`.
+func (w *walker) walk(path []int, header textproto.MIMEHeader, body io.Reader) error {
+	if max := DefaultLimits.MaxDepth; len(path) > max {
+		return &LimitError{DepthLimit, int64(max)}
+	}
+	if w.parts++; w.parts > DefaultLimits.MaxParts {
+		return &LimitError{PartsLimit, int64(DefaultLimits.MaxParts)}
+	}
+	mt, params, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if !strings.HasPrefix(mt, "multipart/") || params["boundary"] == "" {
+		return w.fn(append([]int(nil), path...), header, (&parser{}).decoder(header, body))
+	}
+	if err := w.fn(append([]int(nil), path...), header, nil); err != nil {
+		return err
+	}
+	mr := multipart.NewReader(body, params["boundary"])
+	for i := 0; ; i++ {
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.New("email: cannot read multipart body: " + err.Error())
+		}
+		if err = w.walk(append(path, i), part.Header, part); err != nil {
+			return err
+		}
+	}
+}
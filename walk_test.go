@@ -0,0 +1,52 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func Test_Walk(t *testing.T) {
+	msg := QuickMessage("Test", "Hi there", "<p>Hi there</p>").From(&Address{"", "test@example.com"}).
+		AttachObject("data.bin", "application/octet-stream", []byte{0, 1, 2, 255})
+	raw := msg.Compose(nil)
+	var act []string
+	err := Walk(bytes.NewReader(raw), func(path []int, header textproto.MIMEHeader, body io.Reader) error {
+		entry := fmt.Sprint(path, " ", strings.SplitN(header.Get("Content-Type"), ";", 2)[0])
+		if body != nil {
+			data, err := ioutil.ReadAll(body)
+			if err != nil {
+				return err
+			}
+			entry += fmt.Sprintf(" %q", data)
+		}
+		act = append(act, entry)
+		return nil
+	})
+	exp := []string{
+		"[] multipart/mixed",
+		"[0] multipart/alternative",
+		`[0 0] text/plain "Hi there\r\n"`,
+		`[0 1] text/html "<p>Hi there</p>\r\n"`,
+		`[1] application/octet-stream "\x00\x01\x02\xff"`,
+	}
+	if err != nil || strings.Join(act, "\n") != strings.Join(exp, "\n") {
+		t.Errorf("Walk: got %q, %v want %q", act, err, exp)
+	}
+
+	var found []int
+	err = Walk(bytes.NewReader(raw), func(path []int, header textproto.MIMEHeader, body io.Reader) error {
+		if strings.HasPrefix(header.Get("Content-Type"), "text/") {
+			found = path
+			return ErrStopWalk
+		}
+		return nil
+	})
+	if err != nil || fmt.Sprint(found) != "[0 0]" {
+		t.Errorf("Walk: got %v, %v want [0 0]", found, err)
+	}
+}
@@ -0,0 +1,141 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	// DefaultWorkers is the number of deliveries a Sender performs concurrently, unless set
+	// otherwise with Workers.
+	DefaultWorkers = 4
+	// DefaultQueueSize is the number of composed messages a Sender holds while waiting for a
+	// worker, unless set otherwise with Workers.
+	DefaultQueueSize = 100
+)
+
+// delivery represents a composed message waiting in the queue of a Sender. The Message-ID and
+// fingerprint are those of the composition in `body`: the message may be composed again - e.g. for
+// the next recipient in the individual delivery mode - before the delivery takes place.
+type delivery struct {
+	msg    *Message
+	id     string
+	fp     Fingerprint
+	from   string
+	to     []string
+	body   []byte
+	queued time.Time
+}
+
+// newDelivery returns the delivery of the `body` just composed from `msg` to the `to` addresses.
+func newDelivery(msg *Message, from string, to []string, body []byte) delivery {
+	return delivery{msg, msg.MessageID(), msg.Fingerprint(), from, to, body, now()}
+}
+
+// Workers sets the number of worker goroutines delivering the messages sent by the receiver, and
+// the size of the queue holding the messages composed by Send while all the workers are busy.
+// When the queue is full, Send blocks until a worker is available, which bounds the number of
+// goroutines and SMTP connections used under load.
+//
+// The workers are started by the first call to Send, so Workers must be called before that in
+// order to have any effect.
+func (s *Sender) Workers(n, queueSize int) *Sender {
+	if n < 1 {
+		n = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	s.mu.Lock()
+	if s.queue == nil {
+		s.workers, s.queueSize = n, queueSize
+	}
+	s.mu.Unlock()
+	return s
+}
+
+// enqueue passes a composed message to the workers of the receiver, starting them if needed. It
+// fails if the receiver was closed.
+func (s *Sender) enqueue(d delivery) error {
+	s.startOnce.Do(s.startWorkers)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errors.New("Sender.Send: the sender is closed")
+	}
+	if s.pending == 0 {
+		s.drained = make(chan struct{})
+	}
+	s.pending++
+	queue := s.queue
+	s.mu.Unlock()
+	queue <- d
+	return nil
+}
+
+// done marks a queued delivery as complete.
+func (s *Sender) done() {
+	s.mu.Lock()
+	if s.pending--; s.pending == 0 {
+		close(s.drained)
+	}
+	s.mu.Unlock()
+}
+
+func (s *Sender) startWorkers() {
+	s.mu.Lock()
+	n, size := s.workers, s.queueSize
+	if n == 0 {
+		n, size = DefaultWorkers, DefaultQueueSize
+	}
+	s.queue = make(chan delivery, size)
+	queue := s.queue
+	s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		go func() {
+			for d := range queue {
+				s.deliver(d, 1)
+				s.done()
+			}
+		}()
+	}
+}
+
+// Flush waits until all the messages queued by Send so far are delivered - or failed to be - and
+// returns nil, or until `ctx` is done, returning its error.
+func (s *Sender) Flush(ctx context.Context) error {
+	s.mu.RLock()
+	pending, drained := s.pending, s.drained
+	s.mu.RUnlock()
+	if pending == 0 {
+		return nil
+	}
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops the receiver from accepting messages, and waits for the delivery of the queued ones
+// like Flush; once they are delivered, the workers are stopped. Any subsequent Send fails.
+//
+// If `ctx` is done first, Close returns its error, and the queued messages are still delivered in
+// the background.
+func (s *Sender) Close(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	if err := s.Flush(ctx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if s.queue != nil && !s.stopped {
+		close(s.queue)
+		s.stopped = true
+	}
+	s.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,105 @@
+package email
+
+import (
+	"archive/zip"
+	"bytes"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ZipPolicy sets which attachments of a message are bundled into a single zip archive when composing
+// it - e.g. to stay under the limits of mail gateways on the size or the number of attachments.
+type ZipPolicy struct {
+	// Name is the file name of the archive; it defaults to "attachments.zip".
+	Name string
+	// MinCount is the number of attachments from which all of them are bundled; 0 means never.
+	MinCount int
+	// Threshold is the size, in bytes, above which an attachment is bundled; 0 means never.
+	Threshold int64
+}
+
+// ZipAttachments sets the policy for bundling the attachments of the message into a zip archive
+// when composing it; a nil `p`, which is the default, disables the bundling. The attachments read
+// lazily - see LazyAttachments - are not bundled.
+func (m *Message) ZipAttachments(p *ZipPolicy) *Message {
+	m.Lock()
+	defer m.Unlock()
+	m.zipPolicy = p
+	return m
+}
+
+// zipAttachments substitutes the archive bundling the attachments of the receiver, as selected by its
+// zip policy, for these attachments, returning the function that restores them. The caller must
+// hold the lock on the receiver.
+func (m *Message) zipAttachments() (restore func()) {
+	p := m.zipPolicy
+	if p == nil || len(m.attachments) == 0 {
+		return func() {}
+	}
+	all := p.MinCount > 0 && len(m.attachments) >= p.MinCount
+	zipped := func(a *attachment) bool {
+		return a.data != nil && (all || p.Threshold > 0 && int64(len(a.data)) > p.Threshold)
+	}
+	var bundled []*attachment
+	for _, a := range m.attachments {
+		if zipped(a) {
+			bundled = append(bundled, a)
+		}
+	}
+	if len(bundled) == 0 {
+		return func() {}
+	}
+	name := p.Name
+	if name == "" {
+		name = "attachments.zip"
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	seen := map[string]bool{}
+	for _, a := range bundled {
+		entry := uniqueName(a.name, seen)
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: entry, Method: zip.Deflate, Modified: a.modTime})
+		if err == nil {
+			_, err = w.Write(a.data)
+		}
+		if err != nil {
+			m.failAttachment(ContentError, name, err)
+			return func() {}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		m.failAttachment(ContentError, name, err)
+		return func() {}
+	}
+	archive := &attachment{name: name, ctype: "application/zip", data: buf.Bytes()}
+	attachments := m.attachments
+	m.attachments = make([]*attachment, 0, len(attachments)-len(bundled)+1)
+	for _, a := range attachments {
+		switch {
+		case a == bundled[0]:
+			m.attachments = append(m.attachments, archive)
+		case !zipped(a):
+			m.attachments = append(m.attachments, a)
+		}
+	}
+	return func() {
+		m.attachments = attachments
+	}
+}
+
+// uniqueName returns the `name`, or a variant of it - e.g. "report (2).pdf" - if it is in `seen`,
+// recording the returned name.
+func uniqueName(name string, seen map[string]bool) string {
+	if name == "" {
+		name = "attachment"
+	}
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	unique := name
+	for i := 2; seen[unique]; i++ {
+		unique = base + " (" + strconv.Itoa(i) + ")" + ext
+	}
+	seen[unique] = true
+	return unique
+}
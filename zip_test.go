@@ -0,0 +1,70 @@
+package email
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"mime"
+	"reflect"
+	"testing"
+)
+
+func Test_ZipAttachments(t *testing.T) {
+	newMsg := func(p *ZipPolicy) *Message {
+		return QuickMessage("test", "body").From(&Address{"", "ann@example.com"}).
+			AttachObject("a.txt", "text/plain", []byte("small")).
+			AttachObject("b.bin", "application/octet-stream", bytes.Repeat([]byte("big"), 100)).
+			AttachObject("a.txt", "text/plain", []byte("other")).
+			ZipAttachments(p)
+	}
+	cases := []struct {
+		policy *ZipPolicy
+		names  []string
+		zipped map[string]string
+	}{
+		{nil, []string{"a.txt", "b.bin", "a.txt"}, nil},
+		{&ZipPolicy{MinCount: 4}, []string{"a.txt", "b.bin", "a.txt"}, nil},
+		{&ZipPolicy{MinCount: 3}, []string{"attachments.zip"},
+			map[string]string{"a.txt": "small", "b.bin": string(bytes.Repeat([]byte("big"), 100)), "a (2).txt": "other"}},
+		{&ZipPolicy{Name: "large.zip", Threshold: 100}, []string{"a.txt", "large.zip", "a.txt"},
+			map[string]string{"b.bin": string(bytes.Repeat([]byte("big"), 100))}},
+	}
+	for i, c := range cases {
+		msg := newMsg(c.policy)
+		e, err := ReadEntity(bytes.NewReader(msg.Compose(nil)), nil)
+		if err != nil {
+			t.Fatalf("(*Message).ZipAttachments [%d]: got %v, %v", i, err, msg.Errors())
+		}
+		var names []string
+		var archive []byte
+		for _, p := range e.Parts[1:] {
+			_, params, _ := mime.ParseMediaType(p.Header.Get("Content-Disposition"))
+			names = append(names, params["filename"])
+			if p.MediaType == "application/zip" {
+				archive = p.Body
+			}
+		}
+		if !reflect.DeepEqual(names, c.names) {
+			t.Errorf("(*Message).ZipAttachments [%d]: got attachments %v want %v", i, names, c.names)
+		}
+		if len(msg.attachments) != 3 {
+			t.Errorf("(*Message).ZipAttachments [%d]: the attachments of the message were changed", i)
+		}
+		if c.zipped == nil {
+			continue
+		}
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			t.Fatalf("(*Message).ZipAttachments [%d]: got %v", i, err)
+		}
+		zipped := map[string]string{}
+		for _, f := range zr.File {
+			r, _ := f.Open()
+			b, _ := io.ReadAll(r)
+			zipped[f.Name] = string(b)
+		}
+		if !reflect.DeepEqual(zipped, c.zipped) {
+			t.Errorf("(*Message).ZipAttachments [%d]: got archive %v want %v", i, zipped, c.zipped)
+		}
+	}
+}